@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"httpserver/auth"
+	"httpserver/mail"
+	"httpserver/service"
+	"httpserver/store"
+)
+
+// --- ACCOUNT STATE ---
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var (
+	mailer mail.Mailer = mail.NewFakeMailer()
+
+	// baseURL is used to build the links sent by email.
+	baseURL = envOr("APP_BASE_URL", "http://localhost:8080")
+)
+
+// newMailerFromEnv uses SMTP when SMTP_ADDR is set.
+// Without it, emails are only kept in memory (useful in development).
+func newMailerFromEnv() mail.Mailer {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		log.Println("SMTP_ADDR not set, emails are kept in memory only")
+		return mail.NewFakeMailer()
+	}
+	return &mail.SMTPMailer{Addr: addr, From: envOr("SMTP_FROM", "noreply@localhost")}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// --- SENDING EMAILS ---
+
+func (st *tenantState) sendVerificationEmail(u store.User) error {
+	if u.Email == "" {
+		return errors.New("user has no email")
+	}
+	token, err := st.tokens.Issue(auth.PurposeVerifyEmail, u.ID, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return sendTemplate(mail.TemplateVerifyEmail, u, st.link("/verify-email", token), verifyEmailTTL)
+}
+
+// sendVerificationEmailLogged is used as a service hook: failures are only logged.
+func (st *tenantState) sendVerificationEmailLogged(ctx context.Context, u store.User) {
+	if err := st.sendVerificationEmail(u); err != nil {
+		log.Printf("sending verification email to user %d: %v", u.ID, err)
+	}
+}
+
+func (st *tenantState) sendResetEmail(u store.User) error {
+	token, err := st.tokens.Issue(auth.PurposeResetPassword, u.ID, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	return sendTemplate(mail.TemplateResetPassword, u, st.link("/password/reset", token), resetPasswordTTL)
+}
+
+// link builds an emailed link. Tenant links also carry the tenant, so the
+// token is consumed in the right tenant even without a subdomain.
+func (st *tenantState) link(path, token string) string {
+	q := url.Values{"token": {token}}
+	if st.tenantID != "" {
+		q.Set("tenant", st.tenantID)
+	}
+	return baseURL + path + "?" + q.Encode()
+}
+
+func sendTemplate(name string, u store.User, link string, ttl time.Duration) error {
+	msg, err := mail.Render(name, u.Email, mail.TemplateData{
+		Name:      u.Name,
+		Link:      link,
+		ExpiresIn: ttl.String(),
+	})
+	if err != nil {
+		return err
+	}
+	return mailer.Send(msg)
+}
+
+// --- HANDLER: VERIFY EMAIL (?token=...) ---
+//
+// The link in the email only shows a page with a button. Mail scanners and
+// link previews fetch links with GET, so GET must not use up the token;
+// the button POSTs it back to the same URL, which verifies the email.
+
+var verifyEmailPage = template.Must(template.New("verify-email").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Confirm your email address</title></head>
+<body>
+<form method="post">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Confirm my email address</button>
+</form>
+</body>
+</html>
+`))
+
+func verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		// The token is in the URL: keep it out of caches and Referer headers.
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		verifyEmailPage.Execute(w, r.URL.Query().Get("token"))
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Only GET or POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := st.tokens.Consume(auth.PurposeVerifyEmail, r.FormValue("token"))
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	verified := true
+	u, err := st.service.Update(r.Context(), id, service.UpdateUserInput{EmailVerified: &verified})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u)
+}
+
+// --- HANDLER: RESEND VERIFICATION (POST {"email": "..."}) ---
+
+func resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Always answer the same way, so the endpoint does not reveal which emails exist.
+	u, err := st.users.GetByEmail(r.Context(), strings.TrimSpace(req.Email))
+	if err == nil && !u.EmailVerified {
+		if err := st.sendVerificationEmail(u); err != nil {
+			log.Printf("sending verification email to user %d: %v", u.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// --- HANDLER: FORGOT PASSWORD (POST {"email": "..."}) ---
+
+func forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Same answer for known and unknown emails.
+	if u, err := st.users.GetByEmail(r.Context(), strings.TrimSpace(req.Email)); err == nil {
+		if err := st.sendResetEmail(u); err != nil {
+			log.Printf("sending reset email to user %d: %v", u.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// --- HANDLER: RESET PASSWORD (POST {"token": "...", "password": "..."}) ---
+
+func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "Password must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	id, err := st.tokens.Consume(auth.PurposeResetPassword, req.Token)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	// The reset link proves the user owns the inbox.
+	verified := true
+	u, err := st.service.Update(r.Context(), id, service.UpdateUserInput{EmailVerified: &verified, PasswordHash: &hash})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	// Log out every old session and forget failed attempts.
+	st.sessions.DeleteUser(id)
+	st.lockout.Reset(strings.ToLower(u.Email))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"httpserver/mail"
+)
+
+var tokenRe = regexp.MustCompile(`token=([A-Za-z0-9_%\-]+)`)
+
+// tokenFromMail pulls the token out of the last email sent to addr.
+func tokenFromMail(t *testing.T, fake *mail.FakeMailer, addr string) string {
+	t.Helper()
+	msg, ok := fake.Last(addr)
+	if !ok {
+		t.Fatalf("no email sent to %s", addr)
+	}
+	m := tokenRe.FindStringSubmatch(msg.Text)
+	if m == nil {
+		t.Fatalf("no token in email:\n%s", msg.Text)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatalf("unescaping token: %v", err)
+	}
+	return token
+}
+
+func do(h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func postForm(h http.HandlerFunc, target string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h(rec, r)
+	return rec
+}
+
+func TestVerifyEmailAndResetPasswordFlows(t *testing.T) {
+	fake := mail.NewFakeMailer()
+	mailer = fake
+
+	rec := do(createHandler, "POST", "/users/create", `{"name":"Carol","email":"carol@example.com","password":"first-pass"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d", rec.Code)
+	}
+
+	// Unverified users cannot log in yet.
+	login := `{"email":"carol@example.com","password":"first-pass"}`
+	if rec := do(loginHandler, "POST", "/login", login); rec.Code != http.StatusForbidden {
+		t.Fatalf("login before verification: status %d; want 403", rec.Code)
+	}
+
+	// Opening the link only shows the confirm form; posting it verifies.
+	token := tokenFromMail(t, fake, "carol@example.com")
+	link := "/verify-email?token=" + url.QueryEscape(token)
+	for range 2 {
+		rec := do(verifyEmailHandler, "GET", link, "")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+			t.Fatalf("verify page: status %d\n%s", rec.Code, rec.Body)
+		}
+	}
+	if rec := do(loginHandler, "POST", "/login", login); rec.Code != http.StatusForbidden {
+		t.Fatalf("login after only opening the link: status %d; want 403", rec.Code)
+	}
+	if rec := postForm(verifyEmailHandler, link, url.Values{"token": {token}}); rec.Code != http.StatusOK {
+		t.Fatalf("verify: status %d", rec.Code)
+	}
+	if rec := postForm(verifyEmailHandler, link, url.Values{"token": {token}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("verify with a used token: status %d; want 400", rec.Code)
+	}
+	if rec := do(loginHandler, "POST", "/login", login); rec.Code != http.StatusOK {
+		t.Fatalf("login after verification: status %d", rec.Code)
+	}
+
+	// Forgot password answers 202 for unknown emails too, but only mails known ones.
+	sentBefore := len(fake.Sent())
+	if rec := do(forgotPasswordHandler, "POST", "/password/forgot", `{"email":"nobody@example.com"}`); rec.Code != http.StatusAccepted {
+		t.Errorf("forgot (unknown): status %d; want 202", rec.Code)
+	}
+	if len(fake.Sent()) != sentBefore {
+		t.Errorf("an email was sent for an unknown address")
+	}
+	if rec := do(forgotPasswordHandler, "POST", "/password/forgot", `{"email":"carol@example.com"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("forgot: status %d", rec.Code)
+	}
+
+	reset := tokenFromMail(t, fake, "carol@example.com")
+	body := `{"token":"` + reset + `","password":"second-pass"}`
+	if rec := do(resetPasswordHandler, "POST", "/password/reset", body); rec.Code != http.StatusNoContent {
+		t.Fatalf("reset: status %d", rec.Code)
+	}
+	if rec := do(resetPasswordHandler, "POST", "/password/reset", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("reset with a used token: status %d; want 400", rec.Code)
+	}
+
+	if rec := do(loginHandler, "POST", "/login", login); rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with old password: status %d; want 401", rec.Code)
+	}
+	newLogin := `{"email":"carol@example.com","password":"second-pass"}`
+	if rec := do(loginHandler, "POST", "/login", newLogin); rec.Code != http.StatusOK {
+		t.Errorf("login with new password: status %d; want 200", rec.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpserver/auth"
+	"httpserver/store"
+)
+
+// --- AUTH STATE ---
+
+// Sessions and lockouts are per tenant (see tenantState).
+
+// dummyHash is checked when the email is unknown, so a missing user
+// takes as long to reject as a wrong password.
+var dummyHash, _ = auth.HashPassword("dummy-password")
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// --- HANDLER: LOGIN (POST {"email": "...", "password": "..."}) ---
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key := strings.ToLower(strings.TrimSpace(req.Email))
+	if locked, until := st.lockout.Locked(key); locked {
+		retry := int(time.Until(until).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	u, err := st.users.GetByEmail(r.Context(), key)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		storeError(w, r, err)
+		return
+	}
+	found := err == nil
+	hash := dummyHash
+	if found && u.PasswordHash != "" {
+		hash = u.PasswordHash
+	}
+
+	ok, err := auth.CheckPassword(hash, req.Password)
+	if err != nil {
+		http.Error(w, "Failed to check password", http.StatusInternalServerError)
+		return
+	}
+	if !ok || !found || u.PasswordHash == "" {
+		st.lockout.Fail(key)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	st.lockout.Reset(key)
+
+	if !u.EmailVerified {
+		http.Error(w, "Email not verified", http.StatusForbidden)
+		return
+	}
+
+	sess, err := st.sessions.Create(u.ID)
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	st.sessions.SetCookie(w, sess)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u)
+}
+
+// --- HANDLER: LOGOUT ---
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if c, err := r.Cookie(auth.SessionCookieName); err == nil {
+		st.sessions.Delete(c.Value)
+	}
+	st.sessions.ClearCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- HANDLER: CURRENT USER (needs a valid session) ---
+
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+
+	sess, ok := st.sessions.FromRequest(r)
+	if !ok {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	u, err := st.users.Get(r.Context(), sess.UserID)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		storeError(w, r, err)
+		return
+	}
+
+	// The user was removed while the session was still alive.
+	st.sessions.Delete(sess.ID)
+	st.sessions.ClearCookie(w)
+	http.Error(w, "Not logged in", http.StatusUnauthorized)
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cheapParams keeps the tests fast; production code uses DefaultParams.
+var cheapParams = Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// fakeClock is a clock the tests can move forward by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time      { return c.t }
+func (c *fakeClock) Add(d time.Duration) { c.t = c.t.Add(d) }
+func newFakeClock() *fakeClock           { return &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)} }
+
+func TestHashAndCheckPassword(t *testing.T) {
+	hash, err := HashPasswordWith("secret123", cheapParams)
+	if err != nil {
+		t.Fatalf("HashPasswordWith: %v", err)
+	}
+	if strings.Contains(hash, "secret123") {
+		t.Fatalf("hash %q contains the plain password", hash)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("hash %q is not in argon2id PHC format", hash)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"secret123", true},
+		{"secret124", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		got, err := CheckPassword(hash, tt.password)
+		if err != nil {
+			t.Fatalf("CheckPassword(%q): %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("CheckPassword(%q) = %v; want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestHashPasswordUsesSalt(t *testing.T) {
+	a, _ := HashPasswordWith("same", cheapParams)
+	b, _ := HashPasswordWith("same", cheapParams)
+	if a == b {
+		t.Errorf("two hashes of the same password are equal; salt is missing")
+	}
+}
+
+func TestHashPasswordRejectsEmpty(t *testing.T) {
+	if _, err := HashPassword(""); err != ErrEmptyPassword {
+		t.Errorf("HashPassword(\"\") error = %v; want %v", err, ErrEmptyPassword)
+	}
+}
+
+func TestCheckPasswordInvalidHash(t *testing.T) {
+	for _, h := range []string{"", "plain", "$argon2id$v=19$m=1,t=1,p=1$bad$"} {
+		if _, err := CheckPassword(h, "x"); err == nil {
+			t.Errorf("CheckPassword(%q) returned no error", h)
+		}
+	}
+}
+
+func TestSessionIdleExpiry(t *testing.T) {
+	clock := newFakeClock()
+	store := NewSessionStore(10*time.Minute, time.Hour)
+	store.Now = clock.Now
+
+	sess, err := store.Create(1)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	clock.Add(9 * time.Minute)
+	if _, ok := store.Get(sess.ID); !ok {
+		t.Fatalf("session expired before idle timeout")
+	}
+
+	// Get refreshed the idle timer, so another 9 minutes is still fine.
+	clock.Add(9 * time.Minute)
+	if _, ok := store.Get(sess.ID); !ok {
+		t.Fatalf("idle timer was not refreshed")
+	}
+
+	clock.Add(10 * time.Minute)
+	if _, ok := store.Get(sess.ID); ok {
+		t.Errorf("session still valid after idle timeout")
+	}
+}
+
+func TestSessionAbsoluteExpiry(t *testing.T) {
+	clock := newFakeClock()
+	store := NewSessionStore(10*time.Minute, 30*time.Minute)
+	store.Now = clock.Now
+
+	sess, _ := store.Create(1)
+	for i := 0; i < 3; i++ {
+		clock.Add(9 * time.Minute)
+		if _, ok := store.Get(sess.ID); !ok {
+			t.Fatalf("session expired too early at step %d", i)
+		}
+	}
+
+	clock.Add(9 * time.Minute)
+	if _, ok := store.Get(sess.ID); ok {
+		t.Errorf("session still valid after absolute timeout")
+	}
+}
+
+func TestSessionDeleteAndCookie(t *testing.T) {
+	store := NewSessionStore(time.Minute, time.Hour)
+	sess, _ := store.Create(7)
+
+	rec := httptest.NewRecorder()
+	store.SetCookie(rec, sess)
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly || !cookie.Secure {
+		t.Errorf("cookie should be HttpOnly and Secure: %+v", cookie)
+	}
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(cookie)
+	got, ok := store.FromRequest(req)
+	if !ok || got.UserID != 7 {
+		t.Fatalf("FromRequest = %+v, %v; want user 7", got, ok)
+	}
+
+	store.Delete(sess.ID)
+	if _, ok := store.FromRequest(req); ok {
+		t.Errorf("session still valid after Delete")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLockout(3, time.Minute, 5*time.Minute)
+	l.Now = clock.Now
+
+	for i := 0; i < 2; i++ {
+		if l.Fail("bob") {
+			t.Fatalf("locked after %d failures", i+1)
+		}
+	}
+	if !l.Fail("bob") {
+		t.Fatalf("not locked after 3 failures")
+	}
+	if locked, _ := l.Locked("bob"); !locked {
+		t.Fatalf("Locked = false right after lockout")
+	}
+	if locked, _ := l.Locked("alice"); locked {
+		t.Errorf("other keys must not be locked")
+	}
+
+	clock.Add(5 * time.Minute)
+	if locked, _ := l.Locked("bob"); locked {
+		t.Errorf("still locked after LockDuration")
+	}
+}
+
+func TestLockoutWindowAndReset(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLockout(3, time.Minute, 5*time.Minute)
+	l.Now = clock.Now
+
+	l.Fail("bob")
+	l.Fail("bob")
+	clock.Add(2 * time.Minute) // outside the window: counting starts over
+	if l.Fail("bob") {
+		t.Errorf("old failures outside the window were counted")
+	}
+
+	l.Fail("bob")
+	l.Reset("bob")
+	if l.Fail("bob") {
+		t.Errorf("Reset did not clear the failures")
+	}
+}
+
+func TestLockoutCleanup(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLockout(2, time.Minute, 5*time.Minute)
+	l.Now = clock.Now
+
+	l.Fail("bob")
+	l.Fail("bob") // locked
+	l.Fail("alice")
+	clock.Add(2 * time.Minute)
+	l.Fail("carol")
+	l.Cleanup()
+	if _, ok := l.attempts["alice"]; ok {
+		t.Errorf("alice's old failure was kept")
+	}
+	if len(l.attempts) != 2 {
+		t.Errorf("%d keys left; want the locked bob and carol's recent failure", len(l.attempts))
+	}
+
+	clock.Add(5 * time.Minute)
+	l.Cleanup()
+	if len(l.attempts) != 0 {
+		t.Errorf("%d keys left after the lock and the window ended", len(l.attempts))
+	}
+}
+
+func TestTokenSingleUse(t *testing.T) {
+	store := NewTokenStore()
+	token, err := store.Issue(PurposeVerifyEmail, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, err := store.Consume(PurposeResetPassword, token); err != ErrInvalidToken {
+		t.Errorf("token accepted for the wrong purpose")
+	}
+
+	id, err := store.Consume(PurposeVerifyEmail, token)
+	if err != nil || id != 3 {
+		t.Fatalf("Consume = %d, %v; want 3, nil", id, err)
+	}
+	if _, err := store.Consume(PurposeVerifyEmail, token); err != ErrInvalidToken {
+		t.Errorf("token accepted twice")
+	}
+}
+
+func TestTokenExpiryAndRevoke(t *testing.T) {
+	clock := newFakeClock()
+	store := NewTokenStore()
+	store.Now = clock.Now
+
+	old, _ := store.Issue(PurposeResetPassword, 1, time.Hour)
+	fresh, _ := store.Issue(PurposeResetPassword, 1, time.Hour)
+	if _, err := store.Consume(PurposeResetPassword, old); err != ErrInvalidToken {
+		t.Errorf("older token was not revoked by a new one")
+	}
+
+	clock.Add(time.Hour)
+	if _, err := store.Consume(PurposeResetPassword, fresh); err != ErrInvalidToken {
+		t.Errorf("expired token was accepted")
+	}
+}
+
+func TestTokenCleanup(t *testing.T) {
+	clock := newFakeClock()
+	store := NewTokenStore()
+	store.Now = clock.Now
+
+	store.Issue(PurposeVerifyEmail, 1, time.Minute)
+	fresh, _ := store.Issue(PurposeVerifyEmail, 2, time.Hour)
+	clock.Add(time.Minute)
+	store.Cleanup()
+	if len(store.tokens) != 1 {
+		t.Errorf("%d tokens left; want only the unexpired one", len(store.tokens))
+	}
+	if id, err := store.Consume(PurposeVerifyEmail, fresh); err != nil || id != 2 {
+		t.Errorf("Consume after Cleanup = %d, %v", id, err)
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// --- LOCKOUT AFTER FAILED LOGINS ---
+//
+// Every failed login for a key (usually the email) is counted.
+// After MaxAttempts failures inside Window, the key is locked for LockDuration.
+// A successful login resets the counter.
+
+// Lockout tracks failed login attempts. It is safe for concurrent use.
+type Lockout struct {
+	MaxAttempts  int
+	Window       time.Duration
+	LockDuration time.Duration
+
+	// Now returns the current time. Tests can replace it to move the clock.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*attempt
+}
+
+type attempt struct {
+	failures    int
+	firstFailed time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout returns a Lockout that locks a key for lockFor after max failures within window.
+func NewLockout(max int, window, lockFor time.Duration) *Lockout {
+	return &Lockout{
+		MaxAttempts:  max,
+		Window:       window,
+		LockDuration: lockFor,
+		Now:          time.Now,
+		attempts:     make(map[string]*attempt),
+	}
+}
+
+// Locked reports whether key is locked and, if so, until when.
+func (l *Lockout) Locked(key string) (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok {
+		return false, time.Time{}
+	}
+	if l.Now().Before(a.lockedUntil) {
+		return true, a.lockedUntil
+	}
+	return false, time.Time{}
+}
+
+// Fail records a failed attempt for key and reports whether the key is now locked.
+func (l *Lockout) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	a, ok := l.attempts[key]
+	if !ok || now.Sub(a.firstFailed) > l.Window {
+		a = &attempt{firstFailed: now}
+		l.attempts[key] = a
+	}
+
+	a.failures++
+	if a.failures >= l.MaxAttempts {
+		a.lockedUntil = now.Add(l.LockDuration)
+		a.failures = 0
+		a.firstFailed = now
+		return true
+	}
+	return false
+}
+
+// Reset forgets every failed attempt for key.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	delete(l.attempts, key)
+	l.mu.Unlock()
+}
+
+// Cleanup forgets keys that are not locked and have no failures inside
+// Window. Call it periodically.
+func (l *Lockout) Cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	for key, a := range l.attempts {
+		if !now.Before(a.lockedUntil) && now.Sub(a.firstFailed) > l.Window {
+			delete(l.attempts, key)
+		}
+	}
+}
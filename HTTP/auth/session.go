@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --- SERVER-SIDE SESSIONS ---
+//
+// The browser only receives a random session ID inside a cookie.
+// Everything else (who is logged in, when the session started) stays on the server.
+//
+// A session ends when either:
+// - it was not used for IdleTimeout (idle expiry), or
+// - it is older than AbsoluteTimeout, no matter how active it is (absolute expiry).
+
+// SessionCookieName is the name of the cookie that carries the session ID.
+const SessionCookieName = "session_id"
+
+// Session is a logged-in user on the server side.
+type Session struct {
+	ID        string
+	UserID    int
+	CreatedAt time.Time
+	LastSeen  time.Time
+}
+
+// SessionStore keeps sessions in memory. It is safe for concurrent use.
+type SessionStore struct {
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	Secure          bool // sets the Secure flag on cookies (HTTPS only)
+
+	// Now returns the current time. Tests can replace it to move the clock.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionStore returns a store with the given expiry settings and secure cookies.
+func NewSessionStore(idle, absolute time.Duration) *SessionStore {
+	return &SessionStore{
+		IdleTimeout:     idle,
+		AbsoluteTimeout: absolute,
+		Secure:          true,
+		Now:             time.Now,
+		sessions:        make(map[string]*Session),
+	}
+}
+
+// Create starts a new session for userID.
+func (s *SessionStore) Create(userID int) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.Now()
+	sess := &Session{ID: id, UserID: userID, CreatedAt: now, LastSeen: now}
+
+	s.mu.Lock()
+	s.sessions[id] = sess
+	s.mu.Unlock()
+
+	return sess, nil
+}
+
+// Get returns the session for id and refreshes its idle timer.
+// Expired sessions are removed and reported as not found.
+func (s *SessionStore) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+
+	now := s.Now()
+	if s.expired(sess, now) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+
+	sess.LastSeen = now
+	out := *sess
+	return &out, true
+}
+
+// Delete ends the session with the given id (logout).
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+// DeleteUser ends every session that belongs to userID.
+func (s *SessionStore) DeleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Cleanup removes all expired sessions. Call it periodically.
+func (s *SessionStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for id, sess := range s.sessions {
+		if s.expired(sess, now) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *SessionStore) expired(sess *Session, now time.Time) bool {
+	if s.IdleTimeout > 0 && now.Sub(sess.LastSeen) >= s.IdleTimeout {
+		return true
+	}
+	if s.AbsoluteTimeout > 0 && now.Sub(sess.CreatedAt) >= s.AbsoluteTimeout {
+		return true
+	}
+	return false
+}
+
+// --- COOKIES ---
+
+// SetCookie writes the session cookie to the response.
+// HttpOnly hides it from JavaScript and SameSite blocks cross-site requests.
+func (s *SessionStore) SetCookie(w http.ResponseWriter, sess *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    sess.ID,
+		Path:     "/",
+		Expires:  sess.CreatedAt.Add(s.AbsoluteTimeout),
+		MaxAge:   int(s.AbsoluteTimeout.Seconds()),
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie tells the browser to forget the session cookie.
+func (s *SessionStore) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// FromRequest returns the session referenced by the request cookie, if any.
+func (s *SessionStore) FromRequest(r *http.Request) (*Session, bool) {
+	c, err := r.Cookie(SessionCookieName)
+	if err != nil || c.Value == "" {
+		return nil, false
+	}
+	return s.Get(c.Value)
+}
+
+// newSessionID returns 32 random bytes encoded as URL-safe base64.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// --- SINGLE-USE EXPIRING TOKENS ---
+//
+// Tokens are sent by email (verify email, reset password).
+// Only a SHA-256 of the token is kept, so a leaked store cannot be replayed.
+// A token works once, for one purpose, until it expires.
+
+// Token purposes.
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+)
+
+// ErrInvalidToken is returned for unknown, used, expired or wrong-purpose tokens.
+var ErrInvalidToken = errors.New("auth: invalid or expired token")
+
+// TokenStore issues and consumes tokens. It is safe for concurrent use.
+type TokenStore struct {
+	// Now returns the current time. Tests can replace it to move the clock.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]tokenEntry // key: hex(sha256(token))
+}
+
+type tokenEntry struct {
+	purpose   string
+	userID    int
+	expiresAt time.Time
+}
+
+// NewTokenStore returns an empty TokenStore.
+func NewTokenStore() *TokenStore {
+	return &TokenStore{Now: time.Now, tokens: make(map[string]tokenEntry)}
+}
+
+// Issue creates a token for userID and purpose that is valid for ttl.
+// Older tokens of the same user and purpose are revoked.
+func (s *TokenStore) Issue(purpose string, userID int, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, e := range s.tokens {
+		if e.purpose == purpose && e.userID == userID {
+			delete(s.tokens, k)
+		}
+	}
+	s.tokens[hashToken(token)] = tokenEntry{
+		purpose:   purpose,
+		userID:    userID,
+		expiresAt: s.Now().Add(ttl),
+	}
+	return token, nil
+}
+
+// Consume checks token for purpose and returns its user ID.
+// The token is removed, so a second call always fails.
+func (s *TokenStore) Consume(purpose, token string) (int, error) {
+	key := hashToken(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[key]
+	if !ok || e.purpose != purpose {
+		return 0, ErrInvalidToken
+	}
+	delete(s.tokens, key)
+
+	if !s.Now().Before(e.expiresAt) {
+		return 0, ErrInvalidToken
+	}
+	return e.userID, nil
+}
+
+// Cleanup removes expired tokens. Call it periodically.
+func (s *TokenStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for k, e := range s.tokens {
+		if !now.Before(e.expiresAt) {
+			delete(s.tokens, k)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"httpserver/backup"
+	"httpserver/replication"
+	"httpserver/store"
+)
+
+// --- BACKUP AND RESTORE ---
+//
+// With BACKUP_DIR set, the server snapshots its users and posts on a
+// schedule and archives every change in between, so cmd/backup (or
+// RESTORE_FROM) can bring the data back as of any time the backup covers.
+
+// restoreBackup reads the restore settings from the environment:
+//
+//	RESTORE_FROM   backup directory to load the data from at startup
+//	RESTORE_UNTIL  RFC 3339 time to restore to (default: latest)
+//
+// It must run before configureReplication, so a leader logs on top of the
+// restored data.
+func restoreBackup() {
+	dir := os.Getenv("RESTORE_FROM")
+	if dir == "" {
+		return
+	}
+	if multiTenant {
+		log.Fatal("RESTORE_FROM cannot be combined with MULTI_TENANT")
+	}
+	var until time.Time
+	if v := os.Getenv("RESTORE_UNTIL"); v != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			log.Fatalf("invalid RESTORE_UNTIL %q", v)
+		}
+	}
+
+	res, err := backup.Restore(context.Background(), dir, until)
+	if err != nil {
+		log.Fatalf("restore from %s: %v", dir, err)
+	}
+	users, posts := store.NewMemoryUserStore(), store.NewMemoryPostStore()
+	res.Snapshot.Load(users, posts)
+	defaultState.setStores(store.TracedUserStore{Next: users}, store.TracedPostStore{Next: posts})
+	log.Printf("Restored %d users and %d posts from %s (+%d changes)",
+		len(res.Snapshot.Users), len(res.Snapshot.Posts), res.From.Path, res.Applied)
+}
+
+// configureBackup reads the backup settings from the environment:
+//
+//	BACKUP_DIR       directory of snapshots and the change log archive (off by default)
+//	BACKUP_INTERVAL  time between snapshots (default 1h)
+//	BACKUP_KEEP      snapshots to keep (default 24)
+//	BACKUP_MAX_AGE   also keep snapshots younger than this
+//
+// Backups need the change log of a leader; a plain instance gets one
+// without serving /replication/. It must run after configureReplication.
+func configureBackup() {
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		return
+	}
+	if multiTenant || follower != nil {
+		log.Fatal("BACKUP_DIR needs a single-tenant leader or standalone instance")
+	}
+	interval := envDuration("BACKUP_INTERVAL", time.Hour)
+	if interval == 0 {
+		log.Fatal("invalid BACKUP_INTERVAL 0")
+	}
+	policy := backup.Policy{
+		KeepLast: envInt("BACKUP_KEEP", 24),
+		MaxAge:   envDuration("BACKUP_MAX_AGE", 0),
+	}
+
+	if leader == nil {
+		leader = replication.NewLeader(defaultState.rawUsers, defaultState.posts, envInt("REPLICATION_LOG_SIZE", 0))
+		defaultState.setStores(leader.UserStore(), leader.PostStore())
+	}
+	m, err := backup.NewManager(dir, leader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.Policy = policy
+	go func() {
+		if err := m.Run(context.Background(), interval); err != nil {
+			log.Fatalf("backup: %v", err)
+		}
+	}()
+	log.Printf("Backups in %s every %s", dir, interval)
+}
+
+// envDuration reads a non-negative duration, or returns fallback when key is unset.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Fatalf("invalid %s %q", key, v)
+	}
+	return d
+}
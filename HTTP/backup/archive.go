@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"httpserver/replication"
+)
+
+// --- CHANGE LOG ARCHIVE ---
+
+// Archive appends change log entries to segment files in a directory.
+// A new segment starts with Rotate, so old ones can be pruned whole.
+type Archive struct {
+	dir string
+
+	mu   sync.Mutex
+	file *os.File
+	last uint64 // seq of the last archived entry
+}
+
+// OpenArchive opens the archive in dir and finds where it stopped.
+func OpenArchive(dir string) (*Archive, error) {
+	a := &Archive{dir: dir}
+	segs, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) > 0 {
+		last := segs[len(segs)-1]
+		entries, size, err := readSegment(last.path)
+		if err != nil {
+			return nil, err
+		}
+		// Cut a torn last line, or the next Append would be glued to it.
+		if err := os.Truncate(last.path, size); err != nil {
+			return nil, err
+		}
+		a.last = last.first - 1
+		if len(entries) > 0 {
+			a.last = entries[len(entries)-1].Seq
+		}
+	}
+	return a, nil
+}
+
+// Last returns the seq of the last archived entry (0 for an empty archive).
+func (a *Archive) Last() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
+
+// Append writes entries, which must follow the last archived one, and
+// syncs the segment. A gap (after the log was trimmed) starts a new
+// segment at the first entry, so Restore can tell what is missing.
+func (a *Archive) Append(entries []replication.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.last != 0 && entries[0].Seq != a.last+1 {
+		a.closeSegment()
+	}
+	if a.file == nil {
+		f, err := os.OpenFile(segmentPath(a.dir, entries[0].Seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		a.file = f
+	}
+
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := a.file.Write(buf); err != nil {
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.last = entries[len(entries)-1].Seq
+	return nil
+}
+
+// Rotate makes the next Append start a new segment.
+func (a *Archive) Rotate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closeSegment()
+}
+
+// Close closes the current segment.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closeSegment()
+}
+
+func (a *Archive) closeSegment() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// --- SEGMENTS ---
+
+type segment struct {
+	path  string
+	first uint64
+}
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("wal-%020d.jsonl", first))
+}
+
+// segments returns the segments of dir ordered by their first seq.
+func segments(dir string) ([]segment, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "wal-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var out []segment
+	for _, p := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "wal-"), ".jsonl")
+		first, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, segment{path: p, first: first})
+	}
+	slices.SortFunc(out, func(a, b segment) int { return cmp.Compare(a.first, b.first) })
+	return out, nil
+}
+
+// readSegment reads the entries of a segment and the size of the complete
+// lines. A torn last line, left by a crash in the middle of a write, is
+// ignored.
+func readSegment(path string) ([]replication.Entry, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var (
+		entries []replication.Entry
+		size    int64
+	)
+	br := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return entries, size, nil // a line without '\n' was never fully written
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var e replication.Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, 0, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+		size += int64(len(line))
+	}
+}
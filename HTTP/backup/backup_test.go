@@ -0,0 +1,280 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"httpserver/replication"
+	"httpserver/store"
+)
+
+// clock is a manual time source shared by the leader's log and the manager.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+func (c *clock) tick() time.Time {
+	c.t = c.t.Add(time.Minute)
+	return c.t
+}
+
+func newLeader(c *clock) *replication.Leader {
+	l := replication.NewLeader(store.NewMemoryUserStore(), store.NewMemoryPostStore(), 0)
+	l.Log().Now = c.now
+	return l
+}
+
+func newManager(t *testing.T, dir string, l *replication.Leader, c *clock) *Manager {
+	t.Helper()
+	m, err := NewManager(dir, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Now = c.now
+	return m
+}
+
+// archiveAll copies the leader's log to the archive, like Run does.
+func archiveAll(t *testing.T, m *Manager) {
+	t.Helper()
+	entries, _, err := m.leader.Log().Since(m.archive.Last(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.archive.Append(entries); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createUser(t *testing.T, l *replication.Leader, name string) store.User {
+	t.Helper()
+	u, err := l.UserStore().Create(context.Background(), store.User{Name: name, Email: name + "@example.com", PasswordHash: "h-" + name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func names(snap replication.Snapshot) string {
+	var b bytes.Buffer
+	for _, u := range snap.Users {
+		fmt.Fprint(&b, u.Name, " ")
+	}
+	return b.String()
+}
+
+func TestSnapshotFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	snap := replication.Snapshot{
+		Seq:   7,
+		Users: []replication.User{{ID: 1, Name: "Alice", PasswordHash: "secret"}},
+		Posts: []store.Post{{ID: 1, UserID: 1, Title: "hi"}},
+	}
+	info, err := WriteSnapshot(dir, snap, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Users != 1 || info.Posts != 1 || info.Size == 0 {
+		t.Errorf("info = %+v", info)
+	}
+
+	_, got, err := ReadSnapshot(info.Path)
+	if err != nil || got.Users[0].PasswordHash != "secret" || got.Posts[0].Title != "hi" {
+		t.Fatalf("ReadSnapshot = %+v, %v", got, err)
+	}
+
+	// Tamper with the data and keep the file readable: swap the checksum.
+	data, _ := os.ReadFile(info.Path)
+	data = bytes.Replace(data, []byte(info.SHA256), bytes.Repeat([]byte("0"), 64), 1)
+	os.WriteFile(info.Path, data, 0o600)
+	if _, _, err := ReadSnapshot(info.Path); !errors.Is(err, ErrChecksum) {
+		t.Errorf("tampered snapshot: err = %v; want ErrChecksum", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "snapshot-junk.snap"), []byte("hello\n"), 0o600)
+	if _, err := ListSnapshots(dir); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("junk file: err = %v; want ErrBadFormat", err)
+	}
+}
+
+func TestPointInTimeRestore(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	l := newLeader(c)
+	m := newManager(t, dir, l, c)
+
+	createUser(t, l, "alice")
+	c.tick()
+	if _, err := m.Snapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c.tick()
+	bob := createUser(t, l, "bob")
+	afterBob := c.t
+	c.tick()
+	bob.Name = "robert"
+	l.UserStore().Update(ctx, bob)
+	c.tick()
+	l.UserStore().Delete(ctx, 1)
+	archiveAll(t, m)
+
+	res, err := Restore(ctx, dir, afterBob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(res.Snapshot); got != "alice bob " || res.Applied != 1 {
+		t.Errorf("restore to %v: users %q, applied %d", afterBob, got, res.Applied)
+	}
+	if res.Snapshot.Users[1].PasswordHash != "h-bob" {
+		t.Errorf("password hash not restored: %+v", res.Snapshot.Users[1])
+	}
+
+	res, err = Restore(ctx, dir, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(res.Snapshot); got != "robert " || res.Snapshot.Seq != 4 {
+		t.Errorf("restore to latest: users %q at seq %d", got, res.Snapshot.Seq)
+	}
+
+	if _, err := Restore(ctx, dir, c.t.Add(-time.Hour)); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("restore before the first snapshot: err = %v", err)
+	}
+}
+
+func TestRestoreReportsGap(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	l := newLeader(c)
+	m := newManager(t, dir, l, c)
+
+	m.Snapshot(ctx)
+	c.tick()
+	createUser(t, l, "a")
+	createUser(t, l, "b")
+	entries, _, _ := l.Log().Since(0, 10)
+	m.archive.Append(entries[1:]) // entry 1 never made it
+
+	if _, err := Restore(ctx, dir, time.Time{}); !errors.Is(err, ErrGap) {
+		t.Errorf("err = %v; want ErrGap", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	l := newLeader(c)
+	m := newManager(t, dir, l, c)
+
+	for i := range 5 {
+		createUser(t, l, fmt.Sprint("u", i))
+		archiveAll(t, m)
+		c.tick()
+		if _, err := m.Snapshot(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createUser(t, l, "last")
+	archiveAll(t, m)
+
+	m.Policy = Policy{KeepLast: 2}
+	if _, err := m.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	snaps, _ := ListSnapshots(dir)
+	if len(snaps) != 2 || snaps[0].Seq != 4 {
+		t.Fatalf("kept %d snapshots, oldest at seq %d; want 2 from seq 4", len(snaps), snaps[0].Seq)
+	}
+	segs, _ := segments(dir)
+	if len(segs) == 0 || segs[0].first > snaps[0].Seq+1 {
+		t.Errorf("pruned segments still needed: first left starts at %d", segs[0].first)
+	}
+	if len(segs) > 2 {
+		t.Errorf("%d segments left; the ones before seq 4 should be gone", len(segs))
+	}
+
+	// Everything after the oldest snapshot still restores.
+	res, err := Restore(ctx, dir, time.Time{})
+	if err != nil || len(res.Snapshot.Users) != 6 {
+		t.Errorf("restore after prune: %d users, %v", len(res.Snapshot.Users), err)
+	}
+
+	// MaxAge keeps young snapshots beyond KeepLast.
+	m.Policy = Policy{KeepLast: 1, MaxAge: time.Hour}
+	m.Prune()
+	if snaps, _ := ListSnapshots(dir); len(snaps) != 2 {
+		t.Errorf("MaxAge kept %d snapshots; want 2", len(snaps))
+	}
+}
+
+func TestArchiveSurvivesRestart(t *testing.T) {
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	l := newLeader(c)
+	m := newManager(t, dir, l, c)
+	createUser(t, l, "a")
+	createUser(t, l, "b")
+	archiveAll(t, m)
+	m.archive.Close()
+
+	// A crash in the middle of a write leaves half a line.
+	segs, _ := segments(dir)
+	f, _ := os.OpenFile(segs[0].path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"seq":3,"op":"putU`)
+	f.Close()
+
+	// The next run continues the numbering after the archive.
+	l2 := newLeader(c)
+	m2 := newManager(t, dir, l2, c)
+	if got := m2.archive.Last(); got != 2 {
+		t.Fatalf("archive resumes after seq %d; want 2", got)
+	}
+	createUser(t, l2, "c")
+	archiveAll(t, m2)
+	if head, _ := l2.Log().Head(); head != 3 {
+		t.Errorf("new log head = %d; want 3", head)
+	}
+
+	entries, _, err := readSegment(segs[0].path)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("first segment after restart: %d entries, %v", len(entries), err)
+	}
+}
+
+func TestRunArchivesAndSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	l := replication.NewLeader(store.NewMemoryUserStore(), store.NewMemoryPostStore(), 0)
+	m, err := NewManager(dir, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx, time.Hour) }()
+
+	createUser(t, l, "a")
+	createUser(t, l, "b")
+	deadline := time.Now().Add(5 * time.Second)
+	for m.archive.Last() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("entries were not archived")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v", err)
+	}
+	if snaps, _ := ListSnapshots(dir); len(snaps) != 1 {
+		t.Errorf("Run took %d snapshots; want 1 at start", len(snaps))
+	}
+}
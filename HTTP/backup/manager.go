@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"httpserver/replication"
+)
+
+// --- MANAGER ---
+
+// Policy says which snapshots to keep. A snapshot is kept when it is one
+// of the KeepLast newest or younger than MaxAge. The newest is always
+// kept, and the zero Policy keeps everything.
+type Policy struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+// Manager takes snapshots of a leader's stores on a schedule, archives its
+// change log in between and prunes old files.
+type Manager struct {
+	Dir    string
+	Policy Policy
+	// Now returns the creation time of snapshots and the age of old ones.
+	Now func() time.Time
+
+	leader  *replication.Leader
+	archive *Archive
+}
+
+// NewManager opens the backup directory and makes the leader's log number
+// its entries after the ones already archived, so the archive stays in
+// order across restarts. Call it before the first write.
+func NewManager(dir string, leader *replication.Leader) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	archive, err := OpenArchive(dir)
+	if err != nil {
+		return nil, err
+	}
+	if head, _ := leader.Log().Head(); head < archive.Last() {
+		if err := leader.Log().Continue(archive.Last()); err != nil {
+			return nil, err
+		}
+	}
+	return &Manager{Dir: dir, Now: time.Now, leader: leader, archive: archive}, nil
+}
+
+// Snapshot writes a snapshot and prunes the ones the policy drops. Writes
+// to the leader wait only while the data is copied, not while it is
+// compressed and written.
+func (m *Manager) Snapshot(ctx context.Context) (Info, error) {
+	snap, err := m.leader.Snapshot(ctx)
+	if err != nil {
+		return Info{}, err
+	}
+	m.archive.Rotate()
+	info, err := WriteSnapshot(m.Dir, snap, m.Now())
+	if err != nil {
+		return Info{}, err
+	}
+	if _, err := m.Prune(); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// Run takes a snapshot right away and then every interval, and archives
+// the change log until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) error {
+	if _, err := m.Snapshot(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logs := m.leader.Log()
+	pos := m.archive.Last()
+	for {
+		entries, changed, err := logs.Since(pos, 1000)
+		if errors.Is(err, replication.ErrTrimmed) {
+			// We fell behind the in-memory log. A snapshot covers the lost
+			// entries for restores after it; earlier times have a gap.
+			log.Printf("backup: change log trimmed after seq %d, taking a snapshot", pos)
+			info, err := m.Snapshot(ctx)
+			if err != nil {
+				return err
+			}
+			pos = info.Seq
+			continue
+		}
+		if err := m.archive.Append(entries); err != nil {
+			return fmt.Errorf("backup: archive: %w", err)
+		}
+		if len(entries) > 0 {
+			pos = entries[len(entries)-1].Seq
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if _, err := m.Snapshot(ctx); err != nil {
+				log.Printf("backup: snapshot: %v", err)
+			}
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), m.archive.Close())
+		}
+	}
+}
+
+// Prune applies m.Policy to the backup directory (see Prune).
+func (m *Manager) Prune() ([]string, error) {
+	return Prune(m.Dir, m.Policy, m.Now())
+}
+
+// Prune removes the snapshots in dir that p drops at time now, and the
+// archive segments whose entries are all older than the oldest snapshot
+// left. It returns the removed paths.
+func Prune(dir string, p Policy, now time.Time) ([]string, error) {
+	snaps, err := ListSnapshots(dir)
+	if err != nil || len(snaps) == 0 {
+		return nil, err
+	}
+
+	var removed []string
+	keepFrom := 0
+	if p.KeepLast > 0 || p.MaxAge > 0 {
+		keepFrom = len(snaps) - 1
+		for i := range snaps {
+			newest := len(snaps)-i <= p.KeepLast
+			young := p.MaxAge > 0 && now.Sub(snaps[i].Created) < p.MaxAge
+			if newest || young {
+				keepFrom = i
+				break
+			}
+		}
+		for _, s := range snaps[:keepFrom] {
+			if err := os.Remove(s.Path); err != nil {
+				return removed, err
+			}
+			removed = append(removed, s.Path)
+		}
+	}
+
+	// A segment can go once the next one starts at or before the oldest
+	// snapshot left, since no restore needs its entries any more.
+	oldest := snaps[keepFrom].Seq
+	segs, err := segments(dir)
+	if err != nil {
+		return removed, err
+	}
+	for i := 0; i+1 < len(segs) && segs[i+1].first <= oldest+1; i++ {
+		if err := os.Remove(segs[i].path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, segs[i].path)
+	}
+	return removed, nil
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"httpserver/replication"
+	"httpserver/store"
+)
+
+// --- RESTORE ---
+
+// ErrGap is returned when archived entries needed by a restore are missing.
+var ErrGap = errors.New("backup: change log archive has a gap")
+
+// Result describes a restore.
+type Result struct {
+	Snapshot replication.Snapshot // the restored data
+	From     Info                 // the snapshot it started from
+	Applied  int                  // archived entries applied on top
+}
+
+// Restore rebuilds the data as it was at until, or as recent as the backup
+// allows when until is zero: it loads the newest snapshot taken at or
+// before until and applies the archived entries up to until.
+func Restore(ctx context.Context, dir string, until time.Time) (Result, error) {
+	snaps, err := ListSnapshots(dir)
+	if err != nil {
+		return Result{}, err
+	}
+	var from *Info
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if until.IsZero() || !snaps[i].Created.After(until) {
+			from = &snaps[i]
+			break
+		}
+	}
+	if from == nil {
+		return Result{}, ErrNoSnapshot
+	}
+
+	info, snap, err := ReadSnapshot(from.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	users, posts := store.NewMemoryUserStore(), store.NewMemoryPostStore()
+	snap.Load(users, posts)
+
+	res := Result{From: info}
+	seq, seqTime := snap.Seq, snap.Time
+	segs, err := segments(dir)
+	if err != nil {
+		return Result{}, err
+	}
+replay:
+	for i, seg := range segs {
+		if i+1 < len(segs) && segs[i+1].first <= seq+1 {
+			continue // every entry is already in the snapshot
+		}
+		entries, _, err := readSegment(seg.path)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, e := range entries {
+			if e.Seq <= seq {
+				continue
+			}
+			if !until.IsZero() && e.Time.After(until) {
+				break replay
+			}
+			if e.Seq != seq+1 {
+				return Result{}, fmt.Errorf("%w: entries %d to %d are missing", ErrGap, seq+1, e.Seq-1)
+			}
+			if err := replication.Apply(ctx, users, posts, e); err != nil {
+				return Result{}, err
+			}
+			seq, seqTime = e.Seq, e.Time
+			res.Applied++
+		}
+	}
+
+	res.Snapshot, err = replication.NewSnapshot(ctx, users, posts)
+	if err != nil {
+		return Result{}, err
+	}
+	res.Snapshot.Seq, res.Snapshot.Time = seq, seqTime
+	return res, nil
+}
@@ -0,0 +1,191 @@
+// Package backup writes snapshots of the user data to disk, archives the
+// change log between them and restores either, up to a point in time.
+//
+// A backup directory holds two kinds of files:
+//
+//	snapshot-<created>-<seq>.snap  a header line, then the gzipped data
+//	wal-<first seq>.jsonl          archived change log entries, one per line
+//
+// The header records the SHA-256 of the uncompressed data, which is checked
+// on every read. To restore to time T, the newest snapshot taken before T is
+// loaded and the archived entries up to T are applied on top of it.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"httpserver/replication"
+)
+
+const format = "httpserver-snapshot/1"
+
+var (
+	// ErrChecksum is returned when a snapshot does not match its checksum.
+	ErrChecksum = errors.New("backup: checksum mismatch")
+	// ErrBadFormat is returned for files that are not snapshots.
+	ErrBadFormat = errors.New("backup: not a snapshot file")
+	// ErrNoSnapshot is returned when no snapshot is old enough to restore from.
+	ErrNoSnapshot = errors.New("backup: no snapshot before the target time")
+)
+
+// --- SNAPSHOT FILES ---
+
+// Info is the header of a snapshot file.
+type Info struct {
+	Format  string    `json:"format"`
+	Seq     uint64    `json:"seq"`     // last change log entry in the snapshot
+	Time    time.Time `json:"time"`    // time of that entry
+	Created time.Time `json:"created"` // when the snapshot was taken
+	Users   int       `json:"users"`
+	Posts   int       `json:"posts"`
+	SHA256  string    `json:"sha256"` // of the uncompressed JSON data
+
+	Path string `json:"-"`
+	Size int64  `json:"-"` // file size in bytes
+}
+
+// WriteSnapshot writes snap to dir and returns its header. The file appears
+// under its final name only once it is complete and synced.
+func WriteSnapshot(dir string, snap replication.Snapshot, created time.Time) (Info, error) {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return Info{}, err
+	}
+	sum := sha256.Sum256(data)
+	info := Info{
+		Format:  format,
+		Seq:     snap.Seq,
+		Time:    snap.Time,
+		Created: created.UTC(),
+		Users:   len(snap.Users),
+		Posts:   len(snap.Posts),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+	name := fmt.Sprintf("snapshot-%s-%020d.snap", info.Created.Format("20060102T150405.000000000Z"), info.Seq)
+	info.Path = filepath.Join(dir, name)
+
+	f, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+
+	header, _ := json.Marshal(info)
+	zw := gzip.NewWriter(f)
+	_, err = f.Write(append(header, '\n'))
+	if err == nil {
+		_, err = zw.Write(data)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(f.Name(), info.Path); err != nil {
+		return Info{}, err
+	}
+	st, err := os.Stat(info.Path)
+	if err != nil {
+		return Info{}, err
+	}
+	info.Size = st.Size()
+	return info, nil
+}
+
+// ReadSnapshot reads and verifies the snapshot at path.
+func ReadSnapshot(path string) (Info, replication.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, replication.Snapshot{}, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	info, err := readHeader(br, path)
+	if err != nil {
+		return Info{}, replication.Snapshot{}, err
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return info, replication.Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return info, replication.Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != info.SHA256 {
+		return info, replication.Snapshot{}, fmt.Errorf("%s: %w", path, ErrChecksum)
+	}
+	var snap replication.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return info, replication.Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return info, snap, nil
+}
+
+// ListSnapshots returns the headers of the snapshots in dir, oldest first.
+// It only reads the headers; use ReadSnapshot to verify a file.
+func ListSnapshots(dir string) ([]Info, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "snapshot-*.snap"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Info
+	for _, p := range paths {
+		info, err := statSnapshot(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	slices.SortFunc(out, func(a, b Info) int { return a.Created.Compare(b.Created) })
+	return out, nil
+}
+
+func statSnapshot(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+	info, err := readHeader(bufio.NewReader(f), path)
+	if err != nil {
+		return Info{}, err
+	}
+	if st, err := f.Stat(); err == nil {
+		info.Size = st.Size()
+	}
+	return info, nil
+}
+
+func readHeader(br *bufio.Reader, path string) (Info, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return Info{}, fmt.Errorf("%s: %w", path, ErrBadFormat)
+	}
+	var info Info
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &info); err != nil || info.Format != format {
+		return Info{}, fmt.Errorf("%s: %w", path, ErrBadFormat)
+	}
+	info.Path = path
+	return info, nil
+}
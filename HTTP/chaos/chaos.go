@@ -0,0 +1,350 @@
+// Package chaos injects faults into HTTP responses, to see how clients
+// cope with a misbehaving server.
+//
+// An Injector holds a list of rules. The first enabled rule that matches a
+// request (by method, route, header and percentage) decides what goes wrong:
+// extra latency, an error status, a truncated body or a dropped connection.
+// Rules can be changed while the server runs.
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- RULES ---
+
+var (
+	ErrNotFound    = errors.New("chaos: rule not found")
+	ErrExists      = errors.New("chaos: rule already exists")
+	ErrInvalidRule = errors.New("chaos: invalid rule")
+)
+
+// Duration is a time.Duration written as "250ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rule describes which requests to break and how.
+type Rule struct {
+	ID      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+
+	// Matching. Empty fields match every request.
+	Method      string  `json:"method,omitempty"`
+	Route       string  `json:"route,omitempty"`       // path.Match glob; a trailing "*" also matches deeper paths
+	Header      string  `json:"header,omitempty"`      // the request must have this header...
+	HeaderValue string  `json:"headerValue,omitempty"` // ...with this value, when set
+	Percent     float64 `json:"percent"`               // share of matching requests affected, 0-100
+
+	// Faults. Latency can be combined with any of the others.
+	Latency   Duration `json:"latency,omitempty"`
+	Status    int      `json:"status,omitempty"`    // answer with this status instead of calling the handler
+	Truncate  bool     `json:"truncate,omitempty"`  // send a short body with a matching Content-Length
+	Drop      bool     `json:"drop,omitempty"`      // close the connection in the middle of the body
+	KeepBytes int      `json:"keepBytes,omitempty"` // body bytes sent by Truncate and Drop (0 means half)
+}
+
+// Validate reports the first problem of the rule.
+func (r Rule) Validate() error {
+	invalid := func(msg string) error { return fmt.Errorf("%w: %s", ErrInvalidRule, msg) }
+
+	if r.Percent < 0 || r.Percent > 100 {
+		return invalid("percent must be between 0 and 100")
+	}
+	if r.Route != "" {
+		if !strings.HasPrefix(r.Route, "/") {
+			return invalid("route must start with /")
+		}
+		if _, err := path.Match(r.Route, "/"); err != nil {
+			return invalid("route is not a valid pattern")
+		}
+	}
+	if r.HeaderValue != "" && r.Header == "" {
+		return invalid("headerValue needs header")
+	}
+	if r.Latency < 0 || r.KeepBytes < 0 {
+		return invalid("latency and keepBytes must not be negative")
+	}
+	if r.Status != 0 && (r.Status < 400 || r.Status > 599) {
+		return invalid("status must be a 4xx or 5xx code")
+	}
+	faults := 0
+	for _, on := range []bool{r.Status != 0, r.Truncate, r.Drop} {
+		if on {
+			faults++
+		}
+	}
+	if faults > 1 {
+		return invalid("status, truncate and drop cannot be combined")
+	}
+	if faults == 0 && r.Latency == 0 {
+		return invalid("rule injects no fault")
+	}
+	return nil
+}
+
+func (r *Rule) matches(req *http.Request, roll func() float64) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.Route != "" && !matchRoute(r.Route, req.URL.Path) {
+		return false
+	}
+	if r.Header != "" {
+		v, ok := req.Header[http.CanonicalHeaderKey(r.Header)]
+		if !ok || (r.HeaderValue != "" && (len(v) == 0 || v[0] != r.HeaderValue)) {
+			return false
+		}
+	}
+	return roll()*100 < r.Percent
+}
+
+func matchRoute(pattern, p string) bool {
+	if ok, _ := path.Match(pattern, p); ok {
+		return true
+	}
+	prefix, deep := strings.CutSuffix(pattern, "*")
+	return deep && !strings.ContainsAny(prefix, "*?[") && strings.HasPrefix(p, prefix)
+}
+
+// --- INJECTOR ---
+
+// Injector holds the rules. It is safe for concurrent use.
+type Injector struct {
+	// Rand returns a number in [0, 1) for the percentage check.
+	Rand func() float64
+
+	mu     sync.RWMutex
+	rules  []Rule
+	nextID int
+}
+
+// NewInjector returns an Injector without rules.
+func NewInjector() *Injector {
+	return &Injector{Rand: rand.Float64, nextID: 1}
+}
+
+// Rules returns a copy of the rules in match order.
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule{}, in.rules...)
+}
+
+// Rule returns the rule with id.
+func (in *Injector) Rule(id string) (Rule, error) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if i := in.index(id); i >= 0 {
+		return in.rules[i], nil
+	}
+	return Rule{}, ErrNotFound
+}
+
+// Add appends a rule. An empty ID is assigned automatically.
+func (in *Injector) Add(r Rule) (Rule, error) {
+	if err := r.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if r.ID == "" {
+		for in.index(strconv.Itoa(in.nextID)) >= 0 {
+			in.nextID++
+		}
+		r.ID = strconv.Itoa(in.nextID)
+		in.nextID++
+	} else if in.index(r.ID) >= 0 {
+		return Rule{}, ErrExists
+	}
+	in.rules = append(in.rules, r)
+	return r, nil
+}
+
+// Replace swaps the rule with the same ID, keeping its position.
+func (in *Injector) Replace(r Rule) (Rule, error) {
+	if err := r.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	i := in.index(r.ID)
+	if i < 0 {
+		return Rule{}, ErrNotFound
+	}
+	in.rules[i] = r
+	return r, nil
+}
+
+// SetEnabled turns a rule on or off.
+func (in *Injector) SetEnabled(id string, enabled bool) (Rule, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	i := in.index(id)
+	if i < 0 {
+		return Rule{}, ErrNotFound
+	}
+	in.rules[i].Enabled = enabled
+	return in.rules[i], nil
+}
+
+// Remove deletes a rule.
+func (in *Injector) Remove(id string) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	i := in.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	in.rules = append(in.rules[:i], in.rules[i+1:]...)
+	return nil
+}
+
+// index returns the position of id or -1. The caller must hold the lock.
+func (in *Injector) index(id string) int {
+	for i := range in.rules {
+		if in.rules[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// match returns a copy of the first rule that applies to req.
+func (in *Injector) match(req *http.Request) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	for i := range in.rules {
+		if in.rules[i].matches(req, in.Rand) {
+			return in.rules[i], true
+		}
+	}
+	return Rule{}, false
+}
+
+// --- MIDDLEWARE ---
+
+// RuleHeader names the rule that changed a response, to tell injected
+// faults from real ones.
+const RuleHeader = "X-Fault-Rule"
+
+// Wrap returns a handler that applies the matching rule to each request.
+func (in *Injector) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := in.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rule.Latency > 0 {
+			t := time.NewTimer(time.Duration(rule.Latency))
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			}
+		}
+
+		switch {
+		case rule.Status != 0:
+			w.Header().Set(RuleHeader, rule.ID)
+			http.Error(w, "Injected fault", rule.Status)
+		case rule.Truncate, rule.Drop:
+			breakBody(w, r, next, rule)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// breakBody runs the handler into a buffer and sends only part of its body.
+func breakBody(w http.ResponseWriter, r *http.Request, next http.Handler, rule Rule) {
+	buf := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	next.ServeHTTP(buf, r)
+
+	body := buf.body.Bytes()
+	keep := rule.KeepBytes
+	if keep == 0 || keep > len(body) {
+		keep = len(body) / 2
+	}
+
+	h := w.Header()
+	for k, v := range buf.header {
+		h[k] = v
+	}
+	h.Set(RuleHeader, rule.ID)
+
+	if rule.Truncate {
+		// A well-formed response whose body just stops early.
+		h.Set("Content-Length", strconv.Itoa(keep))
+		w.WriteHeader(buf.status)
+		w.Write(body[:keep])
+		return
+	}
+
+	// Promise the full body, send part of it and abort the connection.
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(buf.status)
+	w.Write(body[:keep])
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// bufferedResponse is a minimal ResponseWriter that keeps everything in memory.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status, b.wroteHeader = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,180 @@
+package chaos
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const payload = `{"id":1,"name":"Alice","email":"alice@example.com"}`
+
+func newServer(t *testing.T, in *Injector) *httptest.Server {
+	srv := httptest.NewServer(in.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload))
+	})))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+	}{
+		{"latency", Rule{Percent: 100, Latency: Duration(time.Millisecond)}, true},
+		{"status", Rule{Percent: 50, Status: 503, Route: "/users/*"}, true},
+		{"no fault", Rule{Percent: 100}, false},
+		{"bad percent", Rule{Percent: 101, Status: 500}, false},
+		{"2xx status", Rule{Percent: 100, Status: 200}, false},
+		{"status and drop", Rule{Percent: 100, Status: 500, Drop: true}, false},
+		{"bad route", Rule{Percent: 100, Status: 500, Route: "/users/["}, false},
+		{"relative route", Rule{Percent: 100, Status: 500, Route: "users"}, false},
+		{"value without header", Rule{Percent: 100, Status: 500, HeaderValue: "x"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.rule.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v; want ok=%v", tt.name, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s: error %v is not ErrInvalidRule", tt.name, err)
+		}
+	}
+}
+
+func TestMatching(t *testing.T) {
+	in := NewInjector()
+	in.Add(Rule{ID: "users", Enabled: true, Percent: 100, Status: 503, Method: "DELETE", Route: "/users/*"})
+	in.Add(Rule{ID: "header", Enabled: true, Percent: 100, Status: 418, Header: "X-Chaos", HeaderValue: "teapot"})
+	in.Add(Rule{ID: "deep", Enabled: true, Percent: 100, Status: 500, Route: "/posts*"})
+
+	tests := []struct {
+		method, path, header string
+		want                 string
+	}{
+		{"DELETE", "/users/1", "", "users"},
+		{"GET", "/users/1", "", ""},
+		{"DELETE", "/users/1/posts", "", "users"}, // a trailing * matches deeper paths
+		{"GET", "/", "teapot", "header"},
+		{"GET", "/", "coffee", ""},
+		{"GET", "/posts/1/comments", "", "deep"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set("X-Chaos", tt.header)
+		}
+		rule, ok := in.match(r)
+		if got := map[bool]string{true: rule.ID}[ok]; got != tt.want {
+			t.Errorf("%s %s (%q) matched %q; want %q", tt.method, tt.path, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	in := NewInjector()
+	in.Add(Rule{Enabled: true, Percent: 30, Status: 500})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	in.Rand = func() float64 { return 0.29 }
+	if _, ok := in.match(r); !ok {
+		t.Error("roll 0.29 under 30% did not match")
+	}
+	in.Rand = func() float64 { return 0.30 }
+	if _, ok := in.match(r); ok {
+		t.Error("roll 0.30 under 30% matched")
+	}
+}
+
+func TestRuntimeToggle(t *testing.T) {
+	in := NewInjector()
+	srv := newServer(t, in)
+
+	rule, err := in.Add(Rule{Enabled: true, Percent: 100, Status: 503})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := http.Get(srv.URL)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || resp.Header.Get(RuleHeader) != rule.ID {
+		t.Errorf("enabled rule: status %d, %s %q", resp.StatusCode, RuleHeader, resp.Header.Get(RuleHeader))
+	}
+
+	in.SetEnabled(rule.ID, false)
+	resp, _ = http.Get(srv.URL)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("disabled rule: status %d", resp.StatusCode)
+	}
+
+	if err := in.Remove(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.SetEnabled(rule.ID, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetEnabled after Remove: err = %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	in := NewInjector()
+	srv := newServer(t, in)
+	in.Add(Rule{Enabled: true, Percent: 100, Latency: Duration(50 * time.Millisecond)})
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("response took %v; want at least 50ms", d)
+	}
+	if string(body) != payload {
+		t.Errorf("latency changed the body: %q", body)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	in := NewInjector()
+	srv := newServer(t, in)
+	in.Add(Rule{Enabled: true, Percent: 100, Truncate: true, KeepBytes: 10})
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("truncated body must read cleanly: %v", err)
+	}
+	if string(body) != payload[:10] || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("body = %q, content type %q", body, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestDrop(t *testing.T) {
+	in := NewInjector()
+	srv := newServer(t, in)
+	in.Add(Rule{Enabled: true, Percent: 100, Drop: true})
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		return // dropped before the headers arrived: also a dropped connection
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Fatalf("read %q without error; want the connection to break", body)
+	}
+	if !strings.HasPrefix(payload, string(body)) || len(body) >= len(payload) {
+		t.Errorf("partial body = %q", body)
+	}
+}
@@ -0,0 +1,113 @@
+// Command backup inspects, restores and prunes a backup directory written
+// by the server (BACKUP_DIR).
+//
+//	go run ./cmd/backup list    -dir backups
+//	go run ./cmd/backup verify  -dir backups
+//	go run ./cmd/backup restore -dir backups -until 2024-05-01T12:00:00Z -out restored
+//	go run ./cmd/backup prune   -dir backups -keep 24 -max-age 168h
+//
+// restore writes one snapshot file with the data as of -until (default:
+// the latest state the backup has) to -out. Start the server with
+// RESTORE_FROM pointing at either directory to load it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"httpserver/backup"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal("usage: backup list|verify|restore|prune [flags]")
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dir := fs.String("dir", "backups", "backup directory")
+
+	switch os.Args[1] {
+	case "list":
+		fs.Parse(os.Args[2:])
+		snaps, err := backup.ListSnapshots(*dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CREATED\tSEQ\tUSERS\tPOSTS\tSIZE\tFILE")
+		for _, s := range snaps {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", s.Created.Format(time.RFC3339), s.Seq, s.Users, s.Posts, s.Size, s.Path)
+		}
+		tw.Flush()
+
+	case "verify":
+		fs.Parse(os.Args[2:])
+		snaps, err := backup.ListSnapshots(*dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bad := 0
+		for _, s := range snaps {
+			if _, _, err := backup.ReadSnapshot(s.Path); err != nil {
+				fmt.Println("BAD ", err)
+				bad++
+				continue
+			}
+			fmt.Println("OK  ", s.Path)
+		}
+		if bad > 0 {
+			os.Exit(1)
+		}
+
+	case "restore":
+		until := fs.String("until", "", "RFC 3339 time to restore to (default: latest)")
+		out := fs.String("out", "restored", "directory of the restored snapshot")
+		fs.Parse(os.Args[2:])
+
+		var t time.Time
+		if *until != "" {
+			var err error
+			if t, err = time.Parse(time.RFC3339Nano, *until); err != nil {
+				log.Fatalf("invalid -until: %v", err)
+			}
+		}
+		res, err := backup.Restore(context.Background(), *dir, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.MkdirAll(*out, 0o700); err != nil {
+			log.Fatal(err)
+		}
+		info, err := backup.WriteSnapshot(*out, res.Snapshot, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("restored %d users and %d posts at seq %d\n", info.Users, info.Posts, info.Seq)
+		fmt.Printf("from %s + %d archived changes\n", res.From.Path, res.Applied)
+		fmt.Printf("wrote %s\n", info.Path)
+
+	case "prune":
+		keep := fs.Int("keep", 0, "keep the N newest snapshots")
+		maxAge := fs.Duration("max-age", 0, "keep snapshots younger than this")
+		fs.Parse(os.Args[2:])
+		if *keep == 0 && *maxAge == 0 {
+			log.Fatal("prune needs -keep or -max-age")
+		}
+
+		removed, err := backup.Prune(*dir, backup.Policy{KeepLast: *keep, MaxAge: *maxAge}, time.Now())
+		for _, p := range removed {
+			fmt.Println("removed", p)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+}
@@ -0,0 +1,68 @@
+// Command loadgen benchmarks the user API.
+//
+//	go run ./cmd/loadgen -url http://localhost:8080 -c 20 -d 30s
+//	go run ./cmd/loadgen -rate 500 -d 1m -scenario mix.json -json > run.json
+//
+// Without -scenario it lists users three times for every create.
+// With -rate the requests start at a fixed rate (open loop); without it
+// -c workers send requests back to back (closed loop).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"httpserver/loadgen"
+)
+
+func main() {
+	var (
+		baseURL     = flag.String("url", "http://localhost:8080", "base URL of the server")
+		scenario    = flag.String("scenario", "", "JSON scenario file (default: list/create mix)")
+		rate        = flag.Float64("rate", 0, "requests per second (0 means fixed concurrency)")
+		concurrency = flag.Int("c", 10, "workers, or max requests in flight with -rate")
+		duration    = flag.Duration("d", 10*time.Second, "how long to run")
+		timeout     = flag.Duration("timeout", 10*time.Second, "timeout of each request")
+		asJSON      = flag.Bool("json", false, "print the report as JSON")
+	)
+	flag.Parse()
+
+	cfg := loadgen.Config{
+		BaseURL:     *baseURL,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Timeout:     *timeout,
+	}
+	if *scenario != "" {
+		s, err := loadgen.LoadScenario(*scenario)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Scenario = s
+	}
+
+	// Ctrl-C stops the run early but still prints the report.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rep, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		err = rep.WriteJSON(os.Stdout)
+	} else {
+		err = rep.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,116 @@
+// Command reshard moves users between shards after the ring changes.
+//
+//	go run ./cmd/reshard -data data -nodes a,b,c,d -dry-run
+//	go run ./cmd/reshard -data data -nodes a,b,c,d
+//
+// Shards are the kv databases the server keeps in <DATA_DIR>/shards/<node>
+// when SHARDS is set. -nodes is the new ring: nodes missing from it are
+// emptied and their directories removed, and new nodes get one. -vnodes
+// must match SHARD_VNODES. Stop the servers first, and start them again
+// with SHARDS set to -nodes.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"httpserver/kv"
+	"httpserver/shard"
+	"httpserver/store"
+)
+
+func main() {
+	var (
+		data   = flag.String("data", "data", "the server's DATA_DIR")
+		nodes  = flag.String("nodes", "", "comma-separated nodes of the new ring")
+		vnodes = flag.Int("vnodes", shard.DefaultVirtualNodes, "virtual nodes per node")
+		dryRun = flag.Bool("dry-run", false, "print the moves without writing")
+	)
+	flag.Parse()
+	if *nodes == "" {
+		log.Fatal("-nodes is required")
+	}
+	names, err := shard.ParseNodes(*nodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ring := shard.NewRing(*vnodes, names...)
+	existing, err := shard.ExistingNodes(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	backends := map[string]shard.Backend{}
+	dbs := map[string]*kv.DB{}
+	for _, name := range existing {
+		db, err := kv.Open(shard.NodeDir(*data, name), kv.Options{})
+		if err != nil {
+			log.Fatal(err)
+		}
+		dbs[name], backends[name] = db, store.NewKVUserStore(db)
+	}
+	for _, name := range ring.Nodes() {
+		if backends[name] != nil {
+			continue
+		}
+		if *dryRun {
+			backends[name] = store.NewMemoryUserStore()
+			continue
+		}
+		db, err := kv.Open(shard.NodeDir(*data, name), kv.Options{})
+		if err != nil {
+			log.Fatal(err)
+		}
+		dbs[name], backends[name] = db, store.NewKVUserStore(db)
+	}
+
+	var moves []shard.Move
+	if *dryRun {
+		moves, err = shard.Plan(ctx, ring, backends)
+	} else {
+		moves, err = shard.Migrate(ctx, ring, backends)
+	}
+	for _, db := range dbs {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Fatal(err) // running it again picks up where it stopped
+	}
+	printSummary(moves)
+	if *dryRun {
+		return
+	}
+
+	// Migrate copies every user before deleting it, so the nodes that left
+	// the ring are empty by now.
+	for _, name := range existing {
+		if !slices.Contains(ring.Nodes(), name) {
+			if err := os.RemoveAll(shard.NodeDir(*data, name)); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+func printSummary(moves []shard.Move) {
+	routes := map[string]int{}
+	for _, m := range moves {
+		routes[m.From+" -> "+m.To]++
+	}
+	keys := make([]string, 0, len(routes))
+	for k := range routes {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Printf("%-20s %d\n", k, routes[k])
+	}
+	fmt.Printf("%d users to move\n", len(moves))
+}
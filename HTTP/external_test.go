@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"httpserver/vcr"
+)
+
+// The upstream call is replayed from a cassette; run with VCR_MODE=record
+// to refresh it from jsonplaceholder.
+func TestExternalAPIClient(t *testing.T) {
+	rec := vcr.Start(t, "testdata/cassettes/external_post.json")
+	old := externalClient
+	externalClient = rec.Client()
+	t.Cleanup(func() { externalClient = old })
+
+	rr := serve("GET", "/external", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d", rr.Code)
+	}
+
+	var post struct {
+		ID     int    `json:"id"`
+		UserID int    `json:"userId"`
+		Title  string `json:"title"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&post); err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != 1 || post.UserID != 1 || post.Title == "" {
+		t.Errorf("post = %+v", post)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"httpserver/chaos"
+)
+
+// --- FAULT INJECTION ---
+//
+// For chaos testing only. With FAULT_INJECTION=true every API request goes
+// through faults and the rules are managed at /admin/faults (ADMIN_TOKEN).
+// Without it neither the middleware nor the admin routes exist.
+
+var (
+	faultInjection bool
+	faults         = chaos.NewInjector()
+)
+
+func configureFaults() {
+	faultInjection = strings.EqualFold(os.Getenv("FAULT_INJECTION"), "true")
+	if faultInjection {
+		log.Println("FAULT_INJECTION enabled, manage rules at /admin/faults")
+	}
+}
+
+// --- HANDLER: LIST / ADD RULES (/admin/faults) ---
+
+func adminFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, faults.Rules())
+
+	case http.MethodPost:
+		// New rules are on and hit every matching request unless told otherwise.
+		rule := chaos.Rule{Enabled: true, Percent: 100}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		rule, err := faults.Add(rule)
+		if err != nil {
+			faultError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, rule)
+
+	default:
+		http.Error(w, "Only GET or POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: SINGLE RULE (/admin/faults/{id}) ---
+
+func adminFaultHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := faults.Rule(id)
+		if err != nil {
+			faultError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodPut:
+		var rule chaos.Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		rule, err := faults.Replace(rule)
+		if err != nil {
+			faultError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodDelete:
+		if err := faults.Remove(id); err != nil {
+			faultError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT or DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: TOGGLE (/admin/faults/{id}/enable, /disable) ---
+
+func adminFaultToggleHandler(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rule, err := faults.SetEnabled(r.PathValue("id"), enabled)
+		if err != nil {
+			faultError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	}
+}
+
+func faultError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, chaos.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, chaos.ErrExists):
+		http.Error(w, "Rule already exists", http.StatusConflict)
+	case errors.Is(err, chaos.ErrInvalidRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
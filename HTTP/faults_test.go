@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"httpserver/chaos"
+	"httpserver/tracing"
+)
+
+func TestFaultInjectionDisabledByDefault(t *testing.T) {
+	// Without FAULT_INJECTION the path falls through to the home page.
+	if rec := serveAs("", "POST", "/admin/faults", `{"status":503}`); rec.Code == http.StatusCreated {
+		t.Error("rule added without FAULT_INJECTION")
+	}
+	if rec := serveAs("", "GET", "/users", ""); rec.Header().Get(chaos.RuleHeader) != "" {
+		t.Error("fault injected without FAULT_INJECTION")
+	}
+}
+
+func TestFaultInjectionAdmin(t *testing.T) {
+	oldEnabled, oldFaults, oldAdmin := faultInjection, faults, adminToken
+	t.Cleanup(func() { faultInjection, faults, adminToken = oldEnabled, oldFaults, oldAdmin })
+	faultInjection, faults, adminToken = true, chaos.NewInjector(), "admin-secret"
+
+	if rec := serveAs("", "POST", "/admin/faults", `{"id":"down","route":"/users","status":200}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid rule: status %d; want 400", rec.Code)
+	}
+	if rec := serveAs("", "POST", "/admin/faults", `{"id":"down","route":"/users","status":503}`); rec.Code != http.StatusCreated {
+		t.Fatalf("add rule: status %d %s", rec.Code, rec.Body)
+	}
+
+	rec := serveAs("", "GET", "/users", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get(chaos.RuleHeader) != "down" {
+		t.Errorf("faulty route: status %d", rec.Code)
+	}
+	if rec := serveAs("", "GET", "/users/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("other route: status %d; want 200", rec.Code)
+	}
+	// The admin API itself is never broken by a rule.
+	if rec := serveAs("", "GET", "/admin/faults", ""); rec.Code != http.StatusOK {
+		t.Errorf("admin list: status %d", rec.Code)
+	}
+
+	serveAs("", "POST", "/admin/faults/down/disable", "")
+	if rec := serveAs("", "GET", "/users", ""); rec.Code != http.StatusOK {
+		t.Errorf("disabled rule: status %d; want 200", rec.Code)
+	}
+
+	if rec := serveAs("", "DELETE", "/admin/faults/down", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", rec.Code)
+	}
+	if rec := serveAs("", "POST", "/admin/faults/down/enable", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("enable deleted rule: status %d; want 404", rec.Code)
+	}
+}
+
+func TestFaultInjectionDropWithTracing(t *testing.T) {
+	oldEnabled, oldFaults, oldTracer := faultInjection, faults, tracer
+	t.Cleanup(func() { faultInjection, faults, tracer = oldEnabled, oldFaults, oldTracer })
+	faultInjection, faults = true, chaos.NewInjector()
+	tracer = tracing.NewTracer("test", tracing.NewMemoryExporter())
+	if _, err := faults.Add(chaos.Rule{ID: "drop", Enabled: true, Percent: 100, Route: "/users", Drop: true, KeepBytes: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(newRouter())
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL + "/users")
+	if err != nil {
+		t.Fatalf("the partial body was not flushed through the tracing middleware: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil || len(body) != 5 {
+		t.Errorf("read %q, %v; want 5 bytes and a broken connection", body, err)
+	}
+}
@@ -0,0 +1,276 @@
+// Package jsonrpc is a small JSON-RPC 2.0 server over HTTP.
+//
+// It supports single calls, batches and notifications (calls without an id)
+// as described in https://www.jsonrpc.org/specification.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// --- ERROR CODES ---
+
+// Standard error codes from the specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is a JSON-RPC error object. Handlers return it to choose the code.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// InvalidParams returns a CodeInvalidParams error with optional details.
+func InvalidParams(message string, data any) *Error {
+	return &Error{Code: CodeInvalidParams, Message: message, Data: data}
+}
+
+// --- MESSAGES ---
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // nil means notification
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// HandlerFunc runs one method. params is the raw "params" value
+// (an object, an array or nil when the client sent none).
+// Returning an *Error sends that error; any other error becomes an internal error.
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// --- SERVER ---
+
+// Server routes calls to registered methods. Register all methods
+// before serving requests.
+type Server struct {
+	methods map[string]HandlerFunc
+
+	// MaxBatch limits the number of calls in one batch (0 means 100).
+	MaxBatch int
+	// MaxBodyBytes limits the request size (0 means 1 MiB).
+	MaxBodyBytes int64
+}
+
+// NewServer returns a Server without methods.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]HandlerFunc)}
+}
+
+// Register adds a method. It panics on duplicates, like http.ServeMux.
+func (s *Server) Register(method string, h HandlerFunc) {
+	if _, ok := s.methods[method]; ok {
+		panic("jsonrpc: method registered twice: " + method)
+	}
+	s.methods[method] = h
+}
+
+// ServeHTTP handles a POSTed JSON-RPC request or batch.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxBody := s.MaxBodyBytes
+	if maxBody == 0 {
+		maxBody = 1 << 20
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		writeJSON(w, errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "Request too large"}))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		s.serveBatch(r.Context(), w, body)
+		return
+	}
+
+	if resp, ok := s.call(r.Context(), body); ok {
+		writeJSON(w, resp)
+		return
+	}
+	// Notifications get no response body.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveBatch(ctx context.Context, w http.ResponseWriter, body []byte) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		writeJSON(w, errorResponse(nil, &Error{Code: CodeParseError, Message: "Parse error"}))
+		return
+	}
+	if len(items) == 0 {
+		writeJSON(w, errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "Empty batch"}))
+		return
+	}
+	maxBatch := s.MaxBatch
+	if maxBatch == 0 {
+		maxBatch = 100
+	}
+	if len(items) > maxBatch {
+		writeJSON(w, errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: fmt.Sprintf("Batch larger than %d calls", maxBatch)}))
+		return
+	}
+
+	// The calls of a batch may run in any order, so they run concurrently.
+	results := make([]*response, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, ok := s.call(ctx, item); ok {
+				results[i] = resp
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := make([]*response, 0, len(results))
+	for _, resp := range results {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusNoContent) // the batch only had notifications
+		return
+	}
+	writeJSON(w, out)
+}
+
+// call runs one request. ok is false when no response must be sent (notification).
+func (s *Server) call(ctx context.Context, raw []byte) (resp *response, ok bool) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if !json.Valid(raw) {
+			return errorResponse(nil, &Error{Code: CodeParseError, Message: "Parse error"}), true
+		}
+		return errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}), true
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" || !validID(req.ID) || !validParams(req.Params) {
+		return errorResponse(idOrNull(req.ID), &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}), true
+	}
+	notification := req.ID == nil
+
+	h, found := s.methods[req.Method]
+	if !found {
+		if notification {
+			return nil, false
+		}
+		return errorResponse(req.ID, &Error{Code: CodeMethodNotFound, Message: "Method not found"}), true
+	}
+
+	result, err := s.run(ctx, h, req)
+	if notification {
+		return nil, false
+	}
+	if err != nil {
+		return errorResponse(req.ID, err), true
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", Result: result, ID: req.ID}, true
+}
+
+// run calls h and turns panics and plain errors into *Error values.
+func (s *Server) run(ctx context.Context, h HandlerFunc, req request) (result any, rpcErr *Error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("jsonrpc: panic in %s: %v", req.Method, p)
+			result, rpcErr = nil, &Error{Code: CodeInternalError, Message: "Internal error"}
+		}
+	}()
+
+	result, err := h(ctx, req.Params)
+	if err == nil {
+		return result, nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return nil, e
+	}
+	log.Printf("jsonrpc: %s: %v", req.Method, err)
+	return nil, &Error{Code: CodeInternalError, Message: "Internal error"}
+}
+
+// --- HELPERS ---
+
+// validID accepts a missing id, a string, a number or null.
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// validParams accepts missing params, an object or an array.
+func validParams(p json.RawMessage) bool {
+	return p == nil || p[0] == '{' || p[0] == '['
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil || !validID(id) {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func errorResponse(id json.RawMessage, err *Error) *response {
+	return &response{JSONRPC: "2.0", Error: err, ID: idOrNull(id)}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// --- PARAMS DECODING ---
+
+// DecodeParams decodes params into v. Unknown fields are rejected.
+// It returns a CodeInvalidParams error on failure.
+func DecodeParams(params json.RawMessage, v any) error {
+	if params == nil {
+		return InvalidParams("Missing params", nil)
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return InvalidParams("Invalid params", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer() *Server {
+	s := NewServer()
+	s.Register("sum", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var nums []int
+		if err := DecodeParams(params, &nums); err != nil {
+			return nil, err
+		}
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total, nil
+	})
+	s.Register("fail", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("secret internal detail")
+	})
+	s.Register("panic", func(ctx context.Context, params json.RawMessage) (any, error) {
+		panic("boom")
+	})
+	return s
+}
+
+func post(s *Server, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+	return rec
+}
+
+func TestSingleCalls(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name, body, want string
+	}{
+		{"result", `{"jsonrpc":"2.0","method":"sum","params":[1,2,3],"id":1}`,
+			`{"jsonrpc":"2.0","result":6,"id":1}`},
+		{"string id", `{"jsonrpc":"2.0","method":"sum","params":[],"id":"abc"}`,
+			`{"jsonrpc":"2.0","result":0,"id":"abc"}`},
+		{"parse error", `{"jsonrpc":"2.0","method":"sum",`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"invalid request", `{"jsonrpc":"1.0","method":"sum","id":1}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"nope","id":2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2}`},
+		{"internal error hides details", `{"jsonrpc":"2.0","method":"fail","id":3}`,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":3}`},
+		{"panic", `{"jsonrpc":"2.0","method":"panic","id":4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":4}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.TrimSpace(post(s, tt.body).Body.String())
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	rec := post(newTestServer(), `{"jsonrpc":"2.0","method":"sum","params":{"a":1},"id":1}`)
+
+	var resp struct {
+		Error Error `json:"error"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Error.Code != CodeInvalidParams || resp.Error.Data == nil {
+		t.Errorf("error = %+v; want invalid params with details", resp.Error)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	s := newTestServer()
+
+	rec := post(s, `{"jsonrpc":"2.0","method":"sum","params":[1]}`)
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("notification: status %d, body %q; want 204 and no body", rec.Code, rec.Body.String())
+	}
+
+	// Even failing notifications get no answer.
+	rec = post(s, `[{"jsonrpc":"2.0","method":"nope"},{"jsonrpc":"2.0","method":"fail"}]`)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("batch of notifications: status %d; want 204", rec.Code)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	body := `[
+		{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":"a"},
+		{"jsonrpc":"2.0","method":"sum","params":[5]},
+		{"jsonrpc":"2.0","method":"nope","id":"b"},
+		1
+	]`
+	rec := post(newTestServer(), body)
+
+	var resps []struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resps); err != nil {
+		t.Fatalf("decoding batch response: %v", err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("got %d responses; want 3 (notification has none)", len(resps))
+	}
+	if string(resps[0].Result) != "3" || string(resps[0].ID) != `"a"` {
+		t.Errorf("first response = %s / %s", resps[0].Result, resps[0].ID)
+	}
+	if resps[1].Error == nil || resps[1].Error.Code != CodeMethodNotFound {
+		t.Errorf("second response error = %+v; want method not found", resps[1].Error)
+	}
+	if resps[2].Error == nil || resps[2].Error.Code != CodeInvalidRequest {
+		t.Errorf("third response error = %+v; want invalid request", resps[2].Error)
+	}
+}
+
+func TestEmptyBatch(t *testing.T) {
+	got := strings.TrimSpace(post(newTestServer(), `[]`).Body.String())
+	want := `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Empty batch"},"id":null}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,489 @@
+// Package kv is an embedded key-value store in the style of Bitcask.
+//
+// Writes are appended to the active data file as CRC-checked records and
+// an in-memory key directory maps every live key to the place of its
+// latest value, so a Get is one disk read. When the active file is full a
+// new one is started. Merge rewrites the older files with only the live
+// values, and writes a hint file next to each result so the next Open
+// reads the keys without the values.
+//
+// Opening a directory replays its files to rebuild the key directory. A
+// record cut short by a crash is dropped, and the file is truncated there.
+// A directory must be opened by one DB at a time.
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxKeySize   = 1 << 16
+	maxValueSize = 1 << 30
+)
+
+var (
+	// ErrNotFound is returned by Get for missing keys.
+	ErrNotFound = errors.New("kv: key not found")
+	// ErrCorrupt is returned for records that fail their CRC check.
+	ErrCorrupt = errors.New("kv: corrupt record")
+	// ErrClosed is returned after Close.
+	ErrClosed = errors.New("kv: database closed")
+	// ErrKeyTooLarge is returned for keys or values over the size limits.
+	ErrKeyTooLarge = errors.New("kv: key or value too large")
+)
+
+// SyncPolicy says when writes are flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs after every write. No acknowledged write is lost.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval syncs every Options.SyncEvery. A crash loses at most
+	// that much of the latest writes.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// Options tune a DB. The zero value is usable.
+type Options struct {
+	// MaxFileSize is the size at which the active file is closed (default 64 MiB).
+	MaxFileSize int64
+	Sync        SyncPolicy
+	// SyncEvery is the period of SyncInterval (default 1s).
+	SyncEvery time.Duration
+	// MergeEvery starts a background Merge this often, when at least
+	// MergeRatio of the older files is dead (0 never merges by itself).
+	MergeEvery time.Duration
+	// MergeRatio is the dead fraction that makes a merge worth it (default 0.5).
+	MergeRatio float64
+}
+
+// location is where the latest value of a key is.
+type location struct {
+	fileID uint32
+	offset int64 // of the record
+	vsize  uint32
+	seq    uint64
+}
+
+func (l location) recordSize(key string) int64 {
+	return headerSize + int64(len(key)) + int64(l.vsize)
+}
+
+// DB is an open database. It is safe for concurrent use.
+type DB struct {
+	dir  string
+	opts Options
+
+	mu       sync.RWMutex
+	keydir   map[string]location
+	files    map[uint32]*os.File // open for reading, including the active one
+	active   *os.File
+	activeID uint32
+	lastID   uint32 // highest file ID in use
+	size     int64  // of the active file
+	seq      uint64 // of the last write
+	total    int64  // bytes in the older files
+	dead     int64  // bytes of overwritten or deleted records in the older files
+	closed   bool
+
+	mergeMu sync.Mutex // one merge at a time
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens or creates the database in dir.
+func Open(dir string, opts Options) (*DB, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = 64 << 20
+	}
+	if opts.SyncEvery <= 0 {
+		opts.SyncEvery = time.Second
+	}
+	if opts.MergeRatio <= 0 {
+		opts.MergeRatio = 0.5
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		dir:    dir,
+		opts:   opts,
+		keydir: make(map[string]location),
+		files:  make(map[uint32]*os.File),
+		stop:   make(chan struct{}),
+	}
+	if err := db.load(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+	if err := db.rotate(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		db.every(opts.SyncEvery, func() {
+			if err := db.Sync(); err != nil && !errors.Is(err, ErrClosed) {
+				log.Printf("kv: sync: %v", err)
+			}
+		})
+	}
+	if opts.MergeEvery > 0 {
+		db.every(opts.MergeEvery, func() {
+			if db.DeadRatio() < opts.MergeRatio {
+				return
+			}
+			if err := db.Merge(); err != nil && !errors.Is(err, ErrClosed) {
+				log.Printf("kv: merge: %v", err)
+			}
+		})
+	}
+	return db, nil
+}
+
+// load rebuilds the key directory from the files in dir.
+func (db *DB) load() error {
+	ids, err := dataFiles(db.dir)
+	if err != nil {
+		return err
+	}
+	// Leftovers of a merge that did not finish; the files it read are
+	// still there.
+	if tmps, err := filepath.Glob(filepath.Join(db.dir, "*.data.tmp")); err == nil {
+		for _, p := range tmps {
+			os.Remove(p)
+		}
+	}
+	// A crash can only cut short the file that was active, which is the
+	// newest one without hints: merged files get theirs before their name.
+	var active uint32
+	for _, id := range ids {
+		if _, err := os.Stat(hintPath(db.dir, id)); errors.Is(err, fs.ErrNotExist) {
+			active = id
+		}
+	}
+	sizes := make(map[uint32]int64)
+	for _, id := range ids {
+		f, err := os.Open(dataPath(db.dir, id))
+		if err != nil {
+			return err
+		}
+		db.files[id] = f
+		db.lastID = id
+
+		if hints, err := readHints(hintPath(db.dir, id)); err == nil {
+			for _, h := range hints {
+				db.index(h.key, location{fileID: id, offset: h.offset, vsize: h.vsize, seq: h.seq}, false)
+			}
+			st, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			sizes[id] = st.Size()
+			continue
+		}
+
+		valid, err := scanFile(dataPath(db.dir, id), func(rec record, offset int64) {
+			loc := location{fileID: id, offset: offset, vsize: uint32(len(rec.value)), seq: rec.seq}
+			db.index(rec.key, loc, rec.deleted)
+		})
+		if err != nil && !errors.Is(err, errTorn) && !errors.Is(err, ErrCorrupt) {
+			return err
+		}
+		if err != nil {
+			if id != active {
+				return fmt.Errorf("%s at offset %d: %w", dataPath(db.dir, id), valid, ErrCorrupt)
+			}
+			log.Printf("kv: dropping the damaged tail of %s after offset %d", dataPath(db.dir, id), valid)
+			if err := os.Truncate(dataPath(db.dir, id), valid); err != nil {
+				return err
+			}
+		}
+		sizes[id] = valid
+	}
+
+	for k, loc := range db.keydir {
+		if loc.vsize == tombstone {
+			delete(db.keydir, k)
+		}
+	}
+	for _, s := range sizes {
+		db.total += s
+	}
+	live := int64(0)
+	for k, loc := range db.keydir {
+		live += loc.recordSize(k)
+	}
+	db.dead = db.total - live
+	return nil
+}
+
+// index puts loc in the key directory unless a newer write is there.
+// Records are seen in any order, so seq decides. Deletes remove the key.
+func (db *DB) index(key string, loc location, deleted bool) {
+	if loc.seq > db.seq {
+		db.seq = loc.seq
+	}
+	if cur, ok := db.keydir[key]; ok && cur.seq >= loc.seq {
+		return
+	}
+	if deleted {
+		// Keep the delete until loading ends, so an older value seen
+		// later does not bring the key back.
+		loc.vsize = tombstone
+	}
+	db.keydir[key] = loc
+}
+
+// --- READS ---
+
+// Get returns the value of key.
+func (db *DB) Get(key string) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	loc, ok := db.keydir[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return db.readValue(key, loc)
+}
+
+// readValue reads and checks the record at loc. The caller must hold db.mu.
+func (db *DB) readValue(key string, loc location) ([]byte, error) {
+	buf := make([]byte, loc.recordSize(key))
+	if _, err := db.files[loc.fileID].ReadAt(buf, loc.offset); err != nil {
+		return nil, fmt.Errorf("kv: read %q: %w", key, err)
+	}
+	rec, err := readRecord(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("kv: read %q: %w", key, err)
+	}
+	return rec.value, nil
+}
+
+// Scan calls fn with every key that starts with prefix and its value, in
+// key order, until fn returns false. Keys added meanwhile are not visited.
+func (db *DB) Scan(prefix string, fn func(key string, value []byte) bool) error {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return ErrClosed
+	}
+	var keys []string
+	for k := range db.keydir {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	db.mu.RUnlock()
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		v, err := db.Get(k)
+		if errors.Is(err, ErrNotFound) {
+			continue // deleted since
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(k, v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Len returns the number of live keys.
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return len(db.keydir)
+}
+
+// --- WRITES ---
+
+// Put sets the value of key.
+func (db *DB) Put(key string, value []byte) error {
+	if len(key) > maxKeySize || len(value) > maxValueSize {
+		return ErrKeyTooLarge
+	}
+	return db.write(record{key: key, value: value})
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (db *DB) Delete(key string) error {
+	db.mu.RLock()
+	_, ok := db.keydir[key]
+	db.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return db.write(record{key: key, deleted: true})
+}
+
+func (db *DB) write(rec record) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	if db.size+rec.size() > db.opts.MaxFileSize && db.size > 0 {
+		if err := db.rotate(); err != nil {
+			return err
+		}
+	}
+	db.seq++
+	rec.seq = db.seq
+	if _, err := db.active.Write(encodeRecord(rec)); err != nil {
+		return err
+	}
+	if db.opts.Sync == SyncAlways {
+		if err := db.active.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if old, ok := db.keydir[rec.key]; ok {
+		db.addDead(old.fileID, old.recordSize(rec.key))
+	}
+	loc := location{fileID: db.activeID, offset: db.size, vsize: uint32(len(rec.value)), seq: rec.seq}
+	db.size += rec.size()
+	if rec.deleted {
+		delete(db.keydir, rec.key)
+	} else {
+		db.keydir[rec.key] = loc
+	}
+	return nil
+}
+
+// addDead counts garbage in file id. The active file is counted when it
+// becomes an older file. The caller must hold db.mu.
+func (db *DB) addDead(id uint32, n int64) {
+	if id != db.activeID {
+		db.dead += n
+	}
+}
+
+// rotate closes the active file and starts a new one. The caller must
+// hold db.mu (or be Open).
+func (db *DB) rotate() error {
+	if db.active != nil {
+		if err := db.active.Sync(); err != nil {
+			return err
+		}
+		if err := db.active.Close(); err != nil {
+			return err
+		}
+		// Count the closed file's garbage now that it is an older file.
+		live := int64(0)
+		for k, loc := range db.keydir {
+			if loc.fileID == db.activeID {
+				live += loc.recordSize(k)
+			}
+		}
+		db.total += db.size
+		db.dead += db.size - live
+	}
+
+	db.lastID++
+	id := db.lastID
+	f, err := os.OpenFile(dataPath(db.dir, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(dataPath(db.dir, id))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	db.active, db.activeID, db.size = f, id, 0
+	db.files[id] = r
+	return nil
+}
+
+// Sync flushes the active file to stable storage.
+func (db *DB) Sync() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return db.active.Sync()
+}
+
+// DeadRatio is the fraction of the older files taken by dead records.
+func (db *DB) DeadRatio() float64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.total == 0 {
+		return 0
+	}
+	return float64(db.dead) / float64(db.total)
+}
+
+// Close stops the background work, syncs and closes the files.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	db.closed = true
+	db.mu.Unlock()
+
+	close(db.stop)
+	db.wg.Wait()
+	db.mergeMu.Lock() // wait for a Merge called by the user
+	defer db.mergeMu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return errors.Join(db.active.Sync(), db.closeFiles())
+}
+
+func (db *DB) closeFiles() error {
+	var errs []error
+	if db.active != nil {
+		errs = append(errs, db.active.Close())
+	}
+	for _, f := range db.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// every runs fn every d until Close.
+func (db *DB) every(d time.Duration, fn func()) {
+	db.wg.Add(1)
+	go func() {
+		defer db.wg.Done()
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				fn()
+			case <-db.stop:
+				return
+			}
+		}
+	}()
+}
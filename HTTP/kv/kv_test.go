@@ -0,0 +1,316 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func open(t *testing.T, dir string, opts Options) *DB {
+	t.Helper()
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func mustGet(t *testing.T, db *DB, key, want string) {
+	t.Helper()
+	v, err := db.Get(key)
+	if err != nil || string(v) != want {
+		t.Errorf("Get(%q) = %q, %v; want %q", key, v, err, want)
+	}
+}
+
+func TestPutGetDeleteScan(t *testing.T) {
+	db := open(t, t.TempDir(), Options{})
+	defer db.Close()
+
+	db.Put("user/2", []byte("bob"))
+	db.Put("user/1", []byte("alice"))
+	db.Put("post/1", []byte("hello"))
+	db.Put("user/1", []byte("alice v2"))
+	mustGet(t, db, "user/1", "alice v2")
+
+	if err := db.Delete("user/2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get("user/2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v", err)
+	}
+	if err := db.Delete("missing"); err != nil {
+		t.Errorf("Delete(missing) = %v", err)
+	}
+
+	db.Put("user/3", []byte("carol"))
+	var got []string
+	db.Scan("user/", func(k string, v []byte) bool {
+		got = append(got, k+"="+string(v))
+		return true
+	})
+	if fmt.Sprint(got) != "[user/1=alice v2 user/3=carol]" {
+		t.Errorf("Scan = %v", got)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d; want 3", db.Len())
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{MaxFileSize: 128, Sync: SyncNever})
+	for i := range 50 {
+		db.Put(fmt.Sprint("k", i%10), []byte(fmt.Sprint("v", i)))
+	}
+	db.Delete("k3")
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := dataFiles(dir); len(ids) < 5 {
+		t.Fatalf("%d data files; want rotation at 128 bytes", len(ids))
+	}
+
+	db = open(t, dir, Options{})
+	defer db.Close()
+	mustGet(t, db, "k0", "v40")
+	mustGet(t, db, "k9", "v49")
+	if _, err := db.Get("k3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted key came back: err = %v", err)
+	}
+	// New writes continue after the recovered ones.
+	db.Put("k0", []byte("new"))
+	mustGet(t, db, "k0", "new")
+}
+
+func TestTornTailIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{})
+	db.Put("a", []byte("1"))
+	db.Put("b", []byte("2"))
+	db.Close()
+
+	// Simulate a crash halfway through writing a record.
+	ids, _ := dataFiles(dir)
+	last := dataPath(dir, ids[len(ids)-1])
+	whole := encodeRecord(record{seq: 99, key: "c", value: []byte("333")})
+	f, _ := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write(whole[:len(whole)-2])
+	f.Close()
+
+	db = open(t, dir, Options{})
+	mustGet(t, db, "a", "1")
+	mustGet(t, db, "b", "2")
+	if _, err := db.Get("c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("torn record was loaded")
+	}
+	db.Put("d", []byte("4"))
+	db.Close()
+
+	db = open(t, dir, Options{})
+	defer db.Close()
+	mustGet(t, db, "d", "4")
+}
+
+func TestTornTailAfterMerge(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{})
+	db.Put("a", []byte("1"))
+	db.Put("a", []byte("2"))
+	if err := db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	db.Put("b", []byte("3"))
+	active := dataPath(dir, db.activeID)
+	db.Close()
+
+	// The merged file has a higher ID than the active one, which is the
+	// file a crash cuts short.
+	f, _ := os.OpenFile(active, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	db = open(t, dir, Options{})
+	defer db.Close()
+	mustGet(t, db, "a", "2")
+	mustGet(t, db, "b", "3")
+}
+
+func TestUnfinishedMergeIsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{})
+	db.Put("a", []byte("1"))
+	db.Close()
+	os.WriteFile(tmpPath(dir, 99), []byte("half a merge"), 0o600)
+
+	db = open(t, dir, Options{})
+	defer db.Close()
+	mustGet(t, db, "a", "1")
+	if _, err := os.Stat(tmpPath(dir, 99)); !os.IsNotExist(err) {
+		t.Errorf("leftover merge file kept: %v", err)
+	}
+}
+
+func TestCorruptOlderFileFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{MaxFileSize: 64})
+	for i := range 10 {
+		db.Put(fmt.Sprint("key", i), []byte("some value"))
+	}
+	db.Close()
+
+	ids, _ := dataFiles(dir)
+	path := dataPath(dir, ids[0])
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(path, data, 0o600)
+
+	if _, err := Open(dir, Options{}); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Open = %v; want ErrCorrupt", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{MaxFileSize: 256, Sync: SyncNever})
+	for i := range 200 {
+		db.Put(fmt.Sprint("k", i%20), []byte(fmt.Sprint("value-", i)))
+	}
+	for i := range 5 {
+		db.Delete(fmt.Sprint("k", i))
+	}
+	before, _ := dataFiles(dir)
+	if db.DeadRatio() < 0.5 {
+		t.Errorf("DeadRatio() = %.2f; want most of the data dead", db.DeadRatio())
+	}
+
+	if err := db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := dataFiles(dir)
+	if len(after) >= len(before) {
+		t.Errorf("merge left %d files; had %d", len(after), len(before))
+	}
+	if db.DeadRatio() != 0 {
+		t.Errorf("DeadRatio() after merge = %.2f", db.DeadRatio())
+	}
+	mustGet(t, db, "k19", "value-199")
+	if _, err := db.Get("k0"); !errors.Is(err, ErrNotFound) {
+		t.Error("deleted key came back after merge")
+	}
+	db.Close()
+
+	hints, _ := filepath.Glob(filepath.Join(dir, "*.hint"))
+	if len(hints) == 0 {
+		t.Fatal("merge wrote no hint files")
+	}
+	db = open(t, dir, Options{})
+	defer db.Close()
+	if db.Len() != 15 {
+		t.Errorf("Len() after reopen = %d; want 15", db.Len())
+	}
+	mustGet(t, db, "k5", "value-185")
+	if _, err := db.Get("k0"); !errors.Is(err, ErrNotFound) {
+		t.Error("deleted key came back after reopen")
+	}
+}
+
+func TestCrashDuringMergeRemovals(t *testing.T) {
+	defer func() { removeFile = os.Remove }()
+	for stop := 1; stop < 6; stop++ {
+		// "a" is in the first file and its tombstone in the sixth.
+		dir := t.TempDir()
+		db := open(t, dir, Options{MaxFileSize: 1, Sync: SyncNever})
+		db.Put("a", []byte("1"))
+		for i := range 4 {
+			db.Put(fmt.Sprint("k", i), []byte("v"))
+		}
+		db.Delete("a")
+
+		// Crash after stop data files are removed.
+		removed := 0
+		removeFile = func(path string) error {
+			if filepath.Ext(path) == ".data" {
+				if removed == stop {
+					return errors.New("crash")
+				}
+				removed++
+			}
+			return os.Remove(path)
+		}
+		if err := db.Merge(); err == nil {
+			t.Fatal("Merge did not stop")
+		}
+		removeFile = os.Remove
+		db.Close()
+
+		db = open(t, dir, Options{})
+		if _, err := db.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("crash after %d removals: deleted key came back", stop)
+		}
+		mustGet(t, db, "k3", "v")
+		db.Close()
+	}
+}
+
+func TestBadHintFallsBackToData(t *testing.T) {
+	dir := t.TempDir()
+	db := open(t, dir, Options{})
+	db.Put("a", []byte("1"))
+	db.Put("a", []byte("2"))
+	db.Merge()
+	db.Close()
+
+	hints, _ := filepath.Glob(filepath.Join(dir, "*.hint"))
+	os.WriteFile(hints[0], []byte("garbage"), 0o600)
+
+	db = open(t, dir, Options{})
+	defer db.Close()
+	mustGet(t, db, "a", "2")
+}
+
+func TestMergeWhileWriting(t *testing.T) {
+	db := open(t, t.TempDir(), Options{MaxFileSize: 512, Sync: SyncNever})
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 300 {
+				key := fmt.Sprint("w", w, "-", i%30)
+				if err := db.Put(key, []byte(fmt.Sprint(i))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for range 5 {
+		if err := db.Merge(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	for w := range 4 {
+		for k := range 30 {
+			mustGet(t, db, fmt.Sprint("w", w, "-", k), fmt.Sprint(270+k))
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	db := open(t, t.TempDir(), Options{Sync: SyncInterval, MergeEvery: 1000000})
+	db.Close()
+	if err := db.Put("a", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Put after Close: %v", err)
+	}
+	if err := db.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package kv
+
+import (
+	"cmp"
+	"os"
+	"slices"
+)
+
+// --- MERGE ---
+
+// removeFile is os.Remove; tests replace it to stop a merge partway.
+var removeFile = os.Remove
+
+type liveKey struct {
+	key string
+	loc location
+}
+
+// Merge rewrites every file but the active one with only the live values,
+// writes a hint file for each new file and removes the old ones. Reads and
+// writes go on while the values are copied.
+func (db *DB) Merge() error {
+	db.mergeMu.Lock()
+	defer db.mergeMu.Unlock()
+
+	// Start a new active file, so everything before it is immutable.
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	if db.size > 0 {
+		if err := db.rotate(); err != nil {
+			db.mu.Unlock()
+			return err
+		}
+	}
+	var inputs []uint32
+	for id := range db.files {
+		if id != db.activeID {
+			inputs = append(inputs, id)
+		}
+	}
+	var live []liveKey
+	for k, loc := range db.keydir {
+		if loc.fileID != db.activeID {
+			live = append(live, liveKey{k, loc})
+		}
+	}
+	db.mu.Unlock()
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	// Read in file order, which is mostly sequential on disk.
+	slices.SortFunc(live, func(a, b liveKey) int {
+		if a.loc.fileID != b.loc.fileID {
+			return cmp.Compare(a.loc.fileID, b.loc.fileID)
+		}
+		return cmp.Compare(a.loc.offset, b.loc.offset)
+	})
+	w := &mergeWriter{db: db}
+	moved := make(map[string]location, len(live))
+	for _, lk := range live {
+		db.mu.RLock()
+		value, err := db.readValue(lk.key, lk.loc)
+		db.mu.RUnlock()
+		if err != nil {
+			w.abort()
+			return err
+		}
+		loc, err := w.write(record{seq: lk.loc.seq, key: lk.key, value: value})
+		if err != nil {
+			w.abort()
+			return err
+		}
+		moved[lk.key] = loc
+	}
+	if err := w.finish(); err != nil {
+		w.abort()
+		return err
+	}
+
+	// Point the keys that did not change meanwhile to their new place.
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed // the new files are complete; the next Open sorts it out by seq
+	}
+	for k, loc := range moved {
+		if cur, ok := db.keydir[k]; ok && cur.seq == loc.seq && cur.fileID != loc.fileID {
+			db.keydir[k] = loc
+		}
+	}
+	for _, f := range w.done {
+		r, err := os.Open(dataPath(db.dir, f.id))
+		if err != nil {
+			return err
+		}
+		db.files[f.id] = r
+	}
+	// Oldest first: a tombstone must outlive the values it hides, or a
+	// crash in between brings a deleted key back.
+	slices.Sort(inputs)
+	for _, id := range inputs {
+		db.files[id].Close()
+		delete(db.files, id)
+		removeFile(hintPath(db.dir, id)) // before the data, so no hint outlives its file
+		if err := removeFile(dataPath(db.dir, id)); err != nil {
+			return err
+		}
+	}
+
+	db.total, db.dead = 0, 0
+	for _, f := range w.done {
+		db.total += f.size
+	}
+	liveBytes := int64(0)
+	for k, loc := range db.keydir {
+		if loc.fileID != db.activeID {
+			liveBytes += loc.recordSize(k)
+		}
+	}
+	db.dead = db.total - liveBytes
+	return nil
+}
+
+// mergeWriter writes the merged records to new files of at most
+// MaxFileSize, each with its hint file.
+type mergeWriter struct {
+	db    *DB
+	file  *os.File
+	id    uint32
+	size  int64
+	hints []hint
+	done  []mergedFile
+}
+
+type mergedFile struct {
+	id   uint32
+	size int64
+}
+
+func (w *mergeWriter) write(rec record) (location, error) {
+	if w.file != nil && w.size+rec.size() > w.db.opts.MaxFileSize {
+		if err := w.finish(); err != nil {
+			return location{}, err
+		}
+	}
+	if w.file == nil {
+		w.db.mu.Lock()
+		w.db.lastID++
+		w.id = w.db.lastID
+		w.db.mu.Unlock()
+		f, err := os.OpenFile(tmpPath(w.db.dir, w.id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return location{}, err
+		}
+		w.file, w.size, w.hints = f, 0, nil
+	}
+
+	if _, err := w.file.Write(encodeRecord(rec)); err != nil {
+		return location{}, err
+	}
+	loc := location{fileID: w.id, offset: w.size, vsize: uint32(len(rec.value)), seq: rec.seq}
+	w.hints = append(w.hints, hint{seq: rec.seq, key: rec.key, vsize: loc.vsize, offset: loc.offset})
+	w.size += rec.size()
+	return loc, nil
+}
+
+// finish syncs and closes the current file, writes its hints and only
+// then gives it its data file name. So every merged data file has a hint
+// file, and a data file without one can only be an active file, which
+// load may find cut short by a crash.
+func (w *mergeWriter) finish() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	if err == nil {
+		err = writeHints(hintPath(w.db.dir, w.id), w.hints)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath(w.db.dir, w.id), dataPath(w.db.dir, w.id))
+	}
+	if err != nil {
+		os.Remove(hintPath(w.db.dir, w.id))
+		os.Remove(tmpPath(w.db.dir, w.id))
+		return err
+	}
+	w.done = append(w.done, mergedFile{id: w.id, size: w.size})
+	return nil
+}
+
+// abort removes the files of a failed merge.
+func (w *mergeWriter) abort() {
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(tmpPath(w.db.dir, w.id))
+	}
+	for _, f := range w.done {
+		os.Remove(hintPath(w.db.dir, f.id))
+		os.Remove(dataPath(w.db.dir, f.id))
+	}
+}
@@ -0,0 +1,231 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --- RECORDS ---
+//
+// A data file is a sequence of records:
+//
+//	crc32 (4) | seq (8) | key size (4) | value size (4) | key | value
+//
+// The CRC covers everything after it. seq orders the writes of the whole
+// database, so recovery does not depend on file order, and a value size of
+// tombstone marks a delete.
+
+const (
+	headerSize = 4 + 8 + 4 + 4
+	tombstone  = math.MaxUint32
+)
+
+type record struct {
+	seq     uint64
+	key     string
+	value   []byte
+	deleted bool
+}
+
+func (r record) size() int64 {
+	return headerSize + int64(len(r.key)) + int64(len(r.value))
+}
+
+func encodeRecord(r record) []byte {
+	buf := make([]byte, r.size())
+	binary.LittleEndian.PutUint64(buf[4:], r.seq)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(r.key)))
+	vsize := uint32(len(r.value))
+	if r.deleted {
+		vsize = tombstone
+	}
+	binary.LittleEndian.PutUint32(buf[16:], vsize)
+	copy(buf[headerSize:], r.key)
+	copy(buf[headerSize+len(r.key):], r.value)
+	binary.LittleEndian.PutUint32(buf[0:], crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// errTorn is returned for a record cut short by a crash.
+var errTorn = errors.New("kv: torn record")
+
+// readRecord reads the record at the reader position.
+func readRecord(r io.Reader) (record, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return record{}, errTorn
+		}
+		return record{}, err // io.EOF at a record boundary
+	}
+	rec := record{seq: binary.LittleEndian.Uint64(hdr[4:])}
+	ksize := binary.LittleEndian.Uint32(hdr[12:])
+	vsize := binary.LittleEndian.Uint32(hdr[16:])
+	if vsize == tombstone {
+		rec.deleted, vsize = true, 0
+	}
+	if ksize > maxKeySize || vsize > maxValueSize {
+		return record{}, ErrCorrupt
+	}
+	body := make([]byte, int(ksize)+int(vsize))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return record{}, errTorn
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(body)
+	if crc.Sum32() != binary.LittleEndian.Uint32(hdr[:4]) {
+		return record{}, ErrCorrupt
+	}
+	rec.key, rec.value = string(body[:ksize]), body[ksize:]
+	return rec, nil
+}
+
+// --- FILES ---
+
+func dataPath(dir string, id uint32) string { return filepath.Join(dir, fmt.Sprintf("%09d.data", id)) }
+func hintPath(dir string, id uint32) string { return filepath.Join(dir, fmt.Sprintf("%09d.hint", id)) }
+
+// tmpPath is where a merge writes a data file until it is complete.
+func tmpPath(dir string, id uint32) string { return dataPath(dir, id) + ".tmp" }
+
+// dataFiles returns the IDs of the data files in dir, in order.
+func dataFiles(dir string) ([]uint32, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.data"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint32
+	for _, p := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(p), ".data"), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// scanFile calls fn with every record of a data file and its offset. It
+// returns the size of the valid part: a torn or corrupt tail ends the scan.
+func scanFile(path string, fn func(rec record, offset int64)) (valid int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReaderSize(f, 64<<10)
+	for {
+		rec, err := readRecord(br)
+		switch {
+		case errors.Is(err, io.EOF):
+			return valid, nil
+		case errors.Is(err, errTorn), errors.Is(err, ErrCorrupt):
+			return valid, err
+		case err != nil:
+			return valid, err
+		}
+		fn(rec, valid)
+		valid += rec.size()
+	}
+}
+
+// --- HINT FILES ---
+//
+// A hint file lists the keys of a merged data file, so opening the
+// database does not read the values:
+//
+//	seq (8) | key size (4) | value size (4) | record offset (8) | key
+//
+// It ends with the CRC-32 of everything before it, and is ignored (the
+// data file is scanned instead) when that does not match.
+
+const hintHeaderSize = 8 + 4 + 4 + 8
+
+type hint struct {
+	seq    uint64
+	key    string
+	vsize  uint32
+	offset int64
+}
+
+func writeHints(path string, hints []hint) error {
+	var buf []byte
+	for _, h := range hints {
+		var hdr [hintHeaderSize]byte
+		binary.LittleEndian.PutUint64(hdr[0:], h.seq)
+		binary.LittleEndian.PutUint32(hdr[8:], uint32(len(h.key)))
+		binary.LittleEndian.PutUint32(hdr[12:], h.vsize)
+		binary.LittleEndian.PutUint64(hdr[16:], uint64(h.offset))
+		buf = append(append(buf, hdr[:]...), h.key...)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return writeFileSync(path, buf)
+}
+
+func readHints(path string) ([]hint, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 4 {
+		return nil, ErrCorrupt
+	}
+	body := buf[:len(buf)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(buf[len(buf)-4:]) {
+		return nil, ErrCorrupt
+	}
+	var hints []hint
+	for len(body) > 0 {
+		if len(body) < hintHeaderSize {
+			return nil, ErrCorrupt
+		}
+		h := hint{
+			seq:    binary.LittleEndian.Uint64(body[0:]),
+			vsize:  binary.LittleEndian.Uint32(body[12:]),
+			offset: int64(binary.LittleEndian.Uint64(body[16:])),
+		}
+		ksize := int(binary.LittleEndian.Uint32(body[8:]))
+		body = body[hintHeaderSize:]
+		if len(body) < ksize {
+			return nil, ErrCorrupt
+		}
+		h.key, body = string(body[:ksize]), body[ksize:]
+		hints = append(hints, h)
+	}
+	return hints, nil
+}
+
+// writeFileSync writes data to path through a synced temporary file, so
+// path is either complete or absent.
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,134 @@
+package loadgen
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// --- HDR-STYLE LATENCY HISTOGRAM ---
+//
+// Values are recorded in microseconds into log-linear buckets: every power
+// of two is split into the same number of linear sub-buckets, so the
+// relative error stays below 1/subHalf (about 0.1%) from 1µs to hours while
+// memory stays small. Percentiles report the upper bound of their bucket,
+// like HdrHistogram's "highest equivalent value".
+
+const (
+	subBits  = 11
+	subCount = 1 << subBits // 2048
+	subHalf  = subCount / 2
+)
+
+// Histogram records latencies. It is not safe for concurrent use; give each
+// worker its own and Merge them.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    int64 // µs
+	min    int64
+	max    int64
+}
+
+// NewHistogram returns an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+func bucketIndex(v int64) int {
+	if v < subCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBits
+	return (shift+1)*subHalf + int(v>>shift) - subHalf
+}
+
+// bucketUpper returns the largest value that falls into bucket i.
+func bucketUpper(i int) int64 {
+	if i < subCount {
+		return int64(i)
+	}
+	shift := i/subHalf - 1
+	sub := int64(i - shift*subHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds one latency. Negative values count as zero.
+func (h *Histogram) Record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		grown := make([]int64, i+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += v
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+}
+
+// Merge adds every value of other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		grown := make([]int64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 { return h.total }
+
+// Min returns the smallest recorded value (exact).
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Max returns the largest recorded value (exact).
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max) * time.Microsecond }
+
+// Mean returns the average of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/h.total) * time.Microsecond
+}
+
+// Percentile returns the value below which p percent (0-100) of the
+// recorded values fall.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	rank = max(1, min(rank, h.total))
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// Never report more than the exact maximum.
+			return time.Duration(min(bucketUpper(i), h.max)) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
@@ -0,0 +1,177 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 5000 * time.Microsecond},
+		{90, 9000 * time.Microsecond},
+		{99, 9900 * time.Microsecond},
+		{100, 10000 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		got := h.Percentile(tt.p)
+		// Buckets above 2048µs are wider than 1µs; the error must stay under 0.1%.
+		if diff := got - tt.want; diff < 0 || float64(diff) > float64(tt.want)*0.001 {
+			t.Errorf("p%v = %v; want %v (within 0.1%%)", tt.p, got, tt.want)
+		}
+	}
+	if h.Max() != 10*time.Millisecond || h.Min() != time.Microsecond {
+		t.Errorf("min/max = %v/%v", h.Min(), h.Max())
+	}
+	if h.Count() != 10000 {
+		t.Errorf("count = %d", h.Count())
+	}
+}
+
+func TestHistogramBucketsRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 1 << 20, 3_600_000_000} {
+		i := bucketIndex(v)
+		if up := bucketUpper(i); up < v || float64(up-v) > float64(v)/subHalf {
+			t.Errorf("value %d: bucket %d upper bound %d", v, i, up)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(time.Second)
+	a.Merge(b)
+	a.Merge(NewHistogram())
+
+	if a.Count() != 2 || a.Max() != time.Second || a.Min() != time.Millisecond {
+		t.Errorf("merged: count %d min %v max %v", a.Count(), a.Min(), a.Max())
+	}
+}
+
+func TestRunClosedLoop(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		BaseURL:     srv.URL,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 4,
+		Scenario: &Scenario{Requests: []RequestSpec{
+			{Name: "get", Path: "/users/{{randInt 1 2}}"},
+			{Name: "post", Method: "post", Path: "/users", Body: `{"seq": {{.Seq}}}`},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Requests == 0 || rep.Requests != rep.Succeeded+rep.Failed {
+		t.Fatalf("requests %d = %d ok + %d failed", rep.Requests, rep.Succeeded, rep.Failed)
+	}
+	if rep.Errors["HTTP 409"] != rep.Failed || rep.StatusCodes[409] != rep.Failed {
+		t.Errorf("errors = %v, statuses = %v; want every failure to be a 409", rep.Errors, rep.StatusCodes)
+	}
+	if rep.ByRequest[1].Failed != rep.ByRequest[1].Requests {
+		t.Errorf("by request = %+v", rep.ByRequest)
+	}
+	if rep.Latency.P50 > rep.Latency.P99 || rep.Latency.P99 > rep.Latency.Max {
+		t.Errorf("latency not ordered: %+v", rep.Latency)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) == 0 || !strings.HasPrefix(bodies[0], `{"seq": `) {
+		t.Errorf("templated bodies = %q", bodies)
+	}
+
+	var text, js bytes.Buffer
+	if err := rep.WriteText(&text); err != nil || !strings.Contains(text.String(), "HTTP 409") {
+		t.Errorf("text report:\n%s", text.String())
+	}
+	if err := rep.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.Requests != rep.Requests {
+		t.Errorf("JSON report does not round-trip: %v", err)
+	}
+}
+
+func TestRunFixedRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		BaseURL:  srv.URL,
+		Rate:     100,
+		Duration: 300 * time.Millisecond,
+		Scenario: &Scenario{Requests: []RequestSpec{{Path: "/"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// About 30 requests; leave room for a slow CI machine.
+	if rep.Requests < 15 || rep.Requests > 35 {
+		t.Errorf("requests = %d; want about 30", rep.Requests)
+	}
+	if rep.Mode != "rate" || rep.Failed != 0 {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestRunConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		BaseURL:     url,
+		Duration:    50 * time.Millisecond,
+		Concurrency: 1,
+		Scenario:    &Scenario{Requests: []RequestSpec{{Path: "/"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Failed == 0 || rep.Errors["connection refused"] != rep.Failed {
+		t.Errorf("errors = %v", rep.Errors)
+	}
+}
+
+func TestScenarioErrors(t *testing.T) {
+	if _, err := Run(context.Background(), Config{Duration: time.Second, Scenario: &Scenario{}}); err == nil {
+		t.Error("empty scenario accepted")
+	}
+	bad := &Scenario{Requests: []RequestSpec{{Path: "/{{.Nope"}}}
+	if _, err := Run(context.Background(), Config{Duration: time.Second, Scenario: bad}); err == nil {
+		t.Error("broken template accepted")
+	}
+}
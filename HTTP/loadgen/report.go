@@ -0,0 +1,133 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the result of a run. Its JSON form is stable, so runs can be
+// saved and compared. Latencies are in milliseconds.
+type Report struct {
+	Mode        string           `json:"mode"` // "rate" or "concurrency"
+	Rate        float64          `json:"rate,omitempty"`
+	Concurrency int              `json:"concurrency"`
+	DurationSec float64          `json:"durationSec"`
+	Requests    int64            `json:"requests"`
+	Succeeded   int64            `json:"succeeded"`
+	Failed      int64            `json:"failed"`
+	Throughput  float64          `json:"throughput"` // completed requests per second
+	StatusCodes map[int]int64    `json:"statusCodes"`
+	Errors      map[string]int64 `json:"errors"`
+	Latency     Latency          `json:"latency"`
+	ByRequest   []RequestReport  `json:"byRequest"`
+}
+
+// Latency summarizes a histogram, in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// RequestReport is the part of a Report for one request of the scenario.
+type RequestReport struct {
+	Name     string  `json:"name"`
+	Requests int64   `json:"requests"`
+	Failed   int64   `json:"failed"`
+	Latency  Latency `json:"latency"`
+}
+
+func ms(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+func summarize(h *Histogram) Latency {
+	return Latency{
+		Min:  ms(h.Min()),
+		Mean: ms(h.Mean()),
+		P50:  ms(h.Percentile(50)),
+		P90:  ms(h.Percentile(90)),
+		P99:  ms(h.Percentile(99)),
+		Max:  ms(h.Max()),
+	}
+}
+
+func newReport(cfg Config, s *stats, elapsed time.Duration) *Report {
+	rep := &Report{
+		Mode:        "concurrency",
+		Rate:        cfg.Rate,
+		Concurrency: cfg.Concurrency,
+		DurationSec: elapsed.Seconds(),
+		Requests:    s.requests,
+		Succeeded:   s.requests - s.failed,
+		Failed:      s.failed,
+		StatusCodes: s.statuses,
+		Errors:      s.errors,
+		Latency:     summarize(s.latency),
+	}
+	if cfg.Rate > 0 {
+		rep.Mode = "rate"
+	}
+	if elapsed > 0 {
+		rep.Throughput = float64(s.latency.Count()) / elapsed.Seconds()
+	}
+	for i, rs := range s.byRequest {
+		rep.ByRequest = append(rep.ByRequest, RequestReport{
+			Name:     cfg.Scenario.Requests[i].Name,
+			Requests: rs.requests,
+			Failed:   rs.failed,
+			Latency:  summarize(rs.latency),
+		})
+	}
+	return rep
+}
+
+// WriteJSON writes the report as indented JSON.
+func (rep *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// WriteText writes a human-readable summary.
+func (rep *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if rep.Mode == "rate" {
+		fmt.Fprintf(tw, "Mode:\tfixed rate, %.1f req/s (max %d in flight)\n", rep.Rate, rep.Concurrency)
+	} else {
+		fmt.Fprintf(tw, "Mode:\tfixed concurrency, %d workers\n", rep.Concurrency)
+	}
+	fmt.Fprintf(tw, "Duration:\t%.1fs\n", rep.DurationSec)
+	fmt.Fprintf(tw, "Requests:\t%d (%d ok, %d failed)\n", rep.Requests, rep.Succeeded, rep.Failed)
+	fmt.Fprintf(tw, "Throughput:\t%.1f req/s\n", rep.Throughput)
+
+	l := rep.Latency
+	fmt.Fprintf(tw, "Latency:\tp50 %.2fms  p90 %.2fms  p99 %.2fms  max %.2fms  (mean %.2fms)\n", l.P50, l.P90, l.P99, l.Max, l.Mean)
+
+	if len(rep.StatusCodes) > 0 {
+		fmt.Fprintln(tw, "\nStatus codes:")
+		for _, code := range slices.Sorted(maps.Keys(rep.StatusCodes)) {
+			fmt.Fprintf(tw, "  %d\t%d\n", code, rep.StatusCodes[code])
+		}
+	}
+	if len(rep.Errors) > 0 {
+		fmt.Fprintln(tw, "\nErrors:")
+		for _, reason := range slices.Sorted(maps.Keys(rep.Errors)) {
+			fmt.Fprintf(tw, "  %s\t%d\n", reason, rep.Errors[reason])
+		}
+	}
+
+	fmt.Fprintln(tw, "\nBy request:\trequests\tfailed\tp50\tp99\tmax")
+	for _, r := range rep.ByRequest {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%.2fms\t%.2fms\t%.2fms\n",
+			r.Name, r.Requests, r.Failed, r.Latency.P50, r.Latency.P99, r.Latency.Max)
+	}
+	return tw.Flush()
+}
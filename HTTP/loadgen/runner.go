@@ -0,0 +1,264 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Config describes one load run.
+type Config struct {
+	BaseURL  string // e.g. "http://localhost:8080"
+	Scenario *Scenario
+	Duration time.Duration
+
+	// Rate is the number of requests started per second. With a rate the
+	// run is open-loop: requests start on schedule whether or not earlier
+	// ones finished, and latency is measured from the scheduled start, so
+	// a stalled server is not hidden (no coordinated omission).
+	// 0 runs a closed loop: Concurrency workers send requests back to back.
+	Rate float64
+	// Concurrency is the number of workers (0 means 10). With a Rate it caps
+	// the requests in flight; calls that find every worker busy are dropped
+	// and reported as errors.
+	Concurrency int
+
+	Timeout time.Duration // per request (0 means 10s)
+	Client  *http.Client  // nil means a client with Timeout
+}
+
+// errDropped is reported for scheduled calls that found every worker busy.
+const errDropped = "dropped (all workers busy)"
+
+// Run drives the scenario until cfg.Duration passes or ctx is done.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Scenario == nil {
+		cfg.Scenario = DefaultScenario()
+	}
+	if err := cfg.Scenario.compile(); err != nil {
+		return nil, err
+	}
+	if cfg.Duration <= 0 {
+		return nil, errors.New("loadgen: duration must be positive")
+	}
+	if cfg.Rate < 0 {
+		return nil, errors.New("loadgen: rate must not be negative")
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{MaxIdleConnsPerHost: cfg.Concurrency},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	r := &runner{cfg: cfg, workers: make([]*stats, cfg.Concurrency)}
+	start := time.Now()
+	if cfg.Rate > 0 {
+		r.openLoop(ctx)
+	} else {
+		r.closedLoop(ctx)
+	}
+	elapsed := time.Since(start)
+
+	total := newStats(len(cfg.Scenario.Requests))
+	for _, s := range r.workers {
+		total.merge(s)
+	}
+	if n := r.dropped.Load(); n > 0 {
+		total.requests += n
+		total.failed += n
+		total.errors[errDropped] += n
+	}
+	return newReport(cfg, total, elapsed), nil
+}
+
+type runner struct {
+	cfg     Config
+	seq     atomic.Int64
+	dropped atomic.Int64
+	workers []*stats
+}
+
+func (r *runner) closedLoop(ctx context.Context) {
+	var wg sync.WaitGroup
+	for w := range r.workers {
+		s := newStats(len(r.cfg.Scenario.Requests))
+		r.workers[w] = s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				r.do(ctx, s, w, time.Now())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *runner) openLoop(ctx context.Context) {
+	tickets := make(chan time.Time, r.cfg.Concurrency)
+
+	var wg sync.WaitGroup
+	for w := range r.workers {
+		s := newStats(len(r.cfg.Scenario.Requests))
+		r.workers[w] = s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for scheduled := range tickets {
+				r.do(ctx, s, w, scheduled)
+			}
+		}()
+	}
+
+	interval := time.Duration(float64(time.Second) / r.cfg.Rate)
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+dispatch:
+	for i := int64(0); ; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+		timer.Reset(time.Until(scheduled))
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case <-timer.C:
+		}
+		select {
+		case tickets <- scheduled:
+		default:
+			r.dropped.Add(1)
+		}
+	}
+	close(tickets)
+	wg.Wait()
+}
+
+// do sends one request and records the outcome in s.
+func (r *runner) do(ctx context.Context, s *stats, worker int, scheduled time.Time) {
+	idx := r.cfg.Scenario.pick()
+	spec := &r.cfg.Scenario.Requests[idx]
+
+	req, err := spec.build(r.cfg.BaseURL, templateData{Seq: r.seq.Add(1), Worker: worker})
+	if err != nil {
+		s.fail(idx, "template: "+err.Error())
+		return
+	}
+
+	resp, err := r.cfg.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return // the run ended while the request was in flight
+		}
+		s.fail(idx, classify(err))
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	latency := time.Since(scheduled)
+	s.statuses[resp.StatusCode]++
+	if resp.StatusCode >= 400 {
+		s.fail(idx, fmt.Sprintf("HTTP %d", resp.StatusCode))
+		s.latency.Record(latency)
+		s.byRequest[idx].latency.Record(latency)
+		return
+	}
+	s.ok(idx, latency)
+}
+
+// classify turns a transport error into a short, stable label.
+func classify(err error) string {
+	var nerr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &nerr) && nerr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection reset"
+	default:
+		return "transport error"
+	}
+}
+
+// --- PER-WORKER COUNTERS ---
+
+type requestStats struct {
+	requests, failed int64
+	latency          *Histogram
+}
+
+// stats belongs to one worker, so it needs no locking.
+type stats struct {
+	latency   *Histogram
+	requests  int64
+	failed    int64
+	statuses  map[int]int64
+	errors    map[string]int64
+	byRequest []requestStats
+}
+
+func newStats(n int) *stats {
+	s := &stats{
+		latency:   NewHistogram(),
+		statuses:  make(map[int]int64),
+		errors:    make(map[string]int64),
+		byRequest: make([]requestStats, n),
+	}
+	for i := range s.byRequest {
+		s.byRequest[i].latency = NewHistogram()
+	}
+	return s
+}
+
+func (s *stats) ok(idx int, latency time.Duration) {
+	s.requests++
+	s.byRequest[idx].requests++
+	s.latency.Record(latency)
+	s.byRequest[idx].latency.Record(latency)
+}
+
+func (s *stats) fail(idx int, reason string) {
+	s.requests++
+	s.failed++
+	s.byRequest[idx].requests++
+	s.byRequest[idx].failed++
+	s.errors[reason]++
+}
+
+func (s *stats) merge(o *stats) {
+	if o == nil {
+		return
+	}
+	s.latency.Merge(o.latency)
+	s.requests += o.requests
+	s.failed += o.failed
+	for k, v := range o.statuses {
+		s.statuses[k] += v
+	}
+	for k, v := range o.errors {
+		s.errors[k] += v
+	}
+	for i := range o.byRequest {
+		s.byRequest[i].requests += o.byRequest[i].requests
+		s.byRequest[i].failed += o.byRequest[i].failed
+		s.byRequest[i].latency.Merge(o.byRequest[i].latency)
+	}
+}
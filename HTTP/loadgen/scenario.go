@@ -0,0 +1,166 @@
+// Package loadgen drives HTTP traffic against a server and measures it.
+//
+// A Scenario is a weighted mix of requests. Paths and bodies are
+// text/template templates, so every request can be different:
+//
+//	{"requests": [
+//	  {"name": "list", "weight": 3, "method": "GET", "path": "/users"},
+//	  {"name": "create", "weight": 1, "method": "POST", "path": "/users/create",
+//	   "body": "{\"name\": \"load {{.Seq}}\", \"email\": \"load{{.Seq}}-{{randString 6}}@example.com\"}"}
+//	]}
+//
+// Templates see .Seq (a counter shared by all requests) and .Worker, and can
+// call randInt MIN MAX and randString N.
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// RequestSpec is one kind of request of a Scenario.
+type RequestSpec struct {
+	Name    string            `json:"name"`
+	Weight  int               `json:"weight"` // relative share of the traffic (0 means 1)
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Body    string            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	path, body *template.Template
+}
+
+// Scenario is the traffic mix of a run.
+type Scenario struct {
+	Requests []RequestSpec `json:"requests"`
+
+	totalWeight int
+}
+
+// DefaultScenario reads the users three times for every create.
+func DefaultScenario() *Scenario {
+	return &Scenario{Requests: []RequestSpec{
+		{Name: "list", Weight: 3, Method: http.MethodGet, Path: "/users"},
+		{
+			Name:    "create",
+			Weight:  1,
+			Method:  http.MethodPost,
+			Path:    "/users/create",
+			Body:    `{"name": "Load {{.Seq}}", "email": "load{{.Seq}}-{{randString 8}}@example.com"}`,
+			Headers: map[string]string{"Content-Type": "application/json"},
+		},
+	}}
+}
+
+// LoadScenario reads a JSON scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("loadgen: parsing %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"randInt": func(lo, hi int) int {
+		if hi <= lo {
+			return lo
+		}
+		return lo + rand.IntN(hi-lo+1)
+	},
+	"randString": func(n int) string {
+		const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = letters[rand.IntN(len(letters))]
+		}
+		return string(b)
+	},
+}
+
+// compile checks the scenario and parses its templates.
+func (s *Scenario) compile() error {
+	if len(s.Requests) == 0 {
+		return errors.New("loadgen: scenario has no requests")
+	}
+	s.totalWeight = 0
+	for i := range s.Requests {
+		rs := &s.Requests[i]
+		if rs.Name == "" {
+			rs.Name = fmt.Sprintf("%s %s", rs.Method, rs.Path)
+		}
+		if rs.Method == "" {
+			rs.Method = http.MethodGet
+		}
+		rs.Method = strings.ToUpper(rs.Method)
+		if rs.Weight < 0 {
+			return fmt.Errorf("loadgen: %s: negative weight", rs.Name)
+		}
+		if rs.Weight == 0 {
+			rs.Weight = 1
+		}
+		s.totalWeight += rs.Weight
+
+		var err error
+		if rs.path, err = template.New(rs.Name + " path").Funcs(templateFuncs).Parse(rs.Path); err != nil {
+			return fmt.Errorf("loadgen: %s: %w", rs.Name, err)
+		}
+		if rs.Body != "" {
+			if rs.body, err = template.New(rs.Name + " body").Funcs(templateFuncs).Parse(rs.Body); err != nil {
+				return fmt.Errorf("loadgen: %s: %w", rs.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pick chooses the index of a request by weight.
+func (s *Scenario) pick() int {
+	n := rand.IntN(s.totalWeight)
+	for i := range s.Requests {
+		if n < s.Requests[i].Weight {
+			return i
+		}
+		n -= s.Requests[i].Weight
+	}
+	return len(s.Requests) - 1
+}
+
+// templateData is what path and body templates see.
+type templateData struct {
+	Seq    int64
+	Worker int
+}
+
+// build renders the request for one call.
+func (rs *RequestSpec) build(baseURL string, data templateData) (*http.Request, error) {
+	var path, body bytes.Buffer
+	if err := rs.path.Execute(&path, data); err != nil {
+		return nil, err
+	}
+	if rs.body != nil {
+		if err := rs.body.Execute(&body, data); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(rs.Method, strings.TrimSuffix(baseURL, "/")+path.String(), &body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range rs.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
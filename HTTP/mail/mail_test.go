@@ -0,0 +1,88 @@
+package mail_test
+
+import (
+	"strings"
+	"testing"
+
+	"httpserver/mail"
+	"httpserver/mail/smtptest"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	data := mail.TemplateData{Name: "Alice <admin>", Link: "http://x/verify?token=abc", ExpiresIn: "24h"}
+
+	for _, name := range []string{mail.TemplateVerifyEmail, mail.TemplateResetPassword} {
+		msg, err := mail.Render(name, "alice@example.com", data)
+		if err != nil {
+			t.Fatalf("Render(%s): %v", name, err)
+		}
+		if msg.Subject == "" || msg.To != "alice@example.com" {
+			t.Errorf("Render(%s) subject/to not set: %+v", name, msg)
+		}
+		if !strings.Contains(msg.Text, data.Link) || !strings.Contains(msg.Text, "Alice <admin>") {
+			t.Errorf("Render(%s) text body missing data:\n%s", name, msg.Text)
+		}
+		if !strings.Contains(msg.HTML, "Alice &lt;admin&gt;") {
+			t.Errorf("Render(%s) HTML body did not escape the name:\n%s", name, msg.HTML)
+		}
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := mail.Render("nope", "a@b.c", mail.TemplateData{}); err == nil {
+		t.Errorf("Render with an unknown template returned no error")
+	}
+}
+
+func TestFakeMailer(t *testing.T) {
+	f := mail.NewFakeMailer()
+	f.Send(mail.Message{To: "a@example.com", Subject: "first"})
+	f.Send(mail.Message{To: "b@example.com", Subject: "other"})
+	f.Send(mail.Message{To: "a@example.com", Subject: "second"})
+
+	if got := len(f.Sent()); got != 3 {
+		t.Errorf("len(Sent()) = %d; want 3", got)
+	}
+	if last, ok := f.Last("A@example.com"); !ok || last.Subject != "second" {
+		t.Errorf("Last = %+v, %v; want subject \"second\"", last, ok)
+	}
+	if err := f.Send(mail.Message{}); err != mail.ErrNoRecipient {
+		t.Errorf("Send without To: err = %v; want %v", err, mail.ErrNoRecipient)
+	}
+}
+
+// Integration test: SMTPMailer talks real SMTP to the local stand-in server.
+func TestSMTPMailerWithLocalServer(t *testing.T) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	m := &mail.SMTPMailer{Addr: srv.Addr, From: "noreply@example.com"}
+	want := mail.Message{
+		To:      "bob@example.com",
+		Subject: "Hello",
+		Text:    "Plain body with a long line that quoted-printable must wrap because it is longer than seventy-six characters.",
+		HTML:    "<p>HTML body</p>",
+	}
+	if err := m.Send(want); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	received := srv.Messages()
+	if len(received) != 1 {
+		t.Fatalf("server received %d messages; want 1", len(received))
+	}
+	if received[0].From != "noreply@example.com" || received[0].To[0] != "bob@example.com" {
+		t.Errorf("envelope = %s -> %v", received[0].From, received[0].To)
+	}
+
+	got, err := received[0].Message()
+	if err != nil {
+		t.Fatalf("parsing received message: %v", err)
+	}
+	if got.Subject != want.Subject || got.Text != want.Text || got.HTML != want.HTML {
+		t.Errorf("received message = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime/quotedprintable"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- MAILER INTERFACE ---
+//
+// The rest of the server only knows about Mailer.
+// Production uses SMTPMailer, tests use FakeMailer.
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// ErrNoRecipient is returned when a message has no To address.
+var ErrNoRecipient = errors.New("mail: message has no recipient")
+
+// --- SMTP IMPLEMENTATION ---
+
+// SMTPMailer sends messages through an SMTP server using net/smtp.
+type SMTPMailer struct {
+	Addr string    // host:port of the SMTP server
+	From string    // sender address
+	Auth smtp.Auth // nil for servers without authentication
+}
+
+// Send delivers msg as a multipart/alternative email.
+func (m *SMTPMailer) Send(msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+
+	body, err := buildMIME(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("mail: sending to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// buildMIME writes the headers and both bodies of msg.
+// The client shows the HTML part and falls back to the text part.
+func buildMIME(from string, msg Message) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s; charset=UTF-8\r\n", part.contentType)
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+		qp := quotedprintable.NewWriter(&b)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	return b.Bytes(), nil
+}
+
+// sanitizeHeader removes line breaks so a value cannot inject extra headers.
+func sanitizeHeader(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+func randomBoundary() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// --- IN-MEMORY FAKE ---
+
+// FakeMailer keeps every sent message in memory. Use it in tests.
+type FakeMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewFakeMailer returns an empty FakeMailer.
+func NewFakeMailer() *FakeMailer {
+	return &FakeMailer{}
+}
+
+// Send records msg.
+func (f *FakeMailer) Send(msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+
+	f.mu.Lock()
+	f.sent = append(f.sent, msg)
+	f.mu.Unlock()
+	return nil
+}
+
+// Sent returns a copy of all recorded messages, oldest first.
+func (f *FakeMailer) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
+
+// Last returns the most recent message sent to the given address.
+func (f *FakeMailer) Last(to string) (Message, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.sent) - 1; i >= 0; i-- {
+		if strings.EqualFold(f.sent[i].To, to) {
+			return f.sent[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,213 @@
+// Package smtptest provides a tiny local SMTP server for integration tests.
+//
+// It speaks just enough SMTP for net/smtp (HELO/EHLO, MAIL, RCPT, DATA,
+// RSET, NOOP, QUIT) and keeps every received message in memory.
+// It does not support TLS or AUTH, so use it with a nil smtp.Auth.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"sync"
+
+	"httpserver/mail"
+)
+
+// Received is one message accepted by the server.
+type Received struct {
+	From string
+	To   []string
+	Data []byte // raw message: headers and body
+}
+
+// Server is a local SMTP stand-in.
+type Server struct {
+	Addr string // host:port to pass to mail.SMTPMailer
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Received
+}
+
+// NewServer starts a server on a random loopback port.
+// Call Close when the test is done.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Addr: ln.Addr().String(), ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns a copy of every received message, oldest first.
+func (s *Server) Messages() []Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Received(nil), s.messages...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// --- SMTP SESSION ---
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		w.Flush()
+	}
+
+	var cur Received
+	reply("220 smtptest ready")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 smtptest")
+		case "EHLO":
+			reply("250-smtptest")
+			reply("250 8BITMIME")
+		case "MAIL":
+			cur = Received{From: addrArg(arg)}
+			reply("250 OK")
+		case "RCPT":
+			cur.To = append(cur.To, addrArg(arg))
+			reply("250 OK")
+		case "DATA":
+			if len(cur.To) == 0 {
+				reply("503 need RCPT first")
+				continue
+			}
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			cur.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, cur)
+			s.mu.Unlock()
+			cur = Received{}
+			reply("250 OK")
+		case "RSET":
+			cur = Received{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// addrArg extracts the address from "FROM:<a@b>" or "TO:<a@b>".
+func addrArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ") // drop params like BODY=8BITMIME
+	return strings.Trim(addr, "<>")
+}
+
+// readData reads the DATA section up to the lone "." line and removes dot-stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		buf.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// --- PARSING RECEIVED MESSAGES ---
+
+// Message parses the raw data back into a mail.Message,
+// decoding the text and HTML parts.
+func (rc Received) Message() (mail.Message, error) {
+	m, err := netmail.ReadMessage(bytes.NewReader(rc.Data))
+	if err != nil {
+		return mail.Message{}, err
+	}
+
+	msg := mail.Message{To: m.Header.Get("To"), Subject: m.Header.Get("Subject")}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return msg, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(m.Body)
+		msg.Text = string(body)
+		return msg, err
+	}
+
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return msg, nil
+		}
+		if err != nil {
+			return msg, err
+		}
+		body, err := io.ReadAll(part) // quoted-printable is decoded by NextPart
+		if err != nil {
+			return msg, err
+		}
+		switch {
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+			msg.Text = string(body)
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+			msg.HTML = string(body)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// --- TEMPLATED MESSAGES ---
+//
+// Every message has two files in templates/: <name>.txt and <name>.html.
+// The HTML version uses html/template, so values are escaped automatically.
+
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+// Template names.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+var subjects = map[string]string{
+	TemplateVerifyEmail:   "Confirm your email address",
+	TemplateResetPassword: "Reset your password",
+}
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
+
+// TemplateData is the data available inside every template.
+type TemplateData struct {
+	Name      string
+	Link      string
+	ExpiresIn string
+}
+
+// Render builds a Message for the named template.
+func Render(name, to string, data TemplateData) (Message, error) {
+	subject, ok := subjects[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("mail: rendering %s.txt: %w", name, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("mail: rendering %s.html: %w", name, err)
+	}
+
+	return Message{To: to, Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
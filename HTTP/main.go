@@ -2,38 +2,107 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+
+	"httpserver/service"
+	"httpserver/store"
+	"httpserver/tenant"
 )
 
 // --- DATA STRUCTS ---
 
-type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
+// deletePolicy is used by the user service of every tenant (see configurePosts).
+var deletePolicy = service.DeleteRestrict
 
-// Dummy in-memory data store
-var users = []User{
-	{ID: 1, Name: "Alice", Email: "alice@example.com"},
-	{ID: 2, Name: "Bob", Email: "bob@example.com"},
-}
+// defaultState holds the in-memory data when multi-tenancy is off.
+var defaultState = newTenantState("", tenant.Quota{},
+	store.User{ID: 1, Name: "Alice", Email: "alice@example.com", EmailVerified: true},
+	store.User{ID: 2, Name: "Bob", Email: "bob@example.com", EmailVerified: true},
+)
 
 // --- MAIN FUNCTION ---
 
 func main() {
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/users", usersHandler)         // GET
-	http.HandleFunc("/users/create", createHandler) // POST
-	http.HandleFunc("/users/query", queryHandler)   // with ?id=...
-	http.HandleFunc("/external", externalAPIClient) // client call example
-
-	fmt.Println("Server running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	mailer = newMailerFromEnv()
+	configureTenants()
+	configureStorage()
+	restoreBackup()
+	configureReplication()
+	configureBackup()
+	configurePosts()
+	configureFaults()
+	configureTracing()
+	startCleanup()
+
+	// ADDR lets several instances (say a leader and its followers) share a host.
+	addr := envOr("ADDR", ":8080")
+	fmt.Printf("Server running at %s\n", addr)
+	log.Fatal(http.ListenAndServe(addr, newRouter()))
+}
+
+// --- ROUTES ---
+
+// newRouter registers every handler. Multi-tenancy, replication and fault
+// injection wrap the API in their middleware and add their own routes.
+func newRouter() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", homeHandler)
+	mux.HandleFunc("/users", usersHandler)         // GET
+	mux.HandleFunc("/users/create", createHandler) // POST
+	mux.HandleFunc("/users/query", queryHandler)   // with ?id=...
+	mux.HandleFunc("/users/search", searchHandler) // GET ?q=...
+	mux.HandleFunc("/users/{id}", userHandler)     // GET, PUT, PATCH, DELETE
+	mux.HandleFunc("/external", externalAPIClient) // client call example
+	mux.HandleFunc("/login", loginHandler)         // POST
+	mux.HandleFunc("/logout", logoutHandler)       // POST
+	mux.HandleFunc("/me", meHandler)               // GET, needs a session
+
+	mux.HandleFunc("/verify-email", verifyEmailHandler)               // GET form, POST token
+	mux.HandleFunc("/verify-email/resend", resendVerificationHandler) // POST
+	mux.HandleFunc("/password/forgot", forgotPasswordHandler)         // POST
+	mux.HandleFunc("/password/reset", resetPasswordHandler)           // POST
+
+	mux.HandleFunc("/users/{id}/posts", userPostsHandler) // GET, POST
+	mux.HandleFunc("/posts/{id}", postHandler)            // GET, PUT, DELETE
+	mux.HandleFunc("/posts/sync", syncPostsHandler)       // POST, imports upstream posts
+
+	mux.Handle("/rpc", newRPCServer()) // JSON-RPC 2.0
+
+	if !multiTenant && !faultInjection && replicationRole == "" {
+		return tracer.Middleware(mux)
+	}
+
+	// The admin and replication routes bypass the API middleware.
+	root := http.NewServeMux()
+	var api http.Handler = mux
+	if multiTenant {
+		root.HandleFunc("/admin/tenants", requireAdmin(adminTenantsHandler))     // GET, POST
+		root.HandleFunc("/admin/tenants/{id}", requireAdmin(adminTenantHandler)) // GET, DELETE
+		root.HandleFunc("/admin/tenants/{id}/suspend", requireAdmin(adminTenantStatusHandler(tenant.StatusSuspended)))
+		root.HandleFunc("/admin/tenants/{id}/resume", requireAdmin(adminTenantStatusHandler(tenant.StatusActive)))
+		api = tenantMiddleware.Wrap(withTenantState(api))
+	}
+	if replicationRole != "" {
+		root.Handle("/replication/", replicationHandler())
+		if follower != nil {
+			api = readOnly.Wrap(api)
+		}
+	}
+	if faultInjection {
+		root.HandleFunc("/admin/faults", requireAdmin(adminFaultsHandler))     // GET, POST
+		root.HandleFunc("/admin/faults/{id}", requireAdmin(adminFaultHandler)) // GET, PUT, DELETE
+		root.HandleFunc("/admin/faults/{id}/enable", requireAdmin(adminFaultToggleHandler(true)))
+		root.HandleFunc("/admin/faults/{id}/disable", requireAdmin(adminFaultToggleHandler(false)))
+		api = faults.Wrap(api)
+	}
+	root.Handle("/", api)
+	return tracer.Middleware(root)
 }
 
 // --- HANDLER: HOME PAGE ---
@@ -45,6 +114,12 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 // --- HANDLER: GET ALL USERS AS JSON ---
 
 func usersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := stateFrom(r.Context()).service.List(r.Context())
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 }
@@ -57,15 +132,18 @@ func createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u User
-	err := json.NewDecoder(r.Body).Decode(&u)
+	var req service.CreateUserInput
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	u.ID = len(users) + 1
-	users = append(users, u)
+	u, err := stateFrom(r.Context()).service.Create(r.Context(), req)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -87,21 +165,74 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, u := range users {
-		if u.ID == id {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(u)
+	u, err := stateFrom(r.Context()).service.Get(r.Context(), id)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u)
+}
+
+// --- HANDLER: SINGLE USER (/users/{id}) ---
+
+func userHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		u, err := stateFrom(r.Context()).service.Get(r.Context(), id)
+		if err != nil {
+			storeError(w, r, err)
 			return
 		}
-	}
+		writeJSON(w, http.StatusOK, u)
+
+	case http.MethodPut:
+		var req service.UpdateUserInput
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		u, err := stateFrom(r.Context()).service.Update(r.Context(), id, req)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, u)
 
-	http.NotFound(w, r)
+	case http.MethodPatch:
+		patchUser(w, r, id)
+
+	case http.MethodDelete:
+		if err := stateFrom(r.Context()).service.Delete(r.Context(), id); err != nil {
+			storeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT, PATCH or DELETE allowed", http.StatusMethodNotAllowed)
+	}
 }
 
 // --- CLIENT: MAKE GET REQUEST TO EXTERNAL API ---
 
+// externalClient makes the upstream call; tests swap it for a vcr.Recorder client.
+var externalClient = tracedClient
+
 func externalAPIClient(w http.ResponseWriter, r *http.Request) {
-	resp, err := http.Get("https://jsonplaceholder.typicode.com/posts/1")
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, "https://jsonplaceholder.typicode.com/posts/1", nil)
+	if err != nil {
+		http.Error(w, "Failed to call external API", http.StatusInternalServerError)
+		return
+	}
+	resp, err := externalClient.Do(req)
 	if err != nil {
 		http.Error(w, "Failed to call external API", http.StatusInternalServerError)
 		return
@@ -112,3 +243,31 @@ func externalAPIClient(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(body)
 }
+
+// --- RESPONSE HELPERS ---
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// storeError turns a store or service error into the matching HTTP status.
+func storeError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *service.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		http.Error(w, verr.Error(), http.StatusBadRequest)
+	case errors.Is(err, store.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, store.ErrEmailTaken):
+		http.Error(w, "Email already in use", http.StatusConflict)
+	case errors.Is(err, service.ErrUserHasPosts):
+		http.Error(w, "User still has posts", http.StatusConflict)
+	case errors.Is(err, service.ErrQuotaExceeded):
+		http.Error(w, "User quota exceeded", http.StatusForbidden)
+	default:
+		log.Printf("store error on %s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
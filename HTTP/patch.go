@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"httpserver/service"
+	"httpserver/store"
+
+	"jsonexamples/patch"
+)
+
+// --- HANDLER: PATCH /users/{id} ---
+//
+// Changes part of a user without sending the whole object. The body is
+// either a JSON Patch (Content-Type: application/json-patch+json) or a
+// JSON Merge Patch (application/merge-patch+json). The patch is applied
+// to the user's JSON as clients see it, so fields hidden from JSON, like
+// the password hash, cannot be read or changed. id and emailVerified can
+// be tested but not changed; name and email go through the same
+// validation as PUT. Nothing is stored unless every operation succeeds.
+
+var acceptPatch = patch.MediaTypeJSONPatch + ", " + patch.MediaTypeMergePatch
+
+func patchUser(w http.ResponseWriter, r *http.Request, id int) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != patch.MediaTypeJSONPatch && mediaType != patch.MediaTypeMergePatch {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		http.Error(w, "Content-Type must be "+acceptPatch, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var p patch.Patch
+	if mediaType == patch.MediaTypeJSONPatch {
+		if p, err = patch.Decode(body); err != nil {
+			patchError(w, err)
+			return
+		}
+	}
+
+	// The patch is applied to the user as stored when the change is
+	// written, so a "test" cannot pass against a value another request
+	// has just replaced.
+	var patchErr error
+	svc := stateFrom(r.Context()).service
+	updated, err := svc.UpdateFunc(r.Context(), id, func(u store.User) (service.UpdateUserInput, error) {
+		patched := u
+		if mediaType == patch.MediaTypeJSONPatch {
+			patchErr = patch.ApplyTo(&patched, p)
+		} else {
+			patchErr = patch.MergeTo(&patched, body)
+		}
+		if patchErr == nil && (patched.ID != u.ID || patched.EmailVerified != u.EmailVerified) {
+			patchErr = errReadOnly
+		}
+
+		var in service.UpdateUserInput
+		if patched.Name != u.Name {
+			in.Name = &patched.Name
+		}
+		if patched.Email != u.Email {
+			in.Email = &patched.Email
+		}
+		return in, patchErr
+	})
+	if patchErr != nil {
+		patchError(w, patchErr)
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+var errReadOnly = errors.New("id and emailVerified are read-only")
+
+// patchError follows RFC 5789: a malformed patch is 400, a patch that
+// cannot be applied to this user is 422, a failed "test" is 409.
+func patchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, patch.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, patch.ErrTestFailed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default: // patch.ErrPath, patch.ErrType, errReadOnly
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	}
+}
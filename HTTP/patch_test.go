@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"httpserver/auth"
+)
+
+func doPatch(id int, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/users/"+strconv.Itoa(id), strings.NewReader(body))
+	req.SetPathValue("id", strconv.Itoa(id))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	userHandler(rec, req)
+	return rec
+}
+
+func TestPatchUser(t *testing.T) {
+	rec := do(createHandler, "POST", "/users/create", `{"name":"Patricia","email":"patricia@example.com","password":"patch-pass"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d", rec.Code)
+	}
+	var created struct{ ID int }
+	json.NewDecoder(rec.Body).Decode(&created)
+	id := created.ID
+	defer defaultState.service.Delete(t.Context(), id)
+
+	rec = doPatch(id, "application/json-patch+json",
+		`[{"op":"test","path":"/name","value":"Patricia"},{"op":"replace","path":"/name","value":"Patty"}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("json patch: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = doPatch(id, "application/merge-patch+json; charset=utf-8", `{"email":"patty@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("merge patch: status %d: %s", rec.Code, rec.Body)
+	}
+	u, _ := defaultState.service.Get(t.Context(), id)
+	if u.Name != "Patty" || u.Email != "patty@example.com" {
+		t.Errorf("user = %+v", u)
+	}
+
+	tests := []struct {
+		name, contentType, body string
+		status                  int
+	}{
+		{"wrong content type", "application/json", `{"name":"x"}`, http.StatusUnsupportedMediaType},
+		{"malformed patch", "application/json-patch+json", `[{"op":"jump"}]`, http.StatusBadRequest},
+		{"failed test", "application/json-patch+json", `[{"op":"test","path":"/name","value":"Nope"}]`, http.StatusConflict},
+		{"missing path", "application/json-patch+json", `[{"op":"remove","path":"/nickname"}]`, http.StatusUnprocessableEntity},
+		{"read-only id", "application/merge-patch+json", `{"id":999}`, http.StatusUnprocessableEntity},
+		{"read-only verified", "application/json-patch+json", `[{"op":"replace","path":"/emailVerified","value":true}]`, http.StatusUnprocessableEntity},
+		{"hidden password", "application/json-patch+json", `[{"op":"add","path":"/PasswordHash","value":"x"}]`, http.StatusUnprocessableEntity},
+		{"hidden password by merge", "application/merge-patch+json", `{"PasswordHash":"x"}`, http.StatusUnprocessableEntity},
+		{"invalid name", "application/merge-patch+json", `{"name":""}`, http.StatusBadRequest},
+		// The second operation fails, so the first is not stored either.
+		{"atomic", "application/json-patch+json", `[{"op":"replace","path":"/name","value":"Changed"},{"op":"remove","path":"/missing"}]`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if rec := doPatch(id, tt.contentType, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status %d; want %d (%s)", tt.name, rec.Code, tt.status, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	after, _ := defaultState.service.Get(t.Context(), id)
+	if after.Name != "Patty" || after.ID != id || after.EmailVerified {
+		t.Errorf("user changed by rejected patches: %+v", after)
+	}
+	if ok, _ := auth.CheckPassword(after.PasswordHash, "patch-pass"); !ok || after.PasswordHash != u.PasswordHash {
+		t.Error("password hash changed")
+	}
+
+	if rec := doPatch(987654, "application/merge-patch+json", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d", rec.Code)
+	}
+}
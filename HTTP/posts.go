@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpserver/postsync"
+	"httpserver/service"
+	"httpserver/store"
+)
+
+// --- POSTS CONFIG ---
+
+var postSyncer = &postsync.Syncer{Upstream: postsync.DefaultUpstream, Client: tracedClient}
+
+// configurePosts reads the posts settings from the environment:
+//
+//	USER_DELETE_POSTS    restrict | cascade (what deleting a user does to their posts)
+//	POSTS_UPSTREAM       base URL of a jsonplaceholder-compatible API
+//	POSTS_USER_MAP       upstream=local user ID pairs, like "1=1,2=1"; posts of
+//	                     other upstream users are not imported (none by default)
+//	POSTS_SYNC_INTERVAL  e.g. "10m"; when set, the sync runs in the background
+func configurePosts() {
+	policy, err := service.ParseDeletePolicy(os.Getenv("USER_DELETE_POSTS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	deletePolicy = policy
+	defaultState.service.DeletePolicy = policy
+
+	// The background sync only fills the default (single-tenant) stores;
+	// tenants sync on demand with POST /posts/sync.
+	postSyncer.Upstream = envOr("POSTS_UPSTREAM", postsync.DefaultUpstream)
+	if postSyncer.UserMap, err = postsync.ParseUserMap(os.Getenv("POSTS_USER_MAP")); err != nil {
+		log.Fatal(err)
+	}
+	postSyncer.Service = defaultState.service
+
+	// A follower gets its posts from the leader, which runs the sync.
+	if v := os.Getenv("POSTS_SYNC_INTERVAL"); v != "" && follower == nil {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid POSTS_SYNC_INTERVAL %q", v)
+		}
+		go postSyncer.Start(context.Background(), interval)
+	}
+}
+
+type postRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// --- HANDLER: POSTS OF A USER (/users/{id}/posts) ---
+
+func userPostsHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, err := st.service.Get(r.Context(), userID); err != nil {
+			storeError(w, r, err)
+			return
+		}
+		posts, err := st.posts.ListByUser(r.Context(), userID)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, posts)
+
+	case http.MethodPost:
+		var req postRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			http.Error(w, "Title must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		p, err := st.service.CreatePost(r.Context(), store.Post{UserID: userID, Title: req.Title, Body: req.Body})
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, p)
+
+	default:
+		http.Error(w, "Only GET or POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: SINGLE POST (/posts/{id}) ---
+
+func postHandler(w http.ResponseWriter, r *http.Request) {
+	st := stateFrom(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		p, err := st.posts.Get(r.Context(), id)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+
+	case http.MethodPut:
+		var req postRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			http.Error(w, "Title must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		p, err := st.posts.Get(r.Context(), id)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		p.Title, p.Body = req.Title, req.Body
+		if p, err = st.posts.Update(r.Context(), p); err != nil {
+			storeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+
+	case http.MethodDelete:
+		if err := st.posts.Delete(r.Context(), id); err != nil {
+			storeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT or DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: RUN THE POSTS SYNC NOW ---
+
+func syncPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st := stateFrom(r.Context())
+	syncer := &postsync.Syncer{
+		Upstream: postSyncer.Upstream,
+		Client:   postSyncer.Client,
+		Service:  st.service,
+		UserMap:  postSyncer.UserMap,
+	}
+
+	res, err := syncer.Run(r.Context())
+	if err != nil {
+		log.Printf("posts sync: %v", err)
+		http.Error(w, "Posts sync failed", http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"httpserver/service"
+	"httpserver/store"
+	"httpserver/tenant"
+)
+
+// serve sends one request through the full router.
+func serve(method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+// resetPostsState gives each test fresh stores and restores the policy afterwards.
+func resetPostsState(t *testing.T, policy service.DeletePolicy) {
+	oldState, oldPolicy := defaultState, deletePolicy
+	t.Cleanup(func() { defaultState, deletePolicy = oldState, oldPolicy })
+
+	deletePolicy = policy
+	defaultState = newTenantState("", tenant.Quota{}, store.User{ID: 1, Name: "Alice", Email: "alice@example.com"})
+}
+
+func TestPostsEndpoints(t *testing.T) {
+	resetPostsState(t, service.DeleteRestrict)
+
+	if rec := serve("POST", "/users/99/posts", `{"title":"x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("create post for missing user: status %d; want 404", rec.Code)
+	}
+	if rec := serve("POST", "/users/1/posts", `{"title":""}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("create post without title: status %d; want 400", rec.Code)
+	}
+
+	rec := serve("POST", "/users/1/posts", `{"title":"Hello","body":"World"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create post: status %d", rec.Code)
+	}
+	var p store.Post
+	json.NewDecoder(rec.Body).Decode(&p)
+	if p.UserID != 1 || p.Title != "Hello" {
+		t.Errorf("created post = %+v", p)
+	}
+
+	rec = serve("GET", "/users/1/posts", "")
+	if !strings.Contains(rec.Body.String(), `"userId":1`) {
+		t.Errorf("list posts = %s", rec.Body.String())
+	}
+
+	if rec := serve("PUT", "/posts/1", `{"title":"Changed","body":"b"}`); rec.Code != http.StatusOK {
+		t.Errorf("update post: status %d", rec.Code)
+	}
+	if rec := serve("GET", "/posts/1", ""); !strings.Contains(rec.Body.String(), "Changed") {
+		t.Errorf("get post after update = %s", rec.Body.String())
+	}
+	if rec := serve("DELETE", "/posts/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete post: status %d", rec.Code)
+	}
+	if rec := serve("GET", "/posts/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get deleted post: status %d; want 404", rec.Code)
+	}
+}
+
+func TestDeleteUserRestrict(t *testing.T) {
+	resetPostsState(t, service.DeleteRestrict)
+	defaultState.posts.Create(context.Background(), store.Post{UserID: 1, Title: "keep me"})
+
+	if rec := serve("DELETE", "/users/1", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("delete user with posts: status %d; want 409", rec.Code)
+	}
+	if _, err := defaultState.users.Get(context.Background(), 1); err != nil {
+		t.Errorf("user was deleted despite restrict policy")
+	}
+}
+
+func TestDeleteUserCascade(t *testing.T) {
+	resetPostsState(t, service.DeleteCascade)
+	defaultState.posts.Create(context.Background(), store.Post{UserID: 1, Title: "goes away"})
+
+	if rec := serve("DELETE", "/users/1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete user: status %d; want 204", rec.Code)
+	}
+	if n, _ := defaultState.posts.CountByUser(context.Background(), 1); n != 0 {
+		t.Errorf("%d posts left after cascade delete", n)
+	}
+	if rec := serve("GET", "/users/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get deleted user: status %d; want 404", rec.Code)
+	}
+}
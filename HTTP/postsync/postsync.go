@@ -0,0 +1,161 @@
+// Package postsync imports posts from a jsonplaceholder-compatible API.
+package postsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpserver/service"
+	"httpserver/store"
+)
+
+// DefaultUpstream is the public jsonplaceholder service.
+const DefaultUpstream = "https://jsonplaceholder.typicode.com"
+
+// upstreamPost is the shape of one item from GET /posts.
+type upstreamPost struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"userId"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// Result counts what one sync run did.
+type Result struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"` // posts of unmapped upstream users or missing local users
+}
+
+// Syncer copies upstream posts into the local PostStore.
+// Upstream posts are matched by their id (stored as ExternalID),
+// so running the sync again updates posts instead of duplicating them.
+//
+// Upstream user IDs mean nothing here: upstream user 1 is not local user
+// 1. UserMap says which local user gets the posts of which upstream user;
+// posts of upstream users it does not list are skipped.
+type Syncer struct {
+	Upstream string       // base URL, e.g. DefaultUpstream
+	Client   *http.Client // nil means http.DefaultClient
+	Service  *service.UserService
+	UserMap  map[int]int // upstream userId -> local user ID
+}
+
+// ParseUserMap reads a UserMap written as "upstream=local" pairs separated
+// by commas, like "1=3,2=3". An empty string is an empty map.
+func ParseUserMap(s string) (map[int]int, error) {
+	m := make(map[int]int)
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		up, local, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		upID, err1 := strconv.Atoi(strings.TrimSpace(up))
+		localID, err2 := strconv.Atoi(strings.TrimSpace(local))
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("postsync: invalid user mapping %q, want upstream=local", pair)
+		}
+		if _, dup := m[upID]; dup {
+			return nil, fmt.Errorf("postsync: upstream user %d is mapped twice", upID)
+		}
+		m[upID] = localID
+	}
+	return m, nil
+}
+
+// Run fetches every upstream post once and imports it.
+func (s *Syncer) Run(ctx context.Context) (Result, error) {
+	var res Result
+
+	posts, err := s.fetch(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	for _, up := range posts {
+		userID, ok := s.UserMap[up.UserID]
+		if !ok {
+			res.Skipped++
+			continue
+		}
+
+		// The service checks the user under the lock its Delete holds,
+		// so a post is never imported for a user being deleted.
+		_, created, err := s.Service.UpsertExternalPost(ctx, store.Post{
+			UserID:     userID,
+			Title:      up.Title,
+			Body:       up.Body,
+			ExternalID: up.ID,
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		if created {
+			res.Created++
+		} else {
+			res.Updated++
+		}
+	}
+	return res, nil
+}
+
+// Start runs the sync every interval until ctx is cancelled.
+// Errors are logged and the next run is tried anyway.
+func (s *Syncer) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := s.Run(ctx)
+		if err != nil {
+			log.Printf("postsync: %v", err)
+		} else {
+			log.Printf("postsync: created=%d updated=%d skipped=%d", res.Created, res.Updated, res.Skipped)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) fetch(ctx context.Context) ([]upstreamPost, error) {
+	url := strings.TrimRight(s.Upstream, "/") + "/posts"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("postsync: fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("postsync: fetching %s: status %s", url, resp.Status)
+	}
+
+	var posts []upstreamPost
+	if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
+		return nil, fmt.Errorf("postsync: decoding %s: %w", url, err)
+	}
+	return posts, nil
+}
@@ -0,0 +1,91 @@
+package postsync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"httpserver/service"
+	"httpserver/store"
+)
+
+const upstreamJSON = `[
+	{"userId": 1, "id": 1, "title": "first", "body": "one"},
+	{"userId": 1, "id": 2, "title": "second", "body": "two"},
+	{"userId": 9, "id": 3, "title": "orphan", "body": "no such user"},
+	{"userId": 2, "id": 4, "title": "unmapped", "body": "not imported"}
+]`
+
+func TestSyncImportsAndUpdates(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/posts" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(upstreamJSON))
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	posts := store.NewMemoryPostStore()
+	s := &Syncer{
+		Upstream: upstream.URL,
+		Service: &service.UserService{
+			Users: store.NewMemoryUserStore(store.User{ID: 1, Name: "Alice"}, store.User{ID: 2, Name: "Bob"}),
+			Posts: posts,
+		},
+		// Upstream user 1 is our Bob; upstream user 9 maps to nobody.
+		UserMap: map[int]int{1: 2, 9: 7},
+	}
+
+	res, err := s.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res != (Result{Created: 2, Skipped: 2}) {
+		t.Errorf("first run = %+v; want 2 created, 2 skipped", res)
+	}
+
+	// A second run must not duplicate posts.
+	res, err = s.Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if res != (Result{Updated: 2, Skipped: 2}) {
+		t.Errorf("second run = %+v; want 2 updated, 2 skipped", res)
+	}
+	if n, _ := posts.CountByUser(ctx, 2); n != 2 {
+		t.Errorf("user 2 has %d posts; want 2", n)
+	}
+	if n, _ := posts.CountByUser(ctx, 1); n != 0 {
+		t.Errorf("user 1 got %d posts of upstream user 1", n)
+	}
+}
+
+func TestSyncUpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	s := &Syncer{Upstream: upstream.URL, Service: &service.UserService{Users: store.NewMemoryUserStore(), Posts: store.NewMemoryPostStore()}}
+	if _, err := s.Run(context.Background()); err == nil {
+		t.Errorf("Run with a failing upstream returned no error")
+	}
+}
+
+func TestParseUserMap(t *testing.T) {
+	m, err := ParseUserMap(" 1=3, 2=3 ")
+	if err != nil || len(m) != 2 || m[1] != 3 || m[2] != 3 {
+		t.Errorf("ParseUserMap = %v, %v", m, err)
+	}
+	if m, err := ParseUserMap(""); err != nil || len(m) != 0 {
+		t.Errorf("empty: %v, %v", m, err)
+	}
+	for _, bad := range []string{"1", "1=x", "1=2,1=3", "1=2,"} {
+		if _, err := ParseUserMap(bad); err == nil {
+			t.Errorf("ParseUserMap(%q): want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"httpserver/replication"
+	"httpserver/store"
+)
+
+// --- REPLICATION ---
+//
+// A leader logs every write to the default stores and streams the log at
+// /replication/. A follower copies the leader's users and posts, serves
+// reads from its copy and forwards (or rejects) everything else. Sessions
+// and tokens are not replicated, so logins and /me belong on the leader.
+
+var (
+	replicationRole string // "", "leader" or "follower"
+	leader          *replication.Leader
+	follower        *replication.Follower
+	readOnly        replication.ReadOnly
+)
+
+// configureReplication reads the replication settings from the environment:
+//
+//	REPLICATION_ROLE      "leader" or "follower" (off by default)
+//	REPLICATION_LEADER    base URL of the leader, for followers
+//	REPLICATION_TOKEN     bearer token of the /replication routes (required)
+//	REPLICATION_WRITES    "forward" (default) or "reject" writes on followers
+//	REPLICATION_LOG_SIZE  entries the leader keeps for followers (default 10000)
+//	REPLICATION_MAX_LAG   entries a follower may trail before it reloads a snapshot
+//
+// It must run before configurePosts, which hands the stores to the syncer.
+func configureReplication() {
+	replicationRole = strings.ToLower(os.Getenv("REPLICATION_ROLE"))
+	if replicationRole == "" {
+		return
+	}
+	if multiTenant {
+		log.Fatal("REPLICATION_ROLE cannot be combined with MULTI_TENANT")
+	}
+	// The change log and snapshots carry password hashes.
+	token := os.Getenv("REPLICATION_TOKEN")
+	if token == "" {
+		log.Fatal("REPLICATION_TOKEN is required with REPLICATION_ROLE")
+	}
+
+	switch replicationRole {
+	case "leader":
+		leader = replication.NewLeader(defaultState.rawUsers, defaultState.posts, envInt("REPLICATION_LOG_SIZE", 0))
+		leader.Token = token
+		defaultState.setStores(leader.UserStore(), leader.PostStore())
+		log.Println("Replication leader, followers connect to /replication/")
+
+	case "follower":
+		u, err := url.Parse(os.Getenv("REPLICATION_LEADER"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			log.Fatalf("invalid REPLICATION_LEADER %q", os.Getenv("REPLICATION_LEADER"))
+		}
+		writes := envOr("REPLICATION_WRITES", "forward")
+		if writes != "forward" && writes != "reject" {
+			log.Fatalf("invalid REPLICATION_WRITES %q", writes)
+		}
+		readOnly = replication.ReadOnly{Leader: u, Forward: writes == "forward"}
+
+		users, posts := store.NewMemoryUserStore(), store.NewMemoryPostStore()
+		follower = replication.NewFollower(u.String(), users, posts)
+		follower.Token = token
+		follower.Client = tracedClient
+		follower.MaxLag = uint64(envInt("REPLICATION_MAX_LAG", 0))
+		defaultState.setStores(store.TracedUserStore{Next: users}, store.TracedPostStore{Next: posts})
+		// Replicated writes bypass the stores' search index.
+		follower.OnLoad = func(replication.Snapshot) { defaultState.index.Rebuild(context.Background(), users) }
+		follower.OnApply = func(e replication.Entry) {
+			switch e.Op {
+			case replication.OpPutUser:
+				defaultState.index.Put(e.User.ID, e.User.Name, e.User.Email)
+			case replication.OpDeleteUser:
+				defaultState.index.Remove(e.ID)
+			}
+		}
+		go follower.Run(context.Background())
+		log.Printf("Replication follower of %s, writes: %s", u, writes)
+
+	default:
+		log.Fatalf("invalid REPLICATION_ROLE %q, want leader or follower", replicationRole)
+	}
+}
+
+// replicationHandler serves /replication/: the log on a leader, the
+// status on a follower.
+func replicationHandler() http.Handler {
+	if leader != nil {
+		return leader.Handler()
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /replication/status", follower.StatusHandler())
+	return mux
+}
+
+// envInt reads a non-negative integer, or returns fallback when key is unset.
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("invalid %s %q", key, v)
+	}
+	return n
+}
@@ -0,0 +1,258 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"httpserver/store"
+)
+
+// --- FOLLOWER ---
+
+// errResync makes Run reload a snapshot instead of resuming the stream.
+var errResync = errors.New("replication: resync needed")
+
+// Follower copies a leader's data into its own stores. Serve reads from
+// Users and Posts, and send writes to the leader (see ReadOnly).
+type Follower struct {
+	Leader string // base URL, e.g. "http://127.0.0.1:8080"
+	Token  string // sent as a bearer token when set
+	Client *http.Client
+
+	Users *store.MemoryUserStore
+	Posts *store.MemoryPostStore
+
+	// MaxLag is how many entries the follower may be behind before it
+	// drops the stream and loads a snapshot (0 never does).
+	MaxLag uint64
+	// RetryDelay is the wait after a failed connection (0 means 1s).
+	RetryDelay time.Duration
+	// Now returns the time of contact with the leader.
+	Now func() time.Time
+	// OnLoad and OnApply, when set, are called after a snapshot replaced
+	// the stores and after an entry was applied to them, for state kept
+	// next to the stores (a search index, say).
+	OnLoad  func(snap Snapshot)
+	OnApply func(e Entry)
+
+	mu          sync.Mutex
+	applied     uint64
+	appliedTime time.Time
+	head        uint64
+	headTime    time.Time
+	connected   bool
+	lastContact time.Time
+	resyncs     int
+	synced      chan struct{} // closed after the first snapshot
+}
+
+// NewFollower returns a follower of leader that fills users and posts.
+func NewFollower(leader string, users *store.MemoryUserStore, posts *store.MemoryPostStore) *Follower {
+	return &Follower{
+		Leader: strings.TrimSuffix(leader, "/"),
+		Client: http.DefaultClient,
+		Users:  users,
+		Posts:  posts,
+		Now:    time.Now,
+		synced: make(chan struct{}),
+	}
+}
+
+// Run replicates until ctx is done. It loads a snapshot, then streams the
+// log, reconnecting after errors and reloading a snapshot when needed.
+func (f *Follower) Run(ctx context.Context) error {
+	needSnapshot := true
+	for {
+		err := func() error {
+			if needSnapshot {
+				if err := f.loadSnapshot(ctx); err != nil {
+					return err
+				}
+				needSnapshot = false
+			}
+			return f.stream(ctx)
+		}()
+		f.setConnected(false)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errResync) {
+			needSnapshot = true
+			f.mu.Lock()
+			f.resyncs++
+			f.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			log.Printf("replication: %v", err)
+		}
+
+		delay := f.RetryDelay
+		if delay <= 0 {
+			delay = time.Second
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Synced is closed once the first snapshot is loaded.
+func (f *Follower) Synced() <-chan struct{} { return f.synced }
+
+// Status reports how far behind the leader the follower is.
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := Status{
+		Role:        "follower",
+		Leader:      f.Leader,
+		AppliedSeq:  f.applied,
+		LeaderSeq:   f.head,
+		Connected:   f.connected,
+		LastContact: f.lastContact,
+		Resyncs:     f.resyncs,
+	}
+	if f.head > f.applied {
+		s.LagEntries = f.head - f.applied
+		s.LagSeconds = f.headTime.Sub(f.appliedTime).Seconds()
+	}
+	return s
+}
+
+// StatusHandler serves Status as JSON.
+func (f *Follower) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, f.Status())
+	})
+}
+
+func (f *Follower) loadSnapshot(ctx context.Context) error {
+	resp, err := f.get(ctx, "/replication/snapshot")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	snap.Load(f.Users, f.Posts)
+	if f.OnLoad != nil {
+		f.OnLoad(snap)
+	}
+
+	f.mu.Lock()
+	f.applied, f.appliedTime = snap.Seq, snap.Time
+	f.head, f.headTime = max(f.head, snap.Seq), snap.Time
+	f.lastContact = f.Now()
+	select {
+	case <-f.synced:
+	default:
+		close(f.synced)
+	}
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *Follower) stream(ctx context.Context) error {
+	f.mu.Lock()
+	after := f.applied
+	f.mu.Unlock()
+
+	resp, err := f.get(ctx, "/replication/stream?after="+strconv.FormatUint(after, 10))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f.setConnected(true)
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 4<<20)
+	for sc.Scan() {
+		var m message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return fmt.Errorf("stream: %w", err)
+		}
+		if err := f.handle(ctx, m); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("stream: %w", err)
+	}
+	return errors.New("stream: closed by leader")
+}
+
+// handle records the leader's head and applies the entry, if any.
+func (f *Follower) handle(ctx context.Context, m message) error {
+	f.mu.Lock()
+	f.head, f.headTime = m.Head, m.HeadTime
+	f.lastContact = f.Now()
+	applied := f.applied
+	f.mu.Unlock()
+
+	if f.MaxLag > 0 && m.Head > applied && m.Head-applied > f.MaxLag {
+		return errResync
+	}
+	if m.Entry == nil {
+		return nil
+	}
+	if m.Entry.Seq != applied+1 {
+		return errResync // a gap means we can no longer trust our copy
+	}
+	if err := Apply(ctx, f.Users, f.Posts, *m.Entry); err != nil {
+		return err
+	}
+	if f.OnApply != nil {
+		f.OnApply(*m.Entry)
+	}
+
+	f.mu.Lock()
+	f.applied, f.appliedTime = m.Entry.Seq, m.Entry.Time
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *Follower) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.Leader+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Token)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+		return nil, errResync
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+func (f *Follower) setConnected(v bool) {
+	f.mu.Lock()
+	f.connected = v
+	f.mu.Unlock()
+}
@@ -0,0 +1,272 @@
+package replication
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"httpserver/store"
+)
+
+// --- LEADER ---
+
+// Leader records the writes made through its stores and serves them to
+// followers. Writes are serialized, so the log order is the store order.
+type Leader struct {
+	// Token must be sent by followers as a bearer token. The log contains
+	// password hashes, so without a Token every request is refused.
+	Token string
+	// Heartbeat is how often an idle stream reports the head (0 means 5s).
+	Heartbeat time.Duration
+
+	log   *Log
+	mu    sync.Mutex // held during a write and its Append, and during snapshots
+	users store.UserStore
+	posts store.PostStore
+}
+
+// NewLeader wraps users and posts. Use the stores returned by UserStore
+// and PostStore for every write, or the change will not be replicated.
+func NewLeader(users store.UserStore, posts store.PostStore, logSize int) *Leader {
+	return &Leader{log: NewLog(logSize), users: users, posts: posts}
+}
+
+// Log returns the change log.
+func (l *Leader) Log() *Log { return l.log }
+
+// UserStore returns the logging user store.
+func (l *Leader) UserStore() store.UserStore { return loggedUsers{l} }
+
+// PostStore returns the logging post store.
+func (l *Leader) PostStore() store.PostStore { return loggedPosts{l} }
+
+// Snapshot copies every user and post while writes are paused.
+func (l *Leader) Snapshot(ctx context.Context) (Snapshot, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snap, err := NewSnapshot(ctx, l.users, l.posts)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap.Seq, snap.Time = l.log.Head()
+	return snap, nil
+}
+
+// Status reports the head of the log.
+func (l *Leader) Status() Status {
+	head, _ := l.log.Head()
+	return Status{Role: "leader", AppliedSeq: head, LeaderSeq: head, Connected: true}
+}
+
+// write runs fn and, when it succeeds, appends the entry it returns.
+func (l *Leader) write(fn func() (Entry, error)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, err := fn()
+	if err != nil {
+		return err
+	}
+	l.log.Append(e)
+	return nil
+}
+
+// --- LOGGING STORES ---
+
+type loggedUsers struct{ l *Leader }
+
+func (s loggedUsers) List(ctx context.Context) ([]store.User, error) { return s.l.users.List(ctx) }
+
+func (s loggedUsers) Get(ctx context.Context, id int) (store.User, error) {
+	return s.l.users.Get(ctx, id)
+}
+
+func (s loggedUsers) GetByEmail(ctx context.Context, email string) (store.User, error) {
+	return s.l.users.GetByEmail(ctx, email)
+}
+
+func (s loggedUsers) Create(ctx context.Context, u store.User) (out store.User, err error) {
+	err = s.l.write(func() (Entry, error) {
+		out, err = s.l.users.Create(ctx, u)
+		return Entry{Op: OpPutUser, User: fromStore(out)}, err
+	})
+	return out, err
+}
+
+func (s loggedUsers) Update(ctx context.Context, u store.User) (out store.User, err error) {
+	err = s.l.write(func() (Entry, error) {
+		out, err = s.l.users.Update(ctx, u)
+		return Entry{Op: OpPutUser, User: fromStore(out)}, err
+	})
+	return out, err
+}
+
+func (s loggedUsers) Delete(ctx context.Context, id int) error {
+	return s.l.write(func() (Entry, error) {
+		return Entry{Op: OpDeleteUser, ID: id}, s.l.users.Delete(ctx, id)
+	})
+}
+
+type loggedPosts struct{ l *Leader }
+
+func (s loggedPosts) ListByUser(ctx context.Context, userID int) ([]store.Post, error) {
+	return s.l.posts.ListByUser(ctx, userID)
+}
+
+func (s loggedPosts) Get(ctx context.Context, id int) (store.Post, error) {
+	return s.l.posts.Get(ctx, id)
+}
+
+func (s loggedPosts) CountByUser(ctx context.Context, userID int) (int, error) {
+	return s.l.posts.CountByUser(ctx, userID)
+}
+
+func (s loggedPosts) Create(ctx context.Context, p store.Post) (out store.Post, err error) {
+	err = s.l.write(func() (Entry, error) {
+		out, err = s.l.posts.Create(ctx, p)
+		return Entry{Op: OpPutPost, Post: &out}, err
+	})
+	return out, err
+}
+
+func (s loggedPosts) Update(ctx context.Context, p store.Post) (out store.Post, err error) {
+	err = s.l.write(func() (Entry, error) {
+		out, err = s.l.posts.Update(ctx, p)
+		return Entry{Op: OpPutPost, Post: &out}, err
+	})
+	return out, err
+}
+
+func (s loggedPosts) Delete(ctx context.Context, id int) error {
+	return s.l.write(func() (Entry, error) {
+		return Entry{Op: OpDeletePost, ID: id}, s.l.posts.Delete(ctx, id)
+	})
+}
+
+func (s loggedPosts) DeleteByUser(ctx context.Context, userID int) (n int, err error) {
+	err = s.l.write(func() (Entry, error) {
+		n, err = s.l.posts.DeleteByUser(ctx, userID)
+		return Entry{Op: OpDeleteUserPosts, ID: userID}, err
+	})
+	return n, err
+}
+
+func (s loggedPosts) UpsertExternal(ctx context.Context, p store.Post) (out store.Post, created bool, err error) {
+	err = s.l.write(func() (Entry, error) {
+		out, created, err = s.l.posts.UpsertExternal(ctx, p)
+		return Entry{Op: OpPutPost, Post: &out}, err
+	})
+	return out, created, err
+}
+
+// --- HTTP ---
+
+// Handler serves the /replication endpoints.
+func (l *Leader) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /replication/stream", l.serveStream)
+	mux.HandleFunc("GET /replication/snapshot", l.serveSnapshot)
+	mux.HandleFunc("GET /replication/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, l.Status())
+	})
+	return requireToken(l.Token, mux)
+}
+
+func (l *Leader) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := l.Snapshot(r.Context())
+	if err != nil {
+		log.Printf("replication: snapshot: %v", err)
+		http.Error(w, "Snapshot failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, snap)
+}
+
+func (l *Leader) serveStream(w http.ResponseWriter, r *http.Request) {
+	var after uint64
+	if v := r.URL.Query().Get("after"); v != "" {
+		var err error
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, _, err := l.log.Since(after, 0); errors.Is(err, ErrTrimmed) {
+		http.Error(w, "Log position trimmed, load a snapshot", http.StatusGone)
+		return
+	}
+
+	heartbeat := l.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 5 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	send := func(m message) bool {
+		if err := enc.Encode(m); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	head, headTime := l.log.Head()
+	if !send(message{Head: head, HeadTime: headTime}) {
+		return
+	}
+	for {
+		entries, changed, err := l.log.Since(after, 256)
+		if err != nil {
+			return // trimmed while we were sending; the follower reconnects and gets 410
+		}
+		head, headTime := l.log.Head()
+		for i := range entries {
+			if !send(message{Head: head, HeadTime: headTime, Entry: &entries[i]}) {
+				return
+			}
+			after = entries[i].Seq
+		}
+		if len(entries) > 0 {
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if !send(message{Head: head, HeadTime: headTime}) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// --- HELPERS ---
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
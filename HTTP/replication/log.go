@@ -0,0 +1,235 @@
+// Package replication copies the data of a leader instance to followers.
+//
+// The leader wraps its stores so every successful write is appended to an
+// in-memory change log with a sequence number. Followers stream the log
+// over HTTP (newline-delimited JSON), apply each entry to their own stores
+// and serve reads. A follower that is too far behind, or that asks for
+// entries the leader already dropped, reloads a full snapshot first.
+//
+//	GET /replication/stream?after=N   entries after N, then new ones as they happen
+//	GET /replication/snapshot         every user and post, with the seq it matches
+//	GET /replication/status           role, sequence numbers and lag
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"httpserver/store"
+)
+
+// --- CHANGE LOG ---
+
+// Op is the kind of change of an Entry.
+type Op string
+
+const (
+	OpPutUser         Op = "putUser"
+	OpDeleteUser      Op = "deleteUser"
+	OpPutPost         Op = "putPost"
+	OpDeletePost      Op = "deletePost"
+	OpDeleteUserPosts Op = "deleteUserPosts"
+)
+
+// Entry is one change. User is set for OpPutUser, Post for OpPutPost and
+// ID (a user or post ID) for the deletes.
+type Entry struct {
+	Seq  uint64      `json:"seq"`
+	Time time.Time   `json:"time"`
+	Op   Op          `json:"op"`
+	User *User       `json:"user,omitempty"`
+	Post *store.Post `json:"post,omitempty"`
+	ID   int         `json:"id,omitempty"`
+}
+
+// User is store.User with its password hash, which store.User keeps out
+// of JSON. Followers need it to take over from the leader.
+type User struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	PasswordHash  string `json:"passwordHash,omitempty"`
+}
+
+func fromStore(u store.User) *User {
+	return &User{ID: u.ID, Name: u.Name, Email: u.Email, EmailVerified: u.EmailVerified, PasswordHash: u.PasswordHash}
+}
+
+func (u *User) toStore() store.User {
+	return store.User{ID: u.ID, Name: u.Name, Email: u.Email, EmailVerified: u.EmailVerified, PasswordHash: u.PasswordHash}
+}
+
+// ErrTrimmed is returned for positions the log no longer holds.
+var ErrTrimmed = errors.New("replication: log position no longer available")
+
+// Log keeps the most recent entries. It is safe for concurrent use.
+type Log struct {
+	mu       sync.Mutex
+	entries  []Entry
+	capacity int
+	head     uint64    // seq of the last entry, 0 when empty
+	headTime time.Time // time of the last entry
+	changed  chan struct{}
+
+	// Now returns the time written on new entries.
+	Now func() time.Time
+}
+
+// NewLog returns a log that keeps up to capacity entries (0 means 10000).
+func NewLog(capacity int) *Log {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &Log{capacity: capacity, changed: make(chan struct{}), Now: time.Now}
+}
+
+// Append numbers e and adds it, dropping the oldest entry when full.
+func (l *Log) Append(e Entry) Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.head++
+	e.Seq, e.Time = l.head, l.Now().UTC()
+	l.headTime = e.Time
+	l.entries = append(l.entries, e)
+	if len(l.entries) > l.capacity {
+		// Copy instead of re-slicing, so the dropped entries can be freed.
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-l.capacity:]...)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+	return e
+}
+
+// Continue makes the numbering of an empty log go on after seq, e.g. from
+// the change log archived by an earlier run.
+func (l *Log) Continue(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) > 0 {
+		return errors.New("replication: Continue on a log that has entries")
+	}
+	l.head = seq
+	return nil
+}
+
+// Head returns the seq and time of the last entry.
+func (l *Log) Head() (uint64, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head, l.headTime
+}
+
+// Since returns up to max entries after seq, and a channel that is closed
+// on the next Append. It returns ErrTrimmed when entries after seq were
+// already dropped.
+func (l *Log) Since(seq uint64, max int) ([]Entry, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq >= l.head {
+		return nil, l.changed, nil
+	}
+	// After Continue the log is empty but its head is not: the entries up
+	// to the head were written by an earlier run and are gone.
+	if len(l.entries) == 0 {
+		return nil, nil, ErrTrimmed
+	}
+	first := l.entries[0].Seq
+	if seq+1 < first {
+		return nil, nil, ErrTrimmed
+	}
+	start := int(seq + 1 - first)
+	end := min(len(l.entries), start+max)
+	return append([]Entry(nil), l.entries[start:end]...), l.changed, nil
+}
+
+// --- WIRE MESSAGES ---
+
+// message is one line of the stream. Every line carries the leader's head,
+// so followers know their lag even when no entry is sent (heartbeats).
+type message struct {
+	Head     uint64    `json:"head"`
+	HeadTime time.Time `json:"headTime"`
+	Entry    *Entry    `json:"entry,omitempty"`
+}
+
+// Snapshot is a consistent copy of the leader's data at Seq.
+type Snapshot struct {
+	Seq   uint64       `json:"seq"`
+	Time  time.Time    `json:"time"`
+	Users []User       `json:"users"`
+	Posts []store.Post `json:"posts"`
+}
+
+// Status describes the replication state of an instance.
+type Status struct {
+	Role        string    `json:"role"` // "leader" or "follower"
+	Leader      string    `json:"leader,omitempty"`
+	AppliedSeq  uint64    `json:"appliedSeq"`
+	LeaderSeq   uint64    `json:"leaderSeq"`
+	LagEntries  uint64    `json:"lagEntries"`
+	LagSeconds  float64   `json:"lagSeconds"` // leader time between the last applied entry and the head
+	Connected   bool      `json:"connected"`
+	LastContact time.Time `json:"lastContact,omitzero"`
+	Resyncs     int       `json:"resyncs"`
+}
+
+// NewSnapshot copies users and posts. Seq and Time are left for the caller,
+// who must keep writes out while it runs for the copy to be consistent.
+func NewSnapshot(ctx context.Context, users store.UserStore, posts store.PostStore) (Snapshot, error) {
+	list, err := users.List(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap := Snapshot{Users: make([]User, 0, len(list)), Posts: []store.Post{}}
+	for _, u := range list {
+		snap.Users = append(snap.Users, *fromStore(u))
+		p, err := posts.ListByUser(ctx, u.ID)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snap.Posts = append(snap.Posts, p...)
+	}
+	return snap, nil
+}
+
+// Load replaces the content of users and posts with the snapshot.
+func (s Snapshot) Load(users *store.MemoryUserStore, posts *store.MemoryPostStore) {
+	list := make([]store.User, len(s.Users))
+	for i := range s.Users {
+		list[i] = s.Users[i].toStore()
+	}
+	users.Reset(list...)
+	posts.Reset(s.Posts...)
+}
+
+// Apply makes the change of e to users and posts. Deleting a record that
+// is already gone is not an error, so entries can be applied twice.
+func Apply(ctx context.Context, users *store.MemoryUserStore, posts *store.MemoryPostStore, e Entry) error {
+	var err error
+	switch e.Op {
+	case OpPutUser:
+		err = users.Put(ctx, e.User.toStore())
+	case OpDeleteUser:
+		err = users.Delete(ctx, e.ID)
+	case OpPutPost:
+		err = posts.Put(ctx, *e.Post)
+	case OpDeletePost:
+		err = posts.Delete(ctx, e.ID)
+	case OpDeleteUserPosts:
+		_, err = posts.DeleteByUser(ctx, e.ID)
+	default:
+		return fmt.Errorf("entry %d: unknown op %q", e.Seq, e.Op)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
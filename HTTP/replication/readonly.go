@@ -0,0 +1,44 @@
+package replication
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// --- READ-ONLY FOLLOWERS ---
+
+// LeaderHeader tells clients where writes go when a follower rejects them.
+const LeaderHeader = "X-Replication-Leader"
+
+// ReadOnly lets reads through and sends every other request to Leader,
+// or rejects it with 503 when Forward is false.
+type ReadOnly struct {
+	Leader  *url.URL
+	Forward bool
+}
+
+// Wrap returns next guarded by ro.
+func (ro ReadOnly) Wrap(next http.Handler) http.Handler {
+	var proxy *httputil.ReverseProxy
+	if ro.Forward {
+		proxy = &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(ro.Leader)
+			r.SetXForwarded()
+			r.Out.Host = ro.Leader.Host
+		}}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			if proxy != nil {
+				proxy.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(LeaderHeader, ro.Leader.String())
+			http.Error(w, "Read-only replica, send writes to the leader", http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"httpserver/store"
+)
+
+// cluster is a leader and its followers, each on a loopback port.
+type cluster struct {
+	leader    *Leader
+	leaderSrv *httptest.Server
+	followers []*Follower
+}
+
+func newCluster(t *testing.T, logSize, followers int, setup func(*Follower)) *cluster {
+	t.Helper()
+	users := store.NewMemoryUserStore(store.User{ID: 1, Name: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+	l := NewLeader(users, store.NewMemoryPostStore(), logSize)
+	l.Heartbeat = 20 * time.Millisecond
+	l.Token = "secret"
+	srv := httptest.NewServer(l.Handler())
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cluster{leader: l, leaderSrv: srv}
+	done := make(chan struct{}, followers)
+	for range followers {
+		f := NewFollower(srv.URL, store.NewMemoryUserStore(), store.NewMemoryPostStore())
+		f.Token = l.Token
+		f.RetryDelay = 10 * time.Millisecond
+		if setup != nil {
+			setup(f)
+		}
+		go func() { f.Run(ctx); done <- struct{}{} }()
+		c.followers = append(c.followers, f)
+	}
+	// Stop the followers before the server, or Close waits on their streams.
+	t.Cleanup(func() {
+		cancel()
+		for range followers {
+			<-done
+		}
+	})
+	return c
+}
+
+// waitApplied waits until every follower applied the leader's head.
+func (c *cluster) waitApplied(t *testing.T) {
+	t.Helper()
+	head, _ := c.leader.Log().Head()
+	for _, f := range c.followers {
+		waitFor(t, func() bool { return f.Status().AppliedSeq >= head })
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for replication")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowersApplyWrites(t *testing.T) {
+	ctx := context.Background()
+	c := newCluster(t, 0, 2, nil)
+	users, posts := c.leader.UserStore(), c.leader.PostStore()
+
+	bob, err := users.Create(ctx, store.User{Name: "Bob", Email: "bob@example.com", PasswordHash: "h2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := posts.Create(ctx, store.Post{UserID: bob.ID, Title: "hi"})
+	posts.Create(ctx, store.Post{UserID: 1, Title: "alice's"})
+	bob.Name = "Robert"
+	users.Update(ctx, bob)
+	posts.Delete(ctx, p.ID)
+	posts.DeleteByUser(ctx, 1)
+	users.Delete(ctx, 1)
+	c.waitApplied(t)
+
+	for i, f := range c.followers {
+		got, err := f.Users.Get(ctx, bob.ID)
+		if err != nil || got.Name != "Robert" || got.PasswordHash != "h2" {
+			t.Errorf("follower %d: Get(bob) = %+v, %v", i, got, err)
+		}
+		if _, err := f.Users.Get(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("follower %d: deleted user still there (err = %v)", i, err)
+		}
+		if n, _ := f.Posts.CountByUser(ctx, bob.ID); n != 0 {
+			t.Errorf("follower %d: bob has %d posts; want 0", i, n)
+		}
+		if n, _ := f.Posts.CountByUser(ctx, 1); n != 0 {
+			t.Errorf("follower %d: alice has %d posts; want 0", i, n)
+		}
+		// IDs continue from the leader's, so a promoted follower does not reuse them.
+		if u, _ := f.Users.Create(ctx, store.User{Name: "New", Email: "new@example.com"}); u.ID != bob.ID+1 {
+			t.Errorf("follower %d: next user ID = %d; want %d", i, u.ID, bob.ID+1)
+		}
+	}
+
+	st := c.followers[0].Status()
+	if !st.Connected || st.LagEntries != 0 || st.Resyncs != 0 || st.LastContact.IsZero() {
+		t.Errorf("Status() = %+v", st)
+	}
+}
+
+// gate fails every request while it is closed.
+type gate struct {
+	closed atomic.Bool
+}
+
+func (g *gate) RoundTrip(r *http.Request) (*http.Response, error) {
+	if g.closed.Load() {
+		return nil, errors.New("gate closed")
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// partition cuts the follower off while write runs.
+func (c *cluster) partition(t *testing.T, g *gate, write func()) {
+	t.Helper()
+	g.closed.Store(true)
+	c.leaderSrv.CloseClientConnections()
+	waitFor(t, func() bool { return !c.followers[0].Status().Connected })
+	write()
+	g.closed.Store(false)
+}
+
+func TestFollowerResyncsFromSnapshotWhenLogTrimmed(t *testing.T) {
+	ctx := context.Background()
+	g := &gate{}
+	c := newCluster(t, 3, 1, func(f *Follower) { f.Client = &http.Client{Transport: g} })
+	f := c.followers[0]
+	<-f.Synced()
+
+	c.partition(t, g, func() {
+		for range 10 {
+			c.leader.PostStore().Create(ctx, store.Post{UserID: 1, Title: "p"})
+		}
+	})
+	c.waitApplied(t)
+
+	if n, _ := f.Posts.CountByUser(ctx, 1); n != 10 {
+		t.Errorf("follower has %d posts; want 10", n)
+	}
+	if st := f.Status(); st.Resyncs == 0 {
+		t.Errorf("Resyncs = 0; want a snapshot reload")
+	}
+}
+
+func TestFollowerResyncsWhenTooFarBehind(t *testing.T) {
+	ctx := context.Background()
+	g := &gate{}
+	c := newCluster(t, 0, 1, func(f *Follower) {
+		f.Client = &http.Client{Transport: g}
+		f.MaxLag = 5
+	})
+	f := c.followers[0]
+	<-f.Synced()
+
+	c.partition(t, g, func() {
+		for i := range 20 {
+			c.leader.UserStore().Create(ctx, store.User{Name: "u", Email: fmt.Sprintf("u%d@example.com", i)})
+		}
+	})
+	c.waitApplied(t)
+
+	if st := f.Status(); st.Resyncs == 0 {
+		t.Errorf("Resyncs = 0; want a snapshot reload past MaxLag")
+	}
+	if _, err := f.Users.Get(ctx, 21); err != nil {
+		t.Errorf("Get(21): %v", err)
+	}
+}
+
+func TestFollowerStatusReportsLag(t *testing.T) {
+	f := NewFollower("http://leader", store.NewMemoryUserStore(), store.NewMemoryPostStore())
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.Now = func() time.Time { return now }
+	f.applied, f.appliedTime = 4, now.Add(-3*time.Second)
+
+	if err := f.handle(context.Background(), message{Head: 9, HeadTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	st := f.Status()
+	if st.LagEntries != 5 || st.LagSeconds != 3 || st.LeaderSeq != 9 || !st.LastContact.Equal(now) {
+		t.Errorf("Status() = %+v", st)
+	}
+
+	// An entry that skips a seq cannot be applied.
+	err := f.handle(context.Background(), message{Head: 9, HeadTime: now, Entry: &Entry{Seq: 6, Op: OpDeleteUser, ID: 1}})
+	if !errors.Is(err, errResync) {
+		t.Errorf("gap: err = %v; want errResync", err)
+	}
+}
+
+func TestLeaderRequiresToken(t *testing.T) {
+	l := NewLeader(store.NewMemoryUserStore(), store.NewMemoryPostStore(), 0)
+	l.Token = "secret"
+	h := l.Handler()
+
+	for _, auth := range []string{"", "Bearer wrong"} {
+		req := httptest.NewRequest("GET", "/replication/snapshot", nil)
+		req.Header.Set("Authorization", auth)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("auth %q: status %d; want 401", auth, rec.Code)
+		}
+	}
+
+	// Without a token nothing is served, not even to a caller that sends none.
+	l.Token = ""
+	rec := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/replication/snapshot", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("leader without a token: status %d; want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/replication/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var st Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil || st.Role != "leader" {
+		t.Errorf("status = %d %+v, %v", rec.Code, st, err)
+	}
+}
+
+func TestLeaderStreamGoneWhenTrimmed(t *testing.T) {
+	l := NewLeader(store.NewMemoryUserStore(), store.NewMemoryPostStore(), 2)
+	l.Token = "secret"
+	for range 5 {
+		l.UserStore().Create(context.Background(), store.User{Name: "x"})
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/replication/stream?after=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	l.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusGone {
+		t.Errorf("status %d; want 410", rec.Code)
+	}
+}
+
+func TestSinceAfterContinue(t *testing.T) {
+	log := NewLog(10)
+	if err := log.Continue(50); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := log.Since(20, 10); !errors.Is(err, ErrTrimmed) {
+		t.Errorf("Since(20) on a continued log: err = %v; want ErrTrimmed", err)
+	}
+	if entries, changed, err := log.Since(50, 10); err != nil || len(entries) != 0 || changed == nil {
+		t.Errorf("Since(head) = %v, %v, %v; want to wait", entries, changed, err)
+	}
+
+	log.Append(Entry{Op: OpDeleteUser, ID: 1})
+	entries, _, err := log.Since(50, 10)
+	if err != nil || len(entries) != 1 || entries[0].Seq != 51 {
+		t.Errorf("Since(50) = %v, %v; want entry 51", entries, err)
+	}
+	if _, _, err := log.Since(49, 10); !errors.Is(err, ErrTrimmed) {
+		t.Errorf("Since(49): err = %v; want ErrTrimmed", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	var leaderGot string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaderGot = r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer leader.Close()
+	u, _ := url.Parse(leader.URL)
+	local := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("local")) })
+
+	fwd := ReadOnly{Leader: u, Forward: true}.Wrap(local)
+	rec := httptest.NewRecorder()
+	fwd.ServeHTTP(rec, httptest.NewRequest("GET", "/users", nil))
+	if rec.Body.String() != "local" {
+		t.Errorf("GET was not served locally: %q", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	fwd.ServeHTTP(rec, httptest.NewRequest("POST", "/users/create", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusCreated || leaderGot != "POST /users/create" {
+		t.Errorf("POST forwarded as %q with status %d", leaderGot, rec.Code)
+	}
+
+	reject := ReadOnly{Leader: u}.Wrap(local)
+	rec = httptest.NewRecorder()
+	reject.ServeHTTP(rec, httptest.NewRequest("DELETE", "/users/1", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get(LeaderHeader) != leader.URL {
+		t.Errorf("DELETE: status %d, leader header %q", rec.Code, rec.Header().Get(LeaderHeader))
+	}
+}
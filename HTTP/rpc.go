@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"httpserver/jsonrpc"
+	"httpserver/service"
+	"httpserver/store"
+)
+
+// --- JSON-RPC ERROR CODES ---
+//
+// -32000 to -32099 are reserved by the specification for server errors.
+const (
+	rpcCodeNotFound = -32001
+	rpcCodeConflict = -32002
+	rpcCodeQuota    = -32003
+)
+
+// --- JSON-RPC ENDPOINT (/rpc) ---
+//
+// The methods call the same user service as the REST handlers:
+//
+//	users.list   {}                                     -> [user, ...]
+//	users.get    {"id": 1} or [1]                       -> user
+//	users.create {"name", "email", "password"}          -> user
+//	users.update {"id": 1, "name"?, "email"?}           -> user
+//	users.delete {"id": 1} or [1]                       -> null
+
+func newRPCServer() *jsonrpc.Server {
+	s := jsonrpc.NewServer()
+
+	s.Register("users.list", func(ctx context.Context, params json.RawMessage) (any, error) {
+		users, err := stateFrom(ctx).service.List(ctx)
+		return users, rpcError(err)
+	})
+
+	s.Register("users.get", func(ctx context.Context, params json.RawMessage) (any, error) {
+		id, err := rpcID(params)
+		if err != nil {
+			return nil, err
+		}
+		u, err := stateFrom(ctx).service.Get(ctx, id)
+		return u, rpcError(err)
+	})
+
+	s.Register("users.create", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var in service.CreateUserInput
+		if err := jsonrpc.DecodeParams(params, &in); err != nil {
+			return nil, err
+		}
+		u, err := stateFrom(ctx).service.Create(ctx, in)
+		return u, rpcError(err)
+	})
+
+	s.Register("users.update", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var in struct {
+			ID int `json:"id"`
+			service.UpdateUserInput
+		}
+		if err := jsonrpc.DecodeParams(params, &in); err != nil {
+			return nil, err
+		}
+		if in.ID <= 0 {
+			return nil, jsonrpc.InvalidParams("Invalid params", []service.FieldError{{Field: "id", Message: "must be a positive integer"}})
+		}
+		u, err := stateFrom(ctx).service.Update(ctx, in.ID, in.UpdateUserInput)
+		return u, rpcError(err)
+	})
+
+	s.Register("users.delete", func(ctx context.Context, params json.RawMessage) (any, error) {
+		id, err := rpcID(params)
+		if err != nil {
+			return nil, err
+		}
+		return nil, rpcError(stateFrom(ctx).service.Delete(ctx, id))
+	})
+
+	return s
+}
+
+// rpcID reads the user id from {"id": 1} or [1].
+func rpcID(params json.RawMessage) (int, error) {
+	var id int
+	if len(params) > 0 && params[0] == '[' {
+		var list []int
+		if err := jsonrpc.DecodeParams(params, &list); err != nil {
+			return 0, err
+		}
+		if len(list) == 1 {
+			id = list[0]
+		}
+	} else {
+		var obj struct {
+			ID int `json:"id"`
+		}
+		if err := jsonrpc.DecodeParams(params, &obj); err != nil {
+			return 0, err
+		}
+		id = obj.ID
+	}
+
+	if id <= 0 {
+		return 0, jsonrpc.InvalidParams("Invalid params", []service.FieldError{{Field: "id", Message: "must be a positive integer"}})
+	}
+	return id, nil
+}
+
+// rpcError maps service and store errors to JSON-RPC errors.
+// Validation failures become "invalid params" with every field in data.
+func rpcError(err error) error {
+	var verr *service.ValidationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &verr):
+		return jsonrpc.InvalidParams("Invalid params", verr.Fields)
+	case errors.Is(err, store.ErrNotFound):
+		return &jsonrpc.Error{Code: rpcCodeNotFound, Message: "User not found"}
+	case errors.Is(err, store.ErrEmailTaken):
+		return &jsonrpc.Error{Code: rpcCodeConflict, Message: "Email already in use"}
+	case errors.Is(err, service.ErrUserHasPosts):
+		return &jsonrpc.Error{Code: rpcCodeConflict, Message: "User still has posts"}
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return &jsonrpc.Error{Code: rpcCodeQuota, Message: "User quota exceeded"}
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"httpserver/service"
+	"httpserver/store"
+)
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int                  `json:"code"`
+		Data []service.FieldError `json:"data"`
+	} `json:"error"`
+}
+
+func callRPC(t *testing.T, body string) rpcResponse {
+	t.Helper()
+	rec := serve("POST", "/rpc", body)
+	var resp rpcResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestRPCUsers(t *testing.T) {
+	resetPostsState(t, service.DeleteRestrict)
+
+	resp := callRPC(t, `{"jsonrpc":"2.0","method":"users.create","params":{"name":"Dan","email":"dan@example.com"},"id":1}`)
+	var u store.User
+	if resp.Error != nil || json.Unmarshal(resp.Result, &u) != nil || u.ID != 2 {
+		t.Fatalf("users.create = %s, %+v", resp.Result, resp.Error)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.get","params":[2],"id":2}`)
+	if resp.Error != nil || json.Unmarshal(resp.Result, &u) != nil || u.Name != "Dan" {
+		t.Errorf("users.get = %s, %+v", resp.Result, resp.Error)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.update","params":{"id":2,"name":"Daniel"},"id":3}`)
+	if resp.Error != nil || json.Unmarshal(resp.Result, &u) != nil || u.Name != "Daniel" {
+		t.Errorf("users.update = %s, %+v", resp.Result, resp.Error)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.list","id":4}`)
+	var list []store.User
+	if resp.Error != nil || json.Unmarshal(resp.Result, &list) != nil || len(list) != 2 {
+		t.Errorf("users.list = %s, %+v", resp.Result, resp.Error)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.delete","params":{"id":2},"id":5}`)
+	if resp.Error != nil {
+		t.Errorf("users.delete error = %+v", resp.Error)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.get","params":{"id":2},"id":6}`)
+	if resp.Error == nil || resp.Error.Code != rpcCodeNotFound {
+		t.Errorf("users.get after delete error = %+v; want not found", resp.Error)
+	}
+}
+
+func TestRPCValidationErrors(t *testing.T) {
+	resetPostsState(t, service.DeleteRestrict)
+
+	resp := callRPC(t, `{"jsonrpc":"2.0","method":"users.create","params":{"name":"","email":"nope"},"id":1}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("error = %+v; want invalid params", resp.Error)
+	}
+	if len(resp.Error.Data) != 2 {
+		t.Errorf("details = %+v; want name and email", resp.Error.Data)
+	}
+
+	resp = callRPC(t, `{"jsonrpc":"2.0","method":"users.get","params":{"id":0},"id":2}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("users.get with id 0: error = %+v; want invalid params", resp.Error)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"httpserver/search"
+	"httpserver/store"
+)
+
+// --- HANDLER: SEARCH USERS (/users/search?q=...) ---
+//
+// Every tenant keeps its users in a search index (see setStores), so a
+// query does not scan the whole store. Results are ranked by BM25 and
+// carry the name and email as HTML with the matches in <mark>.
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type searchResult struct {
+	User      store.User      `json:"user"`
+	Score     float64         `json:"score"`
+	Highlight searchHighlight `json:"highlight"`
+}
+
+type searchHighlight struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing q", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	st := stateFrom(r.Context())
+	results := []searchResult{}
+	for _, hit := range st.index.Search(q, limit) {
+		u, err := st.users.Get(r.Context(), hit.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue // deleted since the search
+		}
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		res := searchResult{User: u, Score: hit.Score}
+		// The spans refer to the indexed text; skip them if u has changed since.
+		if hit.Fields[search.FieldName] == u.Name && hit.Fields[search.FieldEmail] == u.Email {
+			res.Highlight = searchHighlight{
+				Name:  search.Highlight(u.Name, hit.Matches[search.FieldName]),
+				Email: search.Highlight(u.Email, hit.Matches[search.FieldEmail]),
+			}
+		} else {
+			res.Highlight = searchHighlight{Name: search.Highlight(u.Name, nil), Email: search.Highlight(u.Email, nil)}
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,302 @@
+package search
+
+import (
+	"cmp"
+	"html"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// BM25 parameters: k1 limits how much repeating a term helps, b how much
+// long documents are penalized.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// How much a term counts depending on how it matched the query word.
+const (
+	exactWeight  = 1.0
+	prefixWeight = 0.8
+	fuzzyWeight  = 0.5 // divided by the number of edits
+)
+
+// Span is a byte range of a field.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Hit is a matching document.
+type Hit struct {
+	ID     int
+	Score  float64
+	Fields []string // as indexed
+	// Matches holds, for every field of the document, the spans of the
+	// words that matched the query.
+	Matches [][]Span
+}
+
+type document struct {
+	fields []string
+	tokens [][]Token // per field
+	terms  map[string]int
+	length int
+}
+
+// Index maps terms to the documents that contain them. Documents are
+// identified by an int and made of one or more text fields, all searched
+// alike. An Index is safe for concurrent use.
+type Index struct {
+	// writes is held by IndexedUserStore and Rebuild across a store write
+	// or read and the index update, so the index ends up with the last
+	// stored version of every document.
+	writes sync.Mutex
+
+	mu       sync.RWMutex
+	docs     map[int]*document
+	postings map[string]map[int]int // term -> document -> occurrences
+	terms    []string               // sorted keys of postings, for prefix lookups
+	totalLen int
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{docs: make(map[int]*document), postings: make(map[string]map[int]int)}
+}
+
+// Len returns the number of documents.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Put adds document id, replacing its previous fields.
+func (ix *Index) Put(id int, fields ...string) {
+	doc := &document{fields: fields, terms: make(map[string]int)}
+	for _, f := range fields {
+		tokens := Tokenize(f)
+		doc.tokens = append(doc.tokens, tokens)
+		for _, t := range tokens {
+			doc.terms[t.Term]++
+		}
+		doc.length += len(tokens)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+	ix.docs[id] = doc
+	ix.totalLen += doc.length
+	for term, n := range doc.terms {
+		docs := ix.postings[term]
+		if docs == nil {
+			docs = make(map[int]int)
+			ix.postings[term] = docs
+			i, _ := slices.BinarySearch(ix.terms, term)
+			ix.terms = slices.Insert(ix.terms, i, term)
+		}
+		docs[id] = n
+	}
+}
+
+// Remove drops document id, if present.
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// Reset drops every document.
+func (ix *Index) Reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs, ix.postings, ix.terms, ix.totalLen = make(map[int]*document), make(map[string]map[int]int), nil, 0
+}
+
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	ix.totalLen -= doc.length
+	for term := range doc.terms {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+			if i, ok := slices.BinarySearch(ix.terms, term); ok {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+}
+
+// Search returns up to limit documents that match every word of query,
+// best first. limit <= 0 returns them all.
+func (ix *Index) Search(query string, limit int) []Hit {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := float64(len(ix.docs))
+	avgLen := float64(ix.totalLen) / max(n, 1)
+	var scores map[int]float64
+	matched := map[string]bool{} // terms to highlight
+
+	for _, w := range words {
+		// The score of a document for this word is that of its best term.
+		best := map[int]float64{}
+		for term, weight := range ix.expand(w.Term) {
+			docs := ix.postings[term]
+			idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for id, tf := range docs {
+				if scores != nil {
+					if _, ok := scores[id]; !ok {
+						continue // already missed an earlier word
+					}
+				}
+				norm := float64(tf) * (k1 + 1) / (float64(tf) + k1*(1-b+b*float64(ix.docs[id].length)/avgLen))
+				best[id] = max(best[id], weight*idf*norm)
+			}
+			matched[term] = true
+		}
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for id := range scores {
+			if s, ok := best[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		doc := ix.docs[hits[i].ID]
+		hits[i].Fields = doc.fields
+		hits[i].Matches = make([][]Span, len(doc.fields))
+		for f, tokens := range doc.tokens {
+			for _, t := range tokens {
+				if matched[t.Term] {
+					hits[i].Matches[f] = append(hits[i].Matches[f], Span{t.Start, t.End})
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// expand returns the terms that match the query word w, with their weight.
+// The caller must hold the lock.
+func (ix *Index) expand(w string) map[string]float64 {
+	out := map[string]float64{}
+	for i, _ := slices.BinarySearch(ix.terms, w); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], w); i++ {
+		if ix.terms[i] == w {
+			out[w] = exactWeight
+		} else {
+			out[ix.terms[i]] = prefixWeight
+		}
+	}
+
+	maxEdits := fuzziness(w)
+	if maxEdits == 0 {
+		return out
+	}
+	wr := []rune(w)
+	for _, term := range ix.terms {
+		if _, ok := out[term]; ok {
+			continue
+		}
+		if d := editDistance(wr, []rune(term), maxEdits); d <= maxEdits {
+			out[term] = fuzzyWeight / float64(d)
+		}
+	}
+	return out
+}
+
+// fuzziness is how many typos a query word may have: none in short words,
+// where one edit changes the word too much.
+func fuzziness(w string) int {
+	switch n := len([]rune(w)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the optimal string alignment distance between a
+// and b (insertions, deletions, substitutions and swaps of neighbours),
+// or limit+1 once it is sure to be over limit.
+func editDistance(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// Highlight returns text as HTML with the spans wrapped in <mark>.
+func Highlight(text string, spans []Span) string {
+	var sb strings.Builder
+	last := 0
+	for _, s := range spans {
+		sb.WriteString(html.EscapeString(text[last:s.Start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[s.Start:s.End]))
+		sb.WriteString("</mark>")
+		last = s.End
+	}
+	sb.WriteString(html.EscapeString(text[last:]))
+	return sb.String()
+}
@@ -0,0 +1,202 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"httpserver/store"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("JOÃO Conceição-Straße, joao.silva@Exemplo.com.br")
+	want := []string{"joao", "conceicao", "strasse", "joao", "silva", "exemplo", "com", "br"}
+	if len(got) != len(want) {
+		t.Fatalf("Tokenize = %+v", got)
+	}
+	for i, tok := range got {
+		if tok.Term != want[i] {
+			t.Errorf("token %d = %q; want %q", i, tok.Term, want[i])
+		}
+	}
+	// Offsets point at the original bytes, accents included.
+	if s := "JOÃO Conceição"[got[1].Start:got[1].End]; s != "Conceição" {
+		t.Errorf("span of token 1 = %q", s)
+	}
+	// A decomposed accent stays inside its word.
+	if toks := Tokenize("José Maria"); len(toks) != 2 || toks[0].Term != "jose" {
+		t.Errorf("Tokenize(decomposed) = %+v", toks)
+	}
+}
+
+func ids(hits []Hit) string {
+	out := make([]int, len(hits))
+	for i, h := range hits {
+		out[i] = h.ID
+	}
+	return fmt.Sprint(out)
+}
+
+func TestSearch(t *testing.T) {
+	ix := New()
+	ix.Put(1, "João da Silva", "joao@example.com")
+	ix.Put(2, "Maria Conceição", "maria@example.com")
+	ix.Put(3, "Joana Silva Santos", "joana@example.com")
+	ix.Put(4, "Antônio Joaquim", "tonho@example.com")
+
+	tests := []struct{ query, want string }{
+		{"joão", "[1]"},            // exact, accent-insensitive
+		{"CONCEICAO", "[2]"},       // case and accents folded
+		{"jo", "[1 3 4]"},          // prefix
+		{"silva jo", "[1 3]"},      // every word must match
+		{"conceicão maria", "[2]"}, // in any order
+		{"antonoi", "[4]"},         // one swap
+		{"santso", "[3]"},          // one swap
+		{"marai", "[2]"},           // short words allow one edit
+		{"xyz", "[]"},
+	}
+	for _, tt := range tests {
+		if got := ids(ix.Search(tt.query, 0)); got != tt.want {
+			t.Errorf("Search(%q) = %s; want %s", tt.query, got, tt.want)
+		}
+	}
+
+	// An exact match outranks a prefix match, which outranks a typo.
+	ix.Put(5, "Joan", "")
+	ix.Put(6, "Joana", "")
+	ix.Put(7, "Joanna", "")
+	if got := ids(ix.Search("joana", 0)); got != "[6 3 5 7]" {
+		t.Errorf("ranking = %s; want [6 3 5 7]", got)
+	}
+	if got := ids(ix.Search("joana", 2)); got != "[6 3]" {
+		t.Errorf("limit 2 = %s", got)
+	}
+}
+
+func TestBM25PrefersRareTermsAndShortFields(t *testing.T) {
+	ix := New()
+	ix.Put(1, "Ana Souza", "")
+	ix.Put(2, "Ana Souza Lima Pereira Costa", "")
+	ix.Put(3, "Ana Lima", "")
+	if got := ids(ix.Search("souza", 0)); got != "[1 2]" {
+		t.Errorf("shorter document should rank first, got %s", got)
+	}
+	hits := ix.Search("ana lima", 0)
+	if ids(hits) != "[3 2]" {
+		t.Errorf("Search(ana lima) = %s", ids(hits))
+	}
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("scores not decreasing: %v", hits)
+	}
+}
+
+func TestIncrementalUpdates(t *testing.T) {
+	ix := New()
+	ix.Put(1, "Beatriz", "")
+	ix.Put(1, "Bianca", "")
+	if got := ids(ix.Search("beatriz", 0)); got != "[]" {
+		t.Errorf("old text still matches: %s", got)
+	}
+	if got := ids(ix.Search("bianca", 0)); got != "[1]" {
+		t.Errorf("new text does not match: %s", got)
+	}
+	ix.Remove(1)
+	if ix.Len() != 0 || len(ix.terms) != 0 || ix.totalLen != 0 {
+		t.Errorf("Remove left %d docs, terms %v, length %d", ix.Len(), ix.terms, ix.totalLen)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	ix := New()
+	ix.Put(1, "<b>João</b> Silva", "joao@x.com")
+	hit := ix.Search("joao", 0)[0]
+	if got := Highlight(hit.Fields[FieldName], hit.Matches[FieldName]); got != "&lt;b&gt;<mark>João</mark>&lt;/b&gt; Silva" {
+		t.Errorf("Highlight(name) = %s", got)
+	}
+	if got := Highlight(hit.Fields[FieldEmail], hit.Matches[FieldEmail]); got != "<mark>joao</mark>@x.com" {
+		t.Errorf("Highlight(email) = %s", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"joao", "joao", 2, 0},
+		{"joao", "jaoo", 2, 1},
+		{"maria", "mario", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 1, 2}, // over the limit
+		{"ab", "abcdef", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d; want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestIndexedUserStore(t *testing.T) {
+	ctx := context.Background()
+	ix := New()
+	mem := store.NewMemoryUserStore(store.User{ID: 1, Name: "Alice"})
+	if err := ix.Rebuild(ctx, mem); err != nil {
+		t.Fatal(err)
+	}
+	s := IndexedUserStore{Next: mem, Index: ix}
+
+	u, _ := s.Create(ctx, store.User{Name: "Mônica"})
+	if got := ids(ix.Search("monica", 0)); got != "[2]" {
+		t.Errorf("after Create: %s", got)
+	}
+	u.Name = "Mariana"
+	s.Update(ctx, u)
+	if got := ids(ix.Search("mariana", 0)); got != "[2]" {
+		t.Errorf("after Update: %s", got)
+	}
+	s.Delete(ctx, 2)
+	if ix.Len() != 1 {
+		t.Errorf("after Delete: %d documents", ix.Len())
+	}
+	// Failed writes leave the index alone.
+	if _, err := s.Update(ctx, store.User{ID: 9, Name: "Ghost"}); err == nil || ix.Len() != 1 {
+		t.Errorf("failed Update: err %v, %d documents", err, ix.Len())
+	}
+}
+
+// slowUpdate holds an update to "Alpha" back after it is stored, so a
+// later update can overtake it on the way to the index.
+type slowUpdate struct{ store.UserStore }
+
+func (s slowUpdate) Update(ctx context.Context, u store.User) (store.User, error) {
+	u, err := s.UserStore.Update(ctx, u)
+	if u.Name == "Alpha" {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return u, err
+}
+
+func TestIndexedUserStoreConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	ix := New()
+	s := IndexedUserStore{Next: slowUpdate{store.NewMemoryUserStore(store.User{ID: 1, Name: "Alice"})}, Index: ix}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); s.Update(ctx, store.User{ID: 1, Name: "Alpha"}) }()
+	time.Sleep(time.Millisecond)
+	go func() { defer wg.Done(); s.Update(ctx, store.User{ID: 1, Name: "Beta"}) }()
+	wg.Wait()
+
+	stored, _ := s.Get(ctx, 1)
+	if got := ids(ix.Search(stored.Name, 0)); got != "[1]" {
+		t.Errorf("stored name %q not indexed: %s", stored.Name, got)
+	}
+	if n := ix.Len(); n != 1 {
+		t.Errorf("%d documents; want 1", n)
+	}
+}
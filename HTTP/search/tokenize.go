@@ -0,0 +1,65 @@
+// Package search is an in-memory full-text index with BM25 ranking.
+//
+// Text is split into words at anything that is not a letter or a digit,
+// and every word is folded: case is removed and letters lose their
+// diacritics, so "JOÃO" and "joao" are the same term. A query matches a
+// document when each of its words matches a term of the document exactly,
+// as a prefix ("jo" finds João) or with a typo or two ("joao" finds
+// "joão", "jaoo" finds it too).
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a folded word of a text and the bytes it came from.
+type Token struct {
+	Term       string
+	Start, End int // byte offsets in the original text
+}
+
+// Tokenize splits text into folded words.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || (start >= 0 && unicode.Is(unicode.Mn, r))
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+	return tokens
+}
+
+func appendToken(tokens []Token, text string, start, end int) []Token {
+	if term := Fold(text[start:end]); term != "" {
+		tokens = append(tokens, Token{Term: term, Start: start, End: end})
+	}
+	return tokens
+}
+
+// Fold removes case and diacritics from s: "Conceição" becomes "conceicao".
+func Fold(s string) string {
+	// Decompose (ã becomes a + combining tilde), drop the combining
+	// marks, recompose what is left and fold the case. The transformers
+	// keep state, so each call builds its own chain.
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC, cases.Fold())
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+
+	"httpserver/store"
+)
+
+// --- USERS ---
+
+// User fields, in the order PutUser indexes them.
+const (
+	FieldName = iota
+	FieldEmail
+)
+
+// PutUser indexes the name and email of u.
+func (ix *Index) PutUser(u store.User) {
+	ix.Put(u.ID, u.Name, u.Email)
+}
+
+// Rebuild replaces the contents of ix with the users of s.
+func (ix *Index) Rebuild(ctx context.Context, s store.UserStore) error {
+	ix.writes.Lock()
+	defer ix.writes.Unlock()
+
+	users, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	fresh := New()
+	for _, u := range users {
+		fresh.PutUser(u)
+	}
+
+	fresh.mu.Lock()
+	defer fresh.mu.Unlock()
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs, ix.postings, ix.terms, ix.totalLen = fresh.docs, fresh.postings, fresh.terms, fresh.totalLen
+	return nil
+}
+
+// IndexedUserStore keeps Index up to date with the writes that go through
+// it to Next. A write and its index update happen under one lock, so two
+// updates of a user cannot reach the index in another order than the store.
+type IndexedUserStore struct {
+	Next  store.UserStore
+	Index *Index
+}
+
+func (s IndexedUserStore) List(ctx context.Context) ([]store.User, error) {
+	return s.Next.List(ctx)
+}
+
+func (s IndexedUserStore) Get(ctx context.Context, id int) (store.User, error) {
+	return s.Next.Get(ctx, id)
+}
+
+func (s IndexedUserStore) GetByEmail(ctx context.Context, email string) (store.User, error) {
+	return s.Next.GetByEmail(ctx, email)
+}
+
+func (s IndexedUserStore) Create(ctx context.Context, u store.User) (store.User, error) {
+	s.Index.writes.Lock()
+	defer s.Index.writes.Unlock()
+
+	u, err := s.Next.Create(ctx, u)
+	if err == nil {
+		s.Index.PutUser(u)
+	}
+	return u, err
+}
+
+func (s IndexedUserStore) Update(ctx context.Context, u store.User) (store.User, error) {
+	s.Index.writes.Lock()
+	defer s.Index.writes.Unlock()
+
+	u, err := s.Next.Update(ctx, u)
+	if err == nil {
+		s.Index.PutUser(u)
+	}
+	return u, err
+}
+
+func (s IndexedUserStore) Delete(ctx context.Context, id int) error {
+	s.Index.writes.Lock()
+	defer s.Index.writes.Unlock()
+
+	err := s.Next.Delete(ctx, id)
+	if err == nil {
+		s.Index.Remove(id)
+	}
+	return err
+}
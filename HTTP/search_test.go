@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSearchHandler(t *testing.T) {
+	rec := do(createHandler, "POST", "/users/create", `{"name":"Estêvão Conceição","email":"estevao@example.com","password":"search-pass"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d", rec.Code)
+	}
+	var created struct{ ID int }
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	rec = do(searchHandler, "GET", "/users/search?q=estev+CONCEICAO", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search: status %d", rec.Code)
+	}
+	var results []searchResult
+	json.NewDecoder(rec.Body).Decode(&results)
+	if len(results) != 1 || results[0].User.ID != created.ID {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := results[0].Highlight.Name; got != "<mark>Estêvão</mark> <mark>Conceição</mark>" {
+		t.Errorf("highlighted name = %s", got)
+	}
+	if got := results[0].Highlight.Email; got != "<mark>estevao</mark>@example.com" {
+		t.Errorf("highlighted email = %s", got)
+	}
+
+	// Deleted users drop out of the index.
+	if err := defaultState.service.Delete(t.Context(), created.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	rec = do(searchHandler, "GET", "/users/search?q=estevao", "")
+	json.NewDecoder(rec.Body).Decode(&results)
+	if len(results) != 0 {
+		t.Errorf("results after delete = %+v", results)
+	}
+
+	for _, target := range []string{"/users/search", "/users/search?q=a&limit=0", "/users/search?q=a&limit=x"} {
+		if rec := do(searchHandler, "GET", target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d; want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package service holds the user operations shared by every API
+// (REST handlers and JSON-RPC). Validation and business rules live here,
+// persistence is left to the store package.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"httpserver/auth"
+	"httpserver/store"
+)
+
+// DeletePolicy says what happens to a user's posts when the user is deleted.
+type DeletePolicy string
+
+const (
+	DeleteRestrict DeletePolicy = "restrict" // refuse to delete users that still have posts
+	DeleteCascade  DeletePolicy = "cascade"  // delete the posts together with the user
+)
+
+// ParseDeletePolicy accepts "restrict" or "cascade" (empty means restrict).
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch DeletePolicy(strings.ToLower(s)) {
+	case "", DeleteRestrict:
+		return DeleteRestrict, nil
+	case DeleteCascade:
+		return DeleteCascade, nil
+	}
+	return "", fmt.Errorf("service: delete policy must be %q or %q, got %q", DeleteRestrict, DeleteCascade, s)
+}
+
+var (
+	// ErrUserHasPosts is returned by Delete under DeleteRestrict.
+	ErrUserHasPosts = errors.New("service: user still has posts")
+	// ErrQuotaExceeded is returned by Create when MaxUsers is reached.
+	ErrQuotaExceeded = errors.New("service: user quota exceeded")
+)
+
+// --- INPUTS ---
+
+// CreateUserInput is what a client sends to create a user.
+// The plain password only lives here until it is hashed.
+type CreateUserInput struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UpdateUserInput changes a user. Fields left nil keep their value.
+// EmailVerified and PasswordHash are set by the account flows (verify
+// email, reset password); clients cannot send them.
+type UpdateUserInput struct {
+	Name          *string `json:"name"`
+	Email         *string `json:"email"`
+	EmailVerified *bool   `json:"-"`
+	PasswordHash  *string `json:"-"`
+}
+
+// --- SERVICE ---
+
+// UserService implements the user operations on top of the stores.
+type UserService struct {
+	Users        store.UserStore
+	Posts        store.PostStore
+	DeletePolicy DeletePolicy
+	MaxUsers     int // 0 means no limit
+
+	// Optional hooks, called after the change was stored.
+	OnCreated      func(ctx context.Context, u store.User)
+	OnEmailChanged func(ctx context.Context, u store.User)
+	OnDeleted      func(ctx context.Context, id int)
+
+	mu sync.Mutex // serializes writes that depend on what is stored
+}
+
+// List returns every user ordered by ID.
+func (s *UserService) List(ctx context.Context) ([]store.User, error) {
+	return s.Users.List(ctx)
+}
+
+// Get returns one user or store.ErrNotFound.
+func (s *UserService) Get(ctx context.Context, id int) (store.User, error) {
+	return s.Users.Get(ctx, id)
+}
+
+// Create validates in, hashes the password and stores the new user.
+// New users start with an unverified email.
+func (s *UserService) Create(ctx context.Context, in CreateUserInput) (store.User, error) {
+	var v ValidationError
+	name := validateName(&v, in.Name)
+	email := validateEmail(&v, in.Email)
+	if in.Password != "" && utf8.RuneCountInString(in.Password) < MinPasswordLength {
+		v.Add("password", fmt.Sprintf("must have at least %d characters", MinPasswordLength))
+	}
+	if v.HasErrors() {
+		return store.User{}, &v
+	}
+
+	u := store.User{Name: name, Email: email}
+	if in.Password != "" {
+		hash, err := auth.HashPassword(in.Password)
+		if err != nil {
+			return store.User{}, err
+		}
+		u.PasswordHash = hash
+	}
+
+	u, err := s.create(ctx, u)
+	if err != nil {
+		return store.User{}, err
+	}
+	if s.OnCreated != nil {
+		s.OnCreated(ctx, u)
+	}
+	return u, nil
+}
+
+// create stores u, holding the lock so that concurrent creates cannot
+// all pass the quota check.
+func (s *UserService) create(ctx context.Context, u store.User) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.MaxUsers > 0 {
+		users, err := s.Users.List(ctx)
+		if err != nil {
+			return store.User{}, err
+		}
+		if len(users) >= s.MaxUsers {
+			return store.User{}, ErrQuotaExceeded
+		}
+	}
+	return s.Users.Create(ctx, u)
+}
+
+// Update changes the name and/or email of user id.
+// A new email has to be verified again.
+func (s *UserService) Update(ctx context.Context, id int, in UpdateUserInput) (store.User, error) {
+	return s.UpdateFunc(ctx, id, func(store.User) (UpdateUserInput, error) { return in, nil })
+}
+
+// UpdateFunc is Update with the input worked out from the stored user:
+// fn gets the user as it is now and no other update of s can store a
+// change until fn's input is written. An error from fn is returned as is
+// and nothing is stored.
+func (s *UserService) UpdateFunc(ctx context.Context, id int, fn func(store.User) (UpdateUserInput, error)) (store.User, error) {
+	s.mu.Lock()
+	u, emailChanged, err := s.update(ctx, id, fn)
+	s.mu.Unlock()
+	if err != nil {
+		return store.User{}, err
+	}
+	if emailChanged && s.OnEmailChanged != nil {
+		s.OnEmailChanged(ctx, u)
+	}
+	return u, nil
+}
+
+func (s *UserService) update(ctx context.Context, id int, fn func(store.User) (UpdateUserInput, error)) (store.User, bool, error) {
+	u, err := s.Users.Get(ctx, id)
+	if err != nil {
+		return store.User{}, false, err
+	}
+	in, err := fn(u)
+	if err != nil {
+		return store.User{}, false, err
+	}
+
+	var v ValidationError
+	if in.Name != nil {
+		u.Name = validateName(&v, *in.Name)
+	}
+	emailChanged := false
+	if in.Email != nil {
+		email := validateEmail(&v, *in.Email)
+		if !strings.EqualFold(email, u.Email) {
+			u.Email = email
+			u.EmailVerified = false
+			emailChanged = true
+		}
+	}
+	if v.HasErrors() {
+		return store.User{}, false, &v
+	}
+	if in.EmailVerified != nil {
+		u.EmailVerified = *in.EmailVerified
+	}
+	if in.PasswordHash != nil {
+		u.PasswordHash = *in.PasswordHash
+	}
+
+	u, err = s.Users.Update(ctx, u)
+	return u, emailChanged, err
+}
+
+// Delete removes user id, applying the DeletePolicy to their posts first.
+// It holds the lock throughout, so no post is added for the user while
+// their posts are counted or removed.
+func (s *UserService) Delete(ctx context.Context, id int) error {
+	if err := s.delete(ctx, id); err != nil {
+		return err
+	}
+	if s.OnDeleted != nil {
+		s.OnDeleted(ctx, id)
+	}
+	return nil
+}
+
+func (s *UserService) delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Users.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if s.Posts != nil {
+		switch s.DeletePolicy {
+		case DeleteCascade:
+			if _, err := s.Posts.DeleteByUser(ctx, id); err != nil {
+				return err
+			}
+		default:
+			n, err := s.Posts.CountByUser(ctx, id)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrUserHasPosts
+			}
+		}
+	}
+
+	return s.Users.Delete(ctx, id)
+}
+
+// --- POSTS ---
+
+// CreatePost stores a new post of p.UserID, or returns store.ErrNotFound
+// if there is no such user. The user is checked under the lock Delete
+// holds, so the post cannot outlive them.
+func (s *UserService) CreatePost(ctx context.Context, p store.Post) (store.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Users.Get(ctx, p.UserID); err != nil {
+		return store.Post{}, err
+	}
+	return s.Posts.Create(ctx, p)
+}
+
+// UpsertExternalPost is CreatePost for imported posts: it creates or
+// updates the post with p.ExternalID and reports whether it was created.
+func (s *UserService) UpsertExternalPost(ctx context.Context, p store.Post) (store.Post, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Users.Get(ctx, p.UserID); err != nil {
+		return store.Post{}, false, err
+	}
+	return s.Posts.UpsertExternal(ctx, p)
+}
+
+// --- VALIDATION ---
+
+// Limits checked by the validation.
+const (
+	MaxNameLength     = 100
+	MinPasswordLength = 8
+)
+
+// FieldError describes one invalid field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of an input.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Add records a problem with field.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// HasErrors reports whether any field is invalid.
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Fields) > 0
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "invalid user: " + strings.Join(parts, "; ")
+}
+
+func validateName(v *ValidationError, name string) string {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		v.Add("name", "must not be empty")
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		v.Add("name", fmt.Sprintf("must have at most %d characters", MaxNameLength))
+	}
+	return name
+}
+
+func validateEmail(v *ValidationError, email string) string {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		v.Add("email", "must not be empty")
+		return email
+	}
+	// Only a bare address is accepted, not "Name <address>".
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		v.Add("email", "must be a valid email address")
+	}
+	return email
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"httpserver/store"
+)
+
+func newTestService(policy DeletePolicy) *UserService {
+	return &UserService{
+		Users:        store.NewMemoryUserStore(store.User{ID: 1, Name: "Alice", Email: "alice@example.com", EmailVerified: true}),
+		Posts:        store.NewMemoryPostStore(),
+		DeletePolicy: policy,
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+
+	_, err := s.Create(context.Background(), CreateUserInput{Name: " ", Email: "Bob <bob@example.com>", Password: "short"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Create error = %v; want *ValidationError", err)
+	}
+
+	fields := map[string]bool{}
+	for _, f := range verr.Fields {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"name", "email", "password"} {
+		if !fields[want] {
+			t.Errorf("no validation error for %q in %+v", want, verr.Fields)
+		}
+	}
+}
+
+func TestCreateAndHooks(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+	var created store.User
+	s.OnCreated = func(ctx context.Context, u store.User) { created = u }
+
+	u, err := s.Create(context.Background(), CreateUserInput{Name: " Bob ", Email: "bob@example.com", Password: "long-enough"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if u.Name != "Bob" || u.EmailVerified || u.PasswordHash == "" {
+		t.Errorf("created user = %+v; want trimmed name, unverified email and a hash", u)
+	}
+	if created.ID != u.ID {
+		t.Errorf("OnCreated was not called with the new user")
+	}
+}
+
+func TestUpdateEmailNeedsVerification(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+	changed := false
+	s.OnEmailChanged = func(ctx context.Context, u store.User) { changed = true }
+
+	name := "Alicia"
+	u, err := s.Update(context.Background(), 1, UpdateUserInput{Name: &name})
+	if err != nil || u.Name != "Alicia" || !u.EmailVerified || changed {
+		t.Fatalf("name update = %+v, %v; email must stay verified", u, err)
+	}
+
+	email := "alicia@example.com"
+	u, err = s.Update(context.Background(), 1, UpdateUserInput{Email: &email})
+	if err != nil || u.EmailVerified || !changed {
+		t.Errorf("email update = %+v, %v; want unverified email and hook called", u, err)
+	}
+
+	if _, err := s.Update(context.Background(), 42, UpdateUserInput{Name: &name}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update of a missing user: err = %v; want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestUpdateAccountFields(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+	email := "alicia@example.com"
+	s.Update(context.Background(), 1, UpdateUserInput{Email: &email})
+
+	verified, hash := true, "new-hash"
+	u, err := s.Update(context.Background(), 1, UpdateUserInput{EmailVerified: &verified, PasswordHash: &hash})
+	if err != nil || !u.EmailVerified || u.PasswordHash != "new-hash" {
+		t.Errorf("account update = %+v, %v; want verified email and new hash", u, err)
+	}
+
+	// Clients cannot set them.
+	var in UpdateUserInput
+	json.Unmarshal([]byte(`{"EmailVerified":false,"PasswordHash":"x"}`), &in)
+	if in.EmailVerified != nil || in.PasswordHash != nil {
+		t.Errorf("decoded client input %+v sets account fields", in)
+	}
+}
+
+func TestUpdateFuncSeesLatestUser(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+
+	// Every call appends to the name it reads; a lost update would show
+	// up as a shorter name.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.UpdateFunc(context.Background(), 1, func(u store.User) (UpdateUserInput, error) {
+				name := u.Name + "!"
+				return UpdateUserInput{Name: &name}, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if u, _ := s.Get(context.Background(), 1); u.Name != "Alice"+strings.Repeat("!", 20) {
+		t.Errorf("name = %q; updates were lost", u.Name)
+	}
+
+	stop := errors.New("stop")
+	_, err := s.UpdateFunc(context.Background(), 1, func(store.User) (UpdateUserInput, error) {
+		return UpdateUserInput{}, stop
+	})
+	if err != stop {
+		t.Errorf("err = %v; want fn's error", err)
+	}
+}
+
+func TestDeletePolicies(t *testing.T) {
+	ctx := context.Background()
+
+	s := newTestService(DeleteRestrict)
+	s.Posts.Create(ctx, store.Post{UserID: 1, Title: "x"})
+	if err := s.Delete(ctx, 1); !errors.Is(err, ErrUserHasPosts) {
+		t.Errorf("restrict: err = %v; want %v", err, ErrUserHasPosts)
+	}
+
+	s = newTestService(DeleteCascade)
+	s.Posts.Create(ctx, store.Post{UserID: 1, Title: "x"})
+	deleted := 0
+	s.OnDeleted = func(ctx context.Context, id int) { deleted = id }
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("cascade: %v", err)
+	}
+	if n, _ := s.Posts.CountByUser(ctx, 1); n != 0 || deleted != 1 {
+		t.Errorf("cascade left %d posts, OnDeleted(%d)", n, deleted)
+	}
+}
+
+func TestParseDeletePolicy(t *testing.T) {
+	for in, want := range map[string]DeletePolicy{"": DeleteRestrict, "RESTRICT": DeleteRestrict, "cascade": DeleteCascade} {
+		if got, err := ParseDeletePolicy(in); err != nil || got != want {
+			t.Errorf("ParseDeletePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseDeletePolicy("nope"); err == nil {
+		t.Errorf("ParseDeletePolicy(\"nope\") returned no error")
+	}
+}
+
+func TestCreateQuota(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+	s.MaxUsers = 2
+
+	if _, err := s.Create(context.Background(), CreateUserInput{Name: "Bob", Email: "bob@example.com"}); err != nil {
+		t.Fatalf("Create within quota: %v", err)
+	}
+	if _, err := s.Create(context.Background(), CreateUserInput{Name: "Carl", Email: "carl@example.com"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Create over quota: err = %v; want %v", err, ErrQuotaExceeded)
+	}
+}
+
+// slowList gives concurrent creates time to interleave between the
+// quota check and the write.
+type slowList struct{ store.UserStore }
+
+func (s slowList) List(ctx context.Context) ([]store.User, error) {
+	users, err := s.UserStore.List(ctx)
+	time.Sleep(time.Millisecond)
+	return users, err
+}
+
+func TestCreateQuotaConcurrent(t *testing.T) {
+	s := newTestService(DeleteRestrict)
+	s.Users = slowList{s.Users}
+	s.MaxUsers = 5
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Create(context.Background(), CreateUserInput{Name: "User", Email: fmt.Sprintf("u%d@example.com", i)})
+		}()
+	}
+	wg.Wait()
+	if users, _ := s.List(context.Background()); len(users) != 5 {
+		t.Errorf("%d users after concurrent creates; want the quota of 5", len(users))
+	}
+}
+
+// slowGet gives a post create time to slip in between a delete's checks
+// and its writes.
+type slowGet struct{ store.UserStore }
+
+func (s slowGet) Get(ctx context.Context, id int) (store.User, error) {
+	u, err := s.UserStore.Get(ctx, id)
+	time.Sleep(time.Millisecond)
+	return u, err
+}
+
+func TestCreatePostDuringDelete(t *testing.T) {
+	ctx := context.Background()
+	for range 20 {
+		s := newTestService(DeleteCascade)
+		s.Users = slowGet{s.Users}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); s.Delete(ctx, 1) }()
+		go func() { defer wg.Done(); s.CreatePost(ctx, store.Post{UserID: 1, Title: "x"}) }()
+		wg.Wait()
+
+		if _, err := s.Get(ctx, 1); errors.Is(err, store.ErrNotFound) {
+			if n, _ := s.Posts.CountByUser(ctx, 1); n != 0 {
+				t.Fatalf("%d posts left of a deleted user", n)
+			}
+		}
+	}
+	if _, err := newTestService(DeleteRestrict).CreatePost(ctx, store.Post{UserID: 9, Title: "x"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CreatePost for a missing user: err = %v; want %v", err, store.ErrNotFound)
+	}
+}
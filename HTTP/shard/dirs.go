@@ -0,0 +1,57 @@
+package shard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// --- NODE DIRECTORIES ---
+//
+// The server and cmd/reshard keep every node in its own kv database under
+// the data directory:
+//
+//	<DATA_DIR>/shards/a/
+//	<DATA_DIR>/shards/b/
+
+// NodeDir returns the directory of node under dataDir.
+func NodeDir(dataDir, node string) string {
+	return filepath.Join(dataDir, "shards", node)
+}
+
+// ExistingNodes returns the nodes that have a directory under dataDir.
+func ExistingNodes(dataDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, "shards"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for _, e := range entries {
+		if e.IsDir() {
+			nodes = append(nodes, e.Name())
+		}
+	}
+	return nodes, nil
+}
+
+// ParseNodes splits a comma-separated list of node names. Names become
+// directory names, so they cannot be empty or contain a path separator.
+func ParseNodes(s string) ([]string, error) {
+	var nodes []string
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" || n == "." || n == ".." || strings.ContainsAny(n, `/\`) {
+			return nil, fmt.Errorf("shard: invalid node name %q", n)
+		}
+		if slices.Contains(nodes, n) {
+			return nil, fmt.Errorf("%w: %q", ErrNodeExists, n)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
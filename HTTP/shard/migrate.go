@@ -0,0 +1,71 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// --- MIGRATION ---
+
+// Move is one user that is on node From but belongs on node To.
+type Move struct {
+	ID   int    `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Plan lists the users of backends that ring places on another node.
+// Backends that are not on ring (a node being removed) move everything.
+func Plan(ctx context.Context, ring *Ring, backends map[string]Backend) ([]Move, error) {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var moves []Move
+	for _, from := range names {
+		users, err := backends[from].List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", from, err)
+		}
+		for _, u := range users {
+			to, err := ring.OwnerOf(u.ID)
+			if err != nil {
+				return nil, err
+			}
+			if to != from {
+				moves = append(moves, Move{ID: u.ID, From: from, To: to})
+			}
+		}
+	}
+	return moves, nil
+}
+
+// Migrate carries out Plan and returns the moves it made. Each user is
+// copied before it is deleted, so an interrupted migration leaves at most
+// a stale copy, which running Migrate again removes.
+func Migrate(ctx context.Context, ring *Ring, backends map[string]Backend) ([]Move, error) {
+	plan, err := Plan(ctx, ring, backends)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range plan {
+		src, dst := backends[m.From], backends[m.To]
+		if dst == nil {
+			return plan[:i], fmt.Errorf("%w: %q has no backend", ErrUnknownNode, m.To)
+		}
+		u, err := src.Get(ctx, m.ID)
+		if err != nil {
+			return plan[:i], fmt.Errorf("move %d from %s: %w", m.ID, m.From, err)
+		}
+		if err := dst.Put(ctx, u); err != nil {
+			return plan[:i], fmt.Errorf("move %d to %s: %w", m.ID, m.To, err)
+		}
+		if err := src.Delete(ctx, m.ID); err != nil {
+			return plan[:i], fmt.Errorf("move %d from %s: %w", m.ID, m.From, err)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,129 @@
+// Package shard splits users across several stores ("nodes").
+//
+// A consistent-hash Ring maps every user ID to one node. Each node owns
+// many points on the ring (virtual nodes), so keys spread evenly and adding
+// or removing a node only moves the keys next to its points, about 1/n of
+// them. Router implements store.UserStore on top of the nodes, and Migrate
+// moves the keys a ring change sent elsewhere.
+package shard
+
+import (
+	"cmp"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// DefaultVirtualNodes is the number of ring points per node.
+const DefaultVirtualNodes = 128
+
+var (
+	// ErrNoNodes is returned when the ring is empty.
+	ErrNoNodes = errors.New("shard: no nodes")
+	// ErrNodeExists is returned when adding a node twice.
+	ErrNodeExists = errors.New("shard: node already in the ring")
+	// ErrUnknownNode is returned for a node that is not in the ring.
+	ErrUnknownNode = errors.New("shard: unknown node")
+)
+
+// --- RING ---
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// Ring is a consistent-hash ring. It is not safe for concurrent use;
+// Router guards its ring with a lock.
+type Ring struct {
+	vnodes int
+	points []point // sorted by hash
+	nodes  []string
+}
+
+// NewRing returns a ring with the given nodes and vnodes points per node
+// (0 means DefaultVirtualNodes).
+func NewRing(vnodes int, nodes ...string) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	r := &Ring{vnodes: vnodes}
+	for _, n := range nodes {
+		r.Add(n)
+	}
+	return r
+}
+
+// Add puts node on the ring.
+func (r *Ring) Add(node string) error {
+	if slices.Contains(r.nodes, node) {
+		return ErrNodeExists
+	}
+	r.nodes = append(r.nodes, node)
+	slices.Sort(r.nodes)
+	for i := range r.vnodes {
+		r.points = append(r.points, point{hash: hashString(node + "#" + strconv.Itoa(i)), node: node})
+	}
+	// Ties are broken by name, so the order never depends on insertion order.
+	slices.SortFunc(r.points, func(a, b point) int {
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		return cmp.Compare(a.node, b.node)
+	})
+	return nil
+}
+
+// Remove takes node off the ring.
+func (r *Ring) Remove(node string) error {
+	i := slices.Index(r.nodes, node)
+	if i < 0 {
+		return ErrUnknownNode
+	}
+	r.nodes = slices.Delete(r.nodes, i, i+1)
+	r.points = slices.DeleteFunc(r.points, func(p point) bool { return p.node == node })
+	return nil
+}
+
+// Nodes returns the node names in sorted order.
+func (r *Ring) Nodes() []string { return slices.Clone(r.nodes) }
+
+// Clone returns an independent copy of r.
+func (r *Ring) Clone() *Ring {
+	return &Ring{vnodes: r.vnodes, points: slices.Clone(r.points), nodes: slices.Clone(r.nodes)}
+}
+
+// Owner returns the node of key: the first point at or after its hash.
+func (r *Ring) Owner(key string) (string, error) {
+	if len(r.points) == 0 {
+		return "", ErrNoNodes
+	}
+	h := hashString(key)
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int { return cmp.Compare(p.hash, h) })
+	if i == len(r.points) {
+		i = 0 // wrap around
+	}
+	return r.points[i].node, nil
+}
+
+// OwnerOf returns the node of a user ID.
+func (r *Ring) OwnerOf(id int) (string, error) {
+	return r.Owner(strconv.Itoa(id))
+}
+
+// --- HASHING ---
+
+// hashString is FNV-1a followed by the splitmix64 finalizer. FNV alone
+// clusters short, similar keys such as "41" and "42".
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
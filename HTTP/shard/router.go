@@ -0,0 +1,363 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"httpserver/store"
+)
+
+// Backend is one node of the router. Put stores a user under its own ID,
+// which the router needs because IDs are assigned before the node is known.
+type Backend interface {
+	store.UserStore
+	Put(ctx context.Context, u store.User) error
+}
+
+// IDs hands out user IDs for the router. Like the stores' own counters it
+// must never hand out an ID twice, even across restarts, or a new user
+// would take over the links and sessions of a deleted one.
+type IDs interface {
+	// Next reserves a new ID.
+	Next() (int, error)
+	// Raise makes sure Next returns IDs above id.
+	Raise(id int) error
+}
+
+// memoryIDs is the IDs of a router without a persistent counter.
+type memoryIDs struct{ next int }
+
+func (m *memoryIDs) Next() (int, error) {
+	m.next = max(m.next, 1)
+	m.next++
+	return m.next - 1, nil
+}
+
+func (m *memoryIDs) Raise(id int) error {
+	m.next = max(m.next, id+1)
+	return nil
+}
+
+// --- ROUTER ---
+
+// Router is a store.UserStore that sends every user to the node the ring
+// picks for its ID. Emails stay unique across nodes.
+type Router struct {
+	mu       sync.RWMutex // write-locked while the ring changes and keys move
+	ring     *Ring
+	backends map[string]Backend
+
+	writeMu sync.Mutex // serializes writes, for the email check and ids
+	ids     IDs
+	raised  bool // ids is past every ID on the nodes
+}
+
+// NewRouter returns a router over ring. Every node of ring needs a backend.
+// New IDs come from ids; nil keeps the counter in memory, which is only
+// safe while users are never deleted.
+func NewRouter(ring *Ring, backends map[string]Backend, ids IDs) (*Router, error) {
+	if ids == nil {
+		ids = &memoryIDs{}
+	}
+	r := &Router{ring: ring.Clone(), backends: make(map[string]Backend, len(backends)), ids: ids}
+	for name, b := range backends {
+		r.backends[name] = b
+	}
+	for _, n := range ring.Nodes() {
+		if r.backends[n] == nil {
+			return nil, fmt.Errorf("%w: %q has no backend", ErrUnknownNode, n)
+		}
+	}
+	return r, nil
+}
+
+// Nodes returns the names of the nodes on the ring.
+func (r *Router) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ring.Nodes()
+}
+
+// NodeOf returns the node that holds user id.
+func (r *Router) NodeOf(id int) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ring.OwnerOf(id)
+}
+
+// AddNode puts b on the ring as name and moves the keys it now owns.
+// Requests wait until the move is done; if it fails, call Rebalance.
+func (r *Router) AddNode(ctx context.Context, name string, b Backend) ([]Move, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.backends[name] != nil {
+		return nil, ErrNodeExists
+	}
+	ring := r.ring.Clone()
+	if err := ring.Add(name); err != nil {
+		return nil, err
+	}
+	r.backends[name] = b
+	r.ring = ring
+	return Migrate(ctx, r.ring, r.backends)
+}
+
+// RemoveNode moves every key off name and takes it off the ring.
+func (r *Router) RemoveNode(ctx context.Context, name string) ([]Move, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ring := r.ring.Clone()
+	if err := ring.Remove(name); err != nil {
+		return nil, err
+	}
+	if len(ring.Nodes()) == 0 {
+		return nil, ErrNoNodes
+	}
+	moves, err := Migrate(ctx, ring, r.backends)
+	if err != nil {
+		return moves, err // name keeps its keys and its place on the ring
+	}
+	r.ring = ring
+	delete(r.backends, name)
+	return moves, nil
+}
+
+// Rebalance moves keys that are not on their owner, e.g. after a failed
+// AddNode or RemoveNode.
+func (r *Router) Rebalance(ctx context.Context) ([]Move, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Migrate(ctx, r.ring, r.backends)
+}
+
+// --- store.UserStore ---
+
+// List asks every node in parallel and merges the answers by ID.
+func (r *Router) List(ctx context.Context) ([]store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.listLocked(ctx)
+}
+
+func (r *Router) Get(ctx context.Context, id int) (store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, err := r.owner(id)
+	if err != nil {
+		return store.User{}, err
+	}
+	return b.Get(ctx, id)
+}
+
+// GetByEmail asks every node, since emails are not part of the key.
+func (r *Router) GetByEmail(ctx context.Context, email string) (store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.getByEmail(ctx, email)
+}
+
+func (r *Router) Create(ctx context.Context, u store.User) (store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.checkEmail(ctx, u.Email, 0); err != nil {
+		return store.User{}, err
+	}
+	if err := r.raiseIDs(ctx); err != nil {
+		return store.User{}, err
+	}
+	id, err := r.ids.Next()
+	if err != nil {
+		return store.User{}, err
+	}
+
+	u.ID = id
+	b, err := r.owner(u.ID)
+	if err != nil {
+		return store.User{}, err
+	}
+	if err := b.Put(ctx, u); err != nil {
+		return store.User{}, err
+	}
+	return u, nil
+}
+
+// Put stores u under its own ID on its node, replacing any user with that
+// ID, and keeps new IDs above it.
+func (r *Router) Put(ctx context.Context, u store.User) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	b, err := r.owner(u.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.checkEmail(ctx, u.Email, u.ID); err != nil {
+		return err
+	}
+	if err := r.ids.Raise(u.ID); err != nil {
+		return err
+	}
+	return b.Put(ctx, u)
+}
+
+func (r *Router) Update(ctx context.Context, u store.User) (store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	b, err := r.owner(u.ID)
+	if err != nil {
+		return store.User{}, err
+	}
+	if err := r.checkEmail(ctx, u.Email, u.ID); err != nil {
+		return store.User{}, err
+	}
+	return b.Update(ctx, u)
+}
+
+func (r *Router) Delete(ctx context.Context, id int) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, err := r.owner(id)
+	if err != nil {
+		return err
+	}
+	return b.Delete(ctx, id)
+}
+
+// --- HELPERS ---
+
+// owner returns the backend of id. The caller must hold r.mu.
+func (r *Router) owner(id int) (Backend, error) {
+	name, err := r.ring.OwnerOf(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.backends[name], nil
+}
+
+// raiseIDs moves ids past the users already on the nodes, once, so a
+// counter that starts after the nodes were filled does not reuse their
+// IDs. The caller must hold r.mu and r.writeMu.
+func (r *Router) raiseIDs(ctx context.Context) error {
+	if r.raised {
+		return nil
+	}
+	users, err := r.listLocked(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		if err := r.ids.Raise(users[len(users)-1].ID); err != nil {
+			return err
+		}
+	}
+	r.raised = true
+	return nil
+}
+
+// ringBackends returns the backends on the ring. The caller must hold r.mu.
+func (r *Router) ringBackends() []Backend {
+	nodes := r.ring.Nodes()
+	out := make([]Backend, len(nodes))
+	for i, n := range nodes {
+		out[i] = r.backends[n]
+	}
+	return out
+}
+
+// listLocked is List for callers that hold r.mu.
+func (r *Router) listLocked(ctx context.Context) ([]store.User, error) {
+	parts, err := fanOut(r.ringBackends(), func(b Backend) ([]store.User, error) {
+		return b.List(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeByID(parts), nil
+}
+
+func (r *Router) getByEmail(ctx context.Context, email string) (store.User, error) {
+	found, err := fanOut(r.ringBackends(), func(b Backend) ([]store.User, error) {
+		u, err := b.GetByEmail(ctx, email)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return []store.User{u}, err
+	})
+	if err != nil {
+		return store.User{}, err
+	}
+	for _, part := range found {
+		if len(part) > 0 {
+			return part[0], nil
+		}
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+// checkEmail returns ErrEmailTaken when a user other than exceptID has email.
+func (r *Router) checkEmail(ctx context.Context, email string, exceptID int) error {
+	if email == "" {
+		return nil
+	}
+	u, err := r.getByEmail(ctx, email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case u.ID != exceptID:
+		return store.ErrEmailTaken
+	}
+	return nil
+}
+
+// fanOut calls fn on every backend at once and returns the results in
+// backend order, with the errors joined.
+func fanOut(backends []Backend, fn func(Backend) ([]store.User, error)) ([][]store.User, error) {
+	results := make([][]store.User, len(backends))
+	errs := make([]error, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fn(b)
+		}()
+	}
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
+
+// mergeByID merges lists that are each sorted by ID.
+func mergeByID(parts [][]store.User) []store.User {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]store.User, 0, n)
+	for len(out) < n {
+		best := -1
+		for i, p := range parts {
+			if len(p) > 0 && (best < 0 || p[0].ID < parts[best][0].ID) {
+				best = i
+			}
+		}
+		out = append(out, parts[best][0])
+		parts[best] = parts[best][1:]
+	}
+	return out
+}
@@ -0,0 +1,276 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"httpserver/kv"
+	"httpserver/store"
+)
+
+func TestRingIsDeterministicAndBalanced(t *testing.T) {
+	a := NewRing(0, "a", "b", "c")
+	b := NewRing(0, "c", "a", "b")
+
+	counts := map[string]int{}
+	const keys = 30000
+	for id := 1; id <= keys; id++ {
+		na, _ := a.OwnerOf(id)
+		nb, _ := b.OwnerOf(id)
+		if na != nb {
+			t.Fatalf("OwnerOf(%d) = %q and %q depending on insertion order", id, na, nb)
+		}
+		counts[na]++
+	}
+	for node, n := range counts {
+		if n < keys/3*8/10 || n > keys/3*12/10 {
+			t.Errorf("node %s owns %d of %d keys", node, n, keys)
+		}
+	}
+
+	if _, err := NewRing(0).OwnerOf(1); !errors.Is(err, ErrNoNodes) {
+		t.Errorf("empty ring: err = %v; want ErrNoNodes", err)
+	}
+	if err := a.Add("a"); !errors.Is(err, ErrNodeExists) {
+		t.Errorf("Add twice: err = %v", err)
+	}
+}
+
+func TestRingMovesFewKeys(t *testing.T) {
+	before := NewRing(0, "a", "b", "c")
+	after := before.Clone()
+	after.Add("d")
+
+	moved := 0
+	const keys = 20000
+	for id := 1; id <= keys; id++ {
+		x, _ := before.OwnerOf(id)
+		y, _ := after.OwnerOf(id)
+		if x != y {
+			if y != "d" {
+				t.Fatalf("key %d moved from %s to %s, not to the new node", id, x, y)
+			}
+			moved++
+		}
+	}
+	// Ideally a quarter of the keys move to the new node.
+	if moved < keys/4*7/10 || moved > keys/4*13/10 {
+		t.Errorf("%d of %d keys moved; want about %d", moved, keys, keys/4)
+	}
+
+	after.Remove("d")
+	for id := 1; id <= 1000; id++ {
+		x, _ := before.OwnerOf(id)
+		y, _ := after.OwnerOf(id)
+		if x != y {
+			t.Fatalf("after Remove, key %d is on %s; want %s", id, y, x)
+		}
+	}
+}
+
+func newTestRouter(t *testing.T, nodes ...string) (*Router, map[string]*store.MemoryUserStore) {
+	t.Helper()
+	mems := map[string]*store.MemoryUserStore{}
+	backends := map[string]Backend{}
+	for _, n := range nodes {
+		mems[n] = store.NewMemoryUserStore()
+		backends[n] = mems[n]
+	}
+	r, err := NewRouter(NewRing(16, nodes...), backends, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, mems
+}
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+	r, mems := newTestRouter(t, "a", "b", "c")
+
+	for i := 1; i <= 30; i++ {
+		u, err := r.Create(ctx, store.User{Name: fmt.Sprint("user", i), Email: fmt.Sprintf("u%d@example.com", i)})
+		if err != nil || u.ID != i {
+			t.Fatalf("Create = %+v, %v; want ID %d", u, err, i)
+		}
+	}
+
+	// Every user lives on the node the ring picks, and only there.
+	for name, m := range mems {
+		users, _ := m.List(ctx)
+		if len(users) == 0 {
+			t.Errorf("node %s got no users", name)
+		}
+		for _, u := range users {
+			if owner, _ := r.NodeOf(u.ID); owner != name {
+				t.Errorf("user %d is on %s; ring says %s", u.ID, name, owner)
+			}
+		}
+	}
+
+	all, err := r.List(ctx)
+	if err != nil || len(all) != 30 {
+		t.Fatalf("List = %d users, %v", len(all), err)
+	}
+	for i, u := range all {
+		if u.ID != i+1 {
+			t.Fatalf("List is not sorted: position %d has ID %d", i, u.ID)
+		}
+	}
+
+	// Emails are unique across nodes, whatever the case.
+	if _, err := r.Create(ctx, store.User{Name: "dup", Email: "U7@example.com"}); !errors.Is(err, store.ErrEmailTaken) {
+		t.Errorf("Create with a used email: err = %v", err)
+	}
+	u3, _ := r.Get(ctx, 3)
+	u3.Email = "u8@example.com"
+	if _, err := r.Update(ctx, u3); !errors.Is(err, store.ErrEmailTaken) {
+		t.Errorf("Update to a used email: err = %v", err)
+	}
+	u3.Email, u3.Name = "u3@example.com", "renamed"
+	if _, err := r.Update(ctx, u3); err != nil {
+		t.Errorf("Update keeping the own email: %v", err)
+	}
+	if got, err := r.GetByEmail(ctx, "U3@EXAMPLE.COM"); err != nil || got.Name != "renamed" {
+		t.Errorf("GetByEmail = %+v, %v", got, err)
+	}
+
+	if err := r.Delete(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v", err)
+	}
+}
+
+func TestRouterContinuesExistingIDs(t *testing.T) {
+	ctx := context.Background()
+	a := store.NewMemoryUserStore(store.User{ID: 1}, store.User{ID: 9})
+	r, _ := NewRouter(NewRing(0, "a"), map[string]Backend{"a": a}, nil)
+	if u, _ := r.Create(ctx, store.User{Name: "new"}); u.ID != 10 {
+		t.Errorf("ID = %d; want 10", u.ID)
+	}
+	if _, err := NewRouter(NewRing(0, "a", "b"), map[string]Backend{"a": a}, nil); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("node without backend: err = %v", err)
+	}
+}
+
+func TestRouterNeverReusesIDs(t *testing.T) {
+	ctx := context.Background()
+	db, err := kv.Open(t.TempDir(), kv.Options{Sync: kv.SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, b := store.NewMemoryUserStore(), store.NewMemoryUserStore()
+	backends := map[string]Backend{"a": a, "b": b}
+	ring := NewRing(0, "a", "b")
+
+	r, _ := NewRouter(ring, backends, store.NewKVCounter(db, "next/user"))
+	for range 3 {
+		r.Create(ctx, store.User{Name: "user"})
+	}
+	if err := r.Delete(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted router does not give the deleted user's ID away again.
+	r, _ = NewRouter(ring, backends, store.NewKVCounter(db, "next/user"))
+	if u, _ := r.Create(ctx, store.User{Name: "new"}); u.ID != 4 {
+		t.Errorf("ID = %d; want 4", u.ID)
+	}
+	if err := r.Put(ctx, store.User{ID: 20, Name: "seeded"}); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := r.Create(ctx, store.User{Name: "after put"}); u.ID != 21 {
+		t.Errorf("ID after Put = %d; want 21", u.ID)
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	if nodes, err := ParseNodes("a, b,c"); err != nil || !slices.Equal(nodes, []string{"a", "b", "c"}) {
+		t.Errorf("ParseNodes = %q, %v", nodes, err)
+	}
+	for _, bad := range []string{"", "a,,b", "a,../b", "a,a", ".."} {
+		if _, err := ParseNodes(bad); err == nil {
+			t.Errorf("ParseNodes(%q): want an error", bad)
+		}
+	}
+}
+
+func TestRouterAddAndRemoveNode(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRouter(t, "a", "b")
+	for i := 1; i <= 200; i++ {
+		r.Create(ctx, store.User{Name: "u"})
+	}
+
+	d := store.NewMemoryUserStore()
+	moves, err := r.AddNode(ctx, "d", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := d.List(ctx)
+	if len(moves) == 0 || len(got) != len(moves) {
+		t.Errorf("AddNode moved %d users; d has %d", len(moves), len(got))
+	}
+	for _, m := range moves {
+		if m.To != "d" {
+			t.Errorf("move %+v does not go to the new node", m)
+		}
+	}
+	assertAllReachable(t, r, 200)
+
+	if _, err := r.RemoveNode(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if nodes := r.Nodes(); len(nodes) != 2 || nodes[0] != "b" || nodes[1] != "d" {
+		t.Errorf("Nodes() = %v", nodes)
+	}
+	assertAllReachable(t, r, 200)
+
+	if moves, _ := r.Rebalance(ctx); len(moves) != 0 {
+		t.Errorf("Rebalance after a clean move made %d moves", len(moves))
+	}
+}
+
+func TestMigrateIsResumable(t *testing.T) {
+	ctx := context.Background()
+	a, b := store.NewMemoryUserStore(), store.NewMemoryUserStore()
+	for i := 1; i <= 50; i++ {
+		a.Put(ctx, store.User{ID: i})
+	}
+	// Pretend an earlier run copied user 1 to b but died before the delete.
+	ring := NewRing(0, "a", "b")
+	if owner, _ := ring.OwnerOf(1); owner == "b" {
+		b.Put(ctx, store.User{ID: 1})
+	}
+
+	backends := map[string]Backend{"a": a, "b": b}
+	if _, err := Migrate(ctx, ring, backends); err != nil {
+		t.Fatal(err)
+	}
+	if plan, _ := Plan(ctx, ring, backends); len(plan) != 0 {
+		t.Errorf("Plan after Migrate = %v", plan)
+	}
+	ua, _ := a.List(ctx)
+	ub, _ := b.List(ctx)
+	if len(ua)+len(ub) != 50 {
+		t.Errorf("a has %d and b has %d users; want 50 in total", len(ua), len(ub))
+	}
+}
+
+func assertAllReachable(t *testing.T, r *Router, n int) {
+	t.Helper()
+	ctx := context.Background()
+	for id := 1; id <= n; id++ {
+		if _, err := r.Get(ctx, id); err != nil {
+			t.Fatalf("Get(%d): %v", id, err)
+		}
+	}
+	if all, _ := r.List(ctx); len(all) != n {
+		t.Errorf("List has %d users; want %d", len(all), n)
+	}
+}
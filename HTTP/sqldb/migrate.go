@@ -0,0 +1,221 @@
+// Package sqldb runs versioned schema migrations on a database/sql
+// database and wraps work in transactions.
+//
+// Migrations are pairs of SQL files, usually embedded with go:embed:
+//
+//	0001_create_users.up.sql
+//	0001_create_users.down.sql
+//
+// The number is the version. Applied versions are recorded in the
+// schema_migrations table, and every migration runs in its own
+// transaction together with that record, so a failed one leaves the
+// schema as it was.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoDown is returned when rolling back a migration without a down file.
+	ErrNoDown = errors.New("sqldb: migration has no down file")
+	// ErrUnknownVersion is returned when the database has a version the
+	// migrations do not know, or a target version does not exist.
+	ErrUnknownVersion = errors.New("sqldb: unknown migration version")
+)
+
+// Migration is one schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // empty if the migration cannot be rolled back
+}
+
+// Load reads the migrations in dir of fsys, ordered by version.
+// Files that do not end in .up.sql or .down.sql are ignored.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		num, label, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("sqldb: bad migration file name %q, want 0001_name.up.sql", name)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("sqldb: version %d is both %q and %q", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("sqldb: migration %d has no up file", m.Version)
+		}
+		out = append(out, *m)
+	}
+	slices.SortFunc(out, func(a, b Migration) int { return a.Version - b.Version })
+	return out, nil
+}
+
+// Migrator applies and rolls back migrations.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration // ordered by version
+	// Now stamps applied migrations (default time.Now).
+	Now func() time.Time
+}
+
+// Version returns the latest applied version, 0 for an empty database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.init(ctx); err != nil {
+		return 0, err
+	}
+	var v sql.NullInt64
+	err := m.DB.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&v)
+	return int(v.Int64), err
+}
+
+// Up applies every migration newer than the database.
+// It returns the versions it applied.
+func (m *Migrator) Up(ctx context.Context) ([]int, error) {
+	if len(m.Migrations) == 0 {
+		return nil, nil
+	}
+	return m.To(ctx, m.Migrations[len(m.Migrations)-1].Version)
+}
+
+// Down rolls back the newest steps migrations. It returns the versions it
+// rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := m.index(current)
+	if err != nil {
+		return nil, err
+	}
+	target := 0
+	if i-steps >= 0 {
+		target = m.Migrations[i-steps].Version
+	}
+	return m.To(ctx, target)
+}
+
+// To migrates up or down until version is the latest applied one; 0 rolls
+// everything back. It returns the versions it applied or rolled back.
+func (m *Migrator) To(ctx context.Context, version int) ([]int, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := m.index(current)
+	if err != nil {
+		return nil, err
+	}
+	to, err := m.index(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []int
+	for i := from + 1; i <= to; i++ {
+		mig := m.Migrations[i]
+		err := InTx(ctx, m.DB, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				mig.Version, mig.Name, m.now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("sqldb: migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig.Version)
+	}
+	for i := from; i > to; i-- {
+		mig := m.Migrations[i]
+		if mig.Down == "" {
+			return done, fmt.Errorf("%w: %d %s", ErrNoDown, mig.Version, mig.Name)
+		}
+		err := InTx(ctx, m.DB, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("sqldb: rolling back %d %s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig.Version)
+	}
+	return done, nil
+}
+
+func (m *Migrator) init(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	return err
+}
+
+// index returns the position of version in m.Migrations, -1 for version 0.
+func (m *Migrator) index(version int) (int, error) {
+	if version == 0 {
+		return -1, nil
+	}
+	i, ok := slices.BinarySearchFunc(m.Migrations, version, func(mig Migration, v int) int { return mig.Version - v })
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return i, nil
+}
+
+func (m *Migrator) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
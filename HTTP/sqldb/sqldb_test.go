@@ -0,0 +1,150 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var testFS = fstest.MapFS{
+	"m/0001_items.up.sql":   {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);")},
+	"m/0001_items.down.sql": {Data: []byte("DROP TABLE items;")},
+	"m/0002_name.up.sql":    {Data: []byte("ALTER TABLE items ADD COLUMN name TEXT;")},
+	"m/0002_name.down.sql":  {Data: []byte("ALTER TABLE items DROP COLUMN name;")},
+	"m/0003_broken.up.sql":  {Data: []byte("CREATE TABLE tags (id INTEGER); INSERT INTO nope VALUES (1);")},
+	"m/README.md":           {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+	migs, err := Load(testFS, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 3 || migs[0].Name != "items" || migs[2].Down != "" {
+		t.Errorf("Load = %+v", migs)
+	}
+
+	bad := fstest.MapFS{"m/first.up.sql": {}}
+	if _, err := Load(bad, "m"); err == nil {
+		t.Error("Load accepted a file without a version")
+	}
+	downOnly := fstest.MapFS{"m/0001_x.down.sql": {}}
+	if _, err := Load(downOnly, "m"); err == nil {
+		t.Error("Load accepted a migration without an up file")
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	migs, _ := Load(testFS, "m")
+	m := Migrator{DB: db, Migrations: migs[:2]}
+
+	if v, _ := m.Version(ctx); v != 0 {
+		t.Errorf("Version of an empty database = %d", v)
+	}
+	applied, err := m.Up(ctx)
+	if err != nil || !slices.Equal(applied, []int{1, 2}) {
+		t.Fatalf("Up = %v, %v", applied, err)
+	}
+	if _, err := db.Exec("INSERT INTO items (name) VALUES ('a')"); err != nil {
+		t.Fatal(err)
+	}
+	if applied, _ := m.Up(ctx); len(applied) != 0 {
+		t.Errorf("second Up applied %v", applied)
+	}
+
+	// A failing migration leaves no trace.
+	m.Migrations = migs
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("Up with a broken migration succeeded")
+	}
+	if v, _ := m.Version(ctx); v != 2 {
+		t.Errorf("Version after failed migration = %d; want 2", v)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'tags'").Scan(&n)
+	if n != 0 {
+		t.Error("the failed migration's table was kept")
+	}
+
+	m.Migrations = migs[:2]
+	if rolled, err := m.Down(ctx, 1); err != nil || !slices.Equal(rolled, []int{2}) {
+		t.Errorf("Down(1) = %v, %v", rolled, err)
+	}
+	if _, err := db.Exec("INSERT INTO items (name) VALUES ('b')"); err == nil {
+		t.Error("column name survived the rollback")
+	}
+	if rolled, err := m.To(ctx, 0); err != nil || !slices.Equal(rolled, []int{1}) {
+		t.Errorf("To(0) = %v, %v", rolled, err)
+	}
+	if _, err := m.To(ctx, 7); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("To(7): %v", err)
+	}
+}
+
+func TestDownWithoutDownFile(t *testing.T) {
+	ctx := context.Background()
+	m := Migrator{DB: openDB(t), Migrations: []Migration{{Version: 1, Name: "x", Up: "CREATE TABLE x (id INTEGER);"}}}
+	m.Up(ctx)
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrNoDown) {
+		t.Errorf("Down: %v; want ErrNoDown", err)
+	}
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	count := func() (n int) {
+		db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n)
+		return n
+	}
+
+	boom := errors.New("boom")
+	err := InTx(ctx, db, func(tx *sql.Tx) error {
+		tx.Exec("INSERT INTO items VALUES (1)")
+		return boom
+	})
+	if !errors.Is(err, boom) || count() != 0 {
+		t.Errorf("failed tx: err = %v, %d rows", err, count())
+	}
+
+	func() {
+		defer func() { recover() }()
+		InTx(ctx, db, func(tx *sql.Tx) error {
+			tx.Exec("INSERT INTO items VALUES (1)")
+			panic("boom")
+		})
+	}()
+	if count() != 0 {
+		t.Error("panicking tx was committed")
+	}
+
+	n, err := InTxValue(ctx, db, func(tx *sql.Tx) (int64, error) {
+		res, err := tx.Exec("INSERT INTO items VALUES (1), (2)")
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	})
+	if err != nil || n != 2 || count() != 2 {
+		t.Errorf("InTxValue = %d, %v; %d rows", n, err, count())
+	}
+}
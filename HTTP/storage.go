@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"httpserver/kv"
+	"httpserver/shard"
+	"httpserver/store"
+)
+
+// --- STORAGE ---
+//
+// By default users and posts live in memory and are gone after a restart.
+// With DATA_DIR set they are kept in a kv database in that directory, and
+// with DB_CONN_STR set as well the users are kept in a SQLite database
+// instead. Posts are never kept in SQL, so DB_CONN_STR needs DATA_DIR:
+// posts left in memory would point at users that outlive them.
+// With SHARDS set as well, the users are split across one kv database per
+// node (see package shard), while posts and the user ID counter stay in
+// DATA_DIR itself.
+
+// configureStorage reads the storage settings from the environment:
+//
+//	DATA_DIR       directory of the kv database (off by default)
+//	KV_SYNC        "always" (default), "interval" or "never" fsync the writes
+//	KV_MERGE_EVERY how often to check for space to reclaim (default 10m, 0 never)
+//	DB_CONN_STR    SQLite database of the users, like "users.db" (off by default, needs DATA_DIR)
+//	SHARDS         comma-separated nodes the users are split across, like "a,b,c";
+//	               each is a kv database in DATA_DIR/shards/<node> (off by default)
+//	SHARD_VNODES   virtual nodes per shard (default 128), as given to cmd/reshard
+//
+// An empty database starts with the same users as the in-memory stores.
+// It must run before restoreBackup and configureReplication, which wrap
+// the stores it sets.
+func configureStorage() {
+	dir, conn := os.Getenv("DATA_DIR"), os.Getenv("DB_CONN_STR")
+	if dir == "" && conn == "" {
+		if os.Getenv("SHARDS") != "" {
+			log.Fatal("SHARDS needs DATA_DIR")
+		}
+		return
+	}
+	switch {
+	case dir == "":
+		log.Fatal("DB_CONN_STR needs DATA_DIR to keep the posts")
+	case multiTenant:
+		log.Fatal("DATA_DIR and DB_CONN_STR cannot be combined with MULTI_TENANT")
+	case os.Getenv("RESTORE_FROM") != "":
+		log.Fatal("DATA_DIR and DB_CONN_STR cannot be combined with RESTORE_FROM")
+	case os.Getenv("REPLICATION_ROLE") == "follower":
+		log.Fatal("DATA_DIR and DB_CONN_STR cannot be used on a replication follower")
+	case os.Getenv("SHARDS") != "" && conn != "":
+		log.Fatal("SHARDS cannot be combined with DB_CONN_STR")
+	}
+
+	ctx := context.Background()
+	seed, _ := defaultState.users.List(ctx)
+	users, posts := defaultState.rawUsers, defaultState.posts
+
+	if dir != "" {
+		db := openKV(dir)
+		var kvUsers store.UserStore
+		if shards := os.Getenv("SHARDS"); shards != "" {
+			router := openShards(ctx, dir, db, shards)
+			if list, err := router.List(ctx); err != nil {
+				log.Fatal(err)
+			} else if len(list) == 0 && db.Len() == 0 {
+				seedUsers(ctx, router, seed)
+			}
+			kvUsers = router
+			log.Printf("Users in %d shards: %s", len(router.Nodes()), strings.Join(router.Nodes(), ", "))
+		} else {
+			s := store.NewKVUserStore(db)
+			if db.Len() == 0 {
+				seedUsers(ctx, s, seed)
+			}
+			kvUsers = s
+		}
+		users, posts = store.TracedUserStore{Next: kvUsers}, store.TracedPostStore{Next: store.NewKVPostStore(db)}
+		log.Printf("Data in %s (%d keys)", dir, db.Len())
+	}
+
+	if conn != "" {
+		db := openSQL(ctx, conn)
+		sqlUsers := store.NewSQLUserStore(db)
+		if list, err := sqlUsers.List(ctx); err != nil {
+			log.Fatal(err)
+		} else if len(list) == 0 {
+			seedUsers(ctx, sqlUsers, seed)
+		}
+		users = store.TracedUserStore{Next: sqlUsers}
+		log.Println("Users in the SQL database")
+	}
+
+	defaultState.setStores(users, posts)
+}
+
+func openKV(dir string) *kv.DB {
+	opts := kv.Options{MergeEvery: envDuration("KV_MERGE_EVERY", 10*time.Minute)}
+	switch mode := envOr("KV_SYNC", "always"); mode {
+	case "always":
+		opts.Sync = kv.SyncAlways
+	case "interval":
+		opts.Sync = kv.SyncInterval
+	case "never":
+		opts.Sync = kv.SyncNever
+	default:
+		log.Fatalf("invalid KV_SYNC %q", mode)
+	}
+
+	db, err := kv.Open(dir, opts)
+	if err != nil {
+		log.Fatalf("open %s: %v", dir, err)
+	}
+	return db
+}
+
+// openShards opens the kv database of every node in SHARDS and routes the
+// users across them. New user IDs come from the counter in db, the one a
+// KVUserStore on db would use.
+func openShards(ctx context.Context, dir string, db *kv.DB, shards string) *shard.Router {
+	nodes, err := shard.ParseNodes(shards)
+	if err != nil {
+		log.Fatalf("SHARDS: %v", err)
+	}
+	if unsharded, err := store.NewKVUserStore(db).List(ctx); err != nil {
+		log.Fatal(err)
+	} else if len(unsharded) > 0 {
+		log.Fatalf("%s holds %d users from before SHARDS was set", dir, len(unsharded))
+	}
+	existing, err := shard.ExistingNodes(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, n := range existing {
+		if !slices.Contains(nodes, n) {
+			log.Fatalf("shard %q is not in SHARDS; move its users with cmd/reshard", n)
+		}
+	}
+
+	backends := make(map[string]shard.Backend, len(nodes))
+	for _, n := range nodes {
+		backends[n] = store.NewKVUserStore(openKV(shard.NodeDir(dir, n)))
+	}
+	ring := shard.NewRing(envInt("SHARD_VNODES", shard.DefaultVirtualNodes), nodes...)
+	router, err := shard.NewRouter(ring, backends, store.NewKVCounter(db, "next/user"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if moves, err := shard.Plan(ctx, ring, backends); err != nil {
+		log.Fatal(err)
+	} else if len(moves) > 0 {
+		log.Fatalf("%d users are not on the shard SHARDS puts them on; run cmd/reshard", len(moves))
+	}
+	return router
+}
+
+// openSQL opens the SQLite database conn and migrates its schema.
+func openSQL(ctx context.Context, conn string) *sql.DB {
+	// Wait for the write lock instead of failing with SQLITE_BUSY.
+	if !strings.Contains(conn, "busy_timeout") {
+		sep := "?"
+		if strings.Contains(conn, "?") {
+			sep = "&"
+		}
+		conn += sep + "_pragma=busy_timeout(5000)"
+	}
+	db, err := sql.Open("sqlite", conn)
+	if err == nil {
+		err = db.PingContext(ctx)
+	}
+	if err == nil {
+		err = store.MigrateSQL(ctx, db)
+	}
+	if err != nil {
+		log.Fatalf("open DB_CONN_STR: %v", err)
+	}
+	return db
+}
+
+func seedUsers(ctx context.Context, s interface {
+	Put(ctx context.Context, u store.User) error
+}, seed []store.User) {
+	for _, u := range seed {
+		if err := s.Put(ctx, u); err != nil {
+			log.Fatalf("seed users: %v", err)
+		}
+	}
+}
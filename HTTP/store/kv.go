@@ -0,0 +1,484 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"httpserver/kv"
+)
+
+// --- KEY-VALUE STORES ---
+//
+// KVUserStore and KVPostStore keep their records in a kv.DB, so the data
+// outlives the process. Records are JSON under zero-padded IDs, which makes
+// a prefix scan return them in ID order:
+//
+//	user/00000000000000000001           {"id":1,...,"passwordHash":"..."}
+//	user-email/alice@example.com        1
+//	post/00000000000000000007           {"id":7,"userId":1,...}
+//	post-user/00000000000000000001/00000000000000000007
+//	post-ext/00000000000000000042       7
+//	next/user                           2
+//
+// kv has no transactions, so a crash can leave an index entry without the
+// record it points to. The record is the source of truth: every index hit
+// is checked against it, and writes store the record before adding index
+// entries and remove index entries only after the record is gone.
+
+func idKey(prefix string, id int) string { return fmt.Sprintf("%s%020d", prefix, id) }
+
+func getJSON(db *kv.DB, key string, v any) error {
+	data, err := db.Get(key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func putJSON(db *kv.DB, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return db.Put(key, data)
+}
+
+func getInt(db *kv.DB, key string) (int, error) {
+	data, err := db.Get(key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+func putInt(db *kv.DB, key string, n int) error {
+	return db.Put(key, []byte(strconv.Itoa(n)))
+}
+
+// nextID reserves the next ID of counter. IDs are never reused, even
+// after a crash. The caller must serialize calls.
+func nextID(db *kv.DB, counter string) (int, error) {
+	id, err := getInt(db, counter)
+	if errors.Is(err, ErrNotFound) {
+		id, err = 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, putInt(db, counter, id+1)
+}
+
+// raiseNextID makes sure counter is past id.
+func raiseNextID(db *kv.DB, counter string, id int) error {
+	next, err := getInt(db, counter)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if id < next {
+		return nil
+	}
+	return putInt(db, counter, id+1)
+}
+
+// KVCounter is an ID counter kept in a kv.DB the way the stores keep
+// theirs, for IDs that are handed out elsewhere, like by a shard.Router.
+type KVCounter struct {
+	db  *kv.DB
+	key string
+	mu  sync.Mutex
+}
+
+// NewKVCounter returns the counter under key in db.
+func NewKVCounter(db *kv.DB, key string) *KVCounter {
+	return &KVCounter{db: db, key: key}
+}
+
+// Next reserves the next ID.
+func (c *KVCounter) Next() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return nextID(c.db, c.key)
+}
+
+// Raise makes sure Next returns IDs above id.
+func (c *KVCounter) Raise(id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return raiseNextID(c.db, c.key, id)
+}
+
+// kvUser is the stored form of a User. Unlike the API form it keeps the
+// password hash.
+type kvUser struct {
+	User
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// KVUserStore keeps users in a kv.DB.
+type KVUserStore struct {
+	db *kv.DB
+	mu sync.Mutex // serializes writes, so the email check and the write are one step
+}
+
+// NewKVUserStore returns a store on db. The caller keeps ownership of db.
+func NewKVUserStore(db *kv.DB) *KVUserStore {
+	return &KVUserStore{db: db}
+}
+
+func (s *KVUserStore) List(ctx context.Context) ([]User, error) {
+	out := []User{}
+	var decodeErr error
+	err := s.db.Scan("user/", func(_ string, value []byte) bool {
+		var u kvUser
+		if decodeErr = json.Unmarshal(value, &u); decodeErr != nil {
+			return false
+		}
+		out = append(out, u.toUser())
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return out, err
+}
+
+func (s *KVUserStore) Get(ctx context.Context, id int) (User, error) {
+	var u kvUser
+	if err := getJSON(s.db, idKey("user/", id), &u); err != nil {
+		return User{}, err
+	}
+	return u.toUser(), nil
+}
+
+func (s *KVUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	if email == "" {
+		return User{}, ErrNotFound
+	}
+	id, err := getInt(s.db, emailKey(email))
+	if err != nil {
+		return User{}, err
+	}
+	u, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) || (err == nil && !strings.EqualFold(u.Email, email)) {
+		return User{}, ErrNotFound // stale index entry
+	}
+	return u, err
+}
+
+func (s *KVUserStore) Create(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkEmail(ctx, u.Email, 0); err != nil {
+		return User{}, err
+	}
+	id, err := nextID(s.db, "next/user")
+	if err != nil {
+		return User{}, err
+	}
+	u.ID = id
+	return u, s.write(u, "")
+}
+
+func (s *KVUserStore) Update(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.Get(ctx, u.ID)
+	if err != nil {
+		return User{}, err
+	}
+	if err := s.checkEmail(ctx, u.Email, u.ID); err != nil {
+		return User{}, err
+	}
+	return u, s.write(u, old.Email)
+}
+
+func (s *KVUserStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(idKey("user/", id)); err != nil {
+		return err
+	}
+	return s.dropEmail(u.Email, id)
+}
+
+// Put stores u under its own ID, replacing any user with that ID.
+func (s *KVUserStore) Put(ctx context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.Get(ctx, u.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err := raiseNextID(s.db, "next/user", u.ID); err != nil {
+		return err
+	}
+	return s.write(u, old.Email)
+}
+
+// write stores u and points its email at it. oldEmail is the email u had
+// before, if any. The caller must hold the lock.
+func (s *KVUserStore) write(u User, oldEmail string) error {
+	if err := putJSON(s.db, idKey("user/", u.ID), kvUser{User: u, PasswordHash: u.PasswordHash}); err != nil {
+		return err
+	}
+	if u.Email != "" {
+		if err := putInt(s.db, emailKey(u.Email), u.ID); err != nil {
+			return err
+		}
+	}
+	if oldEmail != "" && !strings.EqualFold(oldEmail, u.Email) {
+		return s.dropEmail(oldEmail, u.ID)
+	}
+	return nil
+}
+
+// dropEmail removes the index entry of email if it still points at id.
+func (s *KVUserStore) dropEmail(email string, id int) error {
+	if email == "" {
+		return nil
+	}
+	if owner, err := getInt(s.db, emailKey(email)); err != nil || owner != id {
+		return nil
+	}
+	return s.db.Delete(emailKey(email))
+}
+
+// checkEmail returns ErrEmailTaken when a user other than exceptID uses
+// email. The caller must hold the lock.
+func (s *KVUserStore) checkEmail(ctx context.Context, email string, exceptID int) error {
+	u, err := s.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case u.ID != exceptID:
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func emailKey(email string) string { return "user-email/" + strings.ToLower(email) }
+
+func (u kvUser) toUser() User {
+	out := u.User
+	out.PasswordHash = u.PasswordHash
+	return out
+}
+
+// KVPostStore keeps posts in a kv.DB.
+type KVPostStore struct {
+	db *kv.DB
+	mu sync.Mutex // serializes writes, so the indexes follow the records
+}
+
+// NewKVPostStore returns a store on db. The caller keeps ownership of db.
+func NewKVPostStore(db *kv.DB) *KVPostStore {
+	return &KVPostStore{db: db}
+}
+
+func (s *KVPostStore) ListByUser(ctx context.Context, userID int) ([]Post, error) {
+	var ids []int
+	err := s.db.Scan(idKey("post-user/", userID)+"/", func(key string, _ []byte) bool {
+		id, err := strconv.Atoi(key[strings.LastIndexByte(key, '/')+1:])
+		if err == nil {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Post{}
+	for _, id := range ids {
+		p, err := s.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue // stale index entry
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (s *KVPostStore) Get(ctx context.Context, id int) (Post, error) {
+	var p Post
+	err := getJSON(s.db, idKey("post/", id), &p)
+	return p, err
+}
+
+func (s *KVPostStore) Create(ctx context.Context, p Post) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := nextID(s.db, "next/post")
+	if err != nil {
+		return Post{}, err
+	}
+	p.ID = id
+	return p, s.write(p, Post{})
+}
+
+func (s *KVPostStore) Update(ctx context.Context, p Post) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.Get(ctx, p.ID)
+	if err != nil {
+		return Post{}, err
+	}
+	return p, s.write(p, old)
+}
+
+func (s *KVPostStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.remove(p)
+}
+
+func (s *KVPostStore) DeleteByUser(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	posts, err := s.ListByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range posts {
+		if err := s.remove(p); err != nil {
+			return i, err
+		}
+	}
+	return len(posts), nil
+}
+
+func (s *KVPostStore) CountByUser(ctx context.Context, userID int) (int, error) {
+	posts, err := s.ListByUser(ctx, userID)
+	return len(posts), err
+}
+
+func (s *KVPostStore) UpsertExternal(ctx context.Context, p Post) (Post, bool, error) {
+	if p.ExternalID == 0 {
+		return Post{}, false, ErrNoExternalID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := getInt(s.db, idKey("post-ext/", p.ExternalID))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Post{}, false, err
+	}
+	if err == nil {
+		old, err := s.Get(ctx, id)
+		if err == nil && old.ExternalID == p.ExternalID {
+			p.ID = id
+			return p, false, s.write(p, old)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return Post{}, false, err
+		}
+	}
+
+	if p.ID, err = nextID(s.db, "next/post"); err != nil {
+		return Post{}, false, err
+	}
+	return p, true, s.write(p, Post{})
+}
+
+// Put stores p under its own ID, replacing any post with that ID.
+func (s *KVPostStore) Put(ctx context.Context, p Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.Get(ctx, p.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err := raiseNextID(s.db, "next/post", p.ID); err != nil {
+		return err
+	}
+	return s.write(p, old)
+}
+
+// write stores p and its index entries, and removes the entries of old
+// (the zero Post for new posts) that no longer apply. The caller must hold
+// the lock.
+func (s *KVPostStore) write(p, old Post) error {
+	if err := putJSON(s.db, idKey("post/", p.ID), p); err != nil {
+		return err
+	}
+	if err := s.db.Put(userPostKey(p.UserID, p.ID), nil); err != nil {
+		return err
+	}
+	if p.ExternalID != 0 {
+		if err := putInt(s.db, idKey("post-ext/", p.ExternalID), p.ID); err != nil {
+			return err
+		}
+	}
+	if old.ID != 0 && old.UserID != p.UserID {
+		if err := s.db.Delete(userPostKey(old.UserID, old.ID)); err != nil {
+			return err
+		}
+	}
+	if old.ExternalID != 0 && old.ExternalID != p.ExternalID {
+		return s.dropExternal(old)
+	}
+	return nil
+}
+
+// remove deletes p and then its index entries. The caller must hold the lock.
+func (s *KVPostStore) remove(p Post) error {
+	if err := s.db.Delete(idKey("post/", p.ID)); err != nil {
+		return err
+	}
+	if err := s.db.Delete(userPostKey(p.UserID, p.ID)); err != nil {
+		return err
+	}
+	return s.dropExternal(p)
+}
+
+// dropExternal removes the external-id entry of p if it still points at p.
+func (s *KVPostStore) dropExternal(p Post) error {
+	if p.ExternalID == 0 {
+		return nil
+	}
+	key := idKey("post-ext/", p.ExternalID)
+	if id, err := getInt(s.db, key); err != nil || id != p.ID {
+		return nil
+	}
+	return s.db.Delete(key)
+}
+
+func userPostKey(userID, postID int) string {
+	return idKey(idKey("post-user/", userID)+"/", postID)
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"httpserver/kv"
+)
+
+func openKV(t *testing.T, dir string) *kv.DB {
+	t.Helper()
+	db, err := kv.Open(dir, kv.Options{Sync: kv.SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestKVUserStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openKV(t, dir)
+	s := NewKVUserStore(db)
+
+	alice, _ := s.Create(ctx, User{Name: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+	bob, err := s.Create(ctx, User{Name: "Bob", Email: "bob@example.com"})
+	if err != nil || bob.ID != 2 {
+		t.Fatalf("Create = %+v, %v; want ID 2", bob, err)
+	}
+	if _, err := s.Create(ctx, User{Email: "ALICE@example.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Create with a used email: err = %v", err)
+	}
+
+	// Changing an email frees the old one.
+	bob.Email = "robert@example.com"
+	if _, err := s.Update(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetByEmail(ctx, "bob@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old email still found: %v", err)
+	}
+	if _, err := s.Create(ctx, User{Name: "Other Bob", Email: "bob@example.com"}); err != nil {
+		t.Errorf("Create with a freed email: %v", err)
+	}
+	s.Delete(ctx, 3)
+	db.Close()
+
+	// Everything, including the password hash and the next ID, survives a restart.
+	db = openKV(t, dir)
+	defer db.Close()
+	s = NewKVUserStore(db)
+	if got, err := s.GetByEmail(ctx, "Alice@Example.com"); err != nil || got.ID != alice.ID || got.PasswordHash != "hash" {
+		t.Errorf("GetByEmail after reopen = %+v, %v", got, err)
+	}
+	if list, _ := s.List(ctx); len(list) != 2 || list[0].ID != 1 || list[1].ID != 2 {
+		t.Errorf("List after reopen = %+v", list)
+	}
+	if u, _ := s.Create(ctx, User{Name: "Carol"}); u.ID != 4 {
+		t.Errorf("Create after reopen got ID %d; want 4", u.ID)
+	}
+
+	// An index entry left behind by a crash does not block the email.
+	db.Put(emailKey("ghost@example.com"), []byte("99"))
+	if _, err := s.Create(ctx, User{Email: "ghost@example.com"}); err != nil {
+		t.Errorf("stale index blocked Create: %v", err)
+	}
+}
+
+func TestKVCounter(t *testing.T) {
+	dir := t.TempDir()
+	db := openKV(t, dir)
+	c := NewKVCounter(db, "next/user")
+	if id, err := c.Next(); err != nil || id != 1 {
+		t.Fatalf("Next = %d, %v; want 1", id, err)
+	}
+	c.Raise(9)
+	c.Raise(3)
+	db.Close()
+
+	db = openKV(t, dir)
+	defer db.Close()
+	if id, _ := NewKVCounter(db, "next/user").Next(); id != 10 {
+		t.Errorf("Next after reopen = %d; want 10", id)
+	}
+	if u, _ := NewKVUserStore(db).Create(context.Background(), User{Name: "Ann"}); u.ID != 11 {
+		t.Errorf("the user store's counter is not the same: ID %d", u.ID)
+	}
+}
+
+func TestKVPostStore(t *testing.T) {
+	ctx := context.Background()
+	db := openKV(t, t.TempDir())
+	defer db.Close()
+	s := NewKVPostStore(db)
+
+	s.Create(ctx, Post{UserID: 1, Title: "a"})
+	p, _ := s.Create(ctx, Post{UserID: 2, Title: "b"})
+	s.Create(ctx, Post{UserID: 1, Title: "c"})
+
+	p.UserID = 1
+	s.Update(ctx, p)
+	if n, _ := s.CountByUser(ctx, 2); n != 0 {
+		t.Errorf("CountByUser(2) after moving the post = %d", n)
+	}
+	if list, _ := s.ListByUser(ctx, 1); len(list) != 3 || list[1].Title != "b" {
+		t.Errorf("ListByUser(1) = %+v", list)
+	}
+
+	created, isNew, _ := s.UpsertExternal(ctx, Post{UserID: 3, ExternalID: 42, Title: "old"})
+	got, isNew2, _ := s.UpsertExternal(ctx, Post{UserID: 3, ExternalID: 42, Title: "new"})
+	if !isNew || isNew2 || got.ID != created.ID {
+		t.Errorf("UpsertExternal ids %d, %d (new %v, %v)", created.ID, got.ID, isNew, isNew2)
+	}
+
+	if n, err := s.DeleteByUser(ctx, 1); err != nil || n != 3 {
+		t.Errorf("DeleteByUser = %d, %v; want 3", n, err)
+	}
+	if _, err := s.Get(ctx, p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after DeleteByUser: %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --- IN-MEMORY USER STORE ---
+
+// MemoryUserStore keeps users in a map guarded by a mutex.
+type MemoryUserStore struct {
+	mu     sync.RWMutex
+	users  map[int]User
+	nextID int
+}
+
+// NewMemoryUserStore returns a store that starts with the given users.
+func NewMemoryUserStore(seed ...User) *MemoryUserStore {
+	s := &MemoryUserStore{users: make(map[int]User), nextID: 1}
+	for _, u := range seed {
+		s.users[u.ID] = u
+		if u.ID >= s.nextID {
+			s.nextID = u.ID + 1
+		}
+	}
+	return s
+}
+
+func (s *MemoryUserStore) List(ctx context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id int) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *MemoryUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email != "" && strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) Create(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(u.Email, 0) {
+		return User{}, ErrEmailTaken
+	}
+	u.ID = s.nextID
+	s.nextID++
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *MemoryUserStore) Update(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[u.ID]; !ok {
+		return User{}, ErrNotFound
+	}
+	if s.emailTaken(u.Email, u.ID) {
+		return User{}, ErrEmailTaken
+	}
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	return nil
+}
+
+// Put stores u under its own ID, replacing any user with that ID.
+// Replication uses it to copy the leader's records as they are.
+func (s *MemoryUserStore) Put(ctx context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.ID] = u
+	if u.ID >= s.nextID {
+		s.nextID = u.ID + 1
+	}
+	return nil
+}
+
+// Reset replaces every user with users.
+func (s *MemoryUserStore) Reset(users ...User) {
+	fresh := NewMemoryUserStore(users...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users, s.nextID = fresh.users, fresh.nextID
+}
+
+// emailTaken reports whether a user other than exceptID uses email.
+// The caller must hold the lock.
+func (s *MemoryUserStore) emailTaken(email string, exceptID int) bool {
+	if email == "" {
+		return false
+	}
+	for _, u := range s.users {
+		if u.ID != exceptID && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- IN-MEMORY POST STORE ---
+
+// MemoryPostStore keeps posts in a map guarded by a mutex.
+type MemoryPostStore struct {
+	mu     sync.RWMutex
+	posts  map[int]Post
+	nextID int
+}
+
+// NewMemoryPostStore returns an empty post store.
+func NewMemoryPostStore() *MemoryPostStore {
+	return &MemoryPostStore{posts: make(map[int]Post), nextID: 1}
+}
+
+func (s *MemoryPostStore) ListByUser(ctx context.Context, userID int) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := []Post{}
+	for _, p := range s.posts {
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *MemoryPostStore) Get(ctx context.Context, id int) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return Post{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (s *MemoryPostStore) Create(ctx context.Context, p Post) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.ID = s.nextID
+	s.nextID++
+	s.posts[p.ID] = p
+	return p, nil
+}
+
+func (s *MemoryPostStore) Update(ctx context.Context, p Post) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[p.ID]; !ok {
+		return Post{}, ErrNotFound
+	}
+	s.posts[p.ID] = p
+	return p, nil
+}
+
+func (s *MemoryPostStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.posts, id)
+	return nil
+}
+
+func (s *MemoryPostStore) DeleteByUser(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id, p := range s.posts {
+		if p.UserID == userID {
+			delete(s.posts, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryPostStore) CountByUser(ctx context.Context, userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, p := range s.posts {
+		if p.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryPostStore) UpsertExternal(ctx context.Context, p Post) (Post, bool, error) {
+	if p.ExternalID == 0 {
+		return Post{}, false, ErrNoExternalID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.posts {
+		if existing.ExternalID == p.ExternalID {
+			p.ID = id
+			s.posts[id] = p
+			return p, false, nil
+		}
+	}
+
+	p.ID = s.nextID
+	s.nextID++
+	s.posts[p.ID] = p
+	return p, true, nil
+}
+
+// Put stores p under its own ID, replacing any post with that ID.
+func (s *MemoryPostStore) Put(ctx context.Context, p Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.posts[p.ID] = p
+	if p.ID >= s.nextID {
+		s.nextID = p.ID + 1
+	}
+	return nil
+}
+
+// Reset replaces every post with posts.
+func (s *MemoryPostStore) Reset(posts ...Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.posts, s.nextID = make(map[int]Post, len(posts)), 1
+	for _, p := range posts {
+		s.posts[p.ID] = p
+		if p.ID >= s.nextID {
+			s.nextID = p.ID + 1
+		}
+	}
+}
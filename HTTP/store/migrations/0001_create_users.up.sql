@@ -0,0 +1,7 @@
+CREATE TABLE users (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    name           TEXT    NOT NULL,
+    email          TEXT    NOT NULL DEFAULT '',
+    email_verified INTEGER NOT NULL DEFAULT 0,
+    password_hash  TEXT    NOT NULL DEFAULT ''
+);
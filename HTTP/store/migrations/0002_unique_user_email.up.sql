@@ -0,0 +1,2 @@
+-- Emails are unique ignoring case; users without one are not indexed.
+CREATE UNIQUE INDEX users_email ON users (lower(email)) WHERE email <> '';
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+
+	"httpserver/sqldb"
+)
+
+// --- SQL USER STORE ---
+//
+// SQLUserStore keeps users in a database/sql database. The queries are
+// written for SQLite, which the tests and the server (DB_CONN_STR) use.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the schema changes SQLUserStore needs, oldest first.
+func Migrations() []sqldb.Migration {
+	migs, err := sqldb.Load(migrationFiles, "migrations")
+	if err != nil {
+		panic(err) // the files are compiled in, so this is a bug
+	}
+	return migs
+}
+
+// MigrateSQL brings the schema of db up to date for SQLUserStore.
+func MigrateSQL(ctx context.Context, db *sql.DB) error {
+	m := sqldb.Migrator{DB: db, Migrations: Migrations()}
+	_, err := m.Up(ctx)
+	return err
+}
+
+// SQLUserStore keeps users in a SQL database migrated with MigrateSQL.
+type SQLUserStore struct {
+	db *sql.DB
+}
+
+// NewSQLUserStore returns a store on db. The caller keeps ownership of db.
+func NewSQLUserStore(db *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: db}
+}
+
+const userColumns = "id, name, email, email_verified, password_hash"
+
+// queryer is what *sql.DB and *sql.Tx have in common.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func scanUser(row interface{ Scan(...any) error }) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.EmailVerified, &u.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (s *SQLUserStore) List(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLUserStore) Get(ctx context.Context, id int) (User, error) {
+	return getUser(ctx, s.db, id)
+}
+
+func getUser(ctx context.Context, q queryer, id int) (User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+func (s *SQLUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	if email == "" {
+		return User{}, ErrNotFound
+	}
+	return scanUser(s.db.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE email <> '' AND lower(email) = lower(?)", email))
+}
+
+func (s *SQLUserStore) Create(ctx context.Context, u User) (User, error) {
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO users (name, email, email_verified, password_hash) VALUES (?, ?, ?, ?)",
+		u.Name, u.Email, u.EmailVerified, u.PasswordHash)
+	if err != nil {
+		return User{}, userError(err)
+	}
+	id, err := res.LastInsertId()
+	u.ID = int(id)
+	return u, err
+}
+
+func (s *SQLUserStore) Update(ctx context.Context, u User) (User, error) {
+	return sqldb.InTxValue(ctx, s.db, func(tx *sql.Tx) (User, error) {
+		if _, err := getUser(ctx, tx, u.ID); err != nil {
+			return User{}, err
+		}
+		_, err := tx.ExecContext(ctx,
+			"UPDATE users SET name = ?, email = ?, email_verified = ?, password_hash = ? WHERE id = ?",
+			u.Name, u.Email, u.EmailVerified, u.PasswordHash, u.ID)
+		if err != nil {
+			return User{}, userError(err)
+		}
+		return u, nil
+	})
+}
+
+func (s *SQLUserStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return cmpErr(err, ErrNotFound)
+	}
+	return nil
+}
+
+// Put stores u under its own ID, replacing any user with that ID.
+func (s *SQLUserStore) Put(ctx context.Context, u User) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, email = excluded.email,
+			email_verified = excluded.email_verified, password_hash = excluded.password_hash`,
+		u.ID, u.Name, u.Email, u.EmailVerified, u.PasswordHash)
+	return userError(err)
+}
+
+// userError turns a violation of the unique email index into ErrEmailTaken.
+func userError(err error) error {
+	// SQLITE_CONSTRAINT_UNIQUE; the email index is the only unique one
+	// besides the primary key, which has its own code.
+	const constraintUnique = 2067
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) && coded.Code() == constraintUnique {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// cmpErr returns err if it is set, otherwise fallback.
+func cmpErr(err, fallback error) error {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLUserStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := MigrateSQL(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSQLUserStore(db)
+
+	alice, _ := s.Create(ctx, User{Name: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+	bob, err := s.Create(ctx, User{Name: "Bob", Email: "bob@example.com", EmailVerified: true})
+	if err != nil || bob.ID != 2 {
+		t.Fatalf("Create = %+v, %v; want ID 2", bob, err)
+	}
+	if _, err := s.Create(ctx, User{Name: "Other", Email: "ALICE@example.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Create with a used email: err = %v", err)
+	}
+	s.Create(ctx, User{Name: "No email"})
+	if _, err := s.Create(ctx, User{Name: "No email either"}); err != nil {
+		t.Errorf("second user without an email: %v", err)
+	}
+
+	got, err := s.GetByEmail(ctx, "Alice@Example.com")
+	if err != nil || got != alice {
+		t.Errorf("GetByEmail = %+v, %v; want %+v", got, err, alice)
+	}
+
+	bob.Email = "alice@example.com"
+	if _, err := s.Update(ctx, bob); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Update to a used email: err = %v", err)
+	}
+	if _, err := s.Update(ctx, User{ID: 99}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of a missing user: err = %v", err)
+	}
+
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: err = %v", err)
+	}
+	s.Put(ctx, User{ID: 10, Name: "Grace"})
+	if u, _ := s.Create(ctx, User{Name: "Next"}); u.ID != 11 {
+		t.Errorf("Create after Put(10) got ID %d; want 11", u.ID)
+	}
+	list, _ := s.List(ctx)
+	if len(list) != 5 || list[0].ID != 2 || !list[0].EmailVerified {
+		t.Errorf("List = %+v", list)
+	}
+}
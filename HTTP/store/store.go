@@ -0,0 +1,74 @@
+// Package store holds the domain types of the user service
+// and the interfaces used to persist them.
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// --- DOMAIN TYPES ---
+
+// User is a registered user of the service.
+type User struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	PasswordHash  string `json:"-"` // argon2id hash, never sent to clients
+}
+
+// Post is a message written by a user.
+// The JSON shape mirrors jsonplaceholder's /posts.
+type Post struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"userId"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	ExternalID int    `json:"externalId,omitempty"` // id in the upstream service, for imported posts
+}
+
+// --- ERRORS ---
+
+var (
+	// ErrNotFound is returned when a record does not exist.
+	ErrNotFound = errors.New("store: not found")
+	// ErrEmailTaken is returned when another user already has the email.
+	ErrEmailTaken = errors.New("store: email already in use")
+	// ErrNoExternalID is returned by UpsertExternal for posts without ExternalID.
+	ErrNoExternalID = errors.New("store: post has no external id")
+)
+
+// --- INTERFACES ---
+
+// UserStore persists users. Implementations must be safe for concurrent use.
+type UserStore interface {
+	// List returns every user ordered by ID.
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id int) (User, error)
+	// GetByEmail finds a user by email, ignoring case.
+	GetByEmail(ctx context.Context, email string) (User, error)
+	// Create assigns a new ID to u and stores it.
+	Create(ctx context.Context, u User) (User, error)
+	// Update replaces the stored user that has u.ID.
+	Update(ctx context.Context, u User) (User, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// PostStore persists posts. Implementations must be safe for concurrent use.
+type PostStore interface {
+	// ListByUser returns the posts of userID ordered by ID.
+	ListByUser(ctx context.Context, userID int) ([]Post, error)
+	Get(ctx context.Context, id int) (Post, error)
+	// Create assigns a new ID to p and stores it.
+	Create(ctx context.Context, p Post) (Post, error)
+	Update(ctx context.Context, p Post) (Post, error)
+	Delete(ctx context.Context, id int) error
+	// DeleteByUser removes every post of userID and returns how many were removed.
+	DeleteByUser(ctx context.Context, userID int) (int, error)
+	// CountByUser returns how many posts userID has.
+	CountByUser(ctx context.Context, userID int) (int, error)
+	// UpsertExternal creates or updates the post with p.ExternalID.
+	// It reports whether a new post was created.
+	UpsertExternal(ctx context.Context, p Post) (Post, bool, error)
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore(User{ID: 1, Name: "Alice", Email: "alice@example.com"})
+
+	bob, err := s.Create(ctx, User{Name: "Bob", Email: "bob@example.com"})
+	if err != nil || bob.ID != 2 {
+		t.Fatalf("Create = %+v, %v; want ID 2", bob, err)
+	}
+	if _, err := s.Create(ctx, User{Name: "Other", Email: "ALICE@example.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Create with a used email: err = %v; want %v", err, ErrEmailTaken)
+	}
+
+	got, err := s.GetByEmail(ctx, "Bob@Example.com")
+	if err != nil || got.ID != 2 {
+		t.Errorf("GetByEmail = %+v, %v; want Bob", got, err)
+	}
+
+	bob.Name = "Robert"
+	if _, err := s.Update(ctx, bob); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := s.Get(ctx, 2); got.Name != "Robert" {
+		t.Errorf("Get after Update: name = %q; want Robert", got.Name)
+	}
+
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v; want %v", err, ErrNotFound)
+	}
+	if err := s.Delete(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: err = %v; want %v", err, ErrNotFound)
+	}
+
+	list, _ := s.List(ctx)
+	if len(list) != 1 || list[0].ID != 2 {
+		t.Errorf("List = %+v; want only Bob", list)
+	}
+}
+
+func TestMemoryPostStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryPostStore()
+
+	s.Create(ctx, Post{UserID: 1, Title: "a"})
+	s.Create(ctx, Post{UserID: 2, Title: "b"})
+	s.Create(ctx, Post{UserID: 1, Title: "c"})
+
+	posts, _ := s.ListByUser(ctx, 1)
+	if len(posts) != 2 || posts[0].Title != "a" || posts[1].Title != "c" {
+		t.Errorf("ListByUser(1) = %+v", posts)
+	}
+	if n, _ := s.CountByUser(ctx, 1); n != 2 {
+		t.Errorf("CountByUser(1) = %d; want 2", n)
+	}
+	if n, _ := s.DeleteByUser(ctx, 1); n != 2 {
+		t.Errorf("DeleteByUser(1) = %d; want 2", n)
+	}
+	if n, _ := s.CountByUser(ctx, 2); n != 1 {
+		t.Errorf("posts of other users were deleted")
+	}
+}
+
+func TestUpsertExternal(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryPostStore()
+
+	first, created, err := s.UpsertExternal(ctx, Post{UserID: 1, Title: "old", ExternalID: 42})
+	if err != nil || !created {
+		t.Fatalf("first UpsertExternal = %v, %v; want created", created, err)
+	}
+	second, created, err := s.UpsertExternal(ctx, Post{UserID: 1, Title: "new", ExternalID: 42})
+	if err != nil || created || second.ID != first.ID {
+		t.Fatalf("second UpsertExternal = %+v, %v, %v; want update of ID %d", second, created, err, first.ID)
+	}
+	if got, _ := s.Get(ctx, first.ID); got.Title != "new" {
+		t.Errorf("title = %q; want new", got.Title)
+	}
+	if _, _, err := s.UpsertExternal(ctx, Post{Title: "x"}); !errors.Is(err, ErrNoExternalID) {
+		t.Errorf("UpsertExternal without ExternalID: err = %v", err)
+	}
+}
+
+func TestMemoryPutAndReset(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryUserStore(User{ID: 1, Name: "Alice"})
+
+	users.Put(ctx, User{ID: 7, Name: "Grace"})
+	if u, _ := users.Create(ctx, User{Name: "Next"}); u.ID != 8 {
+		t.Errorf("Create after Put(7) got ID %d; want 8", u.ID)
+	}
+	users.Reset(User{ID: 3, Name: "Only"})
+	if list, _ := users.List(ctx); len(list) != 1 || list[0].ID != 3 {
+		t.Errorf("List after Reset = %+v", list)
+	}
+
+	posts := NewMemoryPostStore()
+	posts.Put(ctx, Post{ID: 5, UserID: 3, Title: "x"})
+	if p, _ := posts.Create(ctx, Post{UserID: 3}); p.ID != 6 {
+		t.Errorf("Create after Put(5) got ID %d; want 6", p.ID)
+	}
+	posts.Reset()
+	if n, _ := posts.CountByUser(ctx, 3); n != 0 {
+		t.Errorf("CountByUser after Reset = %d", n)
+	}
+}
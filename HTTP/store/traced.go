@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"httpserver/tracing"
+)
+
+// --- TRACING WRAPPERS ---
+//
+// Each call becomes a child span of the span in ctx (if any), named like
+// "UserStore.Get". ErrNotFound is an answer, not a failure, so it does not
+// mark the span as an error.
+
+func startSpan(ctx context.Context, name string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, name, tracing.KindInternal)
+	span.SetAttribute("db.operation", name)
+	return ctx, span
+}
+
+func endSpan(span *tracing.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// TracedUserStore adds a span around every call to Next.
+type TracedUserStore struct {
+	Next UserStore
+}
+
+func (s TracedUserStore) List(ctx context.Context) (users []User, err error) {
+	ctx, span := startSpan(ctx, "UserStore.List")
+	defer func() { span.SetAttribute("db.rows", len(users)); endSpan(span, err) }()
+	return s.Next.List(ctx)
+}
+
+func (s TracedUserStore) Get(ctx context.Context, id int) (u User, err error) {
+	ctx, span := startSpan(ctx, "UserStore.Get")
+	span.SetAttribute("user.id", id)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Get(ctx, id)
+}
+
+func (s TracedUserStore) GetByEmail(ctx context.Context, email string) (u User, err error) {
+	ctx, span := startSpan(ctx, "UserStore.GetByEmail")
+	defer func() { endSpan(span, err) }()
+	return s.Next.GetByEmail(ctx, email)
+}
+
+func (s TracedUserStore) Create(ctx context.Context, u User) (created User, err error) {
+	ctx, span := startSpan(ctx, "UserStore.Create")
+	defer func() { span.SetAttribute("user.id", created.ID); endSpan(span, err) }()
+	return s.Next.Create(ctx, u)
+}
+
+func (s TracedUserStore) Update(ctx context.Context, u User) (updated User, err error) {
+	ctx, span := startSpan(ctx, "UserStore.Update")
+	span.SetAttribute("user.id", u.ID)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Update(ctx, u)
+}
+
+func (s TracedUserStore) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "UserStore.Delete")
+	span.SetAttribute("user.id", id)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Delete(ctx, id)
+}
+
+// TracedPostStore adds a span around every call to Next.
+type TracedPostStore struct {
+	Next PostStore
+}
+
+func (s TracedPostStore) ListByUser(ctx context.Context, userID int) (posts []Post, err error) {
+	ctx, span := startSpan(ctx, "PostStore.ListByUser")
+	span.SetAttribute("user.id", userID)
+	defer func() { span.SetAttribute("db.rows", len(posts)); endSpan(span, err) }()
+	return s.Next.ListByUser(ctx, userID)
+}
+
+func (s TracedPostStore) Get(ctx context.Context, id int) (p Post, err error) {
+	ctx, span := startSpan(ctx, "PostStore.Get")
+	span.SetAttribute("post.id", id)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Get(ctx, id)
+}
+
+func (s TracedPostStore) Create(ctx context.Context, p Post) (created Post, err error) {
+	ctx, span := startSpan(ctx, "PostStore.Create")
+	defer func() { span.SetAttribute("post.id", created.ID); endSpan(span, err) }()
+	return s.Next.Create(ctx, p)
+}
+
+func (s TracedPostStore) Update(ctx context.Context, p Post) (updated Post, err error) {
+	ctx, span := startSpan(ctx, "PostStore.Update")
+	span.SetAttribute("post.id", p.ID)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Update(ctx, p)
+}
+
+func (s TracedPostStore) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "PostStore.Delete")
+	span.SetAttribute("post.id", id)
+	defer func() { endSpan(span, err) }()
+	return s.Next.Delete(ctx, id)
+}
+
+func (s TracedPostStore) DeleteByUser(ctx context.Context, userID int) (n int, err error) {
+	ctx, span := startSpan(ctx, "PostStore.DeleteByUser")
+	span.SetAttribute("user.id", userID)
+	defer func() { span.SetAttribute("db.rows", n); endSpan(span, err) }()
+	return s.Next.DeleteByUser(ctx, userID)
+}
+
+func (s TracedPostStore) CountByUser(ctx context.Context, userID int) (n int, err error) {
+	ctx, span := startSpan(ctx, "PostStore.CountByUser")
+	span.SetAttribute("user.id", userID)
+	defer func() { endSpan(span, err) }()
+	return s.Next.CountByUser(ctx, userID)
+}
+
+func (s TracedPostStore) UpsertExternal(ctx context.Context, p Post) (out Post, created bool, err error) {
+	ctx, span := startSpan(ctx, "PostStore.UpsertExternal")
+	span.SetAttribute("post.external_id", p.ExternalID)
+	defer func() { span.SetAttribute("post.created", created); endSpan(span, err) }()
+	return s.Next.UpsertExternal(ctx, p)
+}
@@ -0,0 +1,47 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Middleware resolves the tenant of each request before calling Next.
+type Middleware struct {
+	Registry *Registry
+	Resolver *Resolver
+	Limiter  *Limiter
+}
+
+// Wrap returns a handler that only lets requests of active tenants through,
+// within their rate limit, with the tenant stored in the request context.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := m.Resolver.Resolve(r)
+		switch {
+		case errors.Is(err, ErrBadToken):
+			http.Error(w, "Invalid tenant token", http.StatusUnauthorized)
+			return
+		case err != nil:
+			http.Error(w, "Missing tenant", http.StatusBadRequest)
+			return
+		}
+
+		t, err := m.Registry.Get(id)
+		if err != nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+		if t.Status != StatusActive {
+			http.Error(w, "Tenant suspended", http.StatusForbidden)
+			return
+		}
+
+		if m.Limiter != nil && !m.Limiter.Allow(t.ID, t.Quota) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), t)))
+	})
+}
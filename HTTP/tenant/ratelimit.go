@@ -0,0 +1,69 @@
+package tenant
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// --- PER-TENANT RATE LIMIT (TOKEN BUCKET) ---
+//
+// Each tenant has a bucket that holds up to Burst tokens and refills at
+// RequestsPerSecond. Every request takes one token; an empty bucket means 429.
+
+// Limiter keeps one bucket per tenant. It is safe for concurrent use.
+type Limiter struct {
+	// Now returns the current time. Tests can replace it to move the clock.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns an empty Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{Now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes one token from the bucket of tenantID.
+// A quota without RequestsPerSecond is never limited.
+func (l *Limiter) Allow(tenantID string, q Quota) bool {
+	if q.RequestsPerSecond <= 0 {
+		return true
+	}
+	burst := float64(q.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(q.RequestsPerSecond))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	b, ok := l.buckets[tenantID]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[tenantID] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*q.RequestsPerSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Forget drops the bucket of tenantID, e.g. when the tenant is deleted.
+func (l *Limiter) Forget(tenantID string) {
+	l.mu.Lock()
+	delete(l.buckets, tenantID)
+	l.mu.Unlock()
+}
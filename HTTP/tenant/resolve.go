@@ -0,0 +1,171 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- RESOLVING THE TENANT OF A REQUEST ---
+//
+// With a token secret, the tenant comes only from a signed bearer token
+// (HS256 JWT) with a "tenant" claim; a request without one names no
+// tenant. Anyone can set a header or a query parameter, so they must not
+// pick the tenant once tokens are in use.
+//
+// Without a secret, the tenant is taken from the first source that has one:
+//  1. a header such as X-Tenant-ID,
+//  2. the subdomain of Host, e.g. "acme" in acme.example.com,
+//  3. a query parameter such as ?tenant=acme, only on the paths of
+//     emailed links.
+
+var (
+	// ErrNoTenant is returned when no source names a tenant.
+	ErrNoTenant = errors.New("tenant: request does not name a tenant")
+	// ErrBadToken is returned for malformed, expired or wrongly signed tokens.
+	ErrBadToken = errors.New("tenant: invalid token")
+)
+
+// Resolver finds the tenant ID of a request. Empty fields disable a source.
+type Resolver struct {
+	TokenSecret []byte   // HMAC key of bearer tokens
+	Header      string   // e.g. "X-Tenant-ID"
+	BaseDomain  string   // e.g. "example.com"
+	QueryParam  string   // e.g. "tenant"
+	QueryPaths  []string // paths where QueryParam is read, e.g. "/verify-email"
+
+	// Now returns the current time for token expiry checks.
+	Now func() time.Time
+}
+
+// Resolve returns the tenant ID named by r.
+func (res *Resolver) Resolve(r *http.Request) (string, error) {
+	if len(res.TokenSecret) > 0 {
+		token, ok := bearerToken(r)
+		if !ok {
+			return "", ErrNoTenant
+		}
+		if strings.Count(token, ".") != 2 {
+			return "", ErrBadToken
+		}
+		return res.tenantFromToken(token)
+	}
+
+	if res.Header != "" {
+		if id := strings.TrimSpace(r.Header.Get(res.Header)); id != "" {
+			return strings.ToLower(id), nil
+		}
+	}
+
+	if res.BaseDomain != "" {
+		if id, ok := subdomain(r.Host, res.BaseDomain); ok {
+			return id, nil
+		}
+	}
+
+	if res.QueryParam != "" && slices.Contains(res.QueryPaths, r.URL.Path) {
+		if id := strings.TrimSpace(r.URL.Query().Get(res.QueryParam)); id != "" {
+			return strings.ToLower(id), nil
+		}
+	}
+
+	return "", ErrNoTenant
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:]), true
+	}
+	return "", false
+}
+
+// subdomain returns "acme" for host "acme.example.com:8080" and base "example.com".
+func subdomain(host, base string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	suffix := "." + strings.ToLower(strings.Trim(base, "."))
+	if !strings.HasSuffix(host, suffix) {
+		return "", false
+	}
+	label := strings.TrimSuffix(host, suffix)
+	if label == "" || strings.Contains(label, ".") {
+		return "", false
+	}
+	return label, true
+}
+
+// --- TOKENS (HS256 JWT) ---
+
+type tokenClaims struct {
+	Tenant string `json:"tenant"`
+	Exp    int64  `json:"exp,omitempty"`
+}
+
+func (res *Resolver) tenantFromToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	b64 := base64.RawURLEncoding
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return "", ErrBadToken
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(res.TokenSecret, parts[0]+"."+parts[1])) {
+		return "", ErrBadToken
+	}
+
+	var claims tokenClaims
+	rawClaims, err := b64.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(rawClaims, &claims) != nil {
+		return "", ErrBadToken
+	}
+	id := strings.ToLower(strings.TrimSpace(claims.Tenant))
+	if id == "" {
+		return "", ErrBadToken
+	}
+
+	now := time.Now
+	if res.Now != nil {
+		now = res.Now
+	}
+	if claims.Exp != 0 && now().Unix() >= claims.Exp {
+		return "", ErrBadToken
+	}
+	return id, nil
+}
+
+// SignToken creates an HS256 token with a "tenant" claim, valid for ttl
+// (0 means no expiry). Useful for tests and for issuing tokens to tools.
+func SignToken(secret []byte, tenantID string, ttl time.Duration) string {
+	b64 := base64.RawURLEncoding
+	header := b64.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	claims := tokenClaims{Tenant: tenantID}
+	if ttl > 0 {
+		claims.Exp = time.Now().Add(ttl).Unix()
+	}
+	raw, _ := json.Marshal(claims)
+	payload := b64.EncodeToString(raw)
+
+	return header + "." + payload + "." + b64.EncodeToString(sign(secret, header+"."+payload))
+}
+
+func sign(secret []byte, data string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,152 @@
+// Package tenant lets one server instance host several customers.
+//
+// A tenant is resolved for every request (see Resolver), checked against
+// the Registry (it must exist and be active), rate limited by its quota
+// and then stored in the request context for the handlers.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --- TENANT ---
+
+// Status of a tenant.
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+)
+
+// Quota limits what a tenant may use. Zero values mean "no limit".
+type Quota struct {
+	MaxUsers          int     `json:"maxUsers"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// Tenant is one customer of the service.
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	Quota     Quota     `json:"quota"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var (
+	ErrNotFound  = errors.New("tenant: not found")
+	ErrExists    = errors.New("tenant: already exists")
+	ErrInvalidID = errors.New("tenant: id must be 1-63 lowercase letters, digits or dashes")
+)
+
+// IDs are valid DNS labels, so they also work as subdomains.
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidID reports whether id can be used as a tenant ID.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// --- REGISTRY ---
+
+// Registry keeps the known tenants in memory. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{tenants: make(map[string]Tenant)}
+}
+
+// Create adds an active tenant.
+func (r *Registry) Create(t Tenant) (Tenant, error) {
+	if !ValidID(t.ID) {
+		return Tenant{}, ErrInvalidID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[t.ID]; ok {
+		return Tenant{}, ErrExists
+	}
+	t.Status = StatusActive
+	t.CreatedAt = time.Now().UTC()
+	r.tenants[t.ID] = t
+	return t, nil
+}
+
+// Get returns the tenant with id.
+func (r *Registry) Get(id string) (Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// List returns every tenant ordered by ID.
+func (r *Registry) List() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SetStatus suspends or re-activates a tenant.
+func (r *Registry) SetStatus(id string, status Status) (Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrNotFound
+	}
+	t.Status = status
+	r.tenants[id] = t
+	return t, nil
+}
+
+// Delete removes a tenant.
+func (r *Registry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.tenants, id)
+	return nil
+}
+
+// --- CONTEXT ---
+
+type ctxKey struct{}
+
+// WithTenant returns a copy of ctx that carries t.
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the tenant stored by the middleware.
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(ctxKey{}).(Tenant)
+	return t, ok
+}
@@ -0,0 +1,222 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	secret := []byte("s3cret")
+	open := &Resolver{Header: "X-Tenant-ID", BaseDomain: "example.com", QueryParam: "tenant", QueryPaths: []string{"/verify-email"}}
+	signed := *open
+	signed.TokenSecret = secret
+
+	tests := []struct {
+		name   string
+		signed bool
+		host   string
+		target string
+		header map[string]string
+		want   string
+		err    error
+	}{
+		{name: "header", header: map[string]string{"X-Tenant-ID": "Acme"}, want: "acme"},
+		{name: "subdomain", host: "acme.example.com:8080", want: "acme"},
+		{name: "nested subdomain", host: "a.b.example.com", err: ErrNoTenant},
+		{name: "query on an emailed link", target: "/verify-email?tenant=acme", want: "acme"},
+		{name: "query elsewhere", target: "/users?tenant=acme", err: ErrNoTenant},
+		{name: "nothing", host: "example.com", err: ErrNoTenant},
+		{name: "token without a secret is ignored", header: map[string]string{"Authorization": "Bearer " + SignToken(secret, "globex", 0), "X-Tenant-ID": "acme"}, want: "acme"},
+		{name: "token", signed: true, header: map[string]string{"Authorization": "Bearer " + SignToken(secret, "globex", time.Hour)}, want: "globex"},
+		{name: "token tenant is lowercased", signed: true, header: map[string]string{"Authorization": "Bearer " + SignToken(secret, "GlobEx", 0)}, want: "globex"},
+		{name: "blank token tenant", signed: true, header: map[string]string{"Authorization": "Bearer " + SignToken(secret, " ", 0)}, err: ErrBadToken},
+		{
+			name:   "token wins over header",
+			signed: true,
+			header: map[string]string{
+				"Authorization": "Bearer " + SignToken(secret, "globex", 0),
+				"X-Tenant-ID":   "acme",
+			},
+			want: "globex",
+		},
+		{
+			name:   "bad signature does not fall back",
+			signed: true,
+			header: map[string]string{
+				"Authorization": "Bearer " + SignToken([]byte("other"), "globex", 0),
+				"X-Tenant-ID":   "acme",
+			},
+			err: ErrBadToken,
+		},
+		{name: "opaque bearer is refused", signed: true, header: map[string]string{"Authorization": "Bearer abc", "X-Tenant-ID": "acme"}, err: ErrBadToken},
+		{name: "header needs a token", signed: true, header: map[string]string{"X-Tenant-ID": "acme"}, err: ErrNoTenant},
+		{name: "subdomain needs a token", signed: true, host: "acme.example.com", err: ErrNoTenant},
+		{name: "query needs a token", signed: true, target: "/verify-email?tenant=acme", err: ErrNoTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/"
+			}
+			r := httptest.NewRequest("GET", target, nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			res := open
+			if tt.signed {
+				res = &signed
+			}
+			got, err := res.Resolve(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v; want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("tenant = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignTokenExpiry(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now()
+	res := &Resolver{TokenSecret: secret, Now: func() time.Time { return now }}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+SignToken(secret, "acme", time.Minute))
+	if _, err := res.Resolve(r); err != nil {
+		t.Fatalf("fresh token: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := res.Resolve(r); !errors.Is(err, ErrBadToken) {
+		t.Errorf("expired token: err = %v; want ErrBadToken", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+
+	if _, err := reg.Create(Tenant{ID: "Not Valid"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("invalid id: err = %v", err)
+	}
+	created, err := reg.Create(Tenant{ID: "acme", Name: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != StatusActive || created.CreatedAt.IsZero() {
+		t.Errorf("new tenant = %+v; want active with CreatedAt", created)
+	}
+	if _, err := reg.Create(Tenant{ID: "acme"}); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate: err = %v", err)
+	}
+	reg.Create(Tenant{ID: "beta"})
+
+	if got := reg.List(); len(got) != 2 || got[0].ID != "acme" || got[1].ID != "beta" {
+		t.Errorf("List = %+v", got)
+	}
+
+	if got, _ := reg.SetStatus("acme", StatusSuspended); got.Status != StatusSuspended {
+		t.Errorf("status = %q; want suspended", got.Status)
+	}
+	if _, err := reg.SetStatus("nope", StatusActive); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetStatus missing: err = %v", err)
+	}
+
+	if err := reg.Delete("acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Get("acme"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: err = %v", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewLimiter()
+	l.Now = func() time.Time { return now }
+	q := Quota{RequestsPerSecond: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("acme", q) {
+			t.Fatalf("request %d within burst was limited", i+1)
+		}
+	}
+	if l.Allow("acme", q) {
+		t.Fatal("request over burst was allowed")
+	}
+	if !l.Allow("beta", q) {
+		t.Fatal("buckets are not per tenant")
+	}
+
+	now = now.Add(500 * time.Millisecond) // refills one token
+	if !l.Allow("acme", q) {
+		t.Error("bucket did not refill")
+	}
+	if l.Allow("acme", q) {
+		t.Error("bucket refilled too much")
+	}
+
+	if !l.Allow("acme", Quota{}) {
+		t.Error("quota without a rate must not be limited")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	reg.Create(Tenant{ID: "acme"})
+	reg.Create(Tenant{ID: "slow", Quota: Quota{RequestsPerSecond: 1, Burst: 1}})
+	reg.Create(Tenant{ID: "gone"})
+	reg.SetStatus("gone", StatusSuspended)
+
+	m := &Middleware{
+		Registry: reg,
+		Resolver: &Resolver{TokenSecret: []byte("k")},
+		Limiter:  NewLimiter(),
+	}
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, _ := FromContext(r.Context())
+		w.Write([]byte(t.ID))
+	}))
+
+	bearer := func(id string) string { return "Bearer " + SignToken([]byte("k"), id, 0) }
+	do := func(header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := do("Authorization", bearer("acme")); rec.Code != http.StatusOK || rec.Body.String() != "acme" {
+		t.Errorf("acme: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := do("", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("no tenant: status %d; want 400", rec.Code)
+	}
+	if rec := do("Authorization", bearer("nope")); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown tenant: status %d; want 404", rec.Code)
+	}
+	if rec := do("Authorization", bearer("gone")); rec.Code != http.StatusForbidden {
+		t.Errorf("suspended tenant: status %d; want 403", rec.Code)
+	}
+	if rec := do("Authorization", "Bearer a.b.c"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: status %d; want 401", rec.Code)
+	}
+
+	do("Authorization", bearer("slow"))
+	if rec := do("Authorization", bearer("slow")); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("rate limited: status %d; want 429 with Retry-After", rec.Code)
+	}
+}
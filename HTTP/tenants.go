@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"httpserver/auth"
+	"httpserver/search"
+	"httpserver/service"
+	"httpserver/store"
+	"httpserver/tenant"
+)
+
+// --- PER-TENANT STATE ---
+//
+// Every tenant gets its own stores, sessions, tokens and lockout.
+// Handlers only ever see the state of the tenant of the request, so IDs are
+// per tenant and reading another tenant's data is impossible by construction.
+// With multi-tenancy off, every request uses defaultState.
+
+type tenantState struct {
+	tenantID string // empty for the default (single-tenant) state
+
+	users    store.UserStore // updates index on writes
+	rawUsers store.UserStore // users without the index (see setStores)
+	posts    store.PostStore
+	index    *search.Index
+	service  *service.UserService
+	sessions *auth.SessionStore
+	tokens   *auth.TokenStore
+	lockout  *auth.Lockout
+}
+
+func newTenantState(tenantID string, quota tenant.Quota, seed ...store.User) *tenantState {
+	st := &tenantState{
+		tenantID: tenantID,
+		index:    search.New(),
+		// Sessions expire after 30 minutes without use, or 12 hours after login.
+		sessions: auth.NewSessionStore(30*time.Minute, 12*time.Hour),
+		tokens:   auth.NewTokenStore(),
+		// 5 wrong passwords within 15 minutes lock the account for 15 minutes.
+		lockout: auth.NewLockout(5, 15*time.Minute, 15*time.Minute),
+	}
+
+	st.service = &service.UserService{
+		DeletePolicy: deletePolicy,
+		MaxUsers:     quota.MaxUsers,
+		// New users and new emails must be confirmed before the next login.
+		OnCreated:      st.sendVerificationEmailLogged,
+		OnEmailChanged: st.sendVerificationEmailLogged,
+		// A deleted user is logged out everywhere.
+		OnDeleted: func(ctx context.Context, id int) { st.sessions.DeleteUser(id) },
+	}
+	st.setStores(store.TracedUserStore{Next: store.NewMemoryUserStore(seed...)}, store.TracedPostStore{Next: store.NewMemoryPostStore()})
+	return st
+}
+
+// setStores swaps the stores of st and of its service (see configureReplication).
+// Writes to users then go through the search index, which is rebuilt from
+// the new users. Pass st.rawUsers, not st.users, to a store that wraps
+// the current one, or the index is updated twice.
+func (st *tenantState) setStores(users store.UserStore, posts store.PostStore) {
+	st.rawUsers = users
+	st.users, st.posts = search.IndexedUserStore{Next: users, Index: st.index}, posts
+	st.service.Users, st.service.Posts = st.users, posts
+	if err := st.index.Rebuild(context.Background(), users); err != nil {
+		log.Printf("search index: %v", err)
+	}
+}
+
+// cleanup forgets what has expired in st: sessions, tokens and failed
+// logins that no longer count.
+func (st *tenantState) cleanup() {
+	st.sessions.Cleanup()
+	st.tokens.Cleanup()
+	st.lockout.Cleanup()
+}
+
+// cleanupInterval is how often startCleanup goes over the states.
+const cleanupInterval = time.Minute
+
+// startCleanup cleans up the default state and every tenant's state
+// once per cleanupInterval, for as long as the server runs.
+func startCleanup() {
+	go func() {
+		for range time.Tick(cleanupInterval) {
+			cleanupStates()
+		}
+	}()
+}
+
+func cleanupStates() {
+	defaultState.cleanup()
+	tenantStatesMu.RLock()
+	defer tenantStatesMu.RUnlock()
+	for _, st := range tenantStates {
+		st.cleanup()
+	}
+}
+
+var (
+	multiTenant bool
+	adminToken  string // bearer token of the /admin API
+
+	tenants          = tenant.NewRegistry()
+	tenantLimiter    = tenant.NewLimiter()
+	tenantStatesMu   sync.RWMutex
+	tenantStates     = make(map[string]*tenantState)
+	tenantMiddleware = &tenant.Middleware{Registry: tenants, Limiter: tenantLimiter, Resolver: &tenant.Resolver{}}
+)
+
+type stateKey struct{}
+
+// stateFrom returns the state of the request's tenant.
+func stateFrom(ctx context.Context) *tenantState {
+	if st, ok := ctx.Value(stateKey{}).(*tenantState); ok {
+		return st
+	}
+	return defaultState
+}
+
+// withTenantState runs after tenant.Middleware and attaches the tenant's state.
+func withTenantState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, _ := tenant.FromContext(r.Context())
+
+		tenantStatesMu.RLock()
+		st, ok := tenantStates[t.ID]
+		tenantStatesMu.RUnlock()
+		if !ok {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), stateKey{}, st)))
+	})
+}
+
+// configureTenants reads the multi-tenancy settings from the environment:
+//
+//	MULTI_TENANT         "true" to enable tenants (off by default)
+//	TENANT_HEADER        header that names the tenant (default X-Tenant-ID)
+//	TENANT_BASE_DOMAIN   e.g. "example.com" to resolve acme.example.com
+//	TENANT_TOKEN_SECRET  HMAC key of bearer tokens with a "tenant" claim;
+//	                     when set, only the token names the tenant
+//	ADMIN_TOKEN          bearer token required by the /admin routes
+func configureTenants() {
+	adminToken = os.Getenv("ADMIN_TOKEN")
+
+	multiTenant = strings.EqualFold(os.Getenv("MULTI_TENANT"), "true")
+	if !multiTenant {
+		return
+	}
+
+	tenantMiddleware.Resolver = &tenant.Resolver{
+		Header:      envOr("TENANT_HEADER", "X-Tenant-ID"),
+		BaseDomain:  os.Getenv("TENANT_BASE_DOMAIN"),
+		TokenSecret: []byte(os.Getenv("TENANT_TOKEN_SECRET")),
+		QueryParam:  "tenant",
+		QueryPaths:  []string{"/verify-email", "/password/reset"}, // emailed links
+	}
+	if adminToken == "" {
+		log.Println("ADMIN_TOKEN not set, the tenant admin API is disabled")
+	}
+}
+
+// --- ADMIN API ---
+
+// requireAdmin checks the admin bearer token in constant time.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// --- HANDLER: LIST / CREATE TENANTS (/admin/tenants) ---
+
+func adminTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, tenants.List())
+
+	case http.MethodPost:
+		var req struct {
+			ID    string       `json:"id"`
+			Name  string       `json:"name"`
+			Quota tenant.Quota `json:"quota"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		t, err := tenants.Create(tenant.Tenant{ID: req.ID, Name: req.Name, Quota: req.Quota})
+		if err != nil {
+			tenantError(w, r, err)
+			return
+		}
+
+		tenantStatesMu.Lock()
+		tenantStates[t.ID] = newTenantState(t.ID, t.Quota)
+		tenantStatesMu.Unlock()
+
+		writeJSON(w, http.StatusCreated, t)
+
+	default:
+		http.Error(w, "Only GET or POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: SINGLE TENANT (/admin/tenants/{id}) ---
+
+func adminTenantHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		t, err := tenants.Get(id)
+		if err != nil {
+			tenantError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+
+	case http.MethodDelete:
+		// Deleting a tenant drops all of its data.
+		if err := tenants.Delete(id); err != nil {
+			tenantError(w, r, err)
+			return
+		}
+		tenantStatesMu.Lock()
+		delete(tenantStates, id)
+		tenantStatesMu.Unlock()
+		tenantLimiter.Forget(id)
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET or DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- HANDLER: SUSPEND / RESUME (/admin/tenants/{id}/suspend, /resume) ---
+
+func adminTenantStatusHandler(status tenant.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		t, err := tenants.SetStatus(r.PathValue("id"), status)
+		if err != nil {
+			tenantError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+func tenantError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, tenant.ErrExists):
+		http.Error(w, "Tenant already exists", http.StatusConflict)
+	case errors.Is(err, tenant.ErrInvalidID):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
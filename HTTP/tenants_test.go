@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"httpserver/store"
+	"httpserver/tenant"
+)
+
+// enableTenants turns multi-tenancy on with a fresh registry for one test.
+func enableTenants(t *testing.T) {
+	oldMulti, oldAdmin, oldReg, oldLimiter, oldStates, oldMiddleware :=
+		multiTenant, adminToken, tenants, tenantLimiter, tenantStates, tenantMiddleware
+	t.Cleanup(func() {
+		multiTenant, adminToken, tenants, tenantLimiter, tenantStates, tenantMiddleware =
+			oldMulti, oldAdmin, oldReg, oldLimiter, oldStates, oldMiddleware
+	})
+
+	multiTenant, adminToken = true, "admin-secret"
+	tenants, tenantLimiter = tenant.NewRegistry(), tenant.NewLimiter()
+	tenantStates = make(map[string]*tenantState)
+	tenantMiddleware = &tenant.Middleware{
+		Registry: tenants,
+		Limiter:  tenantLimiter,
+		Resolver: &tenant.Resolver{Header: "X-Tenant-ID"},
+	}
+}
+
+// serveAs sends a request through the router with the given tenant header
+// ("" for none) or, for /admin routes, the admin token.
+func serveAs(tenantID, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if tenantID != "" {
+		r.Header.Set("X-Tenant-ID", tenantID)
+	}
+	if strings.HasPrefix(target, "/admin/") {
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+	}
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, r)
+	return rec
+}
+
+func TestTenantAdminAPI(t *testing.T) {
+	enableTenants(t)
+
+	r := httptest.NewRequest("GET", "/admin/tenants", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong admin token: status %d; want 401", rec.Code)
+	}
+
+	if rec := serveAs("", "POST", "/admin/tenants", `{"id":"acme","name":"Acme"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create tenant: status %d %s", rec.Code, rec.Body)
+	}
+	if rec := serveAs("", "POST", "/admin/tenants", `{"id":"acme"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate tenant: status %d; want 409", rec.Code)
+	}
+	if rec := serveAs("", "POST", "/admin/tenants", `{"id":"Bad ID"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status %d; want 400", rec.Code)
+	}
+
+	if rec := serveAs("", "POST", "/admin/tenants/acme/suspend", ""); rec.Code != http.StatusOK {
+		t.Fatalf("suspend: status %d", rec.Code)
+	}
+	if rec := serveAs("acme", "GET", "/users", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("suspended tenant: status %d; want 403", rec.Code)
+	}
+	serveAs("", "POST", "/admin/tenants/acme/resume", "")
+	if rec := serveAs("acme", "GET", "/users", ""); rec.Code != http.StatusOK {
+		t.Errorf("resumed tenant: status %d; want 200", rec.Code)
+	}
+
+	if rec := serveAs("", "DELETE", "/admin/tenants/acme", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", rec.Code)
+	}
+	if rec := serveAs("acme", "GET", "/users", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted tenant: status %d; want 404", rec.Code)
+	}
+}
+
+func TestTenantIsolationAndQuota(t *testing.T) {
+	enableTenants(t)
+	serveAs("", "POST", "/admin/tenants", `{"id":"acme","quota":{"maxUsers":1}}`)
+	serveAs("", "POST", "/admin/tenants", `{"id":"globex"}`)
+
+	if rec := serveAs("", "GET", "/users", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("no tenant: status %d; want 400", rec.Code)
+	}
+
+	body := `{"name":"Ann","email":"ann@example.com"}`
+	if rec := serveAs("acme", "POST", "/users/create", body); rec.Code != http.StatusCreated {
+		t.Fatalf("create in acme: status %d %s", rec.Code, rec.Body)
+	}
+	// Same email and same ID space in another tenant.
+	rec := serveAs("globex", "POST", "/users/create", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create in globex: status %d %s", rec.Code, rec.Body)
+	}
+	var u store.User
+	json.NewDecoder(rec.Body).Decode(&u)
+	if u.ID != 1 {
+		t.Errorf("first user of globex has ID %d; want 1", u.ID)
+	}
+
+	serveAs("globex", "DELETE", "/users/1", "")
+	if rec := serveAs("acme", "GET", "/users/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("acme user after deleting globex user: status %d; want 200", rec.Code)
+	}
+
+	if rec := serveAs("acme", "POST", "/users/create", `{"name":"Bob","email":"bob@example.com"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("over quota: status %d; want 403", rec.Code)
+	}
+}
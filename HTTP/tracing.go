@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"httpserver/tracing"
+)
+
+// --- TRACING ---
+
+var (
+	// tracer is nil when tracing is off; the middleware and the store
+	// spans then do nothing.
+	tracer *tracing.Tracer
+
+	// tracedClient sends the traceparent header on outbound calls.
+	tracedClient = &http.Client{Transport: &tracing.Transport{}}
+)
+
+// configureTracing reads the tracing settings from the environment:
+//
+//	TRACE_FILE          JSON-lines file the spans are appended to; tracing is off without it
+//	TRACE_SAMPLE_RATIO  share of new traces that are recorded, 0-1 (default 1)
+//	TRACE_SERVICE       service name written on every span (default "httpserver")
+func configureTracing() {
+	path := os.Getenv("TRACE_FILE")
+	if path == "" {
+		return
+	}
+
+	exp, err := tracing.NewFileExporter(path)
+	if err != nil {
+		log.Fatalf("opening TRACE_FILE: %v", err)
+	}
+
+	ratio := 1.0
+	if v := os.Getenv("TRACE_SAMPLE_RATIO"); v != "" {
+		ratio, err = strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			log.Fatalf("invalid TRACE_SAMPLE_RATIO %q", v)
+		}
+	}
+
+	tracer = tracing.NewTracer(envOr("TRACE_SERVICE", "httpserver"), exp)
+	tracer.Sampler = tracing.RatioSampler(ratio)
+}
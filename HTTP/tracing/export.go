@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Exporter receives every finished, sampled span.
+// Implementations must be safe for concurrent use.
+type Exporter interface {
+	Export(SpanData)
+}
+
+// --- JSON LINES ---
+
+// JSONLinesExporter writes one JSON object per span.
+type JSONLinesExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewJSONLinesExporter writes spans to w.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter appends spans to the file at path.
+func NewFileExporter(path string) (*JSONLinesExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewJSONLinesExporter(f)
+	e.c = f
+	return e, nil
+}
+
+func (e *JSONLinesExporter) Export(d SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// A failing trace file must not break requests, so errors are only logged.
+	if err := e.enc.Encode(d); err != nil {
+		log.Printf("tracing: exporting span: %v", err)
+	}
+}
+
+// Close closes the file of a file exporter.
+func (e *JSONLinesExporter) Close() error {
+	if e.c == nil {
+		return nil
+	}
+	return e.c.Close()
+}
+
+// --- IN MEMORY ---
+
+// MemoryExporter keeps spans in memory, for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewMemoryExporter returns an empty MemoryExporter.
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(d SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, d)
+	e.mu.Unlock()
+}
+
+// Spans returns the exported spans in the order they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset forgets every span.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// --- SERVER SIDE ---
+
+// Middleware starts a server span for each request, continuing the trace
+// of an incoming traceparent header. A malformed header starts a new trace.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+			ctx = ContextWithRemote(ctx, sc)
+		}
+
+		ctx, span := t.Start(ctx, r.Method+" "+r.URL.Path, KindServer)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+		if r.URL.RawQuery != "" {
+			span.SetAttribute("url.query", redactQuery(r.URL.RawQuery))
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", sw.status)
+		if sw.status >= 500 {
+			span.SetStatus(StatusError, http.StatusText(sw.status))
+		}
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes on to the real writer, for handlers and middleware that
+// check for http.Flusher themselves.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the real writer (e.g. to flush).
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// --- CLIENT SIDE ---
+
+// Transport is an http.RoundTripper that wraps each call in a client span
+// and sends the traceparent header upstream. Calls made with a context
+// that has no span are passed through untouched.
+type Transport struct {
+	Base http.RoundTripper // nil means http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(req.Context(), "HTTP "+req.Method+" "+req.URL.Host, KindClient)
+	if span == nil {
+		return base.RoundTrip(req)
+	}
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("url.full", redactURL(req.URL))
+
+	// A RoundTripper must not change the caller's request.
+	out := req.Clone(ctx)
+	out.Header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.SetStatus(StatusError, resp.Status)
+	}
+	return resp, nil
+}
+
+// --- REDACTION ---
+
+// Query strings carry secrets such as email verification and password
+// reset tokens, and spans end up in files. Only the parameter names are
+// recorded.
+
+const redacted = "REDACTED"
+
+// redactQuery replaces every value in a raw query string, keeping the
+// names in order: "token=abc&page=2" becomes "token=REDACTED&page=REDACTED".
+func redactQuery(rawQuery string) string {
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		if name, _, ok := strings.Cut(part, "="); ok {
+			parts[i] = name + "=" + redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// redactURL is u as a string with its query values and password redacted.
+func redactURL(u *url.URL) string {
+	c := *u
+	c.RawQuery = redactQuery(c.RawQuery)
+	c.ForceQuery = false
+	return c.Redacted()
+}
@@ -0,0 +1,403 @@
+// Package tracing is a small distributed tracer.
+//
+// Trace context travels between services in the W3C traceparent header
+// (https://www.w3.org/TR/trace-context/). A Tracer starts root and server
+// spans; code further down only calls Start(ctx, name), which creates a
+// child of the span in ctx, or does nothing when there is none. Finished
+// spans go to an Exporter.
+//
+// A nil *Tracer and a nil *Span are valid and do nothing, so tracing can be
+// switched off without touching the instrumented code.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- IDS AND TRACEPARENT ---
+
+// TraceID identifies a whole trace.
+type TraceID [16]byte
+
+// SpanID identifies one span.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		putUint64(id[:8], rand.Uint64())
+		putUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		putUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := range 8 {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// ErrBadTraceparent is returned for headers that do not follow the spec.
+var ErrBadTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceparentHeader is the name of the W3C trace context header.
+const TraceparentHeader = "traceparent"
+
+// ParseTraceparent reads a header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+// Newer versions are accepted as long as they start with the version 00 fields.
+func ParseTraceparent(h string) (SpanContext, error) {
+	h = strings.TrimSpace(h)
+	if len(h) < 55 || h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return SpanContext{}, ErrBadTraceparent
+	}
+	version, err := decodeHex(h[0:2], 1)
+	if err != nil || version[0] == 0xff {
+		return SpanContext{}, ErrBadTraceparent
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if version[0] == 0 && len(h) != 55 || version[0] != 0 && len(h) > 55 && h[55] != '-' {
+		return SpanContext{}, ErrBadTraceparent
+	}
+
+	var sc SpanContext
+	traceID, err1 := decodeHex(h[3:35], 16)
+	spanID, err2 := decodeHex(h[36:52], 8)
+	flags, err3 := decodeHex(h[53:55], 1)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return SpanContext{}, ErrBadTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, ErrBadTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex accepts lowercase hex only, as the spec requires.
+func decodeHex(s string, n int) ([]byte, error) {
+	if strings.ToLower(s) != s {
+		return nil, ErrBadTraceparent
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n {
+		return nil, ErrBadTraceparent
+	}
+	return b, nil
+}
+
+// Traceparent formats sc as a version 00 header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// --- SAMPLING ---
+
+// Sampler decides at the root of a trace whether it is recorded.
+// Children follow the decision of their parent, including remote parents.
+type Sampler func(TraceID) bool
+
+// AlwaysSample records every trace.
+func AlwaysSample() Sampler { return func(TraceID) bool { return true } }
+
+// NeverSample records no trace that starts here.
+func NeverSample() Sampler { return func(TraceID) bool { return false } }
+
+// RatioSampler records about ratio (0-1) of the traces. The decision only
+// depends on the trace ID, so every service with the same ratio agrees.
+func RatioSampler(ratio float64) Sampler {
+	switch {
+	case ratio >= 1:
+		return AlwaysSample()
+	case ratio <= 0:
+		return NeverSample()
+	}
+	limit := uint64(ratio * math.MaxUint64)
+	return func(id TraceID) bool {
+		var v uint64
+		for _, b := range id[8:] {
+			v = v<<8 | uint64(b)
+		}
+		return v < limit
+	}
+}
+
+// --- TRACER ---
+
+// Tracer starts spans and sends the finished ones to Exporter.
+type Tracer struct {
+	Service  string
+	Exporter Exporter
+	Sampler  Sampler // nil means AlwaysSample
+
+	// Now returns the current time. Tests can replace it.
+	Now func() time.Time
+}
+
+// NewTracer returns a Tracer that records every trace.
+func NewTracer(service string, exp Exporter) *Tracer {
+	return &Tracer{Service: service, Exporter: exp, Sampler: AlwaysSample(), Now: time.Now}
+}
+
+func (t *Tracer) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+// Kind tells what a span represents.
+type Kind string
+
+const (
+	KindInternal Kind = "internal"
+	KindServer   Kind = "server"
+	KindClient   Kind = "client"
+)
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithRemote returns a copy of ctx whose next span continues the
+// trace of a remote parent, e.g. one parsed from a traceparent header.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanFromContext returns the current span or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start begins a span. Its parent is the span in ctx, or else a remote
+// parent set with ContextWithRemote; without either it starts a new trace.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: t.now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.sc.TraceID, s.parent, s.sc.Sampled = parent.sc.TraceID, parent.sc.SpanID, parent.sc.Sampled
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok && remote.IsValid() {
+		s.sc.TraceID, s.parent, s.sc.Sampled = remote.TraceID, remote.SpanID, remote.Sampled
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.Sampler == nil || t.Sampler(s.sc.TraceID)
+	}
+	s.sc.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Start begins a child of the span in ctx. Without a span in ctx it
+// returns ctx and a nil span, so libraries can call it unconditionally.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind)
+}
+
+// --- SPAN ---
+
+// Event is something that happened at one point during a span.
+type Event struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Status of a finished span.
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Span is one timed operation. Its methods are safe for concurrent use.
+// Unsampled spans keep their IDs for propagation but record nothing.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   Kind
+	start  time.Time
+
+	mu         sync.Mutex
+	attributes map[string]any
+	events     []Event
+	status     string
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext returns the IDs to propagate. It is the zero value for a nil span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+func (s *Span) recording() bool { return s != nil && s.sc.Sampled }
+
+// SetAttribute records a key/value pair.
+func (s *Span) SetAttribute(key string, value any) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// AddEvent records an event. attrs are key/value pairs.
+func (s *Span) AddEvent(name string, attrs ...any) {
+	if !s.recording() {
+		return
+	}
+	e := Event{Name: name, Time: s.tracer.now()}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if e.Attributes == nil {
+			e.Attributes = make(map[string]any)
+		}
+		e.Attributes[fmt.Sprint(attrs[i])] = attrs[i+1]
+	}
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	s.mu.Unlock()
+}
+
+// SetStatus sets the status (StatusOK or StatusError) and a description.
+func (s *Span) SetStatus(status, msg string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.status, s.statusMsg = status, msg
+	s.mu.Unlock()
+}
+
+// RecordError adds an "exception" event and marks the span as failed.
+// A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.recording() {
+		return
+	}
+	s.AddEvent("exception", "message", err.Error())
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and exports it. Later calls do nothing.
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := s.snapshot(s.tracer.now())
+	s.mu.Unlock()
+
+	if s.tracer.Exporter != nil {
+		s.tracer.Exporter.Export(data)
+	}
+}
+
+// snapshot copies the span. The caller must hold the lock.
+func (s *Span) snapshot(end time.Time) SpanData {
+	d := SpanData{
+		TraceID:   s.sc.TraceID.String(),
+		SpanID:    s.sc.SpanID.String(),
+		Name:      s.name,
+		Kind:      s.kind,
+		Service:   s.tracer.Service,
+		Start:     s.start,
+		End:       end,
+		Duration:  end.Sub(s.start).Seconds() * 1000,
+		Status:    s.status,
+		StatusMsg: s.statusMsg,
+		Events:    append([]Event(nil), s.events...),
+	}
+	if s.parent.IsValid() {
+		d.ParentID = s.parent.String()
+	}
+	if d.Status == "" {
+		d.Status = StatusUnset
+	}
+	if len(s.attributes) > 0 {
+		d.Attributes = make(map[string]any, len(s.attributes))
+		for k, v := range s.attributes {
+			d.Attributes[k] = v
+		}
+	}
+	return d
+}
+
+// SpanData is a finished span, as given to exporters.
+type SpanData struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	ParentID   string         `json:"parentSpanId,omitempty"`
+	Name       string         `json:"name"`
+	Kind       Kind           `json:"kind"`
+	Service    string         `json:"service,omitempty"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Duration   float64        `json:"durationMs"`
+	Status     string         `json:"status"`
+	StatusMsg  string         `json:"statusMessage,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Events     []Event        `json:"events,omitempty"`
+}
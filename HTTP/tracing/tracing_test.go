@@ -0,0 +1,211 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("parsed %+v", sc)
+	}
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("Traceparent() = %q; want %q", got, valid)
+	}
+
+	for _, h := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",          // no flags
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",       // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",       // zero span id
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",       // forbidden version
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",       // uppercase
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", // v00 has 4 fields
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",       // not hex
+	} {
+		if _, err := ParseTraceparent(h); !errors.Is(err, ErrBadTraceparent) {
+			t.Errorf("ParseTraceparent(%q) err = %v; want ErrBadTraceparent", h, err)
+		}
+	}
+
+	// Future versions may add fields after the flags.
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-abcd"); err != nil {
+		t.Errorf("future version: %v", err)
+	}
+}
+
+func TestSpansAndExport(t *testing.T) {
+	exp := NewMemoryExporter()
+	tr := NewTracer("test", exp)
+
+	ctx, root := tr.Start(context.Background(), "root", KindServer)
+	root.SetAttribute("user.id", 7)
+	root.AddEvent("cache miss", "key", "users")
+
+	childCtx, child := Start(ctx, "child", KindInternal)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	if SpanFromContext(childCtx) != child {
+		t.Error("child span is not in its context")
+	}
+	root.End()
+	root.End() // a second End is ignored
+
+	spans := exp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans; want 2", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || r.ParentID != "" {
+		t.Errorf("bad parenting: root %+v child %+v", r, c)
+	}
+	if c.Status != StatusError || c.StatusMsg != "boom" || len(c.Events) != 1 || c.Events[0].Name != "exception" {
+		t.Errorf("child = %+v", c)
+	}
+	if r.Attributes["user.id"] != 7 || r.Events[0].Attributes["key"] != "users" || r.Service != "test" {
+		t.Errorf("root = %+v", r)
+	}
+}
+
+func TestStartWithoutParentIsNoop(t *testing.T) {
+	ctx, span := Start(context.Background(), "orphan", KindInternal)
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("Start without a parent created a span")
+	}
+	// Every method must be safe on a nil span.
+	span.SetAttribute("k", "v")
+	span.AddEvent("e")
+	span.RecordError(errors.New("x"))
+	span.End()
+
+	var tr *Tracer
+	if _, s := tr.Start(context.Background(), "x", KindServer); s != nil {
+		t.Error("nil tracer created a span")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	exp := NewMemoryExporter()
+	tr := NewTracer("test", exp)
+	tr.Sampler = NeverSample()
+
+	ctx, root := tr.Start(context.Background(), "root", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+	child.End()
+	root.End()
+	if n := len(exp.Spans()); n != 0 {
+		t.Errorf("unsampled trace exported %d spans", n)
+	}
+	if !root.SpanContext().IsValid() || child.SpanContext().TraceID != root.SpanContext().TraceID {
+		t.Error("unsampled spans must still carry IDs for propagation")
+	}
+
+	// A sampled remote parent wins over the local sampler.
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, s := tr.Start(ContextWithRemote(context.Background(), remote), "continued", KindServer)
+	s.End()
+	if spans := exp.Spans(); len(spans) != 1 || spans[0].ParentID != "00f067aa0ba902b7" {
+		t.Errorf("remote parent not followed: %+v", spans)
+	}
+
+	half := RatioSampler(0.5)
+	sampled := 0
+	for range 10000 {
+		if half(newTraceID()) {
+			sampled++
+		}
+	}
+	if sampled < 4500 || sampled > 5500 {
+		t.Errorf("RatioSampler(0.5) sampled %d of 10000", sampled)
+	}
+}
+
+func TestHTTPPropagation(t *testing.T) {
+	exp := NewMemoryExporter()
+	tr := NewTracer("test", exp)
+
+	var upstreamParent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamParent = r.Header.Get(TraceparentHeader)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	srv := httptest.NewServer(tr.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", upstream.URL+"/reset?key=secret", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/users?token=secret&x=1&flag", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := exp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans; want client and server", len(spans))
+	}
+	clientSpan, server := spans[0], spans[1]
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentID != "00f067aa0ba902b7" {
+		t.Errorf("server span did not continue the trace: %+v", server)
+	}
+	if server.Name != "GET /users" || server.Attributes["http.status_code"] != http.StatusTeapot {
+		t.Errorf("server span = %+v", server)
+	}
+	if clientSpan.Kind != KindClient || clientSpan.ParentID != server.SpanID {
+		t.Errorf("client span = %+v", clientSpan)
+	}
+	if q := server.Attributes["url.query"]; q != "token=REDACTED&x=REDACTED&flag" {
+		t.Errorf("url.query = %v; want the values redacted", q)
+	}
+	if u := clientSpan.Attributes["url.full"]; u != upstream.URL+"/reset?key=REDACTED" {
+		t.Errorf("url.full = %v; want the values redacted", u)
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + clientSpan.SpanID + "-01"
+	if upstreamParent != want {
+		t.Errorf("upstream traceparent = %q; want %q", upstreamParent, want)
+	}
+}
+
+func TestJSONLinesExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tr := NewTracer("svc", NewJSONLinesExporter(&buf))
+	_, a := tr.Start(context.Background(), "a", KindInternal)
+	a.End()
+	_, b := tr.Start(context.Background(), "b", KindInternal)
+	b.End()
+
+	dec := json.NewDecoder(&buf)
+	var names []string
+	for dec.More() {
+		var d SpanData
+		if err := dec.Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, d.Name)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("lines = %v", names)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"httpserver/service"
+	"httpserver/tracing"
+	"httpserver/vcr"
+)
+
+func TestTracingHandlerStoreAndOutbound(t *testing.T) {
+	resetPostsState(t, service.DeleteRestrict)
+	exp := tracing.NewMemoryExporter()
+	oldTracer, oldClient := tracer, externalClient
+	t.Cleanup(func() { tracer, externalClient = oldTracer, oldClient })
+	tracer = tracing.NewTracer("test", exp)
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("traceparent", parent)
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	spans := exp.Spans()
+	if len(spans) != 2 || spans[0].Name != "UserStore.List" || spans[1].Name != "GET /users" {
+		t.Fatalf("spans = %+v", spans)
+	}
+	if spans[0].ParentID != spans[1].SpanID || spans[1].ParentID != "00f067aa0ba902b7" {
+		t.Errorf("store span is not a child of the handler span of the incoming trace")
+	}
+
+	// The outbound call gets a client span, replayed from a cassette.
+	exp.Reset()
+	externalClient = &http.Client{Transport: &tracing.Transport{Base: vcr.Start(t, "testdata/cassettes/external_post.json")}}
+	if rec := serve("GET", "/external", ""); rec.Code != http.StatusOK {
+		t.Fatalf("external: status %d", rec.Code)
+	}
+	spans = exp.Spans()
+	if len(spans) != 2 || spans[0].Kind != tracing.KindClient || spans[0].ParentID != spans[1].SpanID {
+		t.Errorf("spans = %+v", spans)
+	}
+}
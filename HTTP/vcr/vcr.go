@@ -0,0 +1,362 @@
+// Package vcr records HTTP calls to cassette files and replays them in tests.
+//
+// In record mode the Recorder sends requests to the real Transport and
+// remembers every request/response pair; Save writes them to a JSON
+// cassette. In replay mode the cassette answers instead of the network,
+// so tests of upstream clients are fast and do not need the internet.
+//
+//	rec := vcr.Start(t, "testdata/cassettes/posts.json")
+//	client := rec.Client()
+//
+// Run the tests with VCR_MODE=record to refresh the cassettes.
+package vcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// Mode selects where responses come from.
+type Mode int
+
+const (
+	// ModeReplay answers from the cassette.
+	ModeReplay Mode = iota
+	// ModeRecord calls the real Transport and records the calls.
+	ModeRecord
+)
+
+// ErrNotRecorded is returned in replay mode for a request the cassette
+// does not contain.
+var ErrNotRecorded = errors.New("vcr: request not recorded")
+
+// Redacted replaces the value of redacted headers in cassettes.
+const Redacted = "REDACTED"
+
+// DefaultRedact lists the headers that never reach a cassette.
+var DefaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// --- CASSETTE FORMAT ---
+
+// Cassette is the content of one cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of an *http.Request.
+type Request struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     Body        `json:"body"`
+	BodyHash string      `json:"bodyHash"` // hex sha256 of the body
+}
+
+// Response is the recorded part of an *http.Response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body"`
+}
+
+// Body is stored as plain text when it is valid UTF-8 (so cassettes are
+// easy to read and edit) and as base64 otherwise.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	if err != nil {
+		return err
+	}
+	*b = raw
+	return nil
+}
+
+// --- MATCHING ---
+
+// Matcher chooses which parts of a request must equal the recorded one.
+type Matcher struct {
+	Method bool
+	URL    bool
+	Body   bool // compares the body hash
+}
+
+// DefaultMatcher compares method, URL and body.
+var DefaultMatcher = Matcher{Method: true, URL: true, Body: true}
+
+func (m Matcher) match(req Request, rec Request) bool {
+	return (!m.Method || req.Method == rec.Method) &&
+		(!m.URL || req.URL == rec.URL) &&
+		(!m.Body || req.BodyHash == rec.BodyHash)
+}
+
+// --- RECORDER ---
+
+// Recorder is an http.RoundTripper that records or replays calls.
+// It is safe for concurrent use.
+type Recorder struct {
+	Path    string
+	Mode    Mode
+	Matcher Matcher
+	// Redact lists headers (case-insensitive) whose values are replaced
+	// by Redacted before saving. Redaction only affects the cassette:
+	// the real request and response are left untouched.
+	Redact []string
+	// Strict makes unrecorded requests fail the test (see Start). Without
+	// it they fall through to Transport, which is handy while writing tests.
+	Strict bool
+	// Transport makes the real calls (nil means http.DefaultTransport).
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	missed   []string // unrecorded requests, for Strict
+}
+
+// New returns a Recorder for the cassette at path. In replay mode the
+// cassette is loaded and must exist.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		Path:    path,
+		Mode:    mode,
+		Matcher: DefaultMatcher,
+		Redact:  DefaultRedact,
+		Strict:  true,
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("vcr: loading cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("vcr: parsing cassette %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// ModeFromEnv returns ModeRecord when VCR_MODE is "record" and ModeReplay otherwise.
+func ModeFromEnv() Mode {
+	if strings.EqualFold(os.Getenv("VCR_MODE"), "record") {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Start returns a Recorder for a test, with the mode taken from VCR_MODE.
+// When the test ends the cassette is saved (record mode) and, in strict
+// mode, every request that was not in the cassette fails the test.
+func Start(t testing.TB, path string) *Recorder {
+	t.Helper()
+	r, err := New(path, ModeFromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if r.Mode == ModeRecord {
+			if err := r.Save(); err != nil {
+				t.Errorf("vcr: saving cassette: %v", err)
+			}
+		}
+		if r.Strict {
+			for _, m := range r.Missed() {
+				t.Errorf("vcr: request not in cassette %s: %s", r.Path, m)
+			}
+		}
+	})
+	return r
+}
+
+// Client returns an *http.Client that uses the Recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays one request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recReq, err := r.captureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Mode == ModeReplay {
+		if in, ok := r.find(recReq); ok {
+			return in.Response.toHTTP(req), nil
+		}
+		if r.Strict {
+			r.mu.Lock()
+			r.missed = append(r.missed, recReq.Method+" "+recReq.URL)
+			r.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, recReq.Method, recReq.URL)
+		}
+		return r.transport().RoundTrip(req)
+	}
+
+	resp, err := r.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	in := Interaction{
+		Request: recReq,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     r.redact(resp.Header),
+			Body:       body,
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save writes the recorded interactions to Path.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, append(data, '\n'), 0o644)
+}
+
+// Missed returns the requests that were not found in the cassette.
+func (r *Recorder) Missed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.missed...)
+}
+
+func (r *Recorder) transport() http.RoundTripper {
+	if r.Transport != nil {
+		return r.Transport
+	}
+	return http.DefaultTransport
+}
+
+// captureRequest copies what is recorded from req and leaves req.Body
+// readable for the real Transport.
+func (r *Recorder) captureRequest(req *http.Request) (Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return Request{}, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	return Request{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Header:   r.redact(req.Header),
+		Body:     body,
+		BodyHash: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// find returns the first unused matching interaction. When every match was
+// used already, the last one is served again, so repeated calls keep working.
+func (r *Recorder) find(req Request) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A recorder switched to replay has interactions New never saw.
+	if n := len(r.cassette.Interactions); len(r.used) < n {
+		r.used = append(r.used, make([]bool, n-len(r.used))...)
+	}
+
+	last := -1
+	for i, in := range r.cassette.Interactions {
+		if !r.Matcher.match(req, in.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return in, true
+		}
+		last = i
+	}
+	if last >= 0 {
+		return r.cassette.Interactions[last], true
+	}
+	return Interaction{}, false
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.Redact {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}
+
+func (resp Response) toHTTP(req *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
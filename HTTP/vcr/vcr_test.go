@@ -0,0 +1,153 @@
+package vcr_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"httpserver/vcr"
+)
+
+// upstream echoes the method and body and counts the calls it gets.
+func upstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(body)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func get(t *testing.T, c *http.Client, method, url, body string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer top-secret")
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return string(b), nil
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	srv, calls := upstream(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := vcr.New(path, vcr.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := get(t, rec.Client(), "POST", srv.URL+"/posts", `{"a":1}`); got != `POST /posts {"a":1}` {
+		t.Fatalf("recorded response = %q", got)
+	}
+	get(t, rec.Client(), "POST", srv.URL+"/posts", `{"a":2}`)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "top-secret") || strings.Contains(string(data), "session=secret") {
+		t.Errorf("cassette contains secrets:\n%s", data)
+	}
+
+	srv.Close() // replay must not need the network
+	rep, err := vcr.New(path, vcr.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Matched by body hash, in any order.
+	if got, _ := get(t, rep.Client(), "POST", srv.URL+"/posts", `{"a":2}`); got != `POST /posts {"a":2}` {
+		t.Errorf("replay a=2 = %q", got)
+	}
+	if got, _ := get(t, rep.Client(), "POST", srv.URL+"/posts", `{"a":1}`); got != `POST /posts {"a":1}` {
+		t.Errorf("replay a=1 = %q", got)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("upstream got %d calls; want 2 (only while recording)", calls.Load())
+	}
+}
+
+func TestReplayStrict(t *testing.T) {
+	srv, calls := upstream(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, _ := vcr.New(path, vcr.ModeRecord)
+	get(t, rec.Client(), "GET", srv.URL+"/posts/1", "")
+	rec.Save()
+
+	rep, _ := vcr.New(path, vcr.ModeReplay)
+	if _, err := get(t, rep.Client(), "GET", srv.URL+"/posts/2", ""); !errors.Is(err, vcr.ErrNotRecorded) {
+		t.Errorf("unrecorded request: err = %v; want ErrNotRecorded", err)
+	}
+	if m := rep.Missed(); len(m) != 1 || m[0] != "GET "+srv.URL+"/posts/2" {
+		t.Errorf("Missed = %v", m)
+	}
+
+	// Without Strict the request goes to the real upstream.
+	rep.Strict = false
+	if got, err := get(t, rep.Client(), "GET", srv.URL+"/posts/2", ""); err != nil || got != "GET /posts/2 " {
+		t.Errorf("passthrough = %q, %v", got, err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("upstream got %d calls; want 2", calls.Load())
+	}
+}
+
+func TestReplayAfterRecordingInTheSameRecorder(t *testing.T) {
+	srv, calls := upstream(t)
+	rec, _ := vcr.New(filepath.Join(t.TempDir(), "cassette.json"), vcr.ModeRecord)
+	get(t, rec.Client(), "GET", srv.URL+"/posts/1", "")
+
+	rec.Mode = vcr.ModeReplay
+	if got, err := get(t, rec.Client(), "GET", srv.URL+"/posts/1", ""); err != nil || got != "GET /posts/1 " {
+		t.Errorf("replay = %q, %v", got, err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("upstream got %d calls; want 1", calls.Load())
+	}
+}
+
+func TestMatcherIgnoresBody(t *testing.T) {
+	srv, _ := upstream(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, _ := vcr.New(path, vcr.ModeRecord)
+	get(t, rec.Client(), "POST", srv.URL+"/login", `{"nonce":1}`)
+	rec.Save()
+
+	rep, _ := vcr.New(path, vcr.ModeReplay)
+	rep.Matcher = vcr.Matcher{Method: true, URL: true}
+	for i := 0; i < 2; i++ { // the last match is reused
+		if got, err := get(t, rep.Client(), "POST", srv.URL+"/login", `{"nonce":2}`); err != nil || got != `POST /login {"nonce":1}` {
+			t.Errorf("call %d = %q, %v", i+1, got, err)
+		}
+	}
+}
+
+func TestBinaryBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0x00, 0xfe})
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, _ := vcr.New(path, vcr.ModeRecord)
+	get(t, rec.Client(), "GET", srv.URL, "")
+	rec.Save()
+
+	rep, _ := vcr.New(path, vcr.ModeReplay)
+	if got, _ := get(t, rep.Client(), "GET", srv.URL, ""); got != "\xff\x00\xfe" {
+		t.Errorf("binary body = %q", got)
+	}
+}
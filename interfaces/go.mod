@@ -0,0 +1,8 @@
+module interfaces
+
+go 1.24.3
+
+require jsonexamples v0.0.0
+
+// The JSON packages live in the json lesson next door.
+replace jsonexamples => ../json
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"jsonexamples/poly"
+)
 
 // --- INTERFACE DEFINITION ---
 // An interface defines a *behavior*, not a data structure.
@@ -14,7 +20,7 @@ type Shape interface {
 // If a type satisfies all the methods, it implements the interface automatically.
 
 type Circle struct {
-	Radius float64
+	Radius float64 `json:"radius"`
 }
 
 func (c Circle) Area() float64 {
@@ -26,8 +32,8 @@ func (c Circle) Perimeter() float64 {
 }
 
 type Rectangle struct {
-	Width  float64
-	Height float64
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
 }
 
 func (r Rectangle) Area() float64 {
@@ -47,7 +53,7 @@ type DetailedShape interface {
 
 // --- STRUCT IMPLEMENTING COMPOSED INTERFACE ---
 type Square struct {
-	Side float64
+	Side float64 `json:"side"`
 }
 
 func (s Square) Area() float64 {
@@ -91,6 +97,25 @@ func printAnything(v interface{}) {
 	}
 }
 
+// --- INTERFACES IN JSON ---
+// json.Marshal writes a Circle inside a Shape as {"radius":5}, and then
+// json.Unmarshal cannot tell which type to make. Registering a name for
+// each implementation adds it to the JSON as "type", so it can choose.
+
+func init() {
+	poly.Register[Shape]("circle", Circle{})
+	poly.Register[Shape]("rectangle", Rectangle{})
+	poly.Register[Shape]("square", Square{})
+}
+
+// Drawing holds Shapes in fields. poly.Slice and poly.Value are a []Shape
+// and a Shape that encoding/json knows how to decode.
+type Drawing struct {
+	Title     string            `json:"title"`
+	Shapes    poly.Slice[Shape] `json:"shapes"`
+	Highlight poly.Value[Shape] `json:"highlight"`
+}
+
 func main() {
 	fmt.Println("=== Interface Basics ===")
 
@@ -108,4 +133,32 @@ func main() {
 	printAnything(42)
 	printAnything("hello")
 	printAnything(3.14)
+
+	// --- Interfaces in JSON ---
+	fmt.Println("=== Shapes as JSON ===")
+
+	drawing := Drawing{
+		Title:     "Shapes",
+		Shapes:    poly.Slice[Shape]{c, r, sq},
+		Highlight: poly.Value[Shape]{V: sq},
+	}
+	data, err := json.Marshal(drawing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(data))
+
+	var decoded Drawing
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range decoded.Shapes {
+		fmt.Printf("%T with area %.2f\n", s, s.Area())
+	}
+	if detailed, ok := decoded.Highlight.V.(DetailedShape); ok {
+		fmt.Println("Highlight:", detailed.Description())
+	}
+
+	_, err = poly.Unmarshal[Shape]([]byte(`{"type": "hexagon", "side": 1}`))
+	fmt.Println("Unknown shape:", err)
 }
@@ -0,0 +1,145 @@
+// Command jdiff compares two JSON documents by structure rather than by
+// line, so reordered members and 1.0 versus 1 make no difference.
+//
+//	go run ./cmd/jdiff -key id old.json new.json
+//	go run ./cmd/jdiff -format patch old.json new.json > changes.json
+//	go run ./cmd/jdiff -format side -width 100 old.json new.json
+//	go run ./cmd/jdiff -canon doc.json
+//
+// With -key, elements of arrays of objects are matched by that member, so
+// a reordered list shows up as moves; -keys sets it for particular
+// arrays, like -keys /orders/*/items=sku. "-" reads a document from
+// stdin. -canon prints a document in canonical form (RFC 8785) instead.
+// As with diff, the exit status is 1 when the documents differ and 2 on
+// errors.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"jsonexamples/jsondiff"
+)
+
+func main() {
+	log.SetFlags(0)
+	var opts jsondiff.Options
+	flag.StringVar(&opts.Key, "key", "", "match array elements by this member")
+	flag.Func("keys", "match the array at `PATTERN=member` by member (repeatable)", func(s string) error {
+		pattern, key, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want PATTERN=member, got %q", s)
+		}
+		if opts.Keys == nil {
+			opts.Keys = make(map[string]string)
+		}
+		opts.Keys[pattern] = key
+		return nil
+	})
+	format := flag.String("format", "text", "output `format`: text, patch or side")
+	width := flag.Int("width", 120, "line width for -format side")
+	canon := flag.Bool("canon", false, "print FILE in canonical form")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jdiff [-key member] [-format text|patch|side] A B")
+		fmt.Fprintln(os.Stderr, "       jdiff -canon FILE")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *canon {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		data, err := read(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		out, err := jsondiff.Canonicalize(data)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w", flag.Arg(0), err))
+		}
+		os.Stdout.Write(append(out, '\n'))
+		return
+	}
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	a, err := load(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	b, err := load(flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	changes := jsondiff.Diff(a, b, opts)
+
+	switch *format {
+	case "text":
+		err = jsondiff.Text(os.Stdout, changes)
+	case "patch":
+		err = writePatch(os.Stdout, changes)
+	case "side":
+		err = jsondiff.SideBySide(os.Stdout, a, b, *width)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+func writePatch(w io.Writer, changes []jsondiff.Change) error {
+	p, err := jsondiff.Patch(changes)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func read(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// load reads and decodes one document, keeping numbers exact.
+func load(name string) (any, error) {
+	data, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	// Canonicalize rejects what the diff cannot compare reliably:
+	// duplicate members and trailing data.
+	if _, err := jsondiff.Canonicalize(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(2)
+}
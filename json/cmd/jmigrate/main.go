@@ -0,0 +1,57 @@
+// Command jmigrate upgrades user data files to the current document
+// version, in place.
+//
+//	go run ./cmd/jmigrate users.json more-users.ndjson
+//	go run ./cmd/jmigrate -n users.json
+//
+// A file may hold a JSON array of users, NDJSON or one user. Each file is
+// rewritten atomically: a crash or a bad document leaves it as it was.
+// -n only reports what would change. The exit status is 2 if any file
+// could not be upgraded.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"jsonexamples/records"
+)
+
+func main() {
+	log.SetFlags(0)
+	dryRun := flag.Bool("n", false, "report what would be upgraded, without writing")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jmigrate [-n] FILE...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m := records.Users
+	failed := false
+	for _, path := range flag.Args() {
+		upgrade := m.UpgradeFile
+		if *dryRun {
+			upgrade = m.CheckFile
+		}
+		stats, err := upgrade(path)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		verb := "upgraded"
+		if *dryRun {
+			verb = "to upgrade"
+		}
+		fmt.Printf("%s: %d of %d documents %s to v%d\n", path, stats.Upgraded, stats.Documents, verb, m.Current())
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,115 @@
+// Command jpath runs a JSONPath query (RFC 9535) over JSON read from
+// stdin, or from files, and prints every match, like a small jq.
+//
+//	curl -s https://jsonplaceholder.typicode.com/users | go run ./cmd/jpath '$[?@.address.city == "Gwenborough"].email'
+//	go run ./cmd/jpath -p '$..price' store.json
+//
+// The input may hold several JSON values one after the other, as NDJSON
+// does; the query runs on each. Matches are printed indented, or one per
+// line with -c; -p puts the location of each match in front of it. As
+// with grep, the exit status is 1 when nothing matched and 2 on errors.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"jsonexamples/jsonpath"
+)
+
+func main() {
+	log.SetFlags(0)
+	compact := flag.Bool("c", false, "print each match on one line")
+	paths := flag.Bool("p", false, "print the location of each match")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jpath [-c] [-p] QUERY [FILE...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	query, err := jsonpath.Parse(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+
+	out := &printer{w: os.Stdout, compact: *compact, paths: *paths}
+	if flag.NArg() == 1 {
+		err = out.run(query, os.Stdin, "stdin")
+	}
+	for _, name := range flag.Args()[1:] {
+		f, err2 := os.Open(name)
+		if err2 != nil {
+			fatal(err2)
+		}
+		err = out.run(query, f, name)
+		f.Close()
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if out.matches == 0 {
+		os.Exit(1)
+	}
+}
+
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(2)
+}
+
+type printer struct {
+	w              io.Writer
+	compact, paths bool
+	matches        int
+}
+
+// run queries every JSON value in r.
+func (p *printer) run(query *jsonpath.Path, r io.Reader, name string) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber() // print numbers exactly as they came
+	for {
+		var doc any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: at byte %d: %w", name, dec.InputOffset(), err)
+		}
+		for _, n := range query.SelectNodes(doc) {
+			if err := p.print(n); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *printer) print(n jsonpath.Node) error {
+	p.matches++
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if !p.compact {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(n.Value); err != nil {
+		return err
+	}
+	if p.paths {
+		fmt.Fprint(p.w, n.Location, "\t")
+	}
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
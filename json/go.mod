@@ -0,0 +1,3 @@
+module jsonexamples
+
+go 1.24.3
@@ -0,0 +1,247 @@
+// Package jsondiff compares JSON documents. It writes the canonical form
+// of a document (RFC 8785), finds the structural differences between two
+// documents, matching array elements by a key such as "id", and renders
+// them as text, as a JSON Patch or side by side.
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// --- CANONICAL JSON (RFC 8785) ---
+//
+// The JSON Canonicalization Scheme gives every document one spelling:
+// object members sorted by the UTF-16 code units of their names, numbers
+// written as ECMAScript writes doubles (4.50 is 4.5, 1E30 is 1e+30), the
+// shortest string escapes and no whitespace. Two documents are equal
+// exactly when their canonical forms are the same bytes, which also makes
+// the form good for hashing and signing.
+
+// Canonicalize returns the canonical form of the JSON document data.
+// Duplicate member names and numbers beyond float64 are errors, since the
+// scheme requires I-JSON (RFC 7493).
+func Canonicalize(data []byte) ([]byte, error) {
+	v, err := decodeStrict(data)
+	if err != nil {
+		return nil, err
+	}
+	return Canonical(v)
+}
+
+// Canonical returns the canonical form of v, a value decoded from JSON
+// into an any (with or without json.Decoder.UseNumber).
+func Canonical(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeCanonical(&b, v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCanonical(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		writeString(b, v)
+	case float64:
+		s, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("jsondiff: number %s does not fit a float64", v)
+		}
+		s, err := formatNumber(f)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	case []any:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeCanonical(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[string]any:
+		b.WriteByte('{')
+		for i, k := range sortedKeys(v) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeString(b, k)
+			b.WriteByte(':')
+			if err := writeCanonical(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("jsondiff: %T is not a decoded JSON value", v)
+	}
+	return nil
+}
+
+// sortedKeys orders member names by UTF-16 code units, as RFC 8785 asks.
+// It differs from Go's byte order only above U+FFFF.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return slices.Compare(utf16.Encode([]rune(a)), utf16.Encode([]rune(b)))
+	})
+	return keys
+}
+
+// writeString escapes only what JSON requires: the quote, the backslash
+// and control characters.
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// formatNumber writes f the way ECMAScript's Number.prototype.toString
+// does: the shortest digits that read back as f, in plain notation from
+// 1e-6 up to 1e21 and in exponent notation outside.
+func formatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", errors.New("jsondiff: NaN and Infinity are not JSON")
+	}
+	if f == 0 {
+		return "0", nil // also for -0
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// 'e' with precision -1 gives the shortest digits: d.dddde±x.
+	mant, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mant, ".", "", 1)
+	e, _ := strconv.Atoi(exp)
+	k, n := len(digits), e+1 // n is where the decimal point goes
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	exponent := "e" + expSign + strconv.Itoa(abs(n-1))
+	if k == 1 {
+		return sign + digits + exponent, nil
+	}
+	return sign + digits[:1] + "." + digits[1:] + exponent, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// --- STRICT DECODING ---
+
+// decodeStrict decodes one JSON value, refusing duplicate member names,
+// which encoding/json would silently resolve to the last one.
+func decodeStrict(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := readValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jsondiff: data after the JSON value")
+	}
+	return v, nil
+}
+
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token() // ]
+		return arr, err
+	case json.Delim('{'):
+		obj := map[string]any{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			if _, dup := obj[key]; dup {
+				return nil, fmt.Errorf("jsondiff: duplicate member %q", key)
+			}
+			if obj[key], err = readValue(dec); err != nil {
+				return nil, err
+			}
+		}
+		_, err := dec.Token() // }
+		return obj, err
+	}
+	return tok, nil
+}
@@ -0,0 +1,274 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- STRUCTURAL DIFF ---
+
+// Kind is the kind of a Change. The values are JSON Patch op names.
+type Kind string
+
+const (
+	Added    Kind = "add"
+	Removed  Kind = "remove"
+	Replaced Kind = "replace"
+	Moved    Kind = "move" // an element matched by key changed position
+)
+
+// Change is one difference between two documents.
+type Change struct {
+	Kind Kind
+	// Path is a JSON Pointer valid at the moment the change applies:
+	// applying the changes in order turns the first document into the
+	// second, which is how Patch renders them.
+	Path string
+	From string // the pointer a Moved element comes from
+	// Label is the location for people: array elements matched by key are
+	// named by it, as in $.users[id=3].email.
+	Label string
+	// Old and New are the values before and after; for Moved, the
+	// element's positions.
+	Old, New any
+}
+
+// Options configures Diff.
+type Options struct {
+	// Key names the member that identifies objects in arrays, like "id".
+	// An array is matched by key when every element on both sides is an
+	// object with a distinct scalar value for it; other arrays are
+	// compared position by position.
+	Key string
+	// Keys sets the key of particular arrays, by their location with every
+	// array index written as *, like "/orders/*/items": "sku". It wins
+	// over Key; an empty value turns key matching off for that array.
+	Keys map[string]string
+}
+
+// DiffJSON decodes two JSON documents and diffs them.
+func DiffJSON(a, b []byte, opts Options) ([]Change, error) {
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(va, vb, opts), nil
+}
+
+// Diff returns the changes that turn a into b, both values decoded from
+// JSON into an any. Numbers are compared by value, so 1.0 equals 1.
+func Diff(a, b any, opts Options) []Change {
+	d := differ{opts: opts}
+	d.diff(a, b, location{pointer: "", label: "$", pattern: ""})
+	return d.changes
+}
+
+type differ struct {
+	opts    Options
+	changes []Change
+}
+
+// location is where the differ is, in the three spellings it needs.
+type location struct {
+	pointer string // JSON Pointer in the document being patched
+	label   string // readable path
+	pattern string // pointer with array indexes as *, for Options.Keys
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (l location) member(name string) location {
+	label := l.label + "." + name
+	if !identifier.MatchString(name) {
+		var b bytes.Buffer
+		writeString(&b, name)
+		label = l.label + "[" + b.String() + "]"
+	}
+	token := escapePointer(name)
+	return location{l.pointer + "/" + token, label, l.pattern + "/" + token}
+}
+
+func (l location) index(i int, label string) location {
+	return location{l.pointer + "/" + strconv.Itoa(i), l.label + "[" + label + "]", l.pattern + "/*"}
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func (d *differ) add(c Change) { d.changes = append(d.changes, c) }
+
+func (d *differ) diff(a, b any, at location) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			d.diffObjects(a, b, at)
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			d.diffArrays(a, b, at)
+			return
+		}
+	}
+	if !Equal(a, b) {
+		d.add(Change{Kind: Replaced, Path: at.pointer, Label: at.label, Old: a, New: b})
+	}
+}
+
+func (d *differ) diffObjects(a, b map[string]any, at location) {
+	for _, k := range sortedKeys(a) {
+		if _, ok := b[k]; !ok {
+			loc := at.member(k)
+			d.add(Change{Kind: Removed, Path: loc.pointer, Label: loc.label, Old: a[k]})
+		}
+	}
+	for _, k := range sortedKeys(b) {
+		loc := at.member(k)
+		if va, ok := a[k]; ok {
+			d.diff(va, b[k], loc)
+		} else {
+			d.add(Change{Kind: Added, Path: loc.pointer, Label: loc.label, New: b[k]})
+		}
+	}
+}
+
+func (d *differ) diffArrays(a, b []any, at location) {
+	key, ok := d.opts.Keys[at.pattern]
+	if !ok {
+		key = d.opts.Key
+	}
+	if key != "" {
+		ka, okA := keysOf(a, key)
+		kb, okB := keysOf(b, key)
+		if okA && okB {
+			d.diffKeyed(a, b, ka, kb, key, at)
+			return
+		}
+	}
+
+	// By position: common prefix, then the tail is removed or added.
+	n := min(len(a), len(b))
+	for i := range n {
+		d.diff(a[i], b[i], at.index(i, strconv.Itoa(i)))
+	}
+	for i := len(a) - 1; i >= n; i-- {
+		loc := at.index(i, strconv.Itoa(i))
+		d.add(Change{Kind: Removed, Path: loc.pointer, Label: loc.label, Old: a[i]})
+	}
+	for i := n; i < len(b); i++ {
+		loc := at.index(i, strconv.Itoa(i))
+		d.add(Change{Kind: Added, Path: loc.pointer, Label: loc.label, New: b[i]})
+	}
+}
+
+// diffKeyed matches elements by key. The changes come in an order that
+// keeps every index valid: removals from the back, then changes inside
+// the kept elements, then moves and additions that build b front to back.
+func (d *differ) diffKeyed(a, b []any, ka, kb []string, key string, at location) {
+	inB := map[string]int{}
+	for i, k := range kb {
+		inB[k] = i
+	}
+	label := func(k string) string { return key + "=" + k }
+
+	var kept []string // keys of a's elements that stay, in a's order
+	keptValue := map[string]any{}
+	for i := len(a) - 1; i >= 0; i-- {
+		if _, ok := inB[ka[i]]; !ok {
+			loc := at.index(i, label(ka[i]))
+			d.add(Change{Kind: Removed, Path: loc.pointer, Label: loc.label, Old: a[i]})
+		}
+	}
+	for i, k := range ka {
+		if _, ok := inB[k]; ok {
+			kept = append(kept, k)
+			keptValue[k] = a[i]
+		}
+	}
+	for i, k := range kept {
+		d.diff(keptValue[k], b[inB[k]], at.index(i, label(k)))
+	}
+
+	// Walk b and bring each element to its place in the working list.
+	work := kept
+	for i, k := range kb {
+		loc := at.index(i, label(k))
+		if _, ok := keptValue[k]; !ok {
+			d.add(Change{Kind: Added, Path: loc.pointer, Label: loc.label, New: b[i]})
+			work = insertAt(work, i, k)
+			continue
+		}
+		j := indexOf(work, k)
+		if j != i {
+			d.add(Change{Kind: Moved, Path: loc.pointer, From: at.pointer + "/" + strconv.Itoa(j), Label: loc.label, Old: j, New: i})
+			work = insertAt(append(work[:j:j], work[j+1:]...), i, k)
+		}
+	}
+}
+
+// keysOf returns the canonical key value of each element, or false when
+// the array cannot be matched by key.
+func keysOf(arr []any, key string) ([]string, bool) {
+	keys := make([]string, len(arr))
+	seen := map[string]bool{}
+	for i, e := range arr {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		v, ok := obj[key]
+		switch v.(type) {
+		case string, float64, json.Number, bool:
+		default:
+			return nil, false
+		}
+		c, err := Canonical(v)
+		if err != nil || seen[string(c)] {
+			return nil, false
+		}
+		seen[string(c)] = true
+		keys[i] = string(c)
+	}
+	return keys, true
+}
+
+func insertAt(s []string, i int, v string) []string {
+	s = append(s, "")
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// Equal reports whether two decoded JSON values are the same document.
+func Equal(a, b any) bool {
+	ca, errA := Canonical(a)
+	cb, errB := Canonical(b)
+	return errA == nil && errB == nil && bytes.Equal(ca, cb)
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
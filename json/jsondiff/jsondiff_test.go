@@ -0,0 +1,279 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"jsonexamples/patch"
+)
+
+// The example from RFC 8785, section 3.2.2.
+func TestCanonicalizeRFCExample(t *testing.T) {
+	in := `{
+	  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+	  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+	  "literals": [null, true, false]
+	}`
+	want := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	got, err := Canonicalize([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestCanonicalizeSortsByUTF16(t *testing.T) {
+	// U+1F600 is a surrogate pair (D83D DE00), which sorts before U+FB33
+	// in UTF-16 although it comes after it in UTF-8.
+	got, err := Canonicalize([]byte(`{"דּ":1,"😀":2,"b":3,"a":4}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":4,\"b\":3,\"\U0001F600\":2,\"\ufb33\":1}"; string(got) != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"a":1,"a":2}`,
+		`{"a":1e400}`,
+		`[1] [2]`,
+		`{"a":`,
+	} {
+		if got, err := Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%s) = %s; want an error", in, got)
+		}
+	}
+}
+
+// Values from the number test data of RFC 8785, appendix B.
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x4630000000000000, "1.2676506002282294e+30"},
+	}
+	for _, tt := range tests {
+		got, err := formatNumber(math.Float64frombits(tt.bits))
+		if err != nil || got != tt.want {
+			t.Errorf("%016x: got %q, %v; want %q", tt.bits, got, err, tt.want)
+		}
+	}
+	if _, err := formatNumber(math.NaN()); err == nil {
+		t.Error("NaN formatted without an error")
+	}
+}
+
+func diffJSON(t *testing.T, a, b string, opts Options) []Change {
+	t.Helper()
+	changes, err := DiffJSON([]byte(a), []byte(b), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func text(t *testing.T, changes []Change) string {
+	t.Helper()
+	var b strings.Builder
+	if err := Text(&b, changes); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// applies checks that the rendered patch turns a into b.
+func applies(t *testing.T, a, b string, changes []Change) {
+	t.Helper()
+	p, err := Patch(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Apply([]byte(a))
+	if err != nil {
+		t.Fatalf("applying the patch: %v", err)
+	}
+	ca, _ := Canonicalize(got)
+	cb, _ := Canonicalize([]byte(b))
+	if string(ca) != string(cb) {
+		raw, _ := json.Marshal(p)
+		t.Errorf("patch %s\ngave %s\nwant %s", raw, ca, cb)
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	a := `{"name":"Ana","age":30,"tags":["a","b"],"address":{"city":"Recife","zip":"50000"}}`
+	b := `{"name":"Ana","age":30.0,"tags":["a","c","d"],"address":{"city":"Olinda"},"email":"ana@example.com"}`
+	changes := diffJSON(t, a, b, Options{})
+	want := `- $.address.zip: "50000"
+~ $.address.city: "Recife" -> "Olinda"
++ $.email: "ana@example.com"
+~ $.tags[1]: "b" -> "c"
++ $.tags[2]: "d"
+`
+	if got := text(t, changes); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	applies(t, a, b, changes)
+
+	if changes := diffJSON(t, a, a, Options{}); len(changes) != 0 {
+		t.Errorf("a document differs from itself: %v", changes)
+	}
+}
+
+func TestDiffKeyed(t *testing.T) {
+	a := `{"users":[
+		{"id":1,"name":"Ana","email":"ana@example.com"},
+		{"id":2,"name":"Bob","email":"bob@example.com"},
+		{"id":3,"name":"Carl"},
+		{"id":4,"name":"Dora"}]}`
+	b := `{"users":[
+		{"id":4,"name":"Dora"},
+		{"id":1,"name":"Ana","email":"ana@example.com"},
+		{"id":2,"name":"Bob","email":"robert@example.com"},
+		{"id":5,"name":"Eve"}]}`
+	changes := diffJSON(t, a, b, Options{Key: "id"})
+	want := `- $.users[id=3]: {"id":3,"name":"Carl"}
+~ $.users[id=2].email: "bob@example.com" -> "robert@example.com"
+> $.users[id=4]: moved from 2 to 0
++ $.users[id=5]: {"id":5,"name":"Eve"}
+`
+	if got := text(t, changes); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	applies(t, a, b, changes)
+
+	// By position, every element after the first differs.
+	if n := len(diffJSON(t, a, b, Options{})); n <= len(changes) {
+		t.Errorf("positional diff has %d changes; keyed has %d", n, len(changes))
+	}
+}
+
+func TestDiffKeysByPath(t *testing.T) {
+	a := `{"orders":[{"id":1,"items":[{"sku":"A","qty":1},{"sku":"B","qty":2}]}]}`
+	b := `{"orders":[{"id":1,"items":[{"sku":"B","qty":3},{"sku":"A","qty":1}]}]}`
+	changes := diffJSON(t, a, b, Options{Key: "id", Keys: map[string]string{"/orders/*/items": "sku"}})
+	got := text(t, changes)
+	if !strings.Contains(got, `$.orders[id=1].items[sku="B"].qty: 2 -> 3`) {
+		t.Errorf("items not matched by sku:\n%s", got)
+	}
+	applies(t, a, b, changes)
+}
+
+func TestDiffFallsBackToPositions(t *testing.T) {
+	// Duplicate and missing keys make key matching impossible.
+	for _, tt := range []struct{ a, b string }{
+		{`[{"id":1},{"id":1}]`, `[{"id":1,"x":2}]`},
+		{`[{"id":1},{"name":"x"}]`, `[{"name":"x"},{"id":1}]`},
+		{`[1,2,3]`, `[3,2,1,0]`},
+	} {
+		changes := diffJSON(t, tt.a, tt.b, Options{Key: "id"})
+		for _, c := range changes {
+			if c.Kind == Moved {
+				t.Errorf("%s -> %s: unexpected move %v", tt.a, tt.b, c)
+			}
+		}
+		applies(t, tt.a, tt.b, changes)
+	}
+}
+
+// Random shuffles of keyed arrays always give a patch that applies.
+func TestDiffKeyedShuffles(t *testing.T) {
+	orders := [][]int{
+		{5, 4, 3, 2, 1},
+		{2, 3, 4, 5, 1},
+		{1, 6, 3, 7},
+		{},
+		{7, 1},
+		{3, 1, 4, 6, 5, 2},
+	}
+	doc := func(ids []int, tag string) string {
+		var parts []string
+		for _, id := range ids {
+			parts = append(parts, fmt.Sprintf(`{"id":%d,"v":"%s%d"}`, id, tag, id%2))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	a := doc([]int{1, 2, 3, 4, 5}, "a")
+	for _, ids := range orders {
+		b := doc(ids, "b")
+		applies(t, a, b, diffJSON(t, a, b, Options{Key: "id"}))
+	}
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	changes := diffJSON(t, `{"a":[1,2]}`, `{"a":[2],"b":{"c":null}}`, Options{})
+	p, err := Patch(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(p)
+	back, err := patch.Decode(raw)
+	if err != nil {
+		t.Fatalf("rendered patch does not decode: %v\n%s", err, raw)
+	}
+	if len(back) != len(changes) {
+		t.Errorf("%d operations; want %d", len(back), len(changes))
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	a := map[string]any{"name": "Ana", "age": json.Number("30"), "city": "Recife"}
+	b := map[string]any{"name": "Ana", "age": json.Number("31"), "city": "Recife", "zip": "50000"}
+	var out strings.Builder
+	if err := SideBySide(&out, a, b, 41); err != nil {
+		t.Fatal(err)
+	}
+	want := `{                     {
+  "age": 30,        |   "age": 31,
+  "city": "Recife",     "city": "Recife",
+  "name": "Ana"     |   "name": "Ana",
+                    >   "zip": "50000"
+}                     }
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	ops := diffLines(a, b)
+	edits := 0
+	var left, right []string
+	for _, op := range ops {
+		switch op.kind {
+		case equal:
+			left, right = append(left, a[op.a]), append(right, b[op.b])
+		case del:
+			left = append(left, a[op.a])
+			edits++
+		case ins:
+			right = append(right, b[op.b])
+			edits++
+		}
+	}
+	// The example from Myers' paper: the shortest script has 5 edits.
+	if edits != 5 || strings.Join(left, " ") != strings.Join(a, " ") || strings.Join(right, " ") != strings.Join(b, " ") {
+		t.Errorf("edits = %d, left %v, right %v", edits, left, right)
+	}
+}
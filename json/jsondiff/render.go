@@ -0,0 +1,217 @@
+package jsondiff
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"jsonexamples/patch"
+)
+
+// --- RENDERING ---
+
+// Text writes one line per change:
+//
+//	~ $.users[id=2].email: "bob@example.com" -> "robert@example.com"
+//	+ $.users[id=5]: {"id":5,"name":"Eve"}
+//	- $.users[id=3]: {"id":3,"name":"Carl"}
+//	> $.users[id=4]: moved from 3 to 0
+func Text(w io.Writer, changes []Change) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			fmt.Fprintf(bw, "+ %s: %s\n", c.Label, short(c.New))
+		case Removed:
+			fmt.Fprintf(bw, "- %s: %s\n", c.Label, short(c.Old))
+		case Replaced:
+			fmt.Fprintf(bw, "~ %s: %s -> %s\n", c.Label, short(c.Old), short(c.New))
+		case Moved:
+			fmt.Fprintf(bw, "> %s: moved from %v to %v\n", c.Label, c.Old, c.New)
+		}
+	}
+	return bw.Flush()
+}
+
+// maxShown caps the length of a value in Text, so a changed subtree does
+// not flood the output.
+const maxShown = 120
+
+func short(v any) string {
+	b, err := Canonical(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return truncate(string(b), maxShown)
+}
+
+// Patch returns the changes as a JSON Patch (RFC 6902) that turns the
+// first document into the second.
+func Patch(changes []Change) (patch.Patch, error) {
+	ops := make(patch.Patch, 0, len(changes))
+	for _, c := range changes {
+		op := patch.Operation{Op: string(c.Kind), Path: c.Path, From: c.From}
+		if c.Kind == Added || c.Kind == Replaced {
+			value, err := Canonical(c.New)
+			if err != nil {
+				return nil, err
+			}
+			op.Value = value
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// SideBySide writes both documents, canonical and indented, in two
+// columns of a width-wide view, marking lines the way diff -y does:
+// | changed, < only on the left, > only on the right.
+func SideBySide(w io.Writer, a, b any, width int) error {
+	left, err := indentedLines(a)
+	if err != nil {
+		return err
+	}
+	right, err := indentedLines(b)
+	if err != nil {
+		return err
+	}
+	col := max((width-3)/2, 10)
+	bw := bufio.NewWriter(w)
+	row := func(l, mark, r string) {
+		fmt.Fprintf(bw, "%s %s %s\n", pad(truncate(l, col), col), mark, truncate(r, col))
+	}
+
+	ops := diffLines(left, right)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == equal {
+			row(left[ops[i].a], " ", right[ops[i].b])
+			i++
+			continue
+		}
+		// Pair a run of deletions with the insertions that follow it.
+		var gone, added []int
+		for ; i < len(ops) && ops[i].kind == del; i++ {
+			gone = append(gone, ops[i].a)
+		}
+		for ; i < len(ops) && ops[i].kind == ins; i++ {
+			added = append(added, ops[i].b)
+		}
+		for j := range max(len(gone), len(added)) {
+			switch {
+			case j < len(gone) && j < len(added):
+				row(left[gone[j]], "|", right[added[j]])
+			case j < len(gone):
+				row(left[gone[j]], "<", "")
+			default:
+				row("", ">", right[added[j]])
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func indentedLines(v any) ([]string, error) {
+	c, err := Canonical(v)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	// RawMessage keeps the canonical member order and number spelling.
+	if err := enc.Encode(json.RawMessage(c)); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n"), nil
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func pad(s string, n int) string {
+	return s + strings.Repeat(" ", max(n-utf8.RuneCountInString(s), 0))
+}
+
+// --- LINE DIFF ---
+
+type lineKind int
+
+const (
+	equal lineKind = iota
+	del
+	ins
+)
+
+type lineOp struct {
+	kind lineKind
+	a, b int // line numbers in the left and right documents
+}
+
+// diffLines is Myers' O(ND) diff: the shortest edit script from a to b.
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, offset)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, n, m, offset int) []lineOp {
+	var ops []lineOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, lineOp{equal, x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, lineOp{ins, x, prevY})
+			} else {
+				ops = append(ops, lineOp{del, prevX, y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
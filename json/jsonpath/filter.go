@@ -0,0 +1,360 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// --- FILTER EXPRESSIONS ---
+//
+// RFC 9535 types every expression. A comparison works on values (or
+// Nothing, when a query finds no node); &&, ||, ! and filters work on
+// logicals; a query on its own is a test that it finds something.
+
+// logical is an expression that is true or false for the current node.
+type logical interface {
+	test(root, current any) bool
+}
+
+type orExpr []logical
+
+func (e orExpr) test(root, cur any) bool {
+	for _, x := range e {
+		if x.test(root, cur) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []logical
+
+func (e andExpr) test(root, cur any) bool {
+	for _, x := range e {
+		if !x.test(root, cur) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct{ x logical }
+
+func (e notExpr) test(root, cur any) bool { return !e.x.test(root, cur) }
+
+// existsExpr is a query used as a test: true when it finds a node.
+type existsExpr struct{ q *queryExpr }
+
+func (e existsExpr) test(root, cur any) bool { return len(e.q.nodes(root, cur)) > 0 }
+
+type compareExpr struct {
+	op          string
+	left, right valueExpr
+}
+
+func (e compareExpr) test(root, cur any) bool {
+	a, b := e.left.value(root, cur), e.right.value(root, cur)
+	switch e.op {
+	case "==":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	case "<":
+		return less(a, b)
+	case "<=":
+		return less(a, b) || equal(a, b)
+	case ">":
+		return less(b, a)
+	default: // >=
+		return less(b, a) || equal(a, b)
+	}
+}
+
+// valueExpr is an expression with one value, or Nothing.
+type valueExpr interface {
+	value(root, current any) any
+}
+
+// nothing is the absence of a value, like a query that finds no node.
+type nothingType struct{}
+
+var nothing = nothingType{}
+
+type literal struct{ v any }
+
+func (l literal) value(_, _ any) any { return l.v }
+
+// queryExpr is a query inside a filter, from the root ($) or from the
+// current node (@).
+type queryExpr struct {
+	absolute bool
+	segments []segment
+}
+
+func (q *queryExpr) nodes(root, cur any) []located {
+	start := cur
+	if q.absolute {
+		start = root
+	}
+	return run(root, start, q.segments)
+}
+
+// singular reports whether q finds at most one node: only names and
+// indexes, no wildcards, slices, filters or descendants.
+func (q *queryExpr) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		switch seg.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// value is the value of a singular query.
+func (q *queryExpr) value(root, cur any) any {
+	nodes := q.nodes(root, cur)
+	if len(nodes) != 1 {
+		return nothing
+	}
+	return nodes[0].value
+}
+
+// --- COMPARISON ---
+
+// equal implements == of RFC 9535, section 2.3.5.2.2.
+func equal(a, b any) bool {
+	if a == nothing || b == nothing {
+		return a == b
+	}
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := b[k]
+			if !ok || !equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	case string, bool, nil:
+		return a == b
+	}
+	return false
+}
+
+// less orders numbers and strings; any other pair is unordered.
+func less(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x.Cmp(y) < 0
+	}
+	if x, ok := a.(string); ok {
+		y, ok := b.(string)
+		return ok && x < y // byte order of UTF-8 is code point order
+	}
+	return false
+}
+
+// number converts the numbers encoding/json produces, and literals,
+// exactly, so 0.1 == 0.1 and big integers keep their digits.
+func number(v any) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	case float64:
+		return new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	}
+	return nil, false
+}
+
+// --- FUNCTIONS ---
+
+type exprType int
+
+const (
+	valueType exprType = iota
+	logicalType
+	nodesType
+)
+
+func (t exprType) String() string {
+	return [...]string{"ValueType", "LogicalType", "NodesType"}[t]
+}
+
+type function struct {
+	params []exprType
+	result exprType
+	// call gets one argument per param: a value (or nothing), a bool, or
+	// a []any of node values.
+	call func(args []any) any
+}
+
+var functions = map[string]function{
+	"length": {[]exprType{valueType}, valueType, func(args []any) any {
+		switch v := args[0].(type) {
+		case string:
+			return utf8.RuneCountInString(v)
+		case []any:
+			return len(v)
+		case map[string]any:
+			return len(v)
+		}
+		return nothing
+	}},
+	"count": {[]exprType{nodesType}, valueType, func(args []any) any {
+		return len(args[0].([]any))
+	}},
+	"match": {[]exprType{valueType, valueType}, logicalType, func(args []any) any {
+		return matchRegexp(args[0], args[1], true)
+	}},
+	"search": {[]exprType{valueType, valueType}, logicalType, func(args []any) any {
+		return matchRegexp(args[0], args[1], false)
+	}},
+	"value": {[]exprType{nodesType}, valueType, func(args []any) any {
+		if nodes := args[0].([]any); len(nodes) == 1 {
+			return nodes[0]
+		}
+		return nothing
+	}},
+}
+
+// callExpr is a function call. It is a valueExpr or a logical depending
+// on the function's result type.
+type callExpr struct {
+	name string
+	fn   function
+	args []any // valueExpr, logical or nodesArg, by param type
+}
+
+// nodesArg is an argument of NodesType.
+type nodesArg interface {
+	nodeValues(root, current any) []any
+}
+
+func (q *queryExpr) nodeValues(root, cur any) []any {
+	nodes := q.nodes(root, cur)
+	values := make([]any, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.value
+	}
+	return values
+}
+
+func (c *callExpr) eval(root, cur any) any {
+	args := make([]any, len(c.args))
+	for i, a := range c.args {
+		switch c.fn.params[i] {
+		case valueType:
+			args[i] = a.(valueExpr).value(root, cur)
+		case logicalType:
+			args[i] = a.(logical).test(root, cur)
+		case nodesType:
+			args[i] = a.(nodesArg).nodeValues(root, cur)
+		}
+	}
+	return c.fn.call(args)
+}
+
+func (c *callExpr) value(root, cur any) any { return c.eval(root, cur) }
+
+func (c *callExpr) test(root, cur any) bool {
+	switch v := c.eval(root, cur).(type) {
+	case bool:
+		return v
+	case []any:
+		return len(v) > 0
+	}
+	return false
+}
+
+// --- REGULAR EXPRESSIONS ---
+
+var regexps sync.Map // pattern and mode -> *regexp.Regexp, or nil when invalid
+
+// matchRegexp implements match (whole string) and search (any part). The
+// pattern is an I-Regexp (RFC 9485); anything that is not a string or a
+// valid pattern gives false.
+func matchRegexp(s, pattern any, whole bool) bool {
+	str, ok1 := s.(string)
+	pat, ok2 := pattern.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	key := pat
+	if whole {
+		key = "^" + pat
+	}
+	re, ok := regexps.Load(key)
+	if !ok {
+		var err error
+		expr := iregexp(pat)
+		if whole {
+			expr = `^(?:` + expr + `)$`
+		}
+		if re, err = regexp.Compile(expr); err != nil {
+			re = (*regexp.Regexp)(nil)
+		}
+		regexps.Store(key, re)
+	}
+	if re := re.(*regexp.Regexp); re != nil {
+		return re.MatchString(str)
+	}
+	return false
+}
+
+// iregexp turns an I-Regexp into RE2 syntax. They mostly agree; the
+// difference that matters is '.', which in I-Regexp matches anything but
+// \n and \r.
+func iregexp(pat string) string {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		switch {
+		case c == '\\' && i+1 < len(pat):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(pat[i])
+			continue
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,298 @@
+// Package jsonpath runs JSONPath queries (RFC 9535) over values decoded
+// by encoding/json into an any: maps, slices, strings, float64 or
+// json.Number, bools and nil.
+//
+//	p := jsonpath.MustParse(`$.users[?@.age >= 18 && match(@.name, 'J.*')].email`)
+//	emails := p.Select(doc)
+//
+// Supported: child (.name, ['name']) and descendant (..) segments,
+// wildcards, indexes (negative from the end), slices (start:end:step) and
+// filters with comparisons, &&, ||, !, existence tests and the functions
+// length, count, match, search and value.
+package jsonpath
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled query. It is safe for concurrent use.
+type Path struct {
+	query    string
+	segments []segment
+}
+
+// Node is a value found by a query and its location in the document, as a
+// normalized path like $['users'][0]['name'].
+type Node struct {
+	Location string
+	Value    any
+}
+
+// SyntaxError describes a query that does not parse.
+type SyntaxError struct {
+	Query  string
+	Offset int // byte offset of the problem in Query
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("jsonpath: %s at offset %d in %q", e.Msg, e.Offset, e.Query)
+}
+
+// Parse compiles a query.
+func Parse(query string) (*Path, error) {
+	p := &parser{s: query}
+	segments, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return &Path{query: query, segments: segments}, nil
+}
+
+// MustParse is Parse for queries known to be valid; it panics on error.
+func MustParse(query string) *Path {
+	p, err := Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Query parses query and runs it on doc.
+func Query(doc any, query string) ([]any, error) {
+	p, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return p.Select(doc), nil
+}
+
+func (p *Path) String() string { return p.query }
+
+// Select returns the values that match, in document order. Object members
+// are visited in key order, since Go maps have none.
+func (p *Path) Select(doc any) []any {
+	nodes := run(doc, doc, p.segments)
+	values := make([]any, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.value
+	}
+	return values
+}
+
+// SelectNodes is Select with the location of each value.
+func (p *Path) SelectNodes(doc any) []Node {
+	nodes := run(doc, doc, p.segments)
+	out := make([]Node, len(nodes))
+	for i, n := range nodes {
+		out[i] = Node{Location: n.path.String(), Value: n.value}
+	}
+	return out
+}
+
+// --- EVALUATION ---
+
+// located is a value and the steps that led to it. The path is a linked
+// list so the common case, where nobody asks for locations, stays cheap.
+type located struct {
+	value any
+	path  *step
+}
+
+type step struct {
+	parent *step
+	name   string // member name
+	index  int    // array index, when isIdx
+	isIdx  bool
+}
+
+func (s *step) child(name string) *step { return &step{parent: s, name: name} }
+func (s *step) elem(i int) *step        { return &step{parent: s, index: i, isIdx: true} }
+
+// String returns the normalized path (RFC 9535, section 2.7).
+func (s *step) String() string {
+	var steps []*step
+	for ; s != nil; s = s.parent {
+		steps = append(steps, s)
+	}
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, st := range slices.Backward(steps) {
+		if st.isIdx {
+			b.WriteString("[" + strconv.Itoa(st.index) + "]")
+			continue
+		}
+		b.WriteString("['")
+		for _, r := range st.name {
+			switch r {
+			case '\b':
+				b.WriteString(`\b`)
+			case '\f':
+				b.WriteString(`\f`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\'':
+				b.WriteString(`\'`)
+			case '\\':
+				b.WriteString(`\\`)
+			default:
+				if r < 0x20 {
+					fmt.Fprintf(&b, `\u%04x`, r)
+				} else {
+					b.WriteRune(r)
+				}
+			}
+		}
+		b.WriteString("']")
+	}
+	return b.String()
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selector interface {
+	apply(root any, n located, emit func(located))
+}
+
+// run applies segments to start, one after the other.
+func run(root, start any, segments []segment) []located {
+	nodes := []located{{value: start}}
+	for _, seg := range segments {
+		var next []located
+		emit := func(n located) { next = append(next, n) }
+		for _, n := range nodes {
+			if seg.descendant {
+				descend(n, func(d located) {
+					for _, sel := range seg.selectors {
+						sel.apply(root, d, emit)
+					}
+				})
+				continue
+			}
+			for _, sel := range seg.selectors {
+				sel.apply(root, n, emit)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descend visits n and everything below it, parents before children.
+func descend(n located, visit func(located)) {
+	visit(n)
+	children(n, func(c located) { descend(c, visit) })
+}
+
+// children calls fn for each element or member of n.
+func children(n located, fn func(located)) {
+	switch v := n.value.(type) {
+	case []any:
+		for i, e := range v {
+			fn(located{e, n.path.elem(i)})
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			fn(located{v[k], n.path.child(k)})
+		}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// --- SELECTORS ---
+
+type nameSelector string
+
+func (s nameSelector) apply(_ any, n located, emit func(located)) {
+	if obj, ok := n.value.(map[string]any); ok {
+		if v, ok := obj[string(s)]; ok {
+			emit(located{v, n.path.child(string(s))})
+		}
+	}
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(_ any, n located, emit func(located)) { children(n, emit) }
+
+type indexSelector int
+
+func (s indexSelector) apply(_ any, n located, emit func(located)) {
+	arr, ok := n.value.([]any)
+	if !ok {
+		return
+	}
+	i := int(s)
+	if i < 0 {
+		i += len(arr)
+	}
+	if i >= 0 && i < len(arr) {
+		emit(located{arr[i], n.path.elem(i)})
+	}
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+// apply follows RFC 9535, section 2.3.4.2.2.
+func (s sliceSelector) apply(_ any, n located, emit func(located)) {
+	arr, ok := n.value.([]any)
+	if !ok || s.step == 0 {
+		return
+	}
+	length := len(arr)
+	normalize := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		if *i < 0 {
+			return length + *i
+		}
+		return *i
+	}
+	if s.step > 0 {
+		lower := min(max(normalize(s.start, 0), 0), length)
+		upper := min(max(normalize(s.end, length), 0), length)
+		for i := lower; i < upper; i += s.step {
+			emit(located{arr[i], n.path.elem(i)})
+		}
+		return
+	}
+	upper := min(max(normalize(s.start, length-1), -1), length-1)
+	lower := min(max(normalize(s.end, -length-1), -1), length-1)
+	for i := upper; lower < i; i += s.step {
+		emit(located{arr[i], n.path.elem(i)})
+	}
+}
+
+type filterSelector struct {
+	expr logical
+}
+
+func (s filterSelector) apply(root any, n located, emit func(located)) {
+	children(n, func(c located) {
+		if s.expr.test(root, c.value) {
+			emit(c)
+		}
+	})
+}
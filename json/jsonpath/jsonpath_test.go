@@ -0,0 +1,225 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The example document of RFC 9535, section 1.5.
+const store = `{ "store": {
+    "book": [
+      { "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+      { "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+      { "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+      { "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+    ],
+    "bicycle": { "color": "red", "price": 399 }
+  }
+}`
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func compact(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestBookstore(t *testing.T) {
+	doc := decode(t, store)
+	tests := []struct{ query, want string }{
+		{`$.store.book[*].author`, `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{`$..author`, `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{`$.store.*`, `[{"color":"red","price":399},[{"author":"Nigel Rees","category":"reference","price":8.95,"title":"Sayings of the Century"},{"author":"Evelyn Waugh","category":"fiction","price":12.99,"title":"Sword of Honour"},{"author":"Herman Melville","category":"fiction","isbn":"0-553-21311-3","price":8.99,"title":"Moby Dick"},{"author":"J. R. R. Tolkien","category":"fiction","isbn":"0-395-19395-8","price":22.99,"title":"The Lord of the Rings"}]]`},
+		{`$.store..price`, `[399,8.95,12.99,8.99,22.99]`},
+		{`$..book[2].title`, `["Moby Dick"]`},
+		{`$..book[-1].title`, `["The Lord of the Rings"]`},
+		{`$..book[0,1].title`, `["Sayings of the Century","Sword of Honour"]`},
+		{`$..book[:2].title`, `["Sayings of the Century","Sword of Honour"]`},
+		{`$..book[?@.isbn].title`, `["Moby Dick","The Lord of the Rings"]`},
+		{`$..book[?@.price<10].title`, `["Sayings of the Century","Moby Dick"]`},
+		{`$..book[?@.price < 10 && @.category == 'fiction'].title`, `["Moby Dick"]`},
+		{`$..book[?!(@.price < 10) || @.isbn == "0-553-21311-3"].price`, `[12.99,8.99,22.99]`},
+		{`$..book[?match(@.author, '.*R.*')].author`, `["Nigel Rees","J. R. R. Tolkien"]`},
+		{`$..book[?search(@.title, 'of')].title`, `["Sayings of the Century","Sword of Honour","The Lord of the Rings"]`},
+		{`$..book[?length(@.title) > 20].title`, `["Sayings of the Century","The Lord of the Rings"]`},
+		{`$.store[?count(@.*) == 2]`, `[{"color":"red","price":399}]`},
+		{`$..book[?@.price == $.store.book[0].price].title`, `["Sayings of the Century"]`},
+		{`$..book[?value(@..isbn) == '0-395-19395-8'].author`, `["J. R. R. Tolkien"]`},
+		{`$..book[?@.price > $.store.bicycle.price]`, `[]`},
+		{`$.store.book[?@.missing == @.alsoMissing].title`, `["Sayings of the Century","Sword of Honour","Moby Dick","The Lord of the Rings"]`},
+		{`$..*[?@ == 'red']`, `["red"]`},
+		{`$["store"]['bicycle']["color"]`, `["red"]`},
+		{`$ .store .bicycle [ 'color' , "price" ]`, `["red",399]`},
+		{`$.nothing.here`, `[]`},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.query, err)
+			continue
+		}
+		if got := compact(p.Select(doc)); got != tt.want && !(got == "[]" && tt.want == "[]") {
+			t.Errorf("%s\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSlices(t *testing.T) {
+	doc := decode(t, `["a","b","c","d","e","f","g"]`)
+	tests := []struct{ query, want string }{
+		{`$[1:3]`, `["b","c"]`},
+		{`$[5:]`, `["f","g"]`},
+		{`$[1:5:2]`, `["b","d"]`},
+		{`$[5:1:-2]`, `["f","d"]`},
+		{`$[::-1]`, `["g","f","e","d","c","b","a"]`},
+		{`$[-2:]`, `["f","g"]`},
+		{`$[:-5]`, `["a","b"]`},
+		{`$[::0]`, `null`},
+		{`$[10:20]`, `null`},
+		{`$[-100:2]`, `["a","b"]`},
+		{`$[7]`, `null`},
+		{`$[-7]`, `["a"]`},
+	}
+	for _, tt := range tests {
+		vals, err := Query(doc, tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if len(vals) == 0 {
+			vals = nil
+		}
+		if got := compact(vals); got != tt.want {
+			t.Errorf("%s = %s; want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	doc := decode(t, `[1, 1.0, 2, "1", "a", "b", true, null, [1], {"x": 1}, {}]`)
+	tests := []struct{ query, want string }{
+		{`$[?@ == 1]`, `[1,1]`},
+		{`$[?@ < 2]`, `[1,1]`},
+		{`$[?@ >= "a"]`, `["a","b"]`},
+		{`$[?@ == true]`, `[true]`},
+		{`$[?@ == null]`, `[null]`},
+		{`$[?@ < true]`, `[]`},
+		{`$[?@ == $[8]]`, `[[1]]`},
+		{`$[?@.x]`, `[{"x":1}]`},
+		{`$[?@.x == 1.0e0]`, `[{"x":1}]`},
+		{`$[?length(@) == 1]`, `["1","a","b",[1],{"x":1}]`},
+		{`$[?@ != 1 && @ != "a"]`, `[2,"1","b",true,null,[1],{"x":1},{}]`},
+	}
+	for _, tt := range tests {
+		vals, err := Query(doc, tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := compact(vals); got != tt.want && !(len(vals) == 0 && tt.want == "[]") {
+			t.Errorf("%s = %s; want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"a": [{"n": 12345678901234567890}, {"n": 12345678901234567891}]}`))
+	dec.UseNumber()
+	var doc any
+	dec.Decode(&doc)
+	vals, _ := Query(doc, `$.a[?@.n > 12345678901234567890].n`)
+	if fmt.Sprint(vals) != "[12345678901234567891]" {
+		t.Errorf("got %v", vals)
+	}
+}
+
+func TestLocations(t *testing.T) {
+	doc := decode(t, `{"a": [{"b'c": 1}, {"b'c": 2, "x\n": 3}]}`)
+	var got []string
+	for _, n := range MustParse(`$..*`).SelectNodes(doc) {
+		got = append(got, n.Location)
+	}
+	want := `$['a'] $['a'][0] $['a'][1] $['a'][0]['b\'c'] $['a'][1]['b\'c'] $['a'][1]['x\n']`
+	if strings.Join(got, " ") != want {
+		t.Errorf("locations:\n got %s\nwant %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	doc := decode(t, `{"é": 1, "a\"b": 2, "a'b": 3, "😀": 4, "\u0007": 5}`)
+	for query, want := range map[string]string{
+		`$.é`:         `[1]`,
+		`$["a\"b"]`:   `[2]`,
+		`$['a\'b']`:   `[3]`,
+		`$['😀']`:      `[4]`,
+		`$['\u0007']`: `[5]`,
+	} {
+		vals, err := Query(doc, query)
+		if err != nil || compact(vals) != want {
+			t.Errorf("%s = %s, %v; want %s", query, compact(vals), err, want)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`store`,
+		`$.`,
+		`$. a`,
+		`$.1a`,
+		`$[01]`,
+		`$[-0]`,
+		`$[9007199254740992]`,
+		`$[1`,
+		`$['a"]`,
+		`$["a\'"]`,
+		`$['\ud83d']`,
+		`$[?@.a == ]`,
+		`$[?1]`,
+		`$[?@.* == 1]`,
+		`$[?@..a == 1]`,
+		`$[?length(@.a)]`,
+		`$[?count(1) == 1]`,
+		`$[?match(@.a) == true]`,
+		`$[?match(@.a, 'x') == true]`,
+		`$[?nope(@)]`,
+		`$[?(@.a]`,
+		`$[?@.a === 1]`,
+		`$ `,
+		`$[?!1]`,
+		`$.a[?@.b == 1 &&]`,
+	} {
+		_, err := Parse(query)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("Parse(%q) = %v; want a SyntaxError", query, err)
+		}
+	}
+}
+
+func TestIRegexp(t *testing.T) {
+	doc := decode(t, `["a\nb", "a\rb", "axb", "A"]`)
+	vals, _ := Query(doc, `$[?match(@, 'a.b')]`)
+	if compact(vals) != `["axb"]` {
+		t.Errorf("dot matched a line break: %s", compact(vals))
+	}
+	vals, _ = Query(doc, `$[?match(@, '[.]') || match(@, '\\p{Lu}')]`)
+	if compact(vals) != `["A"]` {
+		t.Errorf("got %s", compact(vals))
+	}
+	vals, _ = Query(doc, `$[?match(@, '(')]`)
+	if len(vals) != 0 {
+		t.Errorf("invalid pattern matched: %s", compact(vals))
+	}
+}
@@ -0,0 +1,621 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// --- PARSER ---
+//
+// A recursive descent parser over the ABNF of RFC 9535. Function
+// arguments and comparison operands are checked against the function
+// types while parsing, so a query that is not well-typed is a syntax
+// error, as the RFC asks.
+
+// maxSafeInt bounds indexes and slice bounds (I-JSON's exact integers).
+const maxSafeInt = 1<<53 - 1
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Query: p.s, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) eat(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// skipSpace skips the blank space (S) the grammar allows.
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\n\r", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) parseQuery() ([]segment, error) {
+	if !p.eat("$") {
+		return nil, p.errorf("query must start with $")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return segments, nil
+}
+
+// parseSegments reads segments while there are any. Blank space may come
+// before each one, but is not consumed when no segment follows.
+func (p *parser) parseSegments() ([]segment, error) {
+	var segments []segment
+	for {
+		start := p.pos
+		p.skipSpace()
+		var seg segment
+		var err error
+		switch {
+		case p.eat(".."):
+			seg.descendant = true
+			switch p.peek() {
+			case '[':
+				seg.selectors, err = p.parseBracketed()
+			case '*':
+				p.pos++
+				seg.selectors = []selector{wildcardSelector{}}
+			default:
+				var name string
+				name, err = p.parseMemberName()
+				seg.selectors = []selector{nameSelector(name)}
+			}
+		case p.eat("."):
+			if p.eat("*") {
+				seg.selectors = []selector{wildcardSelector{}}
+				break
+			}
+			var name string
+			name, err = p.parseMemberName()
+			seg.selectors = []selector{nameSelector(name)}
+		case p.peek() == '[':
+			seg.selectors, err = p.parseBracketed()
+		default:
+			p.pos = start
+			return segments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+}
+
+// parseMemberName reads the name in .name: a letter, _ or non-ASCII
+// character, then those or digits.
+func (p *parser) parseMemberName() (string, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		nameChar := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= 0x80 && r != utf8.RuneError)
+		if !nameChar && !(p.pos > start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a member name")
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *parser) parseBracketed() ([]selector, error) {
+	p.pos++ // [
+	var selectors []selector
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipSpace()
+		if p.eat("]") {
+			return selectors, nil
+		}
+		if !p.eat(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return nameSelector(s), err
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		expr, err := p.parseLogical()
+		return filterSelector{expr}, err
+	}
+
+	// An index, or a slice whose parts are all optional.
+	var start, end *int
+	if isIntStart(p.peek()) {
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		start = &n
+		p.skipSpace()
+	}
+	if !p.eat(":") {
+		if start == nil {
+			return nil, p.errorf("expected a selector")
+		}
+		return indexSelector(*start), nil
+	}
+	p.skipSpace()
+	if isIntStart(p.peek()) {
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		end = &n
+		p.skipSpace()
+	}
+	step := 1
+	if p.eat(":") {
+		p.skipSpace()
+		if isIntStart(p.peek()) {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			step = n
+		}
+	}
+	return sliceSelector{start: start, end: end, step: step}, nil
+}
+
+func isIntStart(c byte) bool { return c == '-' || (c >= '0' && c <= '9') }
+
+// parseInt reads an integer without leading zeros or -0.
+func (p *parser) parseInt() (int, error) {
+	start := p.pos
+	p.eat("-")
+	digits := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	text := p.s[start:p.pos]
+	switch {
+	case p.pos == digits:
+		return 0, p.errorf("expected digits")
+	case p.s[digits] == '0' && (p.pos-digits > 1 || digits > start):
+		return 0, p.errorf("bad integer %q", text)
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n > maxSafeInt || n < -maxSafeInt {
+		return 0, p.errorf("integer %s out of range", text)
+	}
+	return n, nil
+}
+
+// parseString reads a single- or double-quoted string literal.
+func (p *parser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.s) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++ // backslash
+		if p.pos >= len(p.s) {
+			return "", p.errorf("unterminated string")
+		}
+		esc := p.s[p.pos]
+		p.pos++
+		switch esc {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(esc)
+		case 'u':
+			r, err := p.parseUnicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			if esc != quote {
+				p.pos--
+				return "", p.errorf("bad escape \\%c", esc)
+			}
+			b.WriteByte(esc)
+		}
+	}
+}
+
+// parseUnicodeEscape reads the XXXX of \uXXXX, and a second escape when
+// the first is a high surrogate.
+func (p *parser) parseUnicodeEscape() (rune, error) {
+	hex4 := func() (rune, error) {
+		if p.pos+4 > len(p.s) {
+			return 0, p.errorf("short \\u escape")
+		}
+		n, err := strconv.ParseUint(p.s[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, p.errorf("bad \\u escape")
+		}
+		p.pos += 4
+		return rune(n), nil
+	}
+	r, err := hex4()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case r >= 0xDC00 && r <= 0xDFFF:
+		return 0, p.errorf("lone low surrogate")
+	case r >= 0xD800 && r <= 0xDBFF:
+		if !p.eat(`\u`) {
+			return 0, p.errorf("high surrogate without a low one")
+		}
+		low, err := hex4()
+		if err != nil {
+			return 0, err
+		}
+		if low < 0xDC00 || low > 0xDFFF {
+			return 0, p.errorf("high surrogate without a low one")
+		}
+		return utf16.DecodeRune(r, low), nil
+	}
+	return r, nil
+}
+
+// --- FILTER PARSER ---
+
+// parseLogical reads an expression that must be a logical.
+func (p *parser) parseLogical() (logical, error) {
+	start := p.pos
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return p.toLogical(n, start)
+}
+
+// parseOr and the functions below return an expression node: a logical,
+// or, when there is no operator, a bare literal, query or call whose use
+// the caller decides.
+func (p *parser) parseOr() (any, error) {
+	return p.parseChain("||", p.parseAnd, func(xs []logical) logical { return orExpr(xs) })
+}
+
+func (p *parser) parseAnd() (any, error) {
+	return p.parseChain("&&", p.parseBasic, func(xs []logical) logical { return andExpr(xs) })
+}
+
+func (p *parser) parseChain(op string, next func() (any, error), join func([]logical) logical) (any, error) {
+	start := p.pos
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	var operands []logical
+	for {
+		save := p.pos
+		p.skipSpace()
+		if !p.eat(op) {
+			p.pos = save
+			break
+		}
+		if operands == nil {
+			x, err := p.toLogical(first, start)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, x)
+		}
+		p.skipSpace()
+		at := p.pos
+		n, err := next()
+		if err != nil {
+			return nil, err
+		}
+		x, err := p.toLogical(n, at)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, x)
+	}
+	if operands == nil {
+		return first, nil
+	}
+	return join(operands), nil
+}
+
+func (p *parser) parseBasic() (any, error) {
+	if p.eat("!") {
+		p.skipSpace()
+		at := p.pos
+		var n any
+		var err error
+		if p.peek() == '(' {
+			n, err = p.parseParen()
+		} else {
+			n, err = p.parsePrimary()
+		}
+		if err != nil {
+			return nil, err
+		}
+		x, err := p.toLogical(n, at)
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	}
+	if p.peek() == '(' {
+		return p.parseParen()
+	}
+
+	leftAt := p.pos
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	save := p.pos
+	p.skipSpace()
+	op := ""
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.eat(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		p.pos = save
+		return left, nil
+	}
+	p.skipSpace()
+	rightAt := p.pos
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	l, err := p.toValue(left, leftAt, "comparison")
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.toValue(right, rightAt, "comparison")
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, left: l, right: r}, nil
+}
+
+func (p *parser) parseParen() (logical, error) {
+	p.pos++ // (
+	p.skipSpace()
+	x, err := p.parseLogical()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eat(")") {
+		return nil, p.errorf("expected )")
+	}
+	return x, nil
+}
+
+// parsePrimary reads a literal, a query or a function call.
+func (p *parser) parsePrimary() (any, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return &queryExpr{absolute: c == '$', segments: segments}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return literal{s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z') || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		if p.peek() == '(' {
+			return p.parseCall(name, start)
+		}
+		switch name {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unknown name %q", name)
+	}
+	return nil, p.errorf("expected a value, query or function")
+}
+
+// parseNumber reads a number literal: int or -0, then optional fraction
+// and exponent. It is kept as a json.Number so no digits are lost.
+func (p *parser) parseNumber() (any, error) {
+	start := p.pos
+	p.eat("-")
+	digits := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == digits || (p.s[digits] == '0' && p.pos-digits > 1) {
+		return nil, p.errorf("bad number")
+	}
+	if p.eat(".") {
+		frac := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == frac {
+			return nil, p.errorf("bad number")
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		exp := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == exp {
+			return nil, p.errorf("bad number")
+		}
+	}
+	return literal{json.Number(p.s[start:p.pos])}, nil
+}
+
+func (p *parser) parseCall(name string, at int) (any, error) {
+	fn, ok := functions[name]
+	if !ok {
+		p.pos = at
+		return nil, p.errorf("unknown function %s", name)
+	}
+	p.pos++ // (
+	call := &callExpr{name: name, fn: fn}
+	p.skipSpace()
+	for !p.eat(")") {
+		if len(call.args) > 0 {
+			if !p.eat(",") {
+				return nil, p.errorf("expected , or )")
+			}
+			p.skipSpace()
+		}
+		if len(call.args) == len(fn.params) {
+			return nil, p.errorf("%s takes %d arguments", name, len(fn.params))
+		}
+		argAt := p.pos
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		var arg any
+		switch fn.params[len(call.args)] {
+		case valueType:
+			arg, err = p.toValue(n, argAt, name+" argument")
+		case logicalType:
+			arg, err = p.toLogical(n, argAt)
+		case nodesType:
+			arg, err = p.toNodes(n, argAt, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		p.skipSpace()
+	}
+	if len(call.args) != len(fn.params) {
+		return nil, p.errorf("%s takes %d arguments", name, len(fn.params))
+	}
+	return call, nil
+}
+
+// --- TYPE CHECKS ---
+
+func (p *parser) typeError(at int, format string, args ...any) error {
+	return &SyntaxError{Query: p.s, Offset: at, Msg: fmt.Sprintf(format, args...)}
+}
+
+// toLogical accepts logicals, queries (as existence tests) and calls that
+// return LogicalType or NodesType.
+func (p *parser) toLogical(n any, at int) (logical, error) {
+	switch n := n.(type) {
+	case logical:
+		if c, ok := n.(*callExpr); ok && c.fn.result == valueType {
+			return nil, p.typeError(at, "%s returns a value, not a logical; compare it", c.name)
+		}
+		return n, nil
+	case *queryExpr:
+		return existsExpr{n}, nil
+	}
+	return nil, p.typeError(at, "expected a test or comparison")
+}
+
+// toValue accepts literals, singular queries and calls returning ValueType.
+func (p *parser) toValue(n any, at int, where string) (valueExpr, error) {
+	switch n := n.(type) {
+	case literal:
+		return n, nil
+	case *queryExpr:
+		if !n.singular() {
+			return nil, p.typeError(at, "%s needs a singular query (names and indexes only)", where)
+		}
+		return n, nil
+	case *callExpr:
+		if n.fn.result != valueType {
+			return nil, p.typeError(at, "%s returns %s, not a value", n.name, n.fn.result)
+		}
+		return n, nil
+	}
+	return nil, p.typeError(at, "%s needs a value", where)
+}
+
+// toNodes accepts queries and calls returning NodesType.
+func (p *parser) toNodes(n any, at int, fn string) (nodesArg, error) {
+	if q, ok := n.(*queryExpr); ok {
+		return q, nil
+	}
+	return nil, p.typeError(at, "%s needs a query", fn)
+}
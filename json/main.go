@@ -1,26 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+
+	"jsonexamples/jsondiff"
+	"jsonexamples/jsonpath"
+	"jsonexamples/money"
+	"jsonexamples/patch"
+	"jsonexamples/records"
+	"jsonexamples/redact"
+	"jsonexamples/schema"
+	"jsonexamples/stream"
+	"jsonexamples/wire"
 )
 
 // --- DEFINING STRUCTS FOR JSON ---
 
 type User struct {
-	ID       int     `json:"id"`              // Normal field
-	Name     string  `json:"name"`            // Renames the field for JSON
-	Email    string  `json:"email,omitempty"` // Will be omitted from JSON if empty
-	Password string  `json:"-"`               // Will be ignored by JSON
-	Active   bool    `json:"active"`
-	Balance  float64 `json:"balance,string"` // Marshal as string
-	Address  Address `json:"address"`        // Nested struct
+	ID       int         `json:"id" schema:"minimum=1" wire:"1"`                                     // Normal field
+	Name     string      `json:"name" schema:"minLength=1,maxLength=100" wire:"2"`                   // Renames the field for JSON
+	Email    string      `json:"email,omitempty" schema:"format=email" redact:"mask=email" wire:"3"` // Will be omitted from JSON if empty
+	Password string      `json:"-" redact:"drop"`                                                    // Will be ignored by JSON, and by logs
+	Active   bool        `json:"active" wire:"4"`
+	Balance  money.Money `json:"balance" wire:"5"` // Exact amount, {"amount": "1050.75", "currency": "USD"}
+	Address  Address     `json:"address" wire:"6"` // Nested struct
 }
 
 type Address struct {
-	Street string `json:"street"`
-	City   string `json:"city"`
+	Street string `json:"street" schema:"minLength=1" redact:"mask" wire:"1"`
+	City   string `json:"city" schema:"minLength=1" wire:"2"`
 }
 
 // --- MAIN FUNCTION ---
@@ -34,7 +50,7 @@ func main() {
 		Email:    "",
 		Password: "secret123",
 		Active:   true,
-		Balance:  1050.75,
+		Balance:  money.MustParse("1050.75", money.USD),
 		Address: Address{
 			Street: "123 Go Lane",
 			City:   "Gopher City",
@@ -57,7 +73,7 @@ func main() {
 		"name": "Bob",
 		"email": "bob@example.com",
 		"active": false,
-		"balance": "500.25",
+		"balance": {"amount": 500.25, "currency": "USD"},
 		"address": {
 			"street": "456 Gopher Rd",
 			"city": "GoTown"
@@ -73,6 +89,131 @@ func main() {
 	fmt.Println("Decoded Struct:")
 	fmt.Printf("%+v", user2)
 
+	// --- KEEPING PERSONAL DATA OUT OF LOGS ---
+	// json:"-" only hides Password from JSON: %+v above prints every field.
+	// redact.Value shows the struct with its redact tags applied, for fmt
+	// and for slog alike.
+
+	user.Email = "alice@example.com"
+	fmt.Printf("\nRedacted: %+v\n", redact.Value(user))
+	slog.New(slog.NewTextHandler(os.Stdout, nil)).Info("user loaded", "user", redact.Value(user))
+
+	// --- JSON SCHEMA FROM THE STRUCTS ---
+	// The schema is generated from the struct and its tags, so it cannot
+	// drift from the code. Money has its own MarshalJSON, which reflection
+	// cannot see through, so its schema is given by hand.
+
+	gen := schema.Generator{Types: map[reflect.Type]*schema.Schema{
+		reflect.TypeFor[money.Money](): {
+			Type:     schema.Types{schema.TypeObject},
+			Required: []string{"amount", "currency"},
+			Properties: map[string]*schema.Schema{
+				"amount":   {Type: schema.Types{schema.TypeString, schema.TypeNumber}, Pattern: `^-?[0-9]+(\.[0-9]+)?$`},
+				"currency": {Type: schema.Types{schema.TypeString}, Pattern: "^[A-Z]{3}$"},
+			},
+		},
+	}}
+	userSchema, err := gen.Generate(reflect.TypeFor[User]())
+	if err != nil {
+		log.Fatal(err)
+	}
+	schemaJSON, _ := json.MarshalIndent(userSchema, "", "  ")
+	fmt.Println("\nUser schema:")
+	fmt.Println(string(schemaJSON))
+
+	fmt.Println("Valid document:", userSchema.ValidateJSON([]byte(jsonStr)))
+
+	// Every problem is reported at once, each with the JSON Pointer of the value.
+	badJSON := `{"id": 0, "name": "", "email": "bob", "active": "yes",
+		"balance": {"amount": "1.5", "currency": "usd"}, "address": {"street": "x"}, "nick": "b"}`
+	if err := userSchema.ValidateJSON([]byte(badJSON)); err != nil {
+		var verr *schema.ValidationError
+		if errors.As(err, &verr) {
+			for _, v := range verr.Violations {
+				fmt.Printf("  %-18s %s\n", v.Path, v.Message)
+			}
+		}
+	}
+
+	// --- PARTIAL UPDATES WITH PATCHES ---
+	// A JSON Patch lists operations; a Merge Patch looks like the object.
+	// Either way only the JSON fields can change: Password (json:"-") is
+	// out of reach.
+
+	ops, err := patch.Decode([]byte(`[
+		{"op": "test", "path": "/name", "value": "Bob"},
+		{"op": "replace", "path": "/address/city", "value": "Gopherville"}
+	]`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := patch.ApplyTo(&user2, ops); err != nil {
+		log.Fatal(err)
+	}
+	if err := patch.MergeTo(&user2, []byte(`{"email": null, "active": true}`)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Patched: %+v\n", user2)
+	fmt.Println("Touching Password:", patch.MergeTo(&user2, []byte(`{"Password": "hacked"}`)))
+
+	// --- READING OLDER DOCUMENTS ---
+	// Files written before Money have the balance as a string of dollars
+	// and no longer fit User. Stored documents carry a "_v" version, and
+	// records.Users upgrades an old one step by step before it is decoded.
+	// Writes use the latest version; go run ./cmd/jmigrate upgrades whole
+	// files.
+
+	var legacy User
+	err = records.Users.Unmarshal([]byte(`{"id": 7, "name": "Old Timer", "active": true,
+		"balance": "19.99", "address": {"street": "1 Legacy Way", "city": "Oldtown"}}`), &legacy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Upgraded: %+v\n", legacy)
+	stored, err := records.Users.Marshal(legacy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Stored as:", string(stored))
+
+	// --- A COMPACT BINARY FORM ---
+	// For exports and storage where nobody reads the bytes, wire encodes
+	// the same fields as JSON, by the numbers in their wire tags instead
+	// of by name. go test -bench . compares it with encoding/json and gob.
+
+	binaryForm, err := wire.Marshal(legacy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var fromBinary User
+	if err := wire.Unmarshal(binaryForm, &fromBinary); err != nil {
+		log.Fatal(err)
+	}
+	jsonForm, _ := json.Marshal(legacy)
+	fmt.Printf("Binary: %d bytes instead of %d, decodes to %+v\n", len(binaryForm), len(jsonForm), fromBinary)
+
+	// --- EXACT MONEY ARITHMETIC ---
+	// With float64, 0.1 + 0.2 is 0.30000000000000004 and 1.005 rounds to 1.00.
+	// money.Decimal keeps every digit and only rounds when told how.
+
+	interest := user.Balance.Mul(money.MustParseDecimal("0.015"), money.HalfEven) // 1.5% of 1050.75 = 15.76125
+	total, err := user.Balance.Add(interest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("\nBalance with interest:", total)
+
+	// Splitting never loses a cent: the parts add up to the total.
+	parts, _ := total.Split(3)
+	fmt.Println("Split in 3:", parts)
+
+	// Brazilians write 1.066,51 for 1066.51.
+	brl, err := money.ParseIn("1.066,51", money.BRL, money.PtBR)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Parsed pt-BR:", brl, "=", brl.Format(money.PtBR))
+
 	// --- UNMARSHALING TO GENERIC INTERFACE{} ---
 
 	rawJSON := `{"foo": "bar", "number": 123, "active": true}`
@@ -88,18 +229,76 @@ func main() {
 		fmt.Printf("Key: %s, Value: %v, Type: %T", k, v, v)
 	}
 
-	// --- WORKING WITH JSON ARRAYS ---
+	// --- QUERYING GENERIC JSON WITH JSONPATH ---
+	// Walking nested maps by hand means a type assertion at every level.
+	// A JSONPath query (RFC 9535) says what to find instead.
 
-	jsonArr := `[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]`
-	var users []User
+	var orders any
+	err = json.Unmarshal([]byte(`{"orders": [
+		{"id": 1, "customer": {"name": "Ana", "city": "Recife"}, "items": [{"sku": "pen", "qty": 3}]},
+		{"id": 2, "customer": {"name": "Bruno", "city": "Natal"}, "items": [{"sku": "ink", "qty": 1}, {"sku": "pad", "qty": 12}]}
+	]}`), &orders)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, q := range []string{
+		`$.orders[*].customer.name`, // every customer
+		`$..sku`,                    // every sku, at any depth
+		`$.orders[?@.customer.city == 'Natal'].id`, // filter by a nested field
+		`$.orders[?count(@.items[?@.qty > 10]) > 0].id`,
+		`$.orders[-1].items[0:1]`,
+	} {
+		found, err := jsonpath.Query(orders, q)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("\n%-46s %v", q, found)
+	}
+	fmt.Println()
+
+	// --- COMPARING JSON DOCUMENTS ---
+	// Comparing JSON text byte by byte reports differences that are only
+	// spelling. jsondiff compares structure, matching orders by their id,
+	// so a reordered list is a move and not a rewrite of every element.
 
-	err = json.Unmarshal([]byte(jsonArr), &users)
+	var changed any
+	err = json.Unmarshal([]byte(`{"orders": [
+		{"id": 2, "customer": {"name": "Bruno", "city": "Natal"}, "items": [{"sku": "ink", "qty": 1}, {"sku": "pad", "qty": 12}]},
+		{"id": 1, "customer": {"name": "Ana", "city": "Olinda"}, "items": [{"sku": "pen", "qty": 3.0}]}
+	]}`), &changed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	canonical, err := jsondiff.Canonical(orders)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println("Canonical:", string(canonical))
+	fmt.Println("Changes:")
+	jsondiff.Text(os.Stdout, jsondiff.Diff(orders, changed, jsondiff.Options{Key: "id"}))
+
+	// --- WORKING WITH JSON ARRAYS ---
+	// json.Unmarshal into a []User holds the whole array in memory. For a
+	// big export, stream.Stream decodes one element at a time instead.
+
+	jsonArr := `{"exported": "2024-05-01", "users": [{"id":1,"name":"John"},{"id":2,"name":"Jane"},{"id":"3"}]}`
 
 	fmt.Println("Array of Users:")
-	for _, u := range users {
-		fmt.Printf("User: %+v", u)
+	for u, err := range stream.NewArrayAt[User](strings.NewReader(jsonArr), "/users").All() {
+		if err != nil {
+			fmt.Println("Skipped:", err) // a bad element, with its byte offset
+			continue
+		}
+		fmt.Printf("User: %+v\n", u)
+	}
+
+	// NDJSON, one value per line, is the usual format for exports.
+	var ndjson bytes.Buffer
+	w := stream.NewNDJSONWriter[User](&ndjson)
+	w.Write(user)
+	w.Write(user2)
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
 	}
+	fmt.Print("NDJSON:\n", ndjson.String())
 }
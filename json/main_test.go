@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"jsonexamples/money"
+	"jsonexamples/wire"
+)
+
+// exportUsers builds users like the ones a real export holds.
+func exportUsers(n int) []User {
+	users := make([]User, n)
+	for i := range users {
+		users[i] = User{
+			ID:       i + 1,
+			Name:     fmt.Sprintf("User Number %d", i+1),
+			Password: "never exported",
+			Active:   i%3 != 0,
+			Balance:  money.FromMinor(int64(i*7919%1000000), money.USD),
+			Address:  Address{Street: fmt.Sprintf("%d Gopher Lane", i%500), City: "Gopher City"},
+		}
+		if i%4 != 0 {
+			users[i].Email = fmt.Sprintf("user%d@example.com", i+1)
+		}
+	}
+	return users
+}
+
+// The binary form carries exactly what the JSON form does.
+func TestWireMatchesJSON(t *testing.T) {
+	users := exportUsers(50)
+	data, err := wire.Marshal(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back []User
+	if err := wire.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(users)
+	got, _ := json.Marshal(back)
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON after a wire round trip:\n%s\nwant\n%s", got, want)
+	}
+	if back[1].Password != "" {
+		t.Error("Password went over the wire")
+	}
+}
+
+// go test -bench . -benchmem compares the three encodings of an export.
+// bytes/user is the size of the encoded slice over its length.
+func BenchmarkEncode(b *testing.B) {
+	users := exportUsers(1000)
+	encoders := []struct {
+		name string
+		fn   func([]User) ([]byte, error)
+	}{
+		{"json", func(u []User) ([]byte, error) { return json.Marshal(u) }},
+		{"gob", func(u []User) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(u)
+			return buf.Bytes(), err
+		}},
+		{"wire", func(u []User) ([]byte, error) { return wire.Marshal(u) }},
+	}
+	for _, enc := range encoders {
+		b.Run(enc.name, func(b *testing.B) {
+			var size int
+			for b.Loop() {
+				data, err := enc.fn(users)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size)/float64(len(users)), "bytes/user")
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	users := exportUsers(1000)
+	jsonData, _ := json.Marshal(users)
+	var gobData bytes.Buffer
+	gob.NewEncoder(&gobData).Encode(users)
+	wireData, _ := wire.Marshal(users)
+
+	decoders := []struct {
+		name string
+		fn   func(*[]User) error
+	}{
+		{"json", func(u *[]User) error { return json.Unmarshal(jsonData, u) }},
+		{"gob", func(u *[]User) error { return gob.NewDecoder(bytes.NewReader(gobData.Bytes())).Decode(u) }},
+		{"wire", func(u *[]User) error { return wire.Unmarshal(wireData, u) }},
+	}
+	for _, dec := range decoders {
+		b.Run(dec.name, func(b *testing.B) {
+			for b.Loop() {
+				var out []User
+				if err := dec.fn(&out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
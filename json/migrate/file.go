@@ -0,0 +1,201 @@
+package migrate
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"jsonexamples/stream"
+)
+
+// --- UPGRADING DATA FILES ---
+//
+// A data file holds a JSON array of documents, NDJSON or a single
+// document. UpgradeFile rewrites it in the same shape: arrays and NDJSON
+// one document per line, a single document indented. Documents that are
+// already current are copied byte for byte.
+
+// Stats counts the documents of a file.
+type Stats struct {
+	Documents int // documents in the file
+	Upgraded  int // documents that were older than current
+}
+
+// UpgradeFile upgrades every document in the file at path to the current
+// version. The new file is written next to the old one, synced and then
+// renamed over it, so a crash leaves either the old file or the new one,
+// never a mix; any bad document leaves the file untouched. A file with
+// nothing to upgrade is not rewritten.
+func (m *Migrator) UpgradeFile(path string) (Stats, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return Stats{}, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	stats, err := m.upgrade(in, tmp)
+	if err == nil && stats.Upgraded > 0 {
+		err = tmp.Chmod(info.Mode().Perm())
+		if err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", path, err)
+	}
+	if stats.Upgraded == 0 {
+		return stats, nil
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return stats, err
+	}
+	// Sync the directory too, or the rename itself may not survive a crash.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return stats, nil
+}
+
+// CheckFile counts the documents in the file at path that UpgradeFile
+// would upgrade, without writing anything.
+func (m *Migrator) CheckFile(path string) (Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer f.Close()
+	stats, err := m.upgrade(f, io.Discard)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// upgrade copies the documents of r to w, upgrading them.
+func (m *Migrator) upgrade(r io.Reader, w io.Writer) (Stats, error) {
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err != nil {
+		return Stats{}, err
+	}
+	if first == '[' {
+		return m.upgradeArray(br, w)
+	}
+	return m.upgradeValues(br, w)
+}
+
+func (m *Migrator) upgradeArray(r io.Reader, w io.Writer) (Stats, error) {
+	var stats Stats
+	out := stream.NewArrayWriter[json.RawMessage](w)
+	s := stream.NewArray[json.RawMessage](r)
+	for {
+		raw, err := s.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		doc, err := m.upgradeOne(&stats, raw)
+		if err != nil {
+			return stats, err
+		}
+		if err := out.Write(doc); err != nil {
+			return stats, err
+		}
+	}
+	return stats, out.Close()
+}
+
+// upgradeValues handles NDJSON and single documents: it holds back the
+// first document until it knows whether a second one follows.
+func (m *Migrator) upgradeValues(r io.Reader, w io.Writer) (Stats, error) {
+	var stats Stats
+	var first json.RawMessage
+	var out *stream.Writer[json.RawMessage]
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return stats, fmt.Errorf("document %d: %w", stats.Documents, err)
+		}
+		doc, err := m.upgradeOne(&stats, raw)
+		if err != nil {
+			return stats, err
+		}
+		switch {
+		case stats.Documents == 1:
+			first = doc
+			continue
+		case out == nil:
+			out = stream.NewNDJSONWriter[json.RawMessage](w)
+			err = out.Write(first)
+		}
+		if err == nil {
+			err = out.Write(doc)
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+	if out != nil {
+		return stats, out.Close()
+	}
+	data, err := json.MarshalIndent(first, "", "  ")
+	if err != nil {
+		return stats, err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return stats, err
+}
+
+func (m *Migrator) upgradeOne(stats *Stats, raw json.RawMessage) (json.RawMessage, error) {
+	index := stats.Documents
+	stats.Documents++
+	doc, changed, err := m.UpgradeJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("document %d: %w", index, err)
+	}
+	if changed {
+		stats.Upgraded++
+	}
+	return doc, nil
+}
+
+// firstByte returns the first byte that is not white space, leaving it
+// unread. An empty file has no documents, which is an error here.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, errors.New("no documents")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b, r.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package migrate versions stored JSON documents. Every document carries
+// its version in a "_v" member. A Migrator holds the steps between
+// versions and runs the ones an old document needs when it is read, v1 to
+// v2 to ... to the current version; writes always use the current one.
+//
+//	users := migrate.New(
+//		func(doc map[string]any) error { ... }, // v1 -> v2
+//		func(doc map[string]any) error { ... }, // v2 -> v3
+//	)
+//	data, err := users.Marshal(u)     // {"_v":3,...}
+//	err = users.Unmarshal(old, &u)    // upgrades old first
+//
+// A document without "_v" is version 1: it was written before versions
+// were. Steps work on the decoded document, not on the current struct,
+// since the struct no longer describes old data. Numbers are json.Number.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// VersionField is the member that holds a document's version.
+const VersionField = "_v"
+
+var (
+	// ErrNotObject means a document is not a JSON object.
+	ErrNotObject = errors.New("migrate: document is not an object")
+	// ErrVersion means a document's version is not a positive integer.
+	ErrVersion = errors.New("migrate: bad version")
+	// ErrTooNew means a document was written by a newer program. It is
+	// never downgraded.
+	ErrTooNew = errors.New("migrate: document is newer than this program")
+)
+
+// StepError is a step that failed.
+type StepError struct {
+	From int // the version the step upgrades from
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("migrate: upgrading version %d to %d: %v", e.From, e.From+1, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// Step upgrades a document by one version, in place. It does not set
+// VersionField; the Migrator does.
+type Step func(doc map[string]any) error
+
+// Migrator upgrades documents of one kind. Register every step before
+// using it; after that it is safe for concurrent use.
+type Migrator struct {
+	steps []Step
+}
+
+// New returns a Migrator with the given steps: the first upgrades version
+// 1 to 2, the second 2 to 3, and so on.
+func New(steps ...Step) *Migrator {
+	return &Migrator{steps: steps}
+}
+
+// Register adds the step from the current version to a new one, which
+// becomes current.
+func (m *Migrator) Register(step Step) {
+	m.steps = append(m.steps, step)
+}
+
+// Current returns the version documents are written with.
+func (m *Migrator) Current() int { return len(m.steps) + 1 }
+
+// Version returns the version of doc: 1 when it has none.
+func Version(doc map[string]any) (int, error) {
+	raw, ok := doc[VersionField]
+	if !ok {
+		return 1, nil
+	}
+	var s string
+	switch v := raw.(type) {
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		s = strconv.Itoa(v)
+	default:
+		return 0, fmt.Errorf("%w: %v", ErrVersion, raw)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: %s", ErrVersion, s)
+	}
+	return n, nil
+}
+
+// Upgrade brings doc to the current version in place and returns the
+// version it had.
+func (m *Migrator) Upgrade(doc map[string]any) (from int, err error) {
+	from, err = Version(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > m.Current() {
+		return from, fmt.Errorf("%w: version %d, current is %d", ErrTooNew, from, m.Current())
+	}
+	for v := from; v < m.Current(); v++ {
+		if err := m.steps[v-1](doc); err != nil {
+			return from, &StepError{From: v, Err: err}
+		}
+		doc[VersionField] = v + 1
+	}
+	doc[VersionField] = m.Current()
+	return from, nil
+}
+
+// UpgradeJSON upgrades one encoded document. A document that is already
+// current comes back as it was, with changed false.
+func (m *Migrator) UpgradeJSON(data []byte) (out []byte, changed bool, err error) {
+	doc, err := decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+	from, err := m.Upgrade(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if from == m.Current() {
+		return data, false, nil
+	}
+	out, err = encode(doc)
+	return out, true, err
+}
+
+// Unmarshal upgrades the document in data and decodes it into v. Members
+// v has no field for are an error, so a missing step shows up here rather
+// than as silently lost data.
+func (m *Migrator) Unmarshal(data []byte, v any) error {
+	doc, err := decode(data)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Upgrade(doc); err != nil {
+		return err
+	}
+	delete(doc, VersionField)
+	current, err := encode(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(current))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Marshal encodes v, which must encode as an object, with the current
+// version as its first member.
+func (m *Migrator) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != '{' {
+		return nil, ErrNotObject
+	}
+	out := fmt.Appendf(nil, `{"%s":%d`, VersionField, m.Current())
+	if data[1] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...), nil
+}
+
+func decode(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, ErrNotObject
+	}
+	return doc, nil
+}
+
+func encode(doc map[string]any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
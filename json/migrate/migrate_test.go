@@ -0,0 +1,201 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// people renames "fullname" to "name" in v2 and adds "tags" in v3.
+func people() *Migrator {
+	m := New(func(doc map[string]any) error {
+		if v, ok := doc["fullname"]; ok {
+			doc["name"] = v
+			delete(doc, "fullname")
+		}
+		return nil
+	})
+	m.Register(func(doc map[string]any) error {
+		if _, ok := doc["tags"]; !ok {
+			doc["tags"] = []any{}
+		}
+		return nil
+	})
+	return m
+}
+
+type person struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func TestUnmarshalUpgrades(t *testing.T) {
+	m := people()
+	for _, in := range []string{
+		`{"fullname":"Ana"}`,
+		`{"_v":1,"fullname":"Ana"}`,
+		`{"_v":2,"name":"Ana"}`,
+		`{"_v":3,"name":"Ana","tags":[]}`,
+	} {
+		var p person
+		if err := m.Unmarshal([]byte(in), &p); err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if p.Name != "Ana" || p.Tags == nil {
+			t.Errorf("%s: got %+v", in, p)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	m := people()
+	failing := New(func(map[string]any) error { return errors.New("boom") })
+	tests := []struct {
+		m    *Migrator
+		in   string
+		want error
+	}{
+		{m, `{"_v":4,"name":"Ana"}`, ErrTooNew},
+		{m, `{"_v":"2","name":"Ana"}`, ErrVersion},
+		{m, `{"_v":1.5}`, ErrVersion},
+		{m, `{"_v":0}`, ErrVersion},
+		{m, `["Ana"]`, ErrNotObject},
+	}
+	for _, tt := range tests {
+		var p person
+		if err := tt.m.Unmarshal([]byte(tt.in), &p); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v; want %v", tt.in, err, tt.want)
+		}
+	}
+
+	var stepErr *StepError
+	if err := failing.Unmarshal([]byte(`{}`), &person{}); !errors.As(err, &stepErr) || stepErr.From != 1 {
+		t.Errorf("failing step: err = %v", err)
+	}
+	// A member no step took care of is not silently dropped.
+	if err := m.Unmarshal([]byte(`{"_v":3,"name":"Ana","nickname":"A"}`), &person{}); err == nil {
+		t.Error("unknown member decoded without an error")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	m := people()
+	data, err := m.Marshal(person{Name: "Ana", Tags: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"_v":3,"name":"Ana","tags":["x"]}`; string(data) != want {
+		t.Errorf("got %s; want %s", data, want)
+	}
+	if data, _ := m.Marshal(struct{}{}); string(data) != `{"_v":3}` {
+		t.Errorf("empty struct: got %s", data)
+	}
+	if _, err := m.Marshal([]int{1}); !errors.Is(err, ErrNotObject) {
+		t.Errorf("slice: err = %v", err)
+	}
+
+	var back person
+	if err := m.Unmarshal(data, &back); err != nil || back.Name != "Ana" {
+		t.Errorf("round trip: %+v, %v", back, err)
+	}
+}
+
+func TestUpgradeJSONKeepsCurrentDocuments(t *testing.T) {
+	in := []byte(`{"tags": [], "_v": 3,  "name": "Ana"}`)
+	out, changed, err := people().UpgradeJSON(in)
+	if err != nil || changed || string(out) != string(in) {
+		t.Errorf("got %s, %v, %v; want the input unchanged", out, changed, err)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpgradeFile(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		upgraded       int
+	}{
+		{"array",
+			"[\n  {\"fullname\": \"Ana\"},\n  {\"_v\":3,\"name\":\"Bo\",\"tags\":[\"x\"]}\n]\n",
+			"[\n{\"_v\":3,\"name\":\"Ana\",\"tags\":[]},\n{\"_v\":3,\"name\":\"Bo\",\"tags\":[\"x\"]}\n]\n", 1},
+		{"ndjson",
+			"{\"fullname\":\"Ana\"}\n{\"_v\":2,\"name\":\"Bo\"}\n",
+			"{\"_v\":3,\"name\":\"Ana\",\"tags\":[]}\n{\"_v\":3,\"name\":\"Bo\",\"tags\":[]}\n", 2},
+		{"single",
+			"{\n  \"fullname\": \"Ana\"\n}\n",
+			"{\n  \"_v\": 3,\n  \"name\": \"Ana\",\n  \"tags\": []\n}\n", 1},
+		{"empty array", "[]", "[]", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "data.json", tt.in)
+			stats, err := people().UpgradeFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Upgraded != tt.upgraded {
+				t.Errorf("Upgraded = %d; want %d", stats.Upgraded, tt.upgraded)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			info, _ := os.Stat(path)
+			if info.Mode().Perm() != 0o640 {
+				t.Errorf("mode = %v; want 0640", info.Mode().Perm())
+			}
+			// Upgrading again finds nothing to do.
+			if stats, err := people().UpgradeFile(path); err != nil || stats.Upgraded != 0 {
+				t.Errorf("second run: %+v, %v", stats, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeFileIsAtomic(t *testing.T) {
+	in := `[{"fullname":"Ana"},{"_v":7}]`
+	path := writeFile(t, "data.json", in)
+	if _, err := people().UpgradeFile(path); !errors.Is(err, ErrTooNew) || !strings.Contains(err.Error(), "document 1") {
+		t.Errorf("err = %v; want ErrTooNew for document 1", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != in {
+		t.Errorf("file changed after a failed upgrade: %s", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %d entries", len(entries))
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	in := "{\"fullname\":\"Ana\"}\n{\"_v\":3,\"name\":\"Bo\",\"tags\":[]}\n"
+	path := writeFile(t, "data.ndjson", in)
+	stats, err := people().CheckFile(path)
+	if err != nil || stats != (Stats{Documents: 2, Upgraded: 1}) {
+		t.Errorf("got %+v, %v", stats, err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != in {
+		t.Errorf("CheckFile wrote the file: %s", got)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for in, want := range map[string]int{`{}`: 1, `{"_v":2}`: 2, `{"_v":2.0}`: 2} {
+		var doc map[string]any
+		json.Unmarshal([]byte(in), &doc)
+		if got, err := Version(doc); got != want || err != nil {
+			t.Errorf("Version(%s) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package money
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// --- BINARY ---
+//
+// The binary form, for gob and other binary encodings, is the length of
+// the currency code, the code, then the amount in minor units as a zigzag
+// varint: 1050.75 USD is 3 "USD" then 105075 in three bytes. An amount
+// beyond int64 minor units is written as text instead, and the high bit of
+// the length says so. The zero Money is empty.
+
+const textAmount = 0x80
+
+func (m Money) MarshalBinary() ([]byte, error) {
+	if m.currency == (Currency{}) {
+		return []byte{}, nil
+	}
+	code := m.currency.code
+	if minor, ok := m.Minor(); ok {
+		b := append([]byte{byte(len(code))}, code...)
+		return binary.AppendVarint(b, minor), nil
+	}
+	b := append([]byte{byte(len(code)) | textAmount}, code...)
+	return append(b, m.amount.String()...), nil
+}
+
+func (m *Money) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		*m = Money{}
+		return nil
+	}
+	n := int(data[0] &^ textAmount)
+	if len(data) < 1+n+1 {
+		return fmt.Errorf("money: truncated binary form %x", data)
+	}
+	c, err := LookupCurrency(string(data[1 : 1+n]))
+	if err != nil {
+		return err
+	}
+	rest := data[1+n:]
+	if data[0]&textAmount != 0 {
+		amount, err := ParseDecimal(string(rest))
+		if err != nil {
+			return err
+		}
+		parsed, err := New(amount, c)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	minor, k := binary.Varint(rest)
+	if k != len(rest) {
+		return fmt.Errorf("money: bad binary form %x", data)
+	}
+	*m = FromMinor(minor, c)
+	return nil
+}
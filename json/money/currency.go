@@ -0,0 +1,85 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// --- CURRENCIES ---
+
+// ErrUnknownCurrency is returned for codes that are not registered.
+var ErrUnknownCurrency = errors.New("money: unknown currency")
+
+// Currency is an ISO 4217 currency. The zero value is no currency.
+type Currency struct {
+	code  string
+	minor int32 // digits after the decimal point
+}
+
+// Code returns the three-letter code, like "BRL".
+func (c Currency) Code() string { return c.code }
+
+// MinorUnits returns how many decimal places amounts have: 2 for USD, 0
+// for JPY, 3 for KWD.
+func (c Currency) MinorUnits() int32 { return c.minor }
+
+func (c Currency) String() string { return c.code }
+
+// Some common currencies. Others can be added with RegisterCurrency.
+var (
+	BRL = mustRegister("BRL", 2)
+	USD = mustRegister("USD", 2)
+	EUR = mustRegister("EUR", 2)
+	GBP = mustRegister("GBP", 2)
+	CHF = mustRegister("CHF", 2)
+	JPY = mustRegister("JPY", 0)
+	CLP = mustRegister("CLP", 0)
+	KWD = mustRegister("KWD", 3)
+	BHD = mustRegister("BHD", 3)
+)
+
+var (
+	currenciesMu sync.RWMutex
+	currencies   = map[string]Currency{}
+)
+
+// RegisterCurrency adds a currency with minor decimal places, so
+// LookupCurrency (and JSON) know it. Registering a known code again with
+// the same minor units returns the existing currency.
+func RegisterCurrency(code string, minor int32) (Currency, error) {
+	if len(code) != 3 || strings.ToUpper(code) != code || minor < 0 || minor > 9 {
+		return Currency{}, fmt.Errorf("money: invalid currency %q with %d minor units", code, minor)
+	}
+	currenciesMu.Lock()
+	defer currenciesMu.Unlock()
+	if c, ok := currencies[code]; ok {
+		if c.minor != minor {
+			return Currency{}, fmt.Errorf("money: currency %s already has %d minor units", code, c.minor)
+		}
+		return c, nil
+	}
+	c := Currency{code: code, minor: minor}
+	currencies[code] = c
+	return c, nil
+}
+
+func mustRegister(code string, minor int32) Currency {
+	c, err := RegisterCurrency(code, minor)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LookupCurrency returns the registered currency with code, ignoring case.
+func LookupCurrency(code string) (Currency, error) {
+	currenciesMu.RLock()
+	defer currenciesMu.RUnlock()
+	c, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
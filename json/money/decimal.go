@@ -0,0 +1,240 @@
+// Package money does exact decimal arithmetic for amounts of money.
+//
+// A float64 cannot hold 0.1 or 1050.75 exactly, so sums drift and
+// rounding happens where nobody asked for it. Decimal stores an integer
+// coefficient and a number of decimal places instead, and only rounds when
+// told to, with an explicit RoundingMode. Money is a Decimal in a Currency,
+// always at the currency's minor unit (cents for USD, none for JPY).
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrSyntax is returned for text that is not a decimal number.
+	ErrSyntax = errors.New("money: invalid decimal syntax")
+	// ErrDivisionByZero is returned by Quo for a zero divisor.
+	ErrDivisionByZero = errors.New("money: division by zero")
+	// ErrScale is returned by Round and Quo for a negative scale.
+	ErrScale = errors.New("money: negative scale")
+)
+
+// maxScale bounds the decimal places of parsed numbers, so "1e-999999999"
+// cannot allocate a huge coefficient.
+const maxScale = 64
+
+// --- DECIMAL ---
+
+// Decimal is coef × 10^-scale. The zero value is 0. Decimals are
+// immutable: every operation returns a new value.
+type Decimal struct {
+	coef  *big.Int // nil means 0; never modified once set
+	scale int32    // number of decimal places, >= 0
+}
+
+// NewDecimal returns coef × 10^-scale: NewDecimal(105075, 2) is 1050.75.
+func NewDecimal(coef int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(coef), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(coef), scale: scale}
+}
+
+// ParseDecimal reads a number like "-1050.75", "1e3" or "2.5E-2". The
+// result keeps every digit it was given: "1.50" has two decimal places.
+func ParseDecimal(s string) (Decimal, error) {
+	mant, exp, hasExp := strings.Cut(s, "e")
+	if !hasExp {
+		mant, exp, hasExp = strings.Cut(s, "E")
+	}
+	neg := false
+	if rest, ok := strings.CutPrefix(mant, "-"); ok {
+		mant, neg = rest, true
+	} else {
+		mant = strings.TrimPrefix(mant, "+")
+	}
+	intPart, frac, _ := strings.Cut(mant, ".")
+	if (intPart == "" && frac == "") || !allDigits(intPart) || !allDigits(frac) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+
+	scale := int64(len(frac))
+	if hasExp {
+		e, err := strconv.ParseInt(exp, 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+		}
+		scale -= e
+	}
+	if scale > maxScale || scale < -maxScale {
+		return Decimal{}, fmt.Errorf("%w: %q is out of range", ErrSyntax, s)
+	}
+
+	coef, _ := new(big.Int).SetString(intPart+frac, 10)
+	if neg {
+		coef.Neg(coef)
+	}
+	if scale < 0 {
+		return Decimal{coef: coef.Mul(coef, pow10(int32(-scale)))}, nil
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// MustParseDecimal is ParseDecimal for constants; it panics on bad input.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) big() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Scale returns the number of decimal places of d.
+func (d Decimal) Scale() int32 { return d.scale }
+
+// Sign returns -1, 0 or +1.
+func (d Decimal) Sign() int { return d.big().Sign() }
+
+// IsZero reports whether d is 0, whatever its scale.
+func (d Decimal) IsZero() bool { return d.Sign() == 0 }
+
+// Cmp compares d and e by value: 1.5 and 1.50 are equal.
+func (d Decimal) Cmp(e Decimal) int {
+	a, b := align(d, e)
+	return a.Cmp(b)
+}
+
+// Equal reports whether d and e have the same value.
+func (d Decimal) Equal(e Decimal) bool { return d.Cmp(e) == 0 }
+
+// align returns the coefficients of d and e at their common scale.
+func align(d, e Decimal) (*big.Int, *big.Int) {
+	a, b := d.big(), e.big()
+	switch {
+	case d.scale < e.scale:
+		a = new(big.Int).Mul(a, pow10(e.scale-d.scale))
+	case d.scale > e.scale:
+		b = new(big.Int).Mul(b, pow10(d.scale-e.scale))
+	}
+	return a, b
+}
+
+// Add returns d + e, exactly.
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b := align(d, e)
+	return Decimal{coef: new(big.Int).Add(a, b), scale: max(d.scale, e.scale)}
+}
+
+// Sub returns d - e, exactly.
+func (d Decimal) Sub(e Decimal) Decimal { return d.Add(e.Neg()) }
+
+// Mul returns d × e, exactly. The scales add up: 1.5 × 0.25 is 0.375.
+func (d Decimal) Mul(e Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.big(), e.big()), scale: d.scale + e.scale}
+}
+
+// Quo returns d / e rounded to scale decimal places with mode.
+func (d Decimal) Quo(e Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if scale < 0 {
+		return Decimal{}, fmt.Errorf("%w: %d", ErrScale, scale)
+	}
+	if e.IsZero() {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d/e at scale is (d.coef × 10^(scale + e.scale - d.scale)) / e.coef.
+	num, den := d.big(), e.big()
+	if shift := scale + e.scale - d.scale; shift >= 0 {
+		num = new(big.Int).Mul(num, pow10(shift))
+	} else {
+		den = new(big.Int).Mul(den, pow10(-shift))
+	}
+	return Decimal{coef: divRound(num, den, mode), scale: scale}, nil
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.big()), scale: d.scale}
+}
+
+// Abs returns |d|.
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.big()), scale: d.scale}
+}
+
+// Round returns d with scale decimal places, rounding with mode when
+// digits are dropped. A larger scale pads with zeros.
+func (d Decimal) Round(scale int32, mode RoundingMode) (Decimal, error) {
+	if scale < 0 {
+		return Decimal{}, fmt.Errorf("%w: %d", ErrScale, scale)
+	}
+	return d.round(scale, mode), nil
+}
+
+// round is Round for a scale known to be valid.
+func (d Decimal) round(scale int32, mode RoundingMode) Decimal {
+	if scale >= d.scale {
+		return Decimal{coef: new(big.Int).Mul(d.big(), pow10(scale-d.scale)), scale: scale}
+	}
+	return Decimal{coef: divRound(d.big(), pow10(d.scale-scale), mode), scale: scale}
+}
+
+// String returns d in plain notation with all its decimal places, like
+// "-1050.75" or "0.010".
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.big()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the nearest float64, for display or statistics only.
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(d.big(), pow10(d.scale)).Float64()
+	return f
+}
+
+var powers []*big.Int
+
+func init() {
+	p := big.NewInt(1)
+	for range maxScale * 2 {
+		powers = append(powers, p)
+		p = new(big.Int).Mul(p, big.NewInt(10))
+	}
+}
+
+// pow10 returns 10^n. The result must not be modified.
+func pow10(n int32) *big.Int {
+	if int(n) < len(powers) {
+		return powers[n]
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,107 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// --- JSON AND TEXT ---
+//
+// A Decimal is written as a JSON string ("1050.75"), so no JSON library
+// on the way turns it into a float. Reading accepts a string or a bare
+// number, since other systems often send 1050.75.
+//
+// Money is written as an object:
+//
+//	{"amount": "1050.75", "currency": "USD"}
+//
+// and as text (map keys, flags, logs) as "1050.75 USD".
+
+func (d Decimal) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) { return json.Marshal(d.String()) }
+
+// UnmarshalJSON reads a string or a number. null leaves d unchanged, like
+// it does for the built-in types.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	// A JSON number is also a valid ParseDecimal input, and keeps all its digits.
+	return d.UnmarshalText(data)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   Decimal `json:"amount"`
+		Currency string  `json:"currency"`
+	}{m.amount, m.currency.code})
+}
+
+// UnmarshalJSON reads the object form. The currency must be registered
+// and the amount must fit its minor units: nothing is rounded on the way in.
+// null leaves m unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var v struct {
+		Amount   *Decimal `json:"amount"`
+		Currency string   `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Amount == nil {
+		return fmt.Errorf("money: missing amount in %s", data)
+	}
+	c, err := LookupCurrency(v.Currency)
+	if err != nil {
+		return err
+	}
+	parsed, err := New(*v.Amount, c)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText reads "1050.75 USD".
+func (m *Money) UnmarshalText(text []byte) error {
+	amount, code, ok := strings.Cut(strings.TrimSpace(string(text)), " ")
+	if !ok {
+		return fmt.Errorf("money: want \"<amount> <currency>\", got %q", text)
+	}
+	c, err := LookupCurrency(strings.TrimSpace(code))
+	if err != nil {
+		return err
+	}
+	parsed, err := Parse(amount, c)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
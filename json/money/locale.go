@@ -0,0 +1,119 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- LOCALES ---
+
+// Locale is how a region writes numbers: 1,050.75 in the US is 1.050,75
+// in Brazil and 1 050,75 in France.
+type Locale struct {
+	Decimal rune // decimal separator
+	Group   rune // thousands separator, 0 for none
+}
+
+// Some common locales.
+var (
+	EnUS = Locale{Decimal: '.', Group: ','}
+	PtBR = Locale{Decimal: ',', Group: '.'}
+	DeDE = Locale{Decimal: ',', Group: '.'}
+	FrFR = Locale{Decimal: ',', Group: '\u202f'} // narrow no-break space
+	DeCH = Locale{Decimal: '.', Group: '\''}
+)
+
+// isGroup reports whether r separates thousands in l. Any kind of space
+// does where l uses one, since people type a plain space.
+func (l Locale) isGroup(r rune) bool {
+	if l.Group == 0 {
+		return false
+	}
+	if isSpace(l.Group) {
+		return isSpace(r)
+	}
+	return r == l.Group
+}
+
+func isSpace(r rune) bool { return r == ' ' || r == '\u00a0' || r == '\u202f' }
+
+// ParseDecimalIn reads a number written the way l writes them, like
+// "-1.050,75" in PtBR. Thousands separators are optional but, when used,
+// must split the integer part into groups of three digits, so a
+// misplaced one ("1.05,75") is an error instead of a silent misreading.
+func ParseDecimalIn(s string, l Locale) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	var plain strings.Builder
+	seenDecimal := false
+	groupLen := -1 // digits since the last separator, -1 before the first one
+	digits := 0    // in the current run
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			plain.WriteRune(r)
+			digits++
+		case (r == '-' || r == '+') && i == 0:
+			plain.WriteRune(r)
+		case r == l.Decimal && !seenDecimal:
+			if groupLen >= 0 && digits != 3 {
+				return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+			}
+			seenDecimal = true
+			plain.WriteByte('.')
+			digits = 0
+		case l.isGroup(r) && !seenDecimal:
+			if digits == 0 || digits > 3 || (groupLen >= 0 && digits != 3) {
+				return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+			}
+			groupLen, digits = digits, 0
+		default:
+			return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+		}
+	}
+	if groupLen >= 0 && !seenDecimal && digits != 3 {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	return ParseDecimal(plain.String())
+}
+
+// ParseIn reads an amount written the way l writes them as Money in c.
+func ParseIn(s string, c Currency, l Locale) (Money, error) {
+	d, err := ParseDecimalIn(s, l)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(d, c)
+}
+
+// Format writes d the way l does, with thousands separators.
+func (d Decimal) Format(l Locale) string {
+	s := d.String()
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 && l.Group != 0 {
+			b.WriteRune(l.Group)
+		}
+		b.WriteRune(c)
+	}
+	if hasFrac {
+		b.WriteRune(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Format writes m the way l does, followed by the currency code:
+// "1.050,75 BRL" in PtBR.
+func (m Money) Format(l Locale) string {
+	if m.currency == (Currency{}) {
+		return m.amount.Format(l)
+	}
+	return m.amount.Format(l) + " " + m.currency.code
+}
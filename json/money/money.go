@@ -0,0 +1,193 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// --- MONEY ---
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	// ErrPrecision is returned for amounts with more decimal places than
+	// their currency has, which would need rounding.
+	ErrPrecision = errors.New("money: more decimal places than the currency allows")
+	// ErrBadRatios is returned by Allocate for no ratios, negative ones or all zero.
+	ErrBadRatios = errors.New("money: ratios must be non-negative and not all zero")
+)
+
+// Money is an amount in a currency, always at the currency's minor unit.
+// Zero with no currency, like the zero value or its negation, adds to
+// any currency.
+type Money struct {
+	amount   Decimal
+	currency Currency
+}
+
+// New returns amount in c. It fails with ErrPrecision rather than round:
+// New(1.005, USD) is an error. Use NewRounded to round.
+func New(amount Decimal, c Currency) (Money, error) {
+	if c == (Currency{}) {
+		return Money{}, fmt.Errorf("%w: no currency", ErrUnknownCurrency)
+	}
+	rounded := amount.round(c.minor, Down)
+	if !rounded.Equal(amount) {
+		return Money{}, fmt.Errorf("%w: %s %s", ErrPrecision, amount, c)
+	}
+	return Money{amount: rounded, currency: c}, nil
+}
+
+// NewRounded returns amount in c, rounded to the minor unit with mode.
+func NewRounded(amount Decimal, c Currency, mode RoundingMode) Money {
+	return Money{amount: amount.round(c.minor, mode), currency: c}
+}
+
+// FromMinor returns minor units of c: FromMinor(105075, USD) is 1050.75 USD.
+func FromMinor(minor int64, c Currency) Money {
+	return Money{amount: NewDecimal(minor, c.minor), currency: c}
+}
+
+// Parse reads an amount in plain notation ("1050.75") as Money in c.
+func Parse(s string, c Currency) (Money, error) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(d, c)
+}
+
+// MustParse is Parse for constants; it panics on bad input.
+func MustParse(s string, c Currency) Money {
+	m, err := Parse(s, c)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Amount returns the amount, with the currency's decimal places.
+func (m Money) Amount() Decimal { return m.amount }
+
+// Currency returns the currency, the zero Currency for the zero Money.
+func (m Money) Currency() Currency { return m.currency }
+
+// Minor returns the amount in minor units (cents), and false if it does
+// not fit an int64.
+func (m Money) Minor() (int64, bool) {
+	c := m.amount.big()
+	return c.Int64(), c.IsInt64()
+}
+
+// Sign returns -1, 0 or +1.
+func (m Money) Sign() int { return m.amount.Sign() }
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.amount.IsZero() }
+
+// String returns the amount and the currency code, like "1050.75 USD".
+func (m Money) String() string {
+	if m.currency == (Currency{}) {
+		return m.amount.String()
+	}
+	return m.amount.String() + " " + m.currency.code
+}
+
+// sameCurrency returns the currency of a result of m and n.
+func sameCurrency(m, n Money) (Currency, error) {
+	switch {
+	case m.currency == n.currency:
+		return m.currency, nil
+	case m.currency == (Currency{}) && m.IsZero():
+		return n.currency, nil
+	case n.currency == (Currency{}) && n.IsZero():
+		return m.currency, nil
+	}
+	return Currency{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, n.currency)
+}
+
+// Add returns m + n. Both must be in the same currency.
+func (m Money) Add(n Money) (Money, error) {
+	c, err := sameCurrency(m, n)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: m.amount.Add(n.amount), currency: c}, nil
+}
+
+// Sub returns m - n. Both must be in the same currency.
+func (m Money) Sub(n Money) (Money, error) { return m.Add(n.Neg()) }
+
+// Neg returns -m.
+func (m Money) Neg() Money { return Money{amount: m.amount.Neg(), currency: m.currency} }
+
+// Cmp compares m and n, which must be in the same currency.
+func (m Money) Cmp(n Money) (int, error) {
+	if _, err := sameCurrency(m, n); err != nil {
+		return 0, err
+	}
+	return m.amount.Cmp(n.amount), nil
+}
+
+// Mul returns m × factor rounded to the minor unit with mode, for
+// interest, taxes and exchange rates.
+func (m Money) Mul(factor Decimal, mode RoundingMode) Money {
+	return NewRounded(m.amount.Mul(factor), m.currency, mode)
+}
+
+// Allocate splits m in proportion to ratios without losing a cent: the
+// parts always add up to m. Cents that do not divide evenly go one each
+// to the first parts, so Allocate(0.05 USD, 3, 7) is 0.02 and 0.03.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	total := 0
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, ErrBadRatios
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, ErrBadRatios
+	}
+
+	// Work in minor units, on the absolute value, and restore the sign at
+	// the end so negative amounts split the same way.
+	minor := new(big.Int).Abs(m.amount.big())
+	parts := make([]*big.Int, len(ratios))
+	rest := new(big.Int).Set(minor)
+	for i, r := range ratios {
+		parts[i] = new(big.Int).Mul(minor, big.NewInt(int64(r)))
+		parts[i].Quo(parts[i], big.NewInt(int64(total)))
+		rest.Sub(rest, parts[i])
+	}
+	one := big.NewInt(1)
+	for i := 0; rest.Sign() > 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Add(parts[i], one)
+		rest.Sub(rest, one)
+	}
+
+	out := make([]Money, len(parts))
+	for i, p := range parts {
+		if m.Sign() < 0 {
+			p.Neg(p)
+		}
+		out[i] = Money{amount: Decimal{coef: p, scale: m.amount.scale}, currency: m.currency}
+	}
+	return out, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrBadRatios
+	}
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
@@ -0,0 +1,290 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"1050.75", "1050.75"},
+		{"-0.5", "-0.5"},
+		{"+3", "3"},
+		{".25", "0.25"},
+		{"1.50", "1.50"},
+		{"1e3", "1000"},
+		{"2.5E-2", "0.025"},
+		{"0.000", "0.000"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil || d.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, %v; want %s", tt.in, d, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "-", ".", "1.2.3", "abc", "1e", "1e1e1", "0x10", "1,5", "1e-999"} {
+		if _, err := ParseDecimal(bad); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseDecimal(%q): err = %v; want ErrSyntax", bad, err)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	// 0.1 + 0.2 is 0.30000000000000004 in float64.
+	sum := MustParseDecimal("0.1").Add(MustParseDecimal("0.2"))
+	if sum.String() != "0.3" || !sum.Equal(MustParseDecimal("0.30")) {
+		t.Errorf("0.1 + 0.2 = %s", sum)
+	}
+	if got := MustParseDecimal("1050.75").Sub(MustParseDecimal("0.751")); got.String() != "1049.999" {
+		t.Errorf("Sub = %s", got)
+	}
+	if got := MustParseDecimal("1.5").Mul(MustParseDecimal("0.25")); got.String() != "0.375" {
+		t.Errorf("Mul = %s", got)
+	}
+	if got, _ := MustParseDecimal("10").Quo(MustParseDecimal("3"), 4, HalfEven); got.String() != "3.3333" {
+		t.Errorf("Quo = %s", got)
+	}
+	if _, err := MustParseDecimal("1").Quo(Decimal{}, 2, HalfEven); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Quo by zero: %v", err)
+	}
+	if (Decimal{}).String() != "0" || !(Decimal{}).Add(NewDecimal(5, 1)).Equal(MustParseDecimal("0.5")) {
+		t.Error("zero Decimal is not 0")
+	}
+	if NewDecimal(5, -2).String() != "500" {
+		t.Errorf("NewDecimal(5, -2) = %s", NewDecimal(5, -2))
+	}
+}
+
+func TestRound(t *testing.T) {
+	inputs := []string{"2.5", "3.5", "-2.5", "2.1", "-2.1", "2.9", "-2.9", "2.0"}
+	want := map[RoundingMode]string{
+		HalfEven: "2 4 -2 2 -2 3 -3 2",
+		HalfUp:   "3 4 -3 2 -2 3 -3 2",
+		HalfDown: "2 3 -2 2 -2 3 -3 2",
+		Up:       "3 4 -3 3 -3 3 -3 2",
+		Down:     "2 3 -2 2 -2 2 -2 2",
+		Ceiling:  "3 4 -2 3 -2 3 -2 2",
+		Floor:    "2 3 -3 2 -3 2 -3 2",
+	}
+	for mode, w := range want {
+		var got []string
+		for _, in := range inputs {
+			r, _ := MustParseDecimal(in).Round(0, mode)
+			got = append(got, r.String())
+		}
+		if strings.Join(got, " ") != w {
+			t.Errorf("%s: %s; want %s", mode, strings.Join(got, " "), w)
+		}
+	}
+	if got, _ := MustParseDecimal("1.005").Round(2, HalfUp); got.String() != "1.01" {
+		t.Errorf("1.005 HalfUp to 2 places = %s", got) // 1.00 with float64
+	}
+	if got, _ := MustParseDecimal("7").Round(2, HalfEven); got.String() != "7.00" {
+		t.Errorf("Round padding = %s", got)
+	}
+	if _, err := MustParseDecimal("7").Round(-1, HalfEven); !errors.Is(err, ErrScale) {
+		t.Errorf("Round to -1 places: err = %v; want %v", err, ErrScale)
+	}
+	if _, err := MustParseDecimal("7").Quo(MustParseDecimal("2"), -1, HalfEven); !errors.Is(err, ErrScale) {
+		t.Errorf("Quo to -1 places: err = %v; want %v", err, ErrScale)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	a := MustParse("1050.75", USD)
+	b := FromMinor(25, USD)
+	sum, err := a.Add(b)
+	if err != nil || sum.String() != "1051.00 USD" {
+		t.Errorf("Add = %s, %v", sum, err)
+	}
+	if _, err := a.Add(MustParse("1", BRL)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies: %v", err)
+	}
+	if _, err := Parse("1.005", USD); !errors.Is(err, ErrPrecision) {
+		t.Errorf("Parse with too many decimals: %v", err)
+	}
+	if _, err := Parse("1.5", JPY); !errors.Is(err, ErrPrecision) {
+		t.Errorf("Parse JPY with decimals: %v", err)
+	}
+	// 1.5% interest on 1050.75 is 15.76125.
+	if got := a.Mul(MustParseDecimal("0.015"), HalfEven); got.String() != "15.76 USD" {
+		t.Errorf("interest = %s", got)
+	}
+	if minor, ok := a.Minor(); !ok || minor != 105075 {
+		t.Errorf("Minor = %d, %v", minor, ok)
+	}
+	var zero Money
+	if got, _ := zero.Add(a); got.String() != a.String() {
+		t.Errorf("zero + a = %s", got)
+	}
+	if got, err := zero.Neg().Add(a); err != nil || got.String() != a.String() {
+		t.Errorf("-zero + a = %s, %v", got, err)
+	}
+	if got, err := a.Sub(zero.Neg()); err != nil || got.String() != a.String() {
+		t.Errorf("a - (-zero) = %s, %v", got, err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		amount string
+		ratios []int
+		want   string
+	}{
+		{"100.00", []int{1, 1, 1}, "[33.34 USD 33.33 USD 33.33 USD]"},
+		{"0.05", []int{3, 7}, "[0.02 USD 0.03 USD]"},
+		{"-0.05", []int{1, 1}, "[-0.03 USD -0.02 USD]"},
+		{"10.00", []int{0, 1, 1}, "[0.00 USD 5.00 USD 5.00 USD]"},
+		{"0.01", []int{1, 1, 1}, "[0.01 USD 0.00 USD 0.00 USD]"},
+	}
+	for _, tt := range tests {
+		m := MustParse(tt.amount, USD)
+		parts, err := m.Allocate(tt.ratios...)
+		if err != nil || fmt.Sprint(parts) != tt.want {
+			t.Errorf("Allocate(%s, %v) = %v, %v; want %s", tt.amount, tt.ratios, parts, err, tt.want)
+		}
+		var total Money
+		for _, p := range parts {
+			total, _ = total.Add(p)
+		}
+		if c, _ := total.Cmp(m); c != 0 {
+			t.Errorf("parts of %s add up to %s", m, total)
+		}
+	}
+	if _, err := MustParse("1", USD).Allocate(0, 0); !errors.Is(err, ErrBadRatios) {
+		t.Errorf("Allocate(0, 0): %v", err)
+	}
+	if parts, _ := MustParse("1000", JPY).Split(3); fmt.Sprint(parts) != "[334 JPY 333 JPY 333 JPY]" {
+		t.Errorf("Split(3) = %v", parts)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		in   string
+		loc  Locale
+		want string
+	}{
+		{"1.050,75", PtBR, "1050.75"},
+		{"-1.234.567,8", DeDE, "-1234567.8"},
+		{"1050,75", PtBR, "1050.75"},
+		{"1,050.75", EnUS, "1050.75"},
+		{"1 050,75", FrFR, "1050.75"},
+		{"1\u202f050,75", FrFR, "1050.75"},
+		{"1'050.75", DeCH, "1050.75"},
+		{"10.500", PtBR, "10500"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimalIn(tt.in, tt.loc)
+		if err != nil || d.String() != tt.want {
+			t.Errorf("ParseDecimalIn(%q) = %s, %v; want %s", tt.in, d, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"1.05,75", "1.0500,75", ".050,75", "1,050.75", "1,2,3", "12.34.567"} {
+		if _, err := ParseDecimalIn(bad, PtBR); err == nil {
+			t.Errorf("ParseDecimalIn(%q, PtBR) succeeded", bad)
+		}
+	}
+
+	m, err := ParseIn("1.234.567,89", BRL, PtBR)
+	if err != nil || m.Format(PtBR) != "1.234.567,89 BRL" || m.Format(EnUS) != "1,234,567.89 BRL" {
+		t.Errorf("ParseIn/Format = %q, %q, %v", m.Format(PtBR), m.Format(EnUS), err)
+	}
+	if got := MustParseDecimal("-123").Format(EnUS); got != "-123" {
+		t.Errorf("Format(-123) = %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type account struct {
+		Balance Money   `json:"balance"`
+		Rate    Decimal `json:"rate"`
+	}
+	in := account{Balance: MustParse("1050.75", USD), Rate: MustParseDecimal("0.015")}
+	data, _ := json.Marshal(in)
+	if string(data) != `{"balance":{"amount":"1050.75","currency":"USD"},"rate":"0.015"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	// Numbers are accepted too, digit for digit.
+	var out account
+	err := json.Unmarshal([]byte(`{"balance":{"amount":500.25,"currency":"brl"},"rate":1.10}`), &out)
+	if err != nil || out.Balance.String() != "500.25 BRL" || out.Rate.String() != "1.10" {
+		t.Errorf("Unmarshal = %+v, %v", out, err)
+	}
+
+	// null is no value, as for the built-in types.
+	out = in
+	if err := json.Unmarshal([]byte(`{"balance":null,"rate":null}`), &out); err != nil || out != in {
+		t.Errorf("Unmarshal of nulls = %+v, %v; want it unchanged", out, err)
+	}
+	var opt struct{ Balance *Money }
+	if err := json.Unmarshal([]byte(`{"Balance":null}`), &opt); err != nil || opt.Balance != nil {
+		t.Errorf("Unmarshal of null into *Money = %v, %v", opt.Balance, err)
+	}
+
+	for _, bad := range []string{
+		`{"balance":{"amount":null,"currency":"USD"}}`,
+		`{"balance":{"amount":"1.001","currency":"USD"}}`,
+		`{"balance":{"amount":"1","currency":"XXX"}}`,
+		`{"balance":{"currency":"USD"}}`,
+		`{"rate":"1,5"}`,
+		`{"rate":true}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), &out); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", bad)
+		}
+	}
+
+	var m Money
+	if err := m.UnmarshalText([]byte("19.90 EUR")); err != nil || m.String() != "19.90 EUR" {
+		t.Errorf("UnmarshalText = %s, %v", m, err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	for _, in := range []Money{
+		{},
+		MustParse("1050.75", USD),
+		MustParse("-1.28", USD),
+		MustParse("99999999999999999999999.99", EUR),
+		FromMinor(0, BRL),
+	} {
+		data, err := in.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out Money
+		if err := out.UnmarshalBinary(data); err != nil || out.String() != in.String() {
+			t.Errorf("%s: round trip gave %s, %v", in, out, err)
+		}
+	}
+	if data, _ := MustParse("1050.75", USD).MarshalBinary(); len(data) != 7 {
+		t.Errorf("1050.75 USD is %d bytes; want 7", len(data))
+	}
+	var m Money
+	for _, bad := range [][]byte{{3, 'U', 'S'}, {3, 'X', 'X', 'X', 0}, {3, 'U', 'S', 'D', 0x80}} {
+		if err := m.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%x) succeeded", bad)
+		}
+	}
+}
+
+func TestRegisterCurrency(t *testing.T) {
+	if c, err := RegisterCurrency("USD", 2); err != nil || c != USD {
+		t.Errorf("re-registering USD = %v, %v", c, err)
+	}
+	if _, err := RegisterCurrency("USD", 3); err == nil {
+		t.Error("USD registered again with 3 minor units")
+	}
+	if _, err := RegisterCurrency("us", 2); err == nil {
+		t.Error("invalid code registered")
+	}
+	uyu, _ := RegisterCurrency("UYU", 2)
+	if c, err := LookupCurrency("uyu"); err != nil || c != uyu {
+		t.Errorf("LookupCurrency(uyu) = %v, %v", c, err)
+	}
+}
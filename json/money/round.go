@@ -0,0 +1,87 @@
+package money
+
+import "math/big"
+
+// --- ROUNDING ---
+
+// RoundingMode says which way to go when digits are dropped.
+type RoundingMode int
+
+const (
+	// HalfEven rounds to the nearest value and ties to the even digit
+	// (banker's rounding): 2.5 → 2, 3.5 → 4. It does not drift upwards
+	// over many roundings.
+	HalfEven RoundingMode = iota
+	// HalfUp rounds to the nearest value and ties away from zero:
+	// 2.5 → 3, -2.5 → -3.
+	HalfUp
+	// HalfDown rounds to the nearest value and ties toward zero: 2.5 → 2.
+	HalfDown
+	// Up rounds away from zero: 2.1 → 3, -2.1 → -3.
+	Up
+	// Down rounds toward zero (truncates): 2.9 → 2, -2.9 → -2.
+	Down
+	// Ceiling rounds toward +∞: 2.1 → 3, -2.9 → -2.
+	Ceiling
+	// Floor rounds toward -∞: 2.9 → 2, -2.1 → -3.
+	Floor
+)
+
+func (m RoundingMode) String() string {
+	switch m {
+	case HalfEven:
+		return "HalfEven"
+	case HalfUp:
+		return "HalfUp"
+	case HalfDown:
+		return "HalfDown"
+	case Up:
+		return "Up"
+	case Down:
+		return "Down"
+	case Ceiling:
+		return "Ceiling"
+	case Floor:
+		return "Floor"
+	}
+	return "RoundingMode(?)"
+}
+
+// divRound returns num / den rounded to an integer with mode. den must
+// not be zero.
+func divRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int)) // q truncated toward zero
+	if r.Sign() == 0 {
+		return q
+	}
+	// The exact result is q + r/den; it is negative when num and den differ in sign.
+	neg := (num.Sign() < 0) != (den.Sign() < 0)
+	awayFromZero := false
+	switch mode {
+	case Up:
+		awayFromZero = true
+	case Down:
+	case Ceiling:
+		awayFromZero = !neg
+	case Floor:
+		awayFromZero = neg
+	default:
+		// Compare the remainder with half of the divisor: 2|r| vs |den|.
+		twice := new(big.Int).Abs(r)
+		twice.Lsh(twice, 1)
+		switch c := twice.Cmp(new(big.Int).Abs(den)); {
+		case c > 0:
+			awayFromZero = true
+		case c == 0:
+			awayFromZero = mode == HalfUp || (mode == HalfEven && q.Bit(0) == 1)
+		}
+	}
+	if awayFromZero {
+		if neg {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
@@ -0,0 +1,45 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// --- JSON MERGE PATCH (RFC 7396) ---
+
+// Merge applies the merge patch to the JSON document doc.
+func Merge(doc, mergePatch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(mergePatch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return json.Marshal(MergeValue(target, p))
+}
+
+// MergeValue returns doc with the merge patch applied. An object patch
+// sets its members and removes those that are null, recursively; any
+// other patch replaces the document. doc is not modified.
+func MergeValue(doc, mergePatch any) any {
+	p, ok := mergePatch.(map[string]any)
+	if !ok {
+		return deepCopy(mergePatch)
+	}
+	target, ok := doc.(map[string]any)
+	if ok {
+		target = deepCopy(target).(map[string]any)
+	} else {
+		target = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		target[k] = MergeValue(target[k], v)
+	}
+	return target
+}
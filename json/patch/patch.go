@@ -0,0 +1,328 @@
+// Package patch applies JSON Patch (RFC 6902) and JSON Merge Patch
+// (RFC 7396) documents, to raw JSON or to Go values.
+//
+// A JSON Patch is a list of operations:
+//
+//	[{"op": "test", "path": "/name", "value": "Bob"},
+//	 {"op": "replace", "path": "/email", "value": "bob@new.example"}]
+//
+// A Merge Patch is an object shaped like the document, where null
+// removes a member:
+//
+//	{"email": "bob@new.example", "nickname": null}
+//
+// Patches are atomic: when one operation fails the document is left as it
+// was. On Go values only the JSON form can change, so fields tagged
+// `json:"-"` are out of reach.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Media types of the two formats, for the Content-Type of PATCH requests.
+const (
+	MediaTypeJSONPatch  = "application/json-patch+json"
+	MediaTypeMergePatch = "application/merge-patch+json"
+)
+
+var (
+	// ErrInvalid means the patch itself is malformed.
+	ErrInvalid = errors.New("patch: invalid patch")
+	// ErrPath means an operation points at a value that does not exist.
+	ErrPath = errors.New("patch: path not found")
+	// ErrTestFailed means a "test" operation did not match.
+	ErrTestFailed = errors.New("patch: test failed")
+)
+
+// OpError is the failure of one operation of a Patch.
+type OpError struct {
+	Index int // position of the operation in the patch
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// Operation is one step of a JSON Patch. Value keeps its raw JSON so a
+// missing value and "value": null can be told apart.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a JSON Patch document.
+type Patch []Operation
+
+// Decode parses a JSON Patch and checks that every operation is well
+// formed, so a bad patch is refused before it touches anything.
+func Decode(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	for i, op := range p {
+		if err := op.check(); err != nil {
+			return nil, &OpError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+		}
+	}
+	return p, nil
+}
+
+func (op Operation) check() error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%w: %q needs a value", ErrInvalid, op.Op)
+		}
+	case "move", "copy":
+		if _, err := parsePointer(op.From); err != nil {
+			return err
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalid, op.Op)
+	}
+	_, err := parsePointer(op.Path)
+	return err
+}
+
+// Apply applies p to the JSON document doc and returns the result.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	v, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = p.ApplyValue(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// ApplyValue applies p to doc, a value decoded from JSON into an any. doc
+// is not modified; the patched copy is returned.
+func (p Patch) ApplyValue(doc any) (any, error) {
+	doc = deepCopy(doc)
+	for i, op := range p {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, &OpError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+func (op Operation) apply(doc any) (any, error) {
+	if err := op.check(); err != nil {
+		return nil, err
+	}
+	path, _ := parsePointer(op.Path)
+	switch op.Op {
+	case "add", "replace":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if op.Op == "add" {
+			return add(doc, path, value)
+		}
+		return replace(doc, path, value)
+	case "remove":
+		_, doc, err := remove(doc, path)
+		return doc, err
+	case "move":
+		from, _ := parsePointer(op.From)
+		if from.isPrefixOf(path) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalid)
+		}
+		value, doc, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	default: // test
+		want, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		got, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(got, want) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+}
+
+// add inserts value at p: a new or replaced object member, or an array
+// element shifted in before index p (or appended with "-").
+func add(doc any, p pointer, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	return update(doc, p, func(parent any, last string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[last] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(last, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], append([]any{value}, node[i:]...)...), nil
+		}
+		return nil, fmt.Errorf("%w: cannot add to a %s", ErrPath, kind(parent))
+	})
+}
+
+// remove deletes the value at p and returns it with the new document.
+func remove(doc any, p pointer) (removed, out any, err error) {
+	if len(p) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalid)
+	}
+	out, err = update(doc, p, func(parent any, last string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			v, ok := node[last]
+			if !ok {
+				return nil, fmt.Errorf("%w: no member %q", ErrPath, last)
+			}
+			removed = v
+			delete(node, last)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(last, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: cannot remove from a %s", ErrPath, kind(parent))
+	})
+	return removed, out, err
+}
+
+// replace is remove and add, except the target has to exist.
+func replace(doc any, p pointer, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	if _, err := get(doc, p); err != nil {
+		return nil, err
+	}
+	return update(doc, p, func(parent any, last string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[last] = value
+		case []any:
+			i, _ := arrayIndex(last, len(node), false)
+			node[i] = value
+		}
+		return parent, nil
+	})
+}
+
+// --- JSON VALUES ---
+
+// decode parses JSON keeping numbers as json.Number, so no digits are
+// lost on the way through a patch.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("patch: trailing data after JSON value")
+	}
+	return v, nil
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = deepCopy(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = deepCopy(e)
+		}
+		return out
+	}
+	return v
+}
+
+// Equal reports whether two decoded JSON values are equal the way the
+// "test" operation compares them: numbers by value (1 equals 1.0),
+// objects regardless of member order.
+func Equal(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			if vb, ok := b[k]; !ok || !Equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number, float64:
+		x, okA := number(a)
+		y, okB := number(b)
+		return okA && okB && x.Cmp(y) == 0
+	}
+	return a == b
+}
+
+func number(v any) (*big.Rat, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case float64:
+		return new(big.Rat).SetFloat64(v), true
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
@@ -0,0 +1,292 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// jsonEqual compares two JSON texts by value.
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	a, err := decode(got)
+	if err != nil {
+		t.Fatalf("bad JSON %s: %v", got, err)
+	}
+	b, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("bad JSON %s: %v", want, err)
+	}
+	return Equal(a, b)
+}
+
+// Cases from RFC 6902, appendix A, and a few edge cases.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+		err                    error
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{"add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{"test ok", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"test fails", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrTestFailed},
+		{"add nested object", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{"add to missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, ErrPath},
+		{"escaped paths", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"copy","from":"/~1","path":"/x"}]`, `{"/":9,"~1":10,"x":9}`, nil},
+		{"append with -", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{"add null value", `{}`, `[{"op":"add","path":"/a","value":null}]`, `{"a":null}`, nil},
+		{"replace root", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`, nil},
+		{"replace missing", `{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`, ``, ErrPath},
+		{"index out of range", `[1,2]`, `[{"op":"add","path":"/3","value":2}]`, ``, ErrPath},
+		{"leading zero index", `[1,2]`, `[{"op":"remove","path":"/01"}]`, ``, ErrPath},
+		{"move into itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ``, ErrInvalid},
+		{"big numbers kept", `{"n":12345678901234567890}`, `[{"op":"copy","from":"/n","path":"/m"}]`, `{"n":12345678901234567890,"m":12345678901234567890}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Decode([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Apply([]byte(tt.doc))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v; want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(t, got, tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	for _, p := range []string{
+		`{"op":"add"}`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"frobnicate","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+		`[{"op":"move","path":"/a","from":"b"}]`,
+		`[{"op":"remove","path":"/a~2"}]`,
+	} {
+		if _, err := Decode([]byte(p)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Decode(%s) = %v; want ErrInvalid", p, err)
+		}
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	doc := map[string]any{"a": []any{"x"}, "b": "keep"}
+	p, _ := Decode([]byte(`[{"op":"add","path":"/a/-","value":"y"},{"op":"remove","path":"/b"},{"op":"test","path":"/a/0","value":"nope"}]`))
+	_, err := p.ApplyValue(doc)
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Index != 2 {
+		t.Fatalf("err = %v; want a failure at operation 2", err)
+	}
+	if len(doc["a"].([]any)) != 1 || doc["b"] != "keep" {
+		t.Errorf("document changed by a failed patch: %v", doc)
+	}
+}
+
+// Cases from RFC 7396, appendix A.
+func TestMerge(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := Merge([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(t, got, tt.want) {
+			t.Errorf("Merge(%s, %s) = %s; want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+type address struct {
+	City string `json:"city"`
+	note string
+}
+
+type user struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email,omitempty"`
+	Password string            `json:"-"`
+	Address  address           `json:"address"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta,omitempty"`
+}
+
+func TestTyped(t *testing.T) {
+	orig := user{ID: 1, Name: "Bob", Email: "bob@example.com", Password: "secret",
+		Address: address{City: "Rio", note: "kept"}, Tags: []string{"a"}, Meta: map[string]string{"k": "v"}}
+
+	u := orig
+	p, _ := Decode([]byte(`[
+		{"op":"replace","path":"/name","value":"Robert"},
+		{"op":"remove","path":"/email"},
+		{"op":"add","path":"/tags/-","value":"b"},
+		{"op":"replace","path":"/address/city","value":"Recife"}
+	]`))
+	if err := ApplyTo(&u, p); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Robert" || u.Email != "" || len(u.Tags) != 2 || u.Address.City != "Recife" {
+		t.Errorf("patched = %+v", u)
+	}
+	if u.Password != "secret" || u.Address.note != "kept" || u.Meta["k"] != "v" {
+		t.Errorf("hidden or untouched fields lost: %+v", u)
+	}
+	if len(orig.Tags) != 1 {
+		t.Errorf("original shares state with the result: %+v", orig)
+	}
+
+	u = orig
+	if err := MergeTo(&u, []byte(`{"meta":null,"address":{"city":"Natal"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if u.Meta != nil || u.Address.City != "Natal" || u.Name != "Bob" || u.Password != "secret" {
+		t.Errorf("merged = %+v", u)
+	}
+}
+
+func TestTypedHiddenFieldsUnreachable(t *testing.T) {
+	orig := user{ID: 1, Name: "Bob", Password: "secret"}
+	for _, patch := range []string{
+		`[{"op":"add","path":"/Password","value":"x"}]`,
+		`[{"op":"add","path":"/password","value":"x"}]`,
+		`[{"op":"replace","path":"/Password","value":"x"}]`,
+		`[{"op":"test","path":"/Password","value":"secret"}]`,
+		`[{"op":"copy","from":"/Password","path":"/name"}]`,
+	} {
+		u := orig
+		p, err := Decode([]byte(patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyTo(&u, p); err == nil {
+			t.Errorf("%s: want an error", patch)
+		}
+		if u.ID != 1 || u.Name != "Bob" || u.Password != "secret" {
+			t.Errorf("%s: changed the user to %+v", patch, u)
+		}
+	}
+
+	u := orig
+	if err := MergeTo(&u, []byte(`{"Password":"x"}`)); !errors.Is(err, ErrType) {
+		t.Errorf("merge into a hidden field: err = %v", err)
+	}
+	if u.Password != "secret" {
+		t.Errorf("password changed to %q", u.Password)
+	}
+}
+
+func TestTypedHiddenBehindPointer(t *testing.T) {
+	type doc struct {
+		Title string   `json:"title"`
+		Owner *user    `json:"owner"`
+		Next  **user   `json:"next"`
+		Tags  []string `json:"tags"`
+	}
+	next := &user{Name: "Ann", Password: "ann"}
+	orig := doc{Title: "a", Owner: &user{Name: "Bob", Password: "secret", Address: address{note: "kept"}}, Next: &next}
+
+	d := orig
+	p, _ := Decode([]byte(`[{"op":"replace","path":"/title","value":"b"},{"op":"replace","path":"/owner/name","value":"Robert"}]`))
+	if err := ApplyTo(&d, p); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "b" || d.Owner.Name != "Robert" || d.Owner.Password != "secret" ||
+		d.Owner.Address.note != "kept" || (*d.Next).Password != "ann" {
+		t.Errorf("patched = %+v, owner %+v", d, d.Owner)
+	}
+	if orig.Owner.Name != "Bob" || d.Owner == orig.Owner {
+		t.Errorf("patch wrote through the original pointer: %+v", orig.Owner)
+	}
+
+	d = orig
+	if err := MergeTo(&d, []byte(`{"owner":null}`)); err != nil {
+		t.Fatal(err)
+	}
+	if d.Owner != nil || orig.Owner == nil {
+		t.Errorf("merged owner = %+v, original %+v", d.Owner, orig.Owner)
+	}
+}
+
+func TestTypedHiddenInElements(t *testing.T) {
+	type list struct {
+		Title string `json:"title"`
+		Users []user `json:"users"`
+	}
+	type byName struct {
+		Title string           `json:"title"`
+		Users map[string]*user `json:"users"`
+	}
+	type fixed struct {
+		Users [1]user `json:"users"`
+	}
+	p, _ := Decode([]byte(`[{"op":"replace","path":"/title","value":"b"}]`))
+
+	l := list{Title: "a", Users: []user{{Name: "Bob", Password: "secret"}}}
+	if err := ApplyTo(&l, p); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("slice: err = %v; want ErrUnsupported", err)
+	}
+	m := byName{Title: "a", Users: map[string]*user{"bob": {Password: "secret"}}}
+	if err := MergeTo(&m, []byte(`{"title":"b"}`)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("map: err = %v; want ErrUnsupported", err)
+	}
+	f := fixed{}
+	if err := ApplyTo(&f, p); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("array: err = %v; want ErrUnsupported", err)
+	}
+	if l.Title != "a" || l.Users[0].Password != "secret" || m.Title != "a" || m.Users["bob"].Password != "secret" {
+		t.Errorf("refused patch changed the value: %+v %+v", l, m)
+	}
+}
+
+func TestTypedWrongType(t *testing.T) {
+	u := user{ID: 1, Name: "Bob"}
+	p, _ := Decode([]byte(`[{"op":"replace","path":"/id","value":"one"}]`))
+	if err := ApplyTo(&u, p); !errors.Is(err, ErrType) {
+		t.Errorf("err = %v; want ErrType", err)
+	}
+	if u.ID != 1 {
+		t.Errorf("user changed: %+v", u)
+	}
+}
+
+func TestOperationJSON(t *testing.T) {
+	p := Patch{{Op: "remove", Path: "/a"}, {Op: "add", Path: "/b", Value: json.RawMessage(`null`)}}
+	data, _ := json.Marshal(p)
+	if string(data) != `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":null}]` {
+		t.Errorf("marshal = %s", data)
+	}
+}
@@ -0,0 +1,137 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --- JSON POINTER (RFC 6901) ---
+
+// pointer is a parsed JSON Pointer; the empty pointer is the whole document.
+type pointer []string
+
+func parsePointer(s string) (pointer, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer %q must start with /", ErrInvalid, s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		if strings.Contains(strings.ReplaceAll(strings.ReplaceAll(t, "~0", ""), "~1", ""), "~") {
+			return nil, fmt.Errorf("%w: pointer %q has a bad ~ escape", ErrInvalid, s)
+		}
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// isPrefixOf reports whether p is a proper prefix of q.
+func (p pointer) isPrefixOf(q pointer) bool {
+	if len(p) >= len(q) {
+		return false
+	}
+	for i := range p {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token. Leading zeros are not allowed,
+// so each index has one spelling.
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrPath, token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrPath, i)
+	}
+	return i, nil
+}
+
+// get returns the value at p.
+func get(doc any, p pointer) (any, error) {
+	cur := doc
+	for _, tok := range p {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[tok]
+			if !ok {
+				return nil, fmt.Errorf("%w: no member %q", ErrPath, tok)
+			}
+			cur = v
+		case []any:
+			i, err := arrayIndex(tok, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("%w: cannot step into a %s", ErrPath, kind(cur))
+		}
+	}
+	return cur, nil
+}
+
+// update replaces the container holding the last token of p with the
+// result of fn, and returns the new document. fn gets the parent and the
+// last token. Arrays change length, so every step writes its result back.
+func update(doc any, p pointer, fn func(parent any, last string) (any, error)) (any, error) {
+	if len(p) == 1 {
+		return fn(doc, p[0])
+	}
+	tok, rest := p[0], p[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[tok]
+		if !ok {
+			return nil, fmt.Errorf("%w: no member %q", ErrPath, tok)
+		}
+		child, err := update(child, rest, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tok] = child
+		return node, nil
+	case []any:
+		i, err := arrayIndex(tok, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(node[i], rest, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("%w: cannot step into a %s", ErrPath, kind(doc))
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "number"
+}
@@ -0,0 +1,190 @@
+package patch
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// --- GO VALUES ---
+//
+// A Go value is patched through its JSON form: it is marshaled, patched
+// and decoded back. A field the JSON form does not show, like one tagged
+// `json:"-"`, cannot be read, tested or changed by a patch; an operation
+// that adds a member the type does not have fails instead of being
+// silently dropped.
+//
+// Hidden fields are kept in place, including those behind a pointer. A
+// type with hidden fields inside slice, array or map elements is refused:
+// elements come back from the patched document in a new order or under
+// new keys, so there is no telling which old element a hidden value
+// belongs to.
+
+var (
+	// ErrType means the patched document no longer fits the Go type.
+	ErrType = errors.New("patch: result does not fit the type")
+	// ErrUnsupported means the type has hidden fields a patch would lose.
+	ErrUnsupported = errors.New("patch: type not supported")
+)
+
+// ApplyTo applies p to *v. On error *v is unchanged.
+func ApplyTo[T any](v *T, p Patch) error {
+	return patchValue(v, p.ApplyValue)
+}
+
+// MergeTo applies a merge patch to *v. On error *v is unchanged.
+func MergeTo[T any](v *T, mergePatch []byte) error {
+	p, err := decode(mergePatch)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return patchValue(v, func(doc any) (any, error) { return MergeValue(doc, p), nil })
+}
+
+func patchValue[T any](v *T, fn func(any) (any, error)) error {
+	if err := checkHidden(reflect.TypeFor[T](), map[reflect.Type]bool{}); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	doc, err := decode(data)
+	if err != nil {
+		return err
+	}
+	if doc, err = fn(doc); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return err
+	}
+
+	// Decode once into a zero value to learn what the patched document
+	// holds, then again into a copy that keeps what JSON cannot see and
+	// drops what it can, so a removed member comes back as the zero value
+	// instead of the old one.
+	var fresh T
+	if err := decodeStrict(data, &fresh); err != nil {
+		return err
+	}
+	out := *v
+	clearVisible(reflect.ValueOf(&out).Elem(), reflect.ValueOf(&fresh).Elem())
+	if err := decodeStrict(data, &out); err != nil {
+		return err
+	}
+	*v = out
+	return nil
+}
+
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrType, err)
+	}
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// plainStruct reports whether encoding/json decodes t field by field,
+// rather than handing it to the type's own unmarshaler.
+func plainStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct &&
+		!reflect.PointerTo(t).Implements(jsonUnmarshalerType) &&
+		!reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// hiddenField reports whether encoding/json never shows sf.
+func hiddenField(sf reflect.StructField) bool {
+	return sf.Tag.Get("json") == "-" || (!sf.IsExported() && !sf.Anonymous)
+}
+
+// checkHidden fails if a patch could not keep the hidden fields of t,
+// which is when they sit inside slice, array or map elements.
+func checkHidden(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkHidden(t.Elem(), seen)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if hasHidden(t.Elem(), map[reflect.Type]bool{}) {
+			return fmt.Errorf("%w: elements of %v have fields hidden from JSON", ErrUnsupported, t)
+		}
+	case reflect.Struct:
+		if !plainStruct(t) {
+			return nil
+		}
+		for i := range t.NumField() {
+			if sf := t.Field(i); !hiddenField(sf) {
+				if err := checkHidden(sf.Type, seen); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasHidden reports whether a value of type t can hold a hidden field.
+func hasHidden(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasHidden(t.Elem(), seen)
+	case reflect.Struct:
+		if !plainStruct(t) {
+			return false
+		}
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			if hiddenField(sf) || hasHidden(sf.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clearVisible zeroes the parts of v that encoding/json decodes into,
+// going into plain structs so their hidden fields stay. fresh is the same
+// place decoded from scratch: where it holds a pointer to a value with
+// hidden fields, v is pointed at a cleared copy of its own value, which
+// the decoder then fills in, so the caller's value is never written
+// through.
+func clearVisible(v, fresh reflect.Value) {
+	t := v.Type()
+	if t.Kind() == reflect.Pointer && !v.IsNil() && !fresh.IsNil() &&
+		hasHidden(t.Elem(), map[reflect.Type]bool{}) {
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v.Elem())
+		clearVisible(p.Elem(), fresh.Elem())
+		v.Set(p)
+		return
+	}
+	if !plainStruct(t) {
+		v.SetZero()
+		return
+	}
+	for i := range t.NumField() {
+		if hiddenField(t.Field(i)) {
+			continue
+		}
+		if f := v.Field(i); f.CanSet() {
+			clearVisible(f, fresh.Field(i))
+		}
+	}
+}
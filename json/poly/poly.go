@@ -0,0 +1,285 @@
+// Package poly encodes interface values as JSON. encoding/json can write
+// a Shape but cannot read one back: nothing in {"radius": 5} says it was
+// a Circle. poly writes the concrete type's registered name next to its
+// fields, and reads it to pick the type again.
+//
+//	poly.Register[Shape]("circle", Circle{})
+//	poly.Register[Shape]("square", Square{})
+//
+//	data, err := poly.Marshal[Shape](Circle{Radius: 5}) // {"type":"circle","radius":5}
+//	s, err := poly.Unmarshal[Shape](data)               // Circle{Radius: 5}
+//
+// Inside structs and slices, where encoding/json does the walking, use
+// Value and Slice:
+//
+//	type Drawing struct {
+//		Shapes    poly.Slice[Shape] `json:"shapes"`
+//		Highlight poly.Value[Shape] `json:"highlight"`
+//	}
+//
+// Names are registered per interface, once, like gob.Register; a
+// concrete type can implement several interfaces under different names.
+package poly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TypeField is the member that holds the name of the concrete type.
+const TypeField = "type"
+
+var (
+	// ErrNoType means an object has no TypeField member.
+	ErrNoType = errors.New("poly: no " + TypeField + " member")
+	// ErrNotRegistered means a value's concrete type has no name.
+	ErrNotRegistered = errors.New("poly: type not registered")
+)
+
+// UnknownTypeError is a name that no type was registered under.
+type UnknownTypeError struct {
+	Interface reflect.Type
+	Name      string
+	Known     []string // the registered names, sorted
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("poly: unknown %v type %q (known: %s)", e.Interface, e.Name, strings.Join(e.Known, ", "))
+}
+
+// registry holds the names of the implementations of one interface.
+type registry struct {
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+var (
+	mu         sync.RWMutex
+	registries = make(map[reflect.Type]*registry) // by interface type
+)
+
+// Register makes example's concrete type the one named name among the
+// implementations of I. Register a pointer, like (*Circle)(nil), to get
+// pointers back. Like http.Handle, it panics on a name or type that is
+// already registered, since that is a mistake in the program.
+func Register[I any](name string, example I) {
+	iface := reflect.TypeFor[I]()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("poly: Register[%v]: not an interface type", iface))
+	}
+	t := reflect.TypeOf(example)
+	if t == nil {
+		panic(fmt.Sprintf("poly: Register[%v](%q): nil example", iface, name))
+	}
+	if hasMember(t, TypeField) {
+		panic(fmt.Sprintf("poly: Register[%v](%q): %v has its own %q member", iface, name, t, TypeField))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	r := registries[iface]
+	if r == nil {
+		r = &registry{byName: make(map[string]reflect.Type), byType: make(map[reflect.Type]string)}
+		registries[iface] = r
+	}
+	if other, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("poly: Register[%v](%q): name already used by %v", iface, name, other))
+	}
+	if other, ok := r.byType[t]; ok {
+		panic(fmt.Sprintf("poly: Register[%v](%q): %v already registered as %q", iface, name, t, other))
+	}
+	r.byName[name] = t
+	r.byType[t] = name
+}
+
+// hasMember reports whether values of t encode with a member named name.
+// It looks at direct fields only, which covers the usual mistake.
+func hasMember(t reflect.Type, name string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if tag == name || tag == "" && strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func nameOf(iface, t reflect.Type) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if r := registries[iface]; r != nil {
+		name, ok := r.byType[t]
+		return name, ok
+	}
+	return "", false
+}
+
+// Marshal encodes v, which must encode as a JSON object, with the name of
+// its concrete type as the first member. A nil v is null.
+func Marshal[I any](v I) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return []byte("null"), nil
+	}
+	iface := reflect.TypeFor[I]()
+	name, ok := nameOf(iface, t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v as %v", ErrNotRegistered, t, iface)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("poly: %v does not encode as a JSON object", t)
+	}
+	quoted, _ := json.Marshal(name)
+	out := make([]byte, 0, len(data)+len(TypeField)+len(quoted)+4)
+	out = append(out, `{"`+TypeField+`":`...)
+	out = append(out, quoted...)
+	if data[1] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...), nil
+}
+
+// Unmarshal decodes an object written by Marshal into a new value of the
+// type its TypeField names. null decodes to a nil I.
+func Unmarshal[I any](data []byte) (I, error) {
+	var zero I
+	iface := reflect.TypeFor[I]()
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return zero, nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return zero, fmt.Errorf("poly: %v: %w", iface, err)
+	}
+	raw, ok := members[TypeField]
+	if !ok {
+		return zero, fmt.Errorf("%w in %v object", ErrNoType, iface)
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return zero, fmt.Errorf("poly: %v: %s is not a type name", iface, raw)
+	}
+
+	t, err := typeNamed(iface, name)
+	if err != nil {
+		return zero, err
+	}
+	// The TypeField member is left in: the type has no field for it, so
+	// json.Unmarshal passes over it.
+	elem := t
+	if t.Kind() == reflect.Pointer {
+		elem = t.Elem()
+	}
+	ptr := reflect.New(elem)
+	err = json.Unmarshal(data, ptr.Interface())
+	if err != nil {
+		return zero, fmt.Errorf("poly: %v %q: %w", iface, name, err)
+	}
+	if t.Kind() == reflect.Pointer {
+		return ptr.Interface().(I), nil
+	}
+	return ptr.Elem().Interface().(I), nil
+}
+
+func typeNamed(iface reflect.Type, name string) (reflect.Type, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r := registries[iface]
+	if r != nil {
+		if t, ok := r.byName[name]; ok {
+			return t, nil
+		}
+	}
+	var known []string
+	if r != nil {
+		for n := range r.byName {
+			known = append(known, n)
+		}
+		slices.Sort(known)
+	}
+	return nil, &UnknownTypeError{Interface: iface, Name: name, Known: known}
+}
+
+// --- FIELDS ---
+
+// Value holds one I in a struct that encoding/json encodes, like
+// Highlight poly.Value[Shape]. The zero Value is null.
+type Value[I any] struct {
+	V I
+}
+
+func (v Value[I]) MarshalJSON() ([]byte, error) { return Marshal(v.V) }
+
+func (v *Value[I]) UnmarshalJSON(data []byte) error {
+	i, err := Unmarshal[I](data)
+	if err != nil {
+		return err
+	}
+	v.V = i
+	return nil
+}
+
+// Slice is a []I that encoding/json can encode, each element with its
+// type name. A nil element is null.
+type Slice[I any] []I
+
+func (s Slice[I]) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	b := []byte{'['}
+	for i, v := range s {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		data, err := Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		b = append(b, data...)
+	}
+	return append(b, ']'), nil
+}
+
+func (s *Slice[I]) UnmarshalJSON(data []byte) error {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	if raws == nil {
+		*s = nil
+		return nil
+	}
+	out := make(Slice[I], len(raws))
+	for i, raw := range raws {
+		v, err := Unmarshal[I](raw)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		out[i] = v
+	}
+	*s = out
+	return nil
+}
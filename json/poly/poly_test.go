@@ -0,0 +1,186 @@
+package poly
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type shape interface{ Area() float64 }
+
+type circle struct {
+	R float64 `json:"r"`
+}
+
+func (c circle) Area() float64 { return 3 * c.R * c.R }
+
+type rect struct {
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+func (r *rect) Area() float64 { return r.W * r.H }
+
+// group nests shapes inside a shape.
+type group struct {
+	Name  string       `json:"name"`
+	Items Slice[shape] `json:"items"`
+}
+
+func (g group) Area() float64 {
+	total := 0.0
+	for _, s := range g.Items {
+		total += s.Area()
+	}
+	return total
+}
+
+type unregistered struct{}
+
+func (unregistered) Area() float64 { return 0 }
+
+func init() {
+	Register[shape]("circle", circle{})
+	Register[shape]("rect", (*rect)(nil))
+	Register[shape]("group", group{})
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	data, err := Marshal[shape](circle{R: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"circle","r":2}`; string(data) != want {
+		t.Errorf("Marshal = %s; want %s", data, want)
+	}
+	s, err := Unmarshal[shape](data)
+	if err != nil || s != (circle{R: 2}) {
+		t.Errorf("Unmarshal = %#v, %v", s, err)
+	}
+
+	// Registered as a pointer, it comes back as one.
+	data, _ = Marshal[shape](&rect{W: 2, H: 3})
+	s, err = Unmarshal[shape](data)
+	if r, ok := s.(*rect); !ok || *r != (rect{2, 3}) || err != nil {
+		t.Errorf("Unmarshal(%s) = %#v, %v", data, s, err)
+	}
+
+	// The type member may come anywhere.
+	s, err = Unmarshal[shape]([]byte(`{"r": 1, "type": "circle"}`))
+	if err != nil || s != (circle{R: 1}) {
+		t.Errorf("type last: %#v, %v", s, err)
+	}
+
+	if data, _ := Marshal[shape](nil); string(data) != "null" {
+		t.Errorf("Marshal(nil) = %s", data)
+	}
+	if s, err := Unmarshal[shape]([]byte("null")); s != nil || err != nil {
+		t.Errorf("Unmarshal(null) = %v, %v", s, err)
+	}
+}
+
+type drawing struct {
+	Title     string       `json:"title"`
+	Shapes    Slice[shape] `json:"shapes"`
+	Highlight Value[shape] `json:"highlight"`
+	Empty     Value[shape] `json:"empty"`
+}
+
+func TestNested(t *testing.T) {
+	in := drawing{
+		Title: "d",
+		Shapes: Slice[shape]{
+			circle{R: 1},
+			&rect{W: 1, H: 2},
+			group{Name: "g", Items: Slice[shape]{circle{R: 3}, group{Name: "inner", Items: Slice[shape]{}}}},
+			nil,
+		},
+		Highlight: Value[shape]{&rect{W: 5, H: 5}},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"title":"d","shapes":[{"type":"circle","r":1},{"type":"rect","w":1,"h":2},` +
+		`{"type":"group","name":"g","items":[{"type":"circle","r":3},{"type":"group","name":"inner","items":[]}]},null],` +
+		`"highlight":{"type":"rect","w":5,"h":5},"empty":null}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var out drawing
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip:\n got %#v\nwant %#v", out, in)
+	}
+	if out.Shapes[2].Area() != 27 {
+		t.Errorf("nested group area = %v", out.Shapes[2].Area())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Unmarshal[shape]([]byte(`{"type":"hexagon","side":1}`))
+	var unknown *UnknownTypeError
+	if !errors.As(err, &unknown) || unknown.Name != "hexagon" {
+		t.Fatalf("err = %v; want an UnknownTypeError", err)
+	}
+	if got := err.Error(); !strings.Contains(got, `"hexagon"`) || !strings.Contains(got, "known: circle, group, rect") {
+		t.Errorf("message %q does not say what is known", got)
+	}
+
+	if _, err := Unmarshal[shape]([]byte(`{"r":1}`)); !errors.Is(err, ErrNoType) {
+		t.Errorf("no type: err = %v", err)
+	}
+	if _, err := Unmarshal[shape]([]byte(`{"type":7}`)); err == nil {
+		t.Error("numeric type name accepted")
+	}
+	if _, err := Unmarshal[shape]([]byte(`[1]`)); err == nil {
+		t.Error("array accepted")
+	}
+	if _, err := Unmarshal[shape]([]byte(`{"type":"circle","r":"big"}`)); err == nil {
+		t.Error("bad field accepted")
+	}
+	if _, err := Marshal[shape](unregistered{}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("unregistered: err = %v", err)
+	}
+	// Interfaces have separate registries.
+	if _, err := Unmarshal[interface{ Perimeter() float64 }]([]byte(`{"type":"circle"}`)); !errors.As(err, &unknown) {
+		t.Errorf("other interface: err = %v", err)
+	}
+
+	// Errors deep inside say where they are.
+	var d drawing
+	err = json.Unmarshal([]byte(`{"shapes":[{"type":"circle"},{"type":"group","items":[{"type":"blob"}]}]}`), &d)
+	if !errors.As(err, &unknown) || !strings.Contains(err.Error(), "element 1: ") || !strings.Contains(err.Error(), "element 0: ") {
+		t.Errorf("nested error = %v", err)
+	}
+	if _, err := json.Marshal(drawing{Shapes: Slice[shape]{unregistered{}}}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("nested marshal error = %v", err)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	type withType struct {
+		Type string `json:"type"`
+	}
+	for name, f := range map[string]func(){
+		"name twice":   func() { Register[shape]("circle", &rect{}) },
+		"type twice":   func() { Register[shape]("round", circle{}) },
+		"not an iface": func() { Register[circle]("c", circle{}) },
+		"nil example":  func() { Register[shape]("nil", nil) },
+		"own type":     func() { Register[any]("t", withType{}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
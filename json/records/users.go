@@ -0,0 +1,58 @@
+// Package records describes the documents this lesson keeps on disk, and
+// how each kind has changed over time.
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"jsonexamples/migrate"
+	"jsonexamples/money"
+)
+
+// --- USER DOCUMENTS ---
+//
+// Version 1 is what the User struct wrote before Money: the balance was a
+// float64 tagged json:"balance,string", so it is a string in the document,
+// and there was no "_v":
+//
+//	{"id": 1, "name": "Alice", "active": true, "balance": "1050.75",
+//	 "address": {"street": "123 Go Lane", "city": "Gopher City"}}
+//
+// Version 2, the current one, keeps the balance as exact money:
+//
+//	{"_v": 2, ..., "balance": {"amount": "1050.75", "currency": "USD"}, ...}
+
+// Users upgrades user documents to the current version.
+var Users = migrate.New(
+	balanceToMoney,
+)
+
+// balanceToMoney turns the old float balance, in dollars, into Money,
+// rounded to whole cents. The balance was written as a string; a bare
+// number, as hand-written files have, is taken too.
+func balanceToMoney(doc map[string]any) error {
+	raw, ok := doc["balance"]
+	if !ok {
+		return nil
+	}
+	var text string
+	switch v := raw.(type) {
+	case string:
+		text = v
+	case json.Number:
+		text = v.String()
+	default:
+		return fmt.Errorf("balance %v is not a number", raw)
+	}
+	amount, err := money.ParseDecimal(text)
+	if err != nil {
+		return fmt.Errorf("balance: %w", err)
+	}
+	m := money.NewRounded(amount, money.USD, money.HalfEven)
+	doc["balance"] = map[string]any{
+		"amount":   m.Amount().String(),
+		"currency": m.Currency().Code(),
+	}
+	return nil
+}
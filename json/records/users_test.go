@@ -0,0 +1,63 @@
+package records
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// legacyUser is the User struct of the lesson before balances were Money.
+type legacyUser struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Email    string  `json:"email,omitempty"`
+	Password string  `json:"-"`
+	Active   bool    `json:"active"`
+	Balance  float64 `json:"balance,string"`
+	Address  struct {
+		Street string `json:"street"`
+		City   string `json:"city"`
+	} `json:"address"`
+}
+
+func TestUsersUpgradeLegacyDocument(t *testing.T) {
+	old := legacyUser{ID: 1, Name: "Alice", Password: "secret123", Active: true, Balance: 1050.75}
+	old.Address.Street, old.Address.City = "123 Go Lane", "Gopher City"
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, changed, err := Users.UpgradeJSON(data)
+	want := `{"_v":2,"active":true,"address":{"city":"Gopher City","street":"123 Go Lane"},` +
+		`"balance":{"amount":"1050.75","currency":"USD"},"id":1,"name":"Alice"}`
+	if err != nil || !changed || string(got) != want {
+		t.Errorf("%s:\ngot  %s, %v, %v\nwant %s", data, got, changed, err, want)
+	}
+}
+
+func TestUsersUpgrade(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{"id":1,"balance":"10.555"}`, `{"_v":2,"balance":{"amount":"10.56","currency":"USD"},"id":1}`},
+		{`{"id":2,"balance":3}`, `{"_v":2,"balance":{"amount":"3.00","currency":"USD"},"id":2}`},
+		{`{"id":3,"name":"Cy"}`, `{"_v":2,"id":3,"name":"Cy"}`},
+	}
+	for _, tt := range tests {
+		got, changed, err := Users.UpgradeJSON([]byte(tt.in))
+		if err != nil || !changed || string(got) != tt.want {
+			t.Errorf("%s:\ngot  %s, %v, %v\nwant %s", tt.in, got, changed, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`{"balance":"ten"}`, `{"balance":true}`} {
+		if _, _, err := Users.UpgradeJSON([]byte(bad)); err == nil {
+			t.Errorf("%s upgraded without an error", bad)
+		}
+	}
+	var v map[string]any
+	if err := Users.Unmarshal([]byte(`{"balance":"1"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(v["balance"]); string(b) != `{"amount":"1.00","currency":"USD"}` {
+		t.Errorf("balance = %s", b)
+	}
+}
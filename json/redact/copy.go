@@ -0,0 +1,101 @@
+package redact
+
+import "reflect"
+
+// Copy returns a deep copy of v with the tagged fields redacted by
+// Default. A masked or hashed field that cannot hold the redacted text,
+// like an int, is zeroed, and so is a dropped one.
+func Copy[T any](v T) T {
+	return CopyWith(Default, v)
+}
+
+// CopyWith is Copy with the settings of r.
+func CopyWith[T any](r *Redactor, v T) T {
+	c := copier{r: r, seen: map[pointer]reflect.Value{}}
+	out := c.copy(reflect.ValueOf(&v).Elem(), rule{})
+	return out.Interface().(T)
+}
+
+type copier struct {
+	r    *Redactor
+	seen map[pointer]reflect.Value // pointers already copied, for cycles
+}
+
+type pointer struct {
+	t    reflect.Type
+	addr uintptr
+}
+
+func (c *copier) copy(v reflect.Value, ru rule) reflect.Value {
+	t := v.Type()
+	if ru.action == drop {
+		return reflect.Zero(t)
+	}
+	if isLeaf(t) {
+		switch {
+		case ru.action == keep:
+			return v
+		case t.Kind() == reflect.String:
+			out := reflect.New(t).Elem()
+			out.SetString(c.r.apply(ru, v.String()))
+			return out
+		}
+		return reflect.Zero(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := pointer{t, v.Pointer()}
+		if done, ok := c.seen[key]; ok {
+			return done
+		}
+		out := reflect.New(t.Elem())
+		c.seen[key] = out
+		out.Elem().Set(c.copy(v.Elem(), ru))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t).Elem()
+		out.Set(c.copy(v.Elem(), ru))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := range v.Len() {
+			out.Index(i).Set(c.copy(v.Index(i), ru))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(t).Elem()
+		for i := range v.Len() {
+			out.Index(i).Set(c.copy(v.Index(i), ru))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(t, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			out.SetMapIndex(it.Key(), c.copy(it.Value(), ru))
+		}
+		return out
+	default: // struct
+		out := reflect.New(t).Elem()
+		for _, f := range fields(t) {
+			fr := f.rule
+			if fr.action == keep {
+				fr = ru // a tag on the struct's field covers everything inside
+			}
+			out.Field(f.index).Set(c.copy(v.Field(f.index), fr))
+		}
+		return out
+	}
+}
@@ -0,0 +1,193 @@
+// Package redact makes values safe to log or print by hiding the fields
+// marked with a redact tag:
+//
+//	type User struct {
+//		Name     string
+//		Email    string `redact:"mask=email"` // b***@example.com
+//		Phone    string `redact:"mask=last4"` // ****4321
+//		Token    string `redact:"hash"`       // sha256:1f2e3d4c5b6a7988
+//		Password string `redact:"drop"`       // left out
+//	}
+//
+// Copy returns a redacted deep copy. Value wraps a value so that slog
+// (through LogValue) and fmt (through Format) only ever see the redacted
+// form. Nested structs, pointers, slices, arrays, maps and interfaces are
+// followed; a tag on a field holding several values applies to each.
+// Map keys and unexported fields are not redacted: keys are shown as they
+// are, and unexported fields are left out.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MaskFunc turns a value into its masked form.
+type MaskFunc func(string) string
+
+// Redactor holds the settings of redaction. The zero value is ready to
+// use; Default is the one the package functions use.
+type Redactor struct {
+	// HashKey keys the HMAC behind "hash". Without a key a plain SHA-256
+	// is used, which anyone can recompute for guessable values such as
+	// emails, so set one in production.
+	HashKey []byte
+	// Masks adds named formats for `redact:"mask=name"`, or replaces the
+	// built-in ones: "email", "last4" and "" (a bare "mask").
+	Masks map[string]MaskFunc
+}
+
+// Default is used by Copy and Value.
+var Default = &Redactor{}
+
+var builtinMasks = map[string]MaskFunc{
+	"":      Fixed("****"),
+	"email": MaskEmail,
+	"last4": KeepLast(4),
+}
+
+// Fixed masks every value as s, hiding even its length.
+func Fixed(s string) MaskFunc {
+	return func(string) string { return s }
+}
+
+// KeepLast shows the last n characters, like ****4321 for card numbers.
+// Values of n characters or fewer are fully masked.
+func KeepLast(n int) MaskFunc {
+	return func(s string) string {
+		count := utf8.RuneCountInString(s)
+		if count <= n {
+			return "****"
+		}
+		runes := []rune(s)
+		return "****" + string(runes[count-n:])
+	}
+}
+
+// MaskEmail keeps the first letter and the domain: b***@example.com.
+func MaskEmail(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return "****"
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + "***@" + domain
+}
+
+// --- TAGS ---
+
+type action int
+
+const (
+	keep action = iota
+	mask
+	hash
+	drop
+)
+
+type rule struct {
+	action action
+	mask   string // name of the mask format
+}
+
+// parseTag reads `redact:"mask"`, `redact:"mask=email"`, `redact:"hash"`
+// or `redact:"drop"`.
+func parseTag(tag string) (rule, error) {
+	name, arg, _ := strings.Cut(tag, "=")
+	switch name {
+	case "":
+		return rule{}, nil
+	case "mask":
+		return rule{action: mask, mask: arg}, nil
+	case "hash":
+		return rule{action: hash}, nil
+	case "drop":
+		return rule{action: drop}, nil
+	}
+	return rule{}, fmt.Errorf("redact: unknown tag %q", tag)
+}
+
+type fieldInfo struct {
+	index int
+	name  string // Go name, for fmt
+	key   string // json name when there is one, for slog
+	rule  rule
+}
+
+var fieldCache sync.Map // reflect.Type -> []fieldInfo
+
+// fields returns the exported fields of struct type t. Unexported fields
+// are never shown or copied, since nothing can say they are safe.
+// A bad tag panics, like a bad regexp in MustCompile: it is a bug in the
+// type, not in the data.
+func fields(t reflect.Type) []fieldInfo {
+	if v, ok := fieldCache.Load(t); ok {
+		return v.([]fieldInfo)
+	}
+	var out []fieldInfo
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		r, err := parseTag(sf.Tag.Get("redact"))
+		if err != nil {
+			panic(fmt.Sprintf("%v on %s.%s", err, t, sf.Name))
+		}
+		key, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if key == "" || key == "-" {
+			key = sf.Name
+		}
+		out = append(out, fieldInfo{index: i, name: sf.Name, key: key, rule: r})
+	}
+	fieldCache.Store(t, out)
+	return out
+}
+
+// --- REDACTING ONE VALUE ---
+
+func (r *Redactor) apply(ru rule, s string) string {
+	switch ru.action {
+	case hash:
+		var sum []byte
+		if len(r.HashKey) > 0 {
+			m := hmac.New(sha256.New, r.HashKey)
+			m.Write([]byte(s))
+			sum = m.Sum(nil)
+		} else {
+			h := sha256.Sum256([]byte(s))
+			sum = h[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case mask:
+		if fn, ok := r.Masks[ru.mask]; ok {
+			return fn(s)
+		}
+		if fn, ok := builtinMasks[ru.mask]; ok {
+			return fn(s)
+		}
+		return "****" // an unknown format must not leak the value
+	}
+	return s
+}
+
+var (
+	stringerType = reflect.TypeFor[fmt.Stringer]()
+	errorType    = reflect.TypeFor[error]()
+)
+
+// isLeaf reports whether v is shown as a whole: basic kinds, and types
+// that say how to print themselves, like time.Time.
+func isLeaf(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Array, reflect.Map:
+		return t.Implements(stringerType) || t.Implements(errorType)
+	}
+	return true
+}
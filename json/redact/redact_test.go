@@ -0,0 +1,199 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `json:"street" redact:"mask"`
+	City   string `json:"city"`
+}
+
+type user struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email" redact:"mask=email"`
+	Card     string            `json:"card" redact:"mask=last4"`
+	Token    string            `json:"-" redact:"hash"`
+	Password string            `json:"-" redact:"drop"`
+	PIN      int               `json:"pin" redact:"mask"`
+	Address  *address          `json:"address"`
+	Phones   []string          `json:"phones" redact:"mask=last4"`
+	Notes    map[string]string `json:"notes" redact:"mask"`
+	Friends  []user            `json:"friends,omitempty"`
+	Joined   time.Time         `json:"joined"`
+	Secret   any               `json:"secret" redact:"drop"`
+	internal string
+}
+
+func sample() user {
+	return user{
+		ID: 1, Name: "Bob", Email: "bob@example.com", Card: "4111111111111234",
+		Token: "tok-123", Password: "hunter2", PIN: 4321,
+		Address: &address{Street: "456 Gopher Rd", City: "GoTown"},
+		Phones:  []string{"+55 81 99999-1234"},
+		Notes:   map[string]string{"k": "private"},
+		Friends: []user{{ID: 2, Name: "Ann", Email: "ann@example.com", Password: "pw"}},
+		Joined:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Secret:  "classified", internal: "x",
+	}
+}
+
+func TestCopy(t *testing.T) {
+	orig := sample()
+	c := Copy(orig)
+	if c.Email != "b***@example.com" || c.Card != "****1234" || c.Password != "" || c.PIN != 0 {
+		t.Errorf("copy = %+v", c)
+	}
+	if !strings.HasPrefix(c.Token, "sha256:") || len(c.Token) != len("sha256:")+16 {
+		t.Errorf("token = %q", c.Token)
+	}
+	if c.Address.Street != "****" || c.Address.City != "GoTown" || c.Address == orig.Address {
+		t.Errorf("address = %+v", c.Address)
+	}
+	if c.Phones[0] != "****1234" || c.Notes["k"] != "****" || c.Secret != nil || c.internal != "" {
+		t.Errorf("copy = %+v", c)
+	}
+	if c.Friends[0].Email != "a***@example.com" || c.Friends[0].Password != "" {
+		t.Errorf("nested friend = %+v", c.Friends[0])
+	}
+	if !c.Joined.Equal(orig.Joined) || c.Name != "Bob" {
+		t.Errorf("kept fields changed: %+v", c)
+	}
+	// The original is untouched.
+	if orig.Password != "hunter2" || orig.Address.Street != "456 Gopher Rd" || orig.Phones[0] != "+55 81 99999-1234" {
+		t.Errorf("original changed: %+v", orig)
+	}
+}
+
+func TestHash(t *testing.T) {
+	u := user{Token: "tok-123"}
+	a, b := Copy(u).Token, Copy(u).Token
+	if a != b {
+		t.Errorf("hash not stable: %s %s", a, b)
+	}
+	keyed := CopyWith(&Redactor{HashKey: []byte("k")}, u).Token
+	if keyed == a {
+		t.Error("HashKey did not change the hash")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	u := sample()
+	u.Friends = nil
+	got := fmt.Sprintf("%+v", Value(u))
+	for _, leak := range []string{"hunter2", "bob@example.com", "4111", "tok-123", "private", "456 Gopher", "classified", "4321"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("%%+v leaks %q: %s", leak, got)
+		}
+	}
+	if strings.Contains(got, "Password") || !strings.Contains(got, "Email:b***@example.com") {
+		t.Errorf("%%+v = %s", got)
+	}
+	want := "{1 Bob b***@example.com ****1234"
+	if got := fmt.Sprintf("%v", Value(u)); !strings.HasPrefix(got, want) {
+		t.Errorf("%%v = %s; want prefix %s", got, want)
+	}
+	if got := fmt.Sprint(Value(&address{Street: "x", City: "y"})); got != "&{**** y}" {
+		t.Errorf("pointer = %s", got)
+	}
+	if got := Value([]address{{"a", "b"}}).String(); got != "[{**** b}]" {
+		t.Errorf("slice = %s", got)
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	log.Info("login", "user", Value(sample()))
+
+	var entry struct{ User map[string]any }
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	u := entry.User
+	if u["email"] != "b***@example.com" || u["pin"] != "****" || u["id"] != 1.0 {
+		t.Errorf("user = %v", u)
+	}
+	if _, ok := u["Password"]; ok {
+		t.Error("dropped field logged")
+	}
+	if _, ok := u["secret"]; ok {
+		t.Error("dropped field logged")
+	}
+	if !strings.HasPrefix(u["Token"].(string), "sha256:") {
+		t.Errorf("token = %v", u["Token"])
+	}
+	if u["address"].(map[string]any)["street"] != "****" {
+		t.Errorf("address = %v", u["address"])
+	}
+	friend := u["friends"].([]any)[0].(map[string]any)
+	if friend["email"] != "a***@example.com" || friend["Password"] != nil {
+		t.Errorf("friend = %v", friend)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "private") {
+		t.Errorf("log leaks: %s", buf.String())
+	}
+}
+
+func TestMasks(t *testing.T) {
+	r := &Redactor{Masks: map[string]MaskFunc{
+		"":      Fixed("[redacted]"),
+		"email": func(string) string { return "someone@…" },
+		"name":  KeepLast(2),
+	}}
+	type person struct {
+		A string `redact:"mask"`
+		B string `redact:"mask=email"`
+		C string `redact:"mask=name"`
+		D string `redact:"mask=unknown"`
+	}
+	got := CopyWith(r, person{"a", "b@c", "Joãozinho", "d"})
+	if got != (person{"[redacted]", "someone@…", "****ho", "****"}) {
+		t.Errorf("got %+v", got)
+	}
+	for in, want := range map[string]string{"bob@x.io": "b***@x.io", "élan@x": "é***@x", "nope": "****", "@x": "****"} {
+		if got := MaskEmail(in); got != want {
+			t.Errorf("MaskEmail(%q) = %q; want %q", in, got, want)
+		}
+	}
+	if got := KeepLast(4)("123"); got != "****" {
+		t.Errorf("KeepLast on a short value = %q", got)
+	}
+}
+
+type node struct {
+	Name string `redact:"mask"`
+	Next *node
+}
+
+func TestCycles(t *testing.T) {
+	n := &node{Name: "a"}
+	n.Next = n
+	c := Copy(n)
+	if c.Next != c || c.Name != "****" {
+		t.Errorf("cyclic copy = %+v", c)
+	}
+	if s := fmt.Sprint(Value(n)); !strings.Contains(s, "...") {
+		t.Errorf("cyclic print = %s", s)
+	}
+	slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)).Info("x", "n", Value(n))
+}
+
+func TestBadTag(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want a panic for an unknown tag")
+		}
+	}()
+	type bad struct {
+		X string `redact:"blur"`
+	}
+	Copy(bad{})
+}
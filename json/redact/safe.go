@@ -0,0 +1,224 @@
+package redact
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// maxDepth stops printing cyclic or very deep values.
+const maxDepth = 16
+
+// Safe wraps a value so that logging or printing it shows the redacted
+// form. It implements slog.LogValuer, fmt.Formatter and fmt.Stringer.
+type Safe struct {
+	v any
+	r *Redactor
+}
+
+// Value wraps v for logging with the Default settings:
+//
+//	slog.Info("login", "user", redact.Value(u))
+//	fmt.Printf("%+v\n", redact.Value(u))
+func Value(v any) Safe { return Default.Value(v) }
+
+// Value wraps v for logging with the settings of r.
+func (r *Redactor) Value(v any) Safe { return Safe{v: v, r: r} }
+
+// LogValue turns a struct into a group of its fields, with the field
+// names of its json tags; dropped fields are left out. Slices and maps
+// are logged as their redacted plain form.
+func (s Safe) LogValue() slog.Value {
+	return s.r.logValue(reflect.ValueOf(s.v), rule{}, 0)
+}
+
+// Format prints the redacted value the way fmt prints structs: {a b}
+// with %v, {Name:a Email:b} with %+v. Dropped fields are left out.
+func (s Safe) Format(f fmt.State, verb rune) {
+	var b strings.Builder
+	p := printer{r: s.r, b: &b, names: f.Flag('+') || f.Flag('#')}
+	p.print(reflect.ValueOf(s.v), rule{}, 0)
+	switch verb {
+	case 'q':
+		fmt.Fprintf(f, "%q", b.String())
+	default:
+		f.Write([]byte(b.String()))
+	}
+}
+
+func (s Safe) String() string { return fmt.Sprint(s) }
+
+// --- SLOG ---
+
+func (r *Redactor) logValue(v reflect.Value, ru rule, depth int) slog.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !isLeaf(v.Type()) {
+		if v.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return slog.AnyValue(nil)
+	}
+	if v.Kind() != reflect.Struct || isLeaf(v.Type()) || depth >= maxDepth {
+		return slog.AnyValue(r.plain(v, ru, depth))
+	}
+	var attrs []slog.Attr
+	for _, f := range fields(v.Type()) {
+		fr := f.rule
+		if fr.action == keep {
+			fr = ru
+		}
+		if fr.action == drop {
+			continue
+		}
+		attrs = append(attrs, slog.Attr{Key: f.key, Value: r.logValue(v.Field(f.index), fr, depth+1)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// plain returns the redacted value built from maps, slices and leaves,
+// which every handler knows how to write.
+func (r *Redactor) plain(v reflect.Value, ru rule, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if isLeaf(t) {
+		if ru.action == keep {
+			return v.Interface()
+		}
+		return r.apply(ru, fmt.Sprint(v.Interface()))
+	}
+	if depth >= maxDepth {
+		return "..."
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return r.plain(v.Elem(), ru, depth+1)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = r.plain(v.Index(i), ru, depth+1)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			out[fmt.Sprint(it.Key().Interface())] = r.plain(it.Value(), ru, depth+1)
+		}
+		return out
+	default: // struct
+		out := map[string]any{}
+		for _, f := range fields(t) {
+			fr := f.rule
+			if fr.action == keep {
+				fr = ru
+			}
+			if fr.action != drop {
+				out[f.key] = r.plain(v.Field(f.index), fr, depth+1)
+			}
+		}
+		return out
+	}
+}
+
+// --- FMT ---
+
+type printer struct {
+	r     *Redactor
+	b     *strings.Builder
+	names bool // field names, as with %+v
+}
+
+func (p *printer) print(v reflect.Value, ru rule, depth int) {
+	if !v.IsValid() {
+		p.b.WriteString("<nil>")
+		return
+	}
+	t := v.Type()
+	if isLeaf(t) {
+		s := fmt.Sprint(v.Interface())
+		if ru.action != keep {
+			s = p.r.apply(ru, s)
+		}
+		p.b.WriteString(s)
+		return
+	}
+	if depth >= maxDepth {
+		p.b.WriteString("...")
+		return
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			p.b.WriteString("<nil>")
+			return
+		}
+		p.b.WriteByte('&')
+		p.print(v.Elem(), ru, depth+1)
+	case reflect.Interface:
+		if v.IsNil() {
+			p.b.WriteString("<nil>")
+			return
+		}
+		p.print(v.Elem(), ru, depth+1)
+	case reflect.Slice, reflect.Array:
+		p.b.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				p.b.WriteByte(' ')
+			}
+			p.print(v.Index(i), ru, depth+1)
+		}
+		p.b.WriteByte(']')
+	case reflect.Map:
+		// Sorted by the printed key, as fmt does.
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+		p.b.WriteString("map[")
+		for i, k := range keys {
+			if i > 0 {
+				p.b.WriteByte(' ')
+			}
+			fmt.Fprint(p.b, k.Interface())
+			p.b.WriteByte(':')
+			p.print(v.MapIndex(k), ru, depth+1)
+		}
+		p.b.WriteByte(']')
+	default: // struct
+		p.b.WriteByte('{')
+		first := true
+		for _, f := range fields(t) {
+			fr := f.rule
+			if fr.action == keep {
+				fr = ru
+			}
+			if fr.action == drop {
+				continue
+			}
+			if !first {
+				p.b.WriteByte(' ')
+			}
+			first = false
+			if p.names {
+				p.b.WriteString(f.name + ":")
+			}
+			p.print(v.Field(f.index), fr, depth+1)
+		}
+		p.b.WriteByte('}')
+	}
+}
@@ -0,0 +1,323 @@
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- GENERATION ---
+
+// Generator builds schemas from Go types. The zero value is ready to use.
+type Generator struct {
+	// Types holds fixed schemas for types whose JSON form reflection
+	// cannot see, like types with their own MarshalJSON. time.Time is
+	// known already.
+	Types map[reflect.Type]*Schema
+
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// For returns the schema of T.
+func For[T any]() (*Schema, error) {
+	var g Generator
+	return g.Generate(reflect.TypeFor[T]())
+}
+
+// Generate returns the schema of t. Named struct types go to $defs and
+// are referenced with $ref, which also handles recursive types.
+func (g *Generator) Generate(t reflect.Type) (*Schema, error) {
+	g.defs, g.names = map[string]*Schema{}, map[reflect.Type]string{}
+	s, err := g.schemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	if s.Boolean != nil {
+		s = &Schema{}
+	}
+	s.SchemaURI = Draft
+	if len(g.defs) > 0 {
+		s.Defs = g.defs
+	}
+	return s, nil
+}
+
+func (g *Generator) schemaOf(t reflect.Type) (*Schema, error) {
+	if s, ok := g.Types[t]; ok {
+		return clone(s), nil
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{TypeString}, Format: "date-time"}, nil
+	case t == rawMessageType:
+		return True(), nil
+	case implements(t, jsonMarshalerType):
+		return nil, fmt.Errorf("schema: %s has its own MarshalJSON; add its schema to Generator.Types", t)
+	case implements(t, textMarshalerType):
+		return &Schema{Type: Types{TypeString}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{TypeBoolean}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: Types{TypeInteger}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: Types{TypeInteger}, Minimum: ptr(0.0)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{TypeNumber}}, nil
+	case reflect.String:
+		return &Schema{Type: Types{TypeString}}, nil
+	case reflect.Interface:
+		return True(), nil
+
+	case reflect.Pointer:
+		elem, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(elem), nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice && !implements(t.Elem(), textMarshalerType) {
+			return &Schema{Type: Types{TypeString}, ContentEncoding: "base64"}, nil
+		}
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := &Schema{Type: Types{TypeArray}, Items: items}
+		if t.Kind() == reflect.Array {
+			s.MinItems, s.MaxItems = ptr(t.Len()), ptr(t.Len())
+		}
+		return s, nil
+
+	case reflect.Map:
+		s := &Schema{Type: Types{TypeObject}}
+		switch k := t.Key(); {
+		case k.Kind() == reflect.String:
+		case implements(k, textMarshalerType):
+		case k.Kind() >= reflect.Int && k.Kind() <= reflect.Uintptr:
+			s.PropertyNames = &Schema{Pattern: "^-?[0-9]+$"}
+		default:
+			return nil, fmt.Errorf("schema: unsupported map key type %s", k)
+		}
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s.AdditionalProperties = values
+		return s, nil
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.defName(t)
+			g.names[t] = name
+			g.defs[name] = &Schema{} // placeholder, so recursive references stop here
+			s, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[name] = s
+		}
+		return &Schema{Ref: "#/$defs/" + name}, nil
+	}
+	return nil, fmt.Errorf("schema: unsupported type %s", t)
+}
+
+// defName picks the $defs name of t: its name, or its package path and
+// name when two types share a name.
+func (g *Generator) defName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.defs[name]; !taken {
+		return name
+	}
+	return strings.NewReplacer("/", ".", "[", "_", "]", "_").Replace(t.PkgPath() + "." + t.Name())
+}
+
+func (g *Generator) structSchema(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: Types{TypeObject}, Properties: map[string]*Schema{}, AdditionalProperties: False()}
+	for _, f := range jsonFields(t) {
+		prop, err := g.schemaOf(f.typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t, f.goName, err)
+		}
+		if f.asString {
+			prop = stringEncoded(f.typ)
+		}
+		required := !f.omitEmpty
+		if f.tag != "" {
+			if prop, required, err = applyTag(prop, f.typ, f.tag, required); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t, f.goName, err)
+			}
+		}
+		s.Properties[f.name] = prop
+		if required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	slices.Sort(s.Required)
+	return s, nil
+}
+
+// stringEncoded is the schema of a `json:",string"` field.
+func stringEncoded(t reflect.Type) *Schema {
+	nullablePtr := t.Kind() == reflect.Pointer
+	if nullablePtr {
+		t = t.Elem()
+	}
+	s := &Schema{Type: Types{TypeString}}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Pattern = "^-?[0-9]+$"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.Pattern = "^[0-9]+$"
+	case reflect.Bool:
+		s.Enum = []any{"true", "false"}
+	}
+	if nullablePtr {
+		return nullable(s)
+	}
+	return s
+}
+
+// nullable returns s that also accepts null.
+func nullable(s *Schema) *Schema {
+	switch {
+	case s.Boolean != nil:
+		return s
+	case len(s.Type) > 0 && s.Ref == "" && len(s.Enum) == 0:
+		if !slices.Contains(s.Type, TypeNull) {
+			s.Type = append(slices.Clip(s.Type), TypeNull)
+		}
+		return s
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: Types{TypeNull}}}}
+}
+
+// --- STRUCT FIELDS ---
+
+type field struct {
+	name, goName string
+	typ          reflect.Type
+	omitEmpty    bool // omitempty or omitzero
+	asString     bool
+	tag          string // the schema tag
+	index        []int
+	tagged       bool // the json tag names the field
+}
+
+// jsonFields returns the fields encoding/json writes for t, with the
+// fields of embedded structs promoted by the same rules.
+func jsonFields(t reflect.Type) []field {
+	var all []field
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				et := ft
+				if et.Kind() == reflect.Pointer {
+					et = et.Elem()
+				}
+				if et.Kind() == reflect.Struct {
+					walk(et, append(slices.Clone(index), i))
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			f := field{
+				name:   name,
+				goName: sf.Name,
+				typ:    ft,
+				tag:    sf.Tag.Get("schema"),
+				index:  append(slices.Clone(index), i),
+				tagged: name != "",
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for _, o := range strings.Split(opts, ",") {
+				switch o {
+				case "omitempty", "omitzero":
+					f.omitEmpty = true
+				case "string":
+					f.asString = isStringable(ft)
+				}
+			}
+			all = append(all, f)
+		}
+	}
+	walk(t, nil)
+
+	// A name used at several depths belongs to the shallowest field; at
+	// the same depth to the only tagged one, or to no one.
+	byName := map[string][]field{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var out []field
+	for _, fs := range byName {
+		slices.SortFunc(fs, func(a, b field) int { return len(a.index) - len(b.index) })
+		fs = slices.DeleteFunc(fs, func(f field) bool { return len(f.index) > len(fs[0].index) })
+		if len(fs) > 1 {
+			fs = slices.DeleteFunc(fs, func(f field) bool { return !f.tagged })
+		}
+		if len(fs) == 1 {
+			out = append(out, fs[0])
+		}
+	}
+	slices.SortFunc(out, func(a, b field) int { return slices.Compare(a.index, b.index) })
+	return out
+}
+
+// isStringable reports whether the ",string" option applies to t.
+func isStringable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// clone returns a shallow copy of s, so callers can add keywords.
+func clone(s *Schema) *Schema {
+	c := *s
+	return &c
+}
@@ -0,0 +1,135 @@
+// Package schema generates JSON Schema (draft 2020-12) from Go types and
+// validates decoded JSON against a schema.
+//
+// Generate follows encoding/json: field names and options come from json
+// tags, and a field is required unless it is omitempty or omitzero. A
+// schema tag adds constraints:
+//
+//	type User struct {
+//		Name  string `json:"name" schema:"minLength=1,maxLength=100"`
+//		Email string `json:"email,omitempty" schema:"format=email"`
+//		Role  string `json:"role" schema:"enum=admin|member,default=member"`
+//	}
+//
+// Validate returns every violation, each with the JSON Pointer of the
+// value that broke the rule, instead of stopping at the first one.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Draft is the $schema URI of the schemas this package writes.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema. Only the keywords this package understands are
+// fields; others are dropped when a schema is decoded.
+type Schema struct {
+	// Boolean, when set, makes this the schema true (anything is valid)
+	// or false (nothing is), and every other field is ignored.
+	Boolean *bool `json:"-"`
+
+	SchemaURI   string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Default     any                `json:"default,omitempty"`
+	Deprecated  bool               `json:"deprecated,omitempty"`
+	ReadOnly    bool               `json:"readOnly,omitempty"`
+
+	Type  Types `json:"type,omitempty"`
+	Enum  []any `json:"enum,omitempty"`
+	Const any   `json:"const,omitempty"`
+
+	// Objects.
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+
+	// Arrays.
+	Items       *Schema `json:"items,omitempty"`
+	MinItems    *int    `json:"minItems,omitempty"`
+	MaxItems    *int    `json:"maxItems,omitempty"`
+	UniqueItems bool    `json:"uniqueItems,omitempty"`
+
+	// Strings.
+	MinLength       *int   `json:"minLength,omitempty"`
+	MaxLength       *int   `json:"maxLength,omitempty"`
+	Pattern         string `json:"pattern,omitempty"`
+	Format          string `json:"format,omitempty"`
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	// Numbers.
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+
+	// Combinations.
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+}
+
+// True and False return the boolean schemas.
+func True() *Schema  { t := true; return &Schema{Boolean: &t} }
+func False() *Schema { f := false; return &Schema{Boolean: &f} }
+
+// schemaJSON has the fields of Schema without its methods.
+type schemaJSON Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.Boolean != nil {
+		return json.Marshal(*s.Boolean)
+	}
+	return json.Marshal((*schemaJSON)(s))
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("true")) || bytes.Equal(data, []byte("false")) {
+		b := data[0] == 't'
+		*s = Schema{Boolean: &b}
+		return nil
+	}
+	return json.Unmarshal(data, (*schemaJSON)(s))
+}
+
+// Types is the "type" keyword: one JSON type or several.
+type Types []string
+
+// The JSON types.
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeNumber  = "number"
+	TypeString  = "string"
+	TypeInteger = "integer"
+)
+
+// MarshalJSON writes a single type as a string, several as an array.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
@@ -0,0 +1,274 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `json:"street" schema:"minLength=1"`
+	City   string `json:"city"`
+}
+
+type Base struct {
+	ID      int       `json:"id" schema:"minimum=1"`
+	Created time.Time `json:"created"`
+}
+
+type account struct {
+	Base
+	Name     string          `json:"name" schema:"minLength=2,maxLength=20"`
+	Email    string          `json:"email,omitempty" schema:"format=email"`
+	Password string          `json:"-"`
+	Role     string          `json:"role" schema:"enum=admin|user,default=user"`
+	Age      int             `json:"age,string"`
+	Score    *float64        `json:"score" schema:"exclusiveMinimum=0,multipleOf=0.5"`
+	Tags     []string        `json:"tags,omitempty" schema:"uniqueItems,maxItems=3"`
+	Address  *address        `json:"address"`
+	Limits   map[int]uint    `json:"limits,omitzero"`
+	Extra    map[string]any  `json:"extra,omitempty"`
+	Raw      json.RawMessage `json:"raw,omitempty"`
+	Pair     [2]bool         `json:"pair"`
+	Blob     []byte          `json:"blob,omitempty"`
+	Friends  []*account      `json:"friends,omitempty"`
+	Nick     string          `json:"nick,omitempty" schema:"pattern=^[a-z]{1\\,3}$"`
+	hidden   int
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := For[account]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SchemaURI != Draft || s.Ref != "#/$defs/account" {
+		t.Fatalf("root = %+v", s)
+	}
+	acc := s.Defs["account"]
+	var names []string
+	for name := range acc.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	want := "[address age blob created email extra friends id limits name nick pair raw role score tags]"
+	if got := strings.Join(names, " "); "["+got+"]" != want {
+		t.Errorf("properties = [%s]\nwant %s", got, want)
+	}
+	if got := strings.Join(acc.Required, ","); got != "address,age,created,id,name,pair,role,score" {
+		t.Errorf("required = %s", got)
+	}
+	if b := acc.AdditionalProperties; b == nil || b.Boolean == nil || *b.Boolean {
+		t.Errorf("additionalProperties = %+v; want false", b)
+	}
+
+	p := acc.Properties
+	if p["created"].Format != "date-time" || *p["id"].Minimum != 1 {
+		t.Errorf("promoted fields: created=%+v id=%+v", p["created"], p["id"])
+	}
+	if p["age"].Pattern == "" || !reflect.DeepEqual(p["age"].Type, Types{TypeString}) {
+		t.Errorf(",string field = %+v", p["age"])
+	}
+	if !reflect.DeepEqual(p["role"].Enum, []any{"admin", "user"}) || p["role"].Default != "user" {
+		t.Errorf("role = %+v", p["role"])
+	}
+	if !reflect.DeepEqual(p["score"].Type, Types{TypeNumber, TypeNull}) || *p["score"].MultipleOf != 0.5 {
+		t.Errorf("score = %+v", p["score"])
+	}
+	if len(p["address"].AnyOf) != 2 || p["address"].AnyOf[0].Ref != "#/$defs/address" {
+		t.Errorf("address = %+v", p["address"])
+	}
+	if p["limits"].PropertyNames == nil || *p["limits"].AdditionalProperties.Minimum != 0 {
+		t.Errorf("limits = %+v", p["limits"])
+	}
+	if *p["pair"].MinItems != 2 || *p["pair"].MaxItems != 2 {
+		t.Errorf("pair = %+v", p["pair"])
+	}
+	if p["blob"].ContentEncoding != "base64" || p["raw"].Boolean == nil {
+		t.Errorf("blob = %+v, raw = %+v", p["blob"], p["raw"])
+	}
+	if p["nick"].Pattern != "^[a-z]{1,3}$" {
+		t.Errorf("nick pattern = %q", p["nick"].Pattern)
+	}
+	if p["friends"].Items.AnyOf[0].Ref != "#/$defs/account" {
+		t.Errorf("recursive friends = %+v", p["friends"].Items)
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	s, _ := For[address]()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$schema":"https://json-schema.org/draft/2020-12/schema","$ref":"#/$defs/address","$defs":{"address":{"type":"object","properties":{"city":{"type":"string"},"street":{"type":"string","minLength":1}},"required":["city","street"],"additionalProperties":false}}}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var back Schema
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := json.Marshal(&back)
+	if string(again) != want {
+		t.Errorf("round trip = %s", again)
+	}
+
+	var multi Schema
+	json.Unmarshal([]byte(`{"type":["string","null"],"not":false}`), &multi)
+	if len(multi.Type) != 2 || multi.Not.Boolean == nil || *multi.Not.Boolean {
+		t.Errorf("decoded = %+v", multi)
+	}
+}
+
+type withMarshaler struct {
+	When custom `json:"when"`
+}
+
+type custom struct{}
+
+func (custom) MarshalJSON() ([]byte, error) { return []byte(`"x"`), nil }
+
+func TestGeneratorTypes(t *testing.T) {
+	if _, err := For[withMarshaler](); err == nil {
+		t.Error("type with MarshalJSON: want an error without a registration")
+	}
+	g := Generator{Types: map[reflect.Type]*Schema{
+		reflect.TypeFor[custom](): {Type: Types{TypeString}, Const: "x"},
+	}}
+	s, err := g.Generate(reflect.TypeFor[withMarshaler]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Defs["withMarshaler"].Properties["when"].Const != "x" {
+		t.Errorf("registered schema not used: %+v", s.Defs["withMarshaler"])
+	}
+}
+
+func TestBadTag(t *testing.T) {
+	type bad struct {
+		N int `json:"n" schema:"minimum=low"`
+	}
+	if _, err := For[bad](); err == nil {
+		t.Error("want an error for minimum=low")
+	}
+	type unknown struct {
+		N int `json:"n" schema:"between=1"`
+	}
+	if _, err := For[unknown](); err == nil {
+		t.Error("want an error for an unknown keyword")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := For[account]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := `{
+		"id": 7, "created": "2024-05-01T10:00:00Z", "name": "Ana", "role": "admin",
+		"age": "31", "score": 2.5, "address": null, "pair": [true, false],
+		"limits": {"10": 3}, "friends": [{"id": 8, "created": "2024-05-01T10:00:00Z",
+		"name": "Bo", "role": "user", "age": "2", "score": null, "address": {"street": "Main", "city": "X"},
+		"pair": [false, false]}]
+	}`
+	if err := s.ValidateJSON([]byte(good)); err != nil {
+		t.Fatalf("valid document: %v", err)
+	}
+
+	bad := `{
+		"id": 0, "created": "yesterday", "name": "A", "email": "not an email", "role": "root",
+		"age": 31, "score": 0.75, "tags": ["a", "b", "a"], "pair": [true],
+		"address": {"street": "", "zip": "1"}, "limits": {"ten": -1}, "nick": "toolong",
+		"friends": [{"id": 1.5}], "password": "x"
+	}`
+	err = s.ValidateJSON([]byte(bad))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v; want a *ValidationError", err)
+	}
+	var got []string
+	for _, v := range verr.Violations {
+		got = append(got, v.Path+" "+v.Keyword)
+	}
+	want := []string{
+		"/address required",
+		"/address/street minLength",
+		"/address/zip additionalProperties",
+		"/age type",
+		"/created format",
+		"/email format",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0 required",
+		"/friends/0/id type",
+		"/id minimum",
+		"/limits/ten minimum",
+		"/limits/ten propertyNames",
+		"/name minLength",
+		"/nick pattern",
+		"/pair minItems",
+		"/password additionalProperties",
+		"/role enum",
+		"/score multipleOf",
+		"/tags/2 uniqueItems",
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		schema, doc string
+		valid       bool
+	}{
+		{`true`, `{"a": 1}`, true},
+		{`false`, `1`, false},
+		{`{"type": "integer"}`, `1.0`, true},
+		{`{"type": "integer"}`, `1.5`, false},
+		{`{"type": "number", "multipleOf": 0.1}`, `0.3`, true},
+		{`{"type": "string", "maxLength": 3}`, `"ção"`, true},
+		{`{"type": "string", "format": "uuid"}`, `"3fa85f64-5717-4562-b3fc-2c963f66afa6"`, true},
+		{`{"type": "string", "format": "ipv4"}`, `"::1"`, false},
+		{`{"type": "string", "format": "date"}`, `"2024-02-30"`, false},
+		{`{"const": {"a": [1, 2]}}`, `{"a": [1.0, 2]}`, true},
+		{`{"oneOf": [{"type": "integer"}, {"type": "number"}]}`, `1`, false},
+		{`{"oneOf": [{"type": "integer"}, {"type": "string"}]}`, `1`, true},
+		{`{"not": {"type": "null"}}`, `null`, false},
+		{`{"allOf": [{"minimum": 1}, {"maximum": 3}]}`, `4`, false},
+		{`{"uniqueItems": true}`, `[{"a": 1}, {"a": 1.0}]`, false},
+		{`{"type": "object", "properties": {"next": {"$ref": "#"}}}`, `{"next": {"next": 1}}`, false},
+		{`{"$ref": "#/$defs/missing"}`, `1`, false},
+		{`{"type": "integer"}`, `9007199254740993`, true},
+		{`{"type": "integer"}`, `1e400`, true},
+	}
+	for _, tt := range tests {
+		var s Schema
+		if err := json.Unmarshal([]byte(tt.schema), &s); err != nil {
+			t.Fatal(err)
+		}
+		err := s.ValidateJSON([]byte(tt.doc))
+		if (err == nil) != tt.valid {
+			t.Errorf("%s against %s: err = %v; want valid=%v", tt.doc, tt.schema, err, tt.valid)
+		}
+	}
+}
+
+func TestEscapedPath(t *testing.T) {
+	var s Schema
+	json.Unmarshal([]byte(`{"additionalProperties": {"type": "string"}}`), &s)
+	err := s.Validate(map[string]any{"a/b~c": 1})
+	if err == nil || err.(*ValidationError).Violations[0].Path != "/a~1b~0c" {
+		t.Errorf("err = %v", err)
+	}
+}
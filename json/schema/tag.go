@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// --- SCHEMA TAGS ---
+//
+// A schema tag is a comma-separated list of keywords, most with a value:
+//
+//	schema:"minLength=1,maxLength=100,pattern=^[a-z]+$"
+//
+// Enum values are separated by |. A comma inside a value is written \,
+// (so `schema:"pattern=^a{1\\,3}$"` in a struct tag). Besides the
+// constraint keywords, "required" and "optional" override what the json
+// tag implies, and "nullable" also accepts null.
+
+// applyTag adds the keywords of tag to s, the schema of a field of type t.
+func applyTag(s *Schema, t reflect.Type, tag string, required bool) (*Schema, bool, error) {
+	if s.Boolean != nil {
+		s = &Schema{}
+	}
+	for _, opt := range splitTag(tag) {
+		key, value, hasValue := strings.Cut(opt, "=")
+		var err error
+		switch key {
+		case "required":
+			required = true
+		case "optional":
+			required = false
+		case "nullable":
+			s = nullable(s)
+		case "deprecated":
+			s.Deprecated = true
+		case "readOnly":
+			s.ReadOnly = true
+		case "uniqueItems":
+			s.UniqueItems = true
+		case "title":
+			s.Title = value
+		case "description":
+			s.Description = value
+		case "format":
+			s.Format = value
+		case "pattern":
+			s.Pattern = value
+		case "minLength":
+			s.MinLength, err = intValue(value)
+		case "maxLength":
+			s.MaxLength, err = intValue(value)
+		case "minItems":
+			s.MinItems, err = intValue(value)
+		case "maxItems":
+			s.MaxItems, err = intValue(value)
+		case "minProperties":
+			s.MinProperties, err = intValue(value)
+		case "maxProperties":
+			s.MaxProperties, err = intValue(value)
+		case "minimum":
+			s.Minimum, err = floatValue(value)
+		case "maximum":
+			s.Maximum, err = floatValue(value)
+		case "exclusiveMinimum":
+			s.ExclusiveMinimum, err = floatValue(value)
+		case "exclusiveMaximum":
+			s.ExclusiveMaximum, err = floatValue(value)
+		case "multipleOf":
+			s.MultipleOf, err = floatValue(value)
+		case "enum":
+			s.Enum = nil
+			for _, v := range strings.Split(value, "|") {
+				typed, verr := typedValue(t, v)
+				if verr != nil {
+					err = verr
+					break
+				}
+				s.Enum = append(s.Enum, typed)
+			}
+		case "default":
+			s.Default, err = typedValue(t, value)
+		default:
+			return nil, false, fmt.Errorf("schema: unknown tag keyword %q", key)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("schema: %s: %w", key, err)
+		}
+		if !hasValue && needsValue(key) {
+			return nil, false, fmt.Errorf("schema: %s needs a value", key)
+		}
+	}
+	return s, required, nil
+}
+
+func needsValue(key string) bool {
+	switch key {
+	case "required", "optional", "nullable", "deprecated", "readOnly", "uniqueItems":
+		return false
+	}
+	return true
+}
+
+// splitTag splits tag at commas that are not escaped as \,.
+func splitTag(tag string) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			cur.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(tag[i])
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func intValue(s string) (*int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("want a non-negative integer, got %q", s)
+	}
+	return &n, nil
+}
+
+func floatValue(s string) (*float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("want a number, got %q", s)
+	}
+	return &f, nil
+}
+
+// typedValue converts an enum or default value to the JSON type of t.
+func typedValue(t reflect.Type, s string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return s, nil
+}
@@ -0,0 +1,500 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// --- VALIDATION ---
+
+// Violation is one rule that a value breaks.
+type Violation struct {
+	Path    string `json:"path"`    // JSON Pointer of the value, "" for the root
+	Keyword string `json:"keyword"` // the schema keyword, like "minLength"
+	Message string `json:"message"`
+}
+
+func (v Violation) Error() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + v.Message
+}
+
+// ValidationError holds every violation of a document.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Error()
+	}
+	return "schema: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks instance, a value decoded from JSON into an any (with
+// or without json.Decoder.UseNumber), against s. It returns nil or a
+// *ValidationError with every violation.
+func (s *Schema) Validate(instance any) error {
+	v := validator{root: s}
+	v.validate(s, instance, "", 0)
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: v.violations}
+}
+
+// ValidateJSON decodes data and validates it. Numbers keep all their
+// digits for the checks.
+func (s *Schema) ValidateJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var instance any
+	if err := dec.Decode(&instance); err != nil {
+		return err
+	}
+	return s.Validate(instance)
+}
+
+// maxRefDepth stops $ref cycles that never reach a value, like a schema
+// that is only {"$ref": "#"}.
+const maxRefDepth = 64
+
+type validator struct {
+	root       *Schema
+	violations []Violation
+}
+
+func (v *validator) fail(path, keyword, format string, args ...any) {
+	v.violations = append(v.violations, Violation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether instance matches s, without recording anything.
+func (v *validator) valid(s *Schema, instance any, path string, refs int) bool {
+	sub := validator{root: v.root}
+	sub.validate(s, instance, path, refs)
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(s *Schema, instance any, path string, refs int) {
+	if s == nil {
+		return
+	}
+	if s.Boolean != nil {
+		if !*s.Boolean {
+			v.fail(path, "false", "no value is allowed here")
+		}
+		return
+	}
+
+	if s.Ref != "" {
+		target, err := v.resolve(s.Ref)
+		switch {
+		case err != nil:
+			v.fail(path, "$ref", "%v", err)
+		case refs >= maxRefDepth:
+			v.fail(path, "$ref", "too many nested references")
+		default:
+			v.validate(target, instance, path, refs+1)
+		}
+	}
+
+	if len(s.Type) > 0 && !matchesType(s.Type, instance) {
+		v.fail(path, "type", "expected %s, got %s", strings.Join(s.Type, " or "), typeOf(instance))
+		return // the other keywords would only repeat the mistake
+	}
+	if len(s.Enum) > 0 && !containsJSON(s.Enum, instance) {
+		v.fail(path, "enum", "must be one of %s", jsonList(s.Enum))
+	}
+	if s.Const != nil && !equalJSON(s.Const, instance) {
+		v.fail(path, "const", "must be %s", jsonList([]any{s.Const}))
+	}
+
+	switch x := instance.(type) {
+	case map[string]any:
+		v.object(s, x, path, refs)
+	case []any:
+		v.array(s, x, path, refs)
+	case string:
+		v.string(s, x, path)
+	case float64, json.Number:
+		v.number(s, instance, path)
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, instance, path, refs)
+	}
+	if len(s.AnyOf) > 0 {
+		v.anyOf(s.AnyOf, instance, path, refs)
+	}
+	if len(s.OneOf) > 0 {
+		n := 0
+		for _, sub := range s.OneOf {
+			if v.valid(sub, instance, path, refs) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.fail(path, "oneOf", "matches %d of the schemas, want exactly 1", n)
+		}
+	}
+	if s.Not != nil && v.valid(s.Not, instance, path, refs) {
+		v.fail(path, "not", "matches a schema it must not match")
+	}
+}
+
+// anyOf checks that instance matches one of schemas. When only one of
+// them has the right type, like the object half of a nullable struct,
+// its violations say more than "matches none" and are reported instead.
+func (v *validator) anyOf(schemas []*Schema, instance any, path string, refs int) {
+	var candidates [][]Violation
+	for _, sub := range schemas {
+		try := validator{root: v.root}
+		try.validate(sub, instance, path, refs)
+		if len(try.violations) == 0 {
+			return
+		}
+		if !wrongType(try.violations, path) {
+			candidates = append(candidates, try.violations)
+		}
+	}
+	if len(candidates) == 1 {
+		v.violations = append(v.violations, candidates[0]...)
+		return
+	}
+	v.fail(path, "anyOf", "does not match any of the %d allowed schemas", len(schemas))
+}
+
+// wrongType reports whether violations reject the value at path for its type.
+func wrongType(violations []Violation, path string) bool {
+	for _, vi := range violations {
+		if vi.Path == path && (vi.Keyword == "type" || vi.Keyword == "false") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) object(s *Schema, obj map[string]any, path string, refs int) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			v.fail(path, "required", "missing required property %q", name)
+		}
+	}
+	if s.MinProperties != nil && len(obj) < *s.MinProperties {
+		v.fail(path, "minProperties", "must have at least %d properties", *s.MinProperties)
+	}
+	if s.MaxProperties != nil && len(obj) > *s.MaxProperties {
+		v.fail(path, "maxProperties", "must have at most %d properties", *s.MaxProperties)
+	}
+	for _, name := range sortedKeys(obj) {
+		value, child := obj[name], path+"/"+escapePointer(name)
+		if s.PropertyNames != nil && !v.valid(s.PropertyNames, name, child, refs) {
+			v.fail(child, "propertyNames", "property name %q is not allowed", name)
+		}
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(prop, value, child, refs)
+			continue
+		}
+		if s.AdditionalProperties != nil {
+			if b := s.AdditionalProperties.Boolean; b != nil && !*b {
+				v.fail(child, "additionalProperties", "unknown property %q", name)
+				continue
+			}
+			v.validate(s.AdditionalProperties, value, child, refs)
+		}
+	}
+}
+
+func (v *validator) array(s *Schema, arr []any, path string, refs int) {
+	if s.MinItems != nil && len(arr) < *s.MinItems {
+		v.fail(path, "minItems", "must have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(arr) > *s.MaxItems {
+		v.fail(path, "maxItems", "must have at most %d items", *s.MaxItems)
+	}
+	if s.UniqueItems {
+	dup:
+		for i := range arr {
+			for j := range i {
+				if equalJSON(arr[i], arr[j]) {
+					v.fail(path+"/"+strconv.Itoa(i), "uniqueItems", "duplicates item %d", j)
+					break dup
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range arr {
+			v.validate(s.Items, item, path+"/"+strconv.Itoa(i), refs)
+		}
+	}
+}
+
+func (v *validator) string(s *Schema, str, path string) {
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		v.fail(path, "minLength", "must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		v.fail(path, "maxLength", "must be at most %d characters long", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := compile(s.Pattern)
+		switch {
+		case err != nil:
+			v.fail(path, "pattern", "invalid pattern %q: %v", s.Pattern, err)
+		case !re.MatchString(str):
+			v.fail(path, "pattern", "must match %s", s.Pattern)
+		}
+	}
+	if s.Format != "" && !validFormat(s.Format, str) {
+		v.fail(path, "format", "is not a valid %s", s.Format)
+	}
+}
+
+func (v *validator) number(s *Schema, instance any, path string) {
+	x := toRat(instance)
+	check := func(keyword string, bound *float64, ok func(c int) bool, msg string) {
+		if bound != nil && !ok(x.Cmp(new(big.Rat).SetFloat64(*bound))) {
+			v.fail(path, keyword, "must be %s %v", msg, *bound)
+		}
+	}
+	check("minimum", s.Minimum, func(c int) bool { return c >= 0 }, ">=")
+	check("maximum", s.Maximum, func(c int) bool { return c <= 0 }, "<=")
+	check("exclusiveMinimum", s.ExclusiveMinimum, func(c int) bool { return c > 0 }, ">")
+	check("exclusiveMaximum", s.ExclusiveMaximum, func(c int) bool { return c < 0 }, "<")
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		// Compare as decimals, so 0.3 is a multiple of 0.1.
+		m, _ := new(big.Rat).SetString(strconv.FormatFloat(*s.MultipleOf, 'g', -1, 64))
+		if !new(big.Rat).Quo(x, m).IsInt() {
+			v.fail(path, "multipleOf", "must be a multiple of %v", *s.MultipleOf)
+		}
+	}
+}
+
+// resolve finds the schema a $ref points to. Only references inside the
+// document ("#" and "#/$defs/Name" style pointers) are supported.
+func (v *validator) resolve(ref string) (*Schema, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	rest, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok || strings.Contains(rest, "/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	s, ok := v.root.Defs[unescapePointer(rest)]
+	if !ok {
+		return nil, fmt.Errorf("unknown $ref %q", ref)
+	}
+	return s, nil
+}
+
+// --- JSON VALUES ---
+
+func typeOf(x any) string {
+	switch x := x.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case string:
+		return TypeString
+	case []any:
+		return TypeArray
+	case map[string]any:
+		return TypeObject
+	case float64, json.Number:
+		if toRat(x).IsInt() {
+			return TypeInteger
+		}
+		return TypeNumber
+	}
+	return fmt.Sprintf("%T", x)
+}
+
+func matchesType(types Types, x any) bool {
+	got := typeOf(x)
+	for _, t := range types {
+		if t == got || (t == TypeNumber && got == TypeInteger) {
+			return true
+		}
+	}
+	return false
+}
+
+func toRat(x any) *big.Rat {
+	var s string
+	switch x := x.(type) {
+	case json.Number:
+		s = x.String()
+	case float64:
+		s = strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// equalJSON compares decoded JSON values; 1 and 1.0 are equal.
+func equalJSON(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			if vb, ok := b[k]; !ok || !equalJSON(va, vb) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if isNumber(a) || isNumber(b) {
+		return isNumber(a) && isNumber(b) && numberRat(a).Cmp(numberRat(b)) == 0
+	}
+	return a == b
+}
+
+// isNumber also accepts the Go integers and floats of tag enums.
+func isNumber(x any) bool {
+	switch x.(type) {
+	case float64, json.Number, int64, uint64, int, float32:
+		return true
+	}
+	return false
+}
+
+func numberRat(x any) *big.Rat {
+	switch x := x.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(x)
+	case int:
+		return new(big.Rat).SetInt64(int64(x))
+	case uint64:
+		return new(big.Rat).SetUint64(x)
+	case float32:
+		return toRat(float64(x))
+	}
+	return toRat(x)
+}
+
+func containsJSON(values []any, x any) bool {
+	for _, v := range values {
+		if equalJSON(v, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonList(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escapePointer escapes a JSON Pointer token (RFC 6901).
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func unescapePointer(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
+
+// --- PATTERNS AND FORMATS ---
+
+var patterns sync.Map // string -> *regexp.Regexp or error
+
+// compile compiles a pattern once. Patterns are RE2, which covers the
+// ECMA-262 syntax schemas use except lookaround and backreferences.
+func compile(pattern string) (*regexp.Regexp, error) {
+	if v, ok := patterns.Load(pattern); ok {
+		if err, isErr := v.(error); isErr {
+			return nil, err
+		}
+		return v.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		patterns.Store(pattern, err)
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat checks the formats it knows. Unknown formats are only
+// annotations in draft 2020-12, so they pass.
+func validFormat(format, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", s)
+		return err == nil
+	case "uuid":
+		return uuidRe.MatchString(s)
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "ipv4":
+		a, err := netip.ParseAddr(s)
+		return err == nil && a.Is4()
+	case "ipv6":
+		a, err := netip.ParseAddr(s)
+		return err == nil && a.Is6()
+	}
+	return true
+}
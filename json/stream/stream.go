@@ -0,0 +1,291 @@
+// Package stream reads and writes long sequences of JSON values one at a
+// time, so memory stays flat however large the input is.
+//
+// A Stream reads the elements of a JSON array, either the whole document
+// or an array inside it found by JSON Pointer, or the lines of NDJSON
+// (one value per line). A Writer produces the same two shapes.
+//
+//	s := stream.NewArrayAt[User](f, "/data/users")
+//	for u, err := range s.All() {
+//		...
+//	}
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotArray means the value at the pointer is not an array.
+	ErrNotArray = errors.New("stream: not an array")
+	// ErrNoPath means the document has no value at the pointer.
+	ErrNoPath = errors.New("stream: no value at pointer")
+)
+
+// ElementError is a bad element. The stream can go on past it: Read
+// returns the next element on the following call.
+type ElementError struct {
+	Index  int   // position of the element, from 0
+	Offset int64 // byte offset of the element's start in the input
+	Err    error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("stream: element %d at byte %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *ElementError) Unwrap() error { return e.Err }
+
+// Stream reads values of type T one at a time.
+type Stream[T any] struct {
+	read  func() (json.RawMessage, int64, error) // next raw value and its offset
+	index int
+	err   error // sticky; io.EOF once the input is done
+}
+
+// NewArray returns a Stream over the elements of the top-level array in r.
+func NewArray[T any](r io.Reader) *Stream[T] {
+	return NewArrayAt[T](r, "")
+}
+
+// NewArrayAt returns a Stream over the elements of the array at pointer,
+// a JSON Pointer (RFC 6901) like "/data/users" or "/pages/0/items". The
+// values before it are skipped token by token, never held in memory,
+// and reading stops at the end of the array.
+func NewArrayAt[T any](r io.Reader, pointer string) *Stream[T] {
+	a := &arrayReader{dec: json.NewDecoder(r), pointer: pointer}
+	return &Stream[T]{read: a.next}
+}
+
+// NewNDJSON returns a Stream over newline-delimited JSON. Blank lines
+// are skipped. A malformed line is an ElementError, not the end of the
+// stream.
+func NewNDJSON[T any](r io.Reader) *Stream[T] {
+	n := &lineReader{r: bufio.NewReader(r)}
+	return &Stream[T]{read: n.next}
+}
+
+// Read returns the next value. At the end of the input it returns io.EOF.
+// A value that does not decode into T comes back as an *ElementError and
+// the stream moves on; any other error ends it.
+func (s *Stream[T]) Read() (T, error) {
+	var v T
+	if s.err != nil {
+		return v, s.err
+	}
+	raw, offset, err := s.read()
+	if err != nil {
+		s.err = err
+		return v, err
+	}
+	index := s.index
+	s.index++
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return v, &ElementError{Index: index, Offset: offset, Err: err}
+	}
+	return v, nil
+}
+
+// All ranges over the remaining values. Element errors are yielded with
+// a zero value and the loop goes on; any other error is yielded last.
+func (s *Stream[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := s.Read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(v, err) {
+				return
+			}
+			var elemErr *ElementError
+			if err != nil && !errors.As(err, &elemErr) {
+				return
+			}
+		}
+	}
+}
+
+// --- ARRAYS ---
+
+type arrayReader struct {
+	dec     *json.Decoder
+	pointer string
+	started bool
+}
+
+func (a *arrayReader) next() (json.RawMessage, int64, error) {
+	if !a.started {
+		a.started = true
+		if err := a.open(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if !a.dec.More() {
+		if _, err := a.dec.Token(); err != nil { // the closing ]
+			return nil, 0, err
+		}
+		return nil, 0, io.EOF
+	}
+	var raw json.RawMessage
+	if err := a.dec.Decode(&raw); err != nil {
+		return nil, 0, fmt.Errorf("stream: at byte %d: %w", a.dec.InputOffset(), err)
+	}
+	// The decoder stops right after the value, and raw holds its bytes as
+	// they were, so the value started len(raw) bytes back.
+	return raw, a.dec.InputOffset() - int64(len(raw)), nil
+}
+
+// open walks to the array at the pointer and reads its [.
+func (a *arrayReader) open() error {
+	segments, err := parsePointer(a.pointer)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			err = a.findKey(seg)
+		case json.Delim('['):
+			err = a.findIndex(seg)
+		default:
+			err = fmt.Errorf("%w %q", ErrNoPath, a.pointer)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tok, err := a.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("%w: %q holds %s", ErrNotArray, a.pointer, describe(tok))
+	}
+	return nil
+}
+
+// findKey reads an object up to the value of key, skipping the others.
+func (a *arrayReader) findKey(key string) error {
+	for a.dec.More() {
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == key {
+			return nil
+		}
+		if err := a.skip(); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%w %q", ErrNoPath, a.pointer)
+}
+
+// findIndex reads an array up to its element at seg.
+func (a *arrayReader) findIndex(seg string) error {
+	n, err := strconv.Atoi(seg)
+	if err != nil || n < 0 || (len(seg) > 1 && seg[0] == '0') {
+		return fmt.Errorf("%w %q", ErrNoPath, a.pointer)
+	}
+	for ; n > 0 && a.dec.More(); n-- {
+		if err := a.skip(); err != nil {
+			return err
+		}
+	}
+	if !a.dec.More() {
+		return fmt.Errorf("%w %q", ErrNoPath, a.pointer)
+	}
+	return nil
+}
+
+// skip reads past one value without keeping it.
+func (a *arrayReader) skip() error {
+	depth := 0
+	for {
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// parsePointer splits a JSON Pointer into unescaped reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("stream: JSON Pointer %q must start with /", p)
+	}
+	segs := strings.Split(p[1:], "/")
+	for i, s := range segs {
+		segs[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+	}
+	return segs, nil
+}
+
+func describe(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		if tok == json.Delim('{') {
+			return "an object"
+		}
+	case string:
+		return "a string"
+	case float64, json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(tok)
+}
+
+// --- NDJSON ---
+
+type lineReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (l *lineReader) next() (json.RawMessage, int64, error) {
+	for {
+		line, err := l.r.ReadBytes('\n')
+		start := l.offset
+		l.offset += int64(len(line))
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			// A malformed line goes to Unmarshal too, whose error says why.
+			return trimmed, start + int64(bytes.Index(line, trimmed)), nil
+		}
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+	}
+}
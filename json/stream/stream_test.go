@@ -0,0 +1,175 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func collect[T any](t *testing.T, s *Stream[T]) ([]T, []error) {
+	t.Helper()
+	var vals []T
+	var errs []error
+	for v, err := range s.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		vals = append(vals, v)
+	}
+	return vals, errs
+}
+
+func TestArray(t *testing.T) {
+	in := ` [ {"id":1,"name":"a"}, {"id":2,"name":"b"} ,{"id":3,"name":"c"}]`
+	users, errs := collect(t, NewArray[user](strings.NewReader(in)))
+	if len(errs) > 0 || fmt.Sprint(users) != "[{1 a} {2 b} {3 c}]" {
+		t.Errorf("got %v, %v", users, errs)
+	}
+
+	s := NewArray[user](strings.NewReader(`[]`))
+	if _, err := s.Read(); err != io.EOF {
+		t.Errorf("empty array: %v", err)
+	}
+	if _, err := s.Read(); err != io.EOF {
+		t.Errorf("Read after EOF: %v", err)
+	}
+}
+
+func TestArrayAt(t *testing.T) {
+	in := `{
+		"meta": {"users": "not these", "skip": [[1, {"users": []}], {}]},
+		"pages": [
+			{"users": [{"id": 9}]},
+			{"total": 2, "users": [{"id": 1}, {"id": 2}], "after": [1, 2, 3]}
+		]
+	}`
+	users, errs := collect(t, NewArrayAt[user](strings.NewReader(in), "/pages/1/users"))
+	if len(errs) > 0 || fmt.Sprint(users) != "[{1 } {2 }]" {
+		t.Errorf("got %v, %v", users, errs)
+	}
+
+	ids, _ := collect(t, NewArrayAt[int](strings.NewReader(`{"a/b": {"~": [5, 6]}}`), "/a~1b/~0"))
+	if fmt.Sprint(ids) != "[5 6]" {
+		t.Errorf("escaped pointer: %v", ids)
+	}
+
+	for pointer, want := range map[string]error{
+		"/missing":    ErrNoPath,
+		"/pages/7":    ErrNoPath,
+		"/pages/01":   ErrNoPath,
+		"/meta/users": ErrNotArray,
+		"/meta":       ErrNotArray,
+	} {
+		_, err := NewArrayAt[user](strings.NewReader(in), pointer).Read()
+		if !errors.Is(err, want) {
+			t.Errorf("%s: err = %v; want %v", pointer, err, want)
+		}
+	}
+}
+
+func TestElementErrors(t *testing.T) {
+	in := `[{"id":1}, {"id":"two"}, 3, {"id":4}]`
+	s := NewArray[user](strings.NewReader(in))
+	users, errs := collect(t, s)
+	if fmt.Sprint(users) != "[{1 } {4 }]" || len(errs) != 2 {
+		t.Fatalf("got %v, %v", users, errs)
+	}
+	var e *ElementError
+	if !errors.As(errs[0], &e) || e.Index != 1 || e.Offset != 11 || in[e.Offset] != '{' {
+		t.Errorf("first error = %v", errs[0])
+	}
+	if !errors.As(errs[1], &e) || e.Index != 2 || e.Offset != int64(strings.Index(in, "3")) {
+		t.Errorf("second error = %v", errs[1])
+	}
+
+	// A syntax error ends the stream.
+	_, errs = collect(t, NewArray[user](strings.NewReader(`[{"id":1}, {"id": }, {"id":3}]`)))
+	if len(errs) != 1 || errors.As(errs[0], &e) {
+		t.Errorf("syntax error: %v", errs)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	in := "{\"id\":1}\n\n  {\"id\":2}\r\n{broken\n{\"id\":\"x\"}\n{\"id\":5}"
+	users, errs := collect(t, NewNDJSON[user](strings.NewReader(in)))
+	if fmt.Sprint(users) != "[{1 } {2 } {5 }]" || len(errs) != 2 {
+		t.Fatalf("got %v, %v", users, errs)
+	}
+	var e *ElementError
+	if !errors.As(errs[0], &e) || e.Index != 2 || in[e.Offset:e.Offset+7] != "{broken" {
+		t.Errorf("first error = %v", errs[0])
+	}
+	if !errors.As(errs[1], &e) || e.Index != 3 || in[e.Offset:e.Offset+6] != `{"id":` {
+		t.Errorf("second error = %v", errs[1])
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	users := []user{{1, "a"}, {2, "b<c>"}, {3, ""}}
+	for _, ndjson := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewArrayWriter[user](&buf)
+		if ndjson {
+			w = NewNDJSONWriter[user](&buf)
+		}
+		for _, u := range users {
+			if err := w.Write(u); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(user{}); !errors.Is(err, ErrClosed) {
+			t.Errorf("Write after Close: %v", err)
+		}
+
+		s := NewArray[user](&buf)
+		if ndjson {
+			s = NewNDJSON[user](&buf)
+		}
+		got, errs := collect(t, s)
+		if len(errs) > 0 || fmt.Sprint(got) != fmt.Sprint(users) {
+			t.Errorf("ndjson=%v: got %v, %v", ndjson, got, errs)
+		}
+	}
+
+	var buf bytes.Buffer
+	NewArrayWriter[int](&buf).Close()
+	if buf.String() != "[]\n" {
+		t.Errorf("empty array = %q", buf.String())
+	}
+}
+
+// TestConstantMemory reads a large generated array without holding it.
+func TestConstantMemory(t *testing.T) {
+	const n = 100000
+	pr, pw := io.Pipe()
+	go func() {
+		w := NewArrayWriter[user](pw)
+		for i := range n {
+			w.Write(user{ID: i, Name: strings.Repeat("x", 100)})
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	count, sum := 0, 0
+	for u, err := range NewArray[user](pr).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		sum += u.ID
+	}
+	if count != n || sum != n*(n-1)/2 {
+		t.Errorf("read %d users, id sum %d", count, sum)
+	}
+}
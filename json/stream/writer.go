@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ErrClosed is returned by writes after Close.
+var ErrClosed = errors.New("stream: writer closed")
+
+// Writer writes values of type T one at a time, as a JSON array or as
+// NDJSON. Output is buffered; Close flushes it.
+type Writer[T any] struct {
+	w      *bufio.Writer
+	ndjson bool
+	n      int
+	closed bool
+}
+
+// NewArrayWriter returns a Writer that writes a JSON array, one element
+// per line. Close writes the closing bracket.
+func NewArrayWriter[T any](w io.Writer) *Writer[T] {
+	return &Writer[T]{w: bufio.NewWriter(w)}
+}
+
+// NewNDJSONWriter returns a Writer that writes one value per line.
+func NewNDJSONWriter[T any](w io.Writer) *Writer[T] {
+	return &Writer[T]{w: bufio.NewWriter(w), ndjson: true}
+}
+
+// Write writes v.
+func (w *Writer[T]) Write(v T) error {
+	if w.closed {
+		return ErrClosed
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	switch {
+	case w.ndjson:
+	case w.n == 0:
+		w.w.WriteString("[\n")
+	default:
+		w.w.WriteString(",\n")
+	}
+	w.n++
+	if w.ndjson {
+		data = append(data, '\n')
+	}
+	_, err = w.w.Write(data) // bufio.Writer keeps the first error, so this one covers the writes above
+	return err
+}
+
+// Flush writes buffered values to the underlying writer.
+func (w *Writer[T]) Flush() error { return w.w.Flush() }
+
+// Close finishes the array, if any, and flushes. It does not close the
+// underlying writer.
+func (w *Writer[T]) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	if !w.ndjson {
+		if w.n == 0 {
+			w.w.WriteString("[]\n")
+		} else {
+			w.w.WriteString("\n]\n")
+		}
+	}
+	return w.w.Flush()
+}
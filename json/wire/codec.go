@@ -0,0 +1,646 @@
+package wire
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- CODECS ---
+//
+// A codec encodes and decodes the body of one type: the value without a
+// field key and, for length-delimited types, without the length. Codecs
+// are built once per type and cached; a struct codec is a list of field
+// codecs, so encoding does no tag parsing and no lookups by name.
+
+type codec struct {
+	wt     wireType
+	encode func(b []byte, v reflect.Value) ([]byte, error)
+	// decode reads one value from the front of d; for length-delimited
+	// types d holds exactly the value.
+	decode func(d *decoder, v reflect.Value) error
+}
+
+// encodeElem appends v with its length, if it has one: the form of a field
+// value or of an element in a slice or map.
+func (c *codec) encodeElem(b []byte, v reflect.Value) ([]byte, error) {
+	if c.wt != wireBytes {
+		return c.encode(b, v)
+	}
+	b, start := beginDelimited(b)
+	b, err := c.encode(b, v)
+	if err != nil {
+		return b, err
+	}
+	return endDelimited(b, start), nil
+}
+
+func (c *codec) decodeElem(d *decoder, v reflect.Value) error {
+	if c.wt != wireBytes {
+		return c.decode(d, v)
+	}
+	// Narrow d to the value rather than make a new decoder, which would
+	// escape to the heap. Every decode consumes all it is given.
+	n, err := d.length()
+	if err != nil {
+		return err
+	}
+	rest := d.buf[n:]
+	d.buf = d.buf[:n]
+	if err := c.decode(d, v); err != nil {
+		return err
+	}
+	d.buf = rest
+	return nil
+}
+
+var (
+	codecs  sync.Map // reflect.Type -> *codec
+	buildMu sync.Mutex
+)
+
+func codecFor(t reflect.Type) (*codec, error) {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec), nil
+	}
+	buildMu.Lock()
+	defer buildMu.Unlock()
+	b := builder{building: make(map[reflect.Type]*codec)}
+	c, err := b.build(t)
+	if err != nil {
+		return nil, err
+	}
+	// Publish the whole set at once: a codec of a recursive type is only
+	// complete when the outermost build returns.
+	for t, c := range b.building {
+		codecs.Store(t, c)
+	}
+	return c, nil
+}
+
+type builder struct {
+	building map[reflect.Type]*codec
+}
+
+var (
+	binaryMarshaler   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshaler = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+	textMarshaler     = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshaler   = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func (b *builder) build(t reflect.Type) (*codec, error) {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec), nil
+	}
+	if c, ok := b.building[t]; ok {
+		return c, nil // a recursive type; the caller is filling it in
+	}
+	// The wire type is known up front, so a recursive type's fields see
+	// it before the codec is complete.
+	c := &codec{wt: wireTypeOf(t)}
+	b.building[t] = c
+
+	switch marshalerOf(t) {
+	case binaryMarshaler:
+		*c = marshalerCodec(binaryCodec)
+		return c, nil
+	case textMarshaler:
+		*c = marshalerCodec(textCodec)
+		return c, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		*c = boolCodec
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*c = intCodec
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		*c = uintCodec
+	case reflect.Float64:
+		*c = float64Codec
+	case reflect.Float32:
+		*c = float32Codec
+	case reflect.String:
+		*c = stringCodec
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			*c = bytesCodec
+			break
+		}
+		elem, err := b.build(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		*c = sliceCodec(t, elem)
+	case reflect.Array:
+		elem, err := b.build(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		*c = arrayCodec(t, elem)
+	case reflect.Map:
+		key, err := b.build(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := b.build(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		*c = mapCodec(t, key, elem)
+	case reflect.Pointer:
+		elem, err := b.build(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		*c = pointerCodec(t, elem)
+	case reflect.Struct:
+		s, err := b.structCodec(t)
+		if err != nil {
+			return nil, err
+		}
+		*c = s
+	default:
+		return nil, &TypeError{Type: t, Reason: "type not supported"}
+	}
+	return c, nil
+}
+
+// marshalerOf returns binaryMarshaler or textMarshaler if t encodes
+// itself that way, and nil if not. Pointers are left to pointerCodec.
+func marshalerOf(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return nil
+	}
+	switch pt := reflect.PointerTo(t); {
+	case t.Implements(binaryMarshaler) && pt.Implements(binaryUnmarshaler):
+		return binaryMarshaler
+	case t.Implements(textMarshaler) && pt.Implements(textUnmarshaler):
+		return textMarshaler
+	}
+	return nil
+}
+
+func wireTypeOf(t reflect.Type) wireType {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if marshalerOf(t) != nil {
+		return wireBytes
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return wireVarint
+	case reflect.Float64:
+		return wireFixed64
+	case reflect.Float32:
+		return wireFixed32
+	}
+	return wireBytes
+}
+
+// --- SCALARS ---
+
+var boolCodec = codec{
+	wt: wireVarint,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		x, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if x > 1 {
+			return d.fail("bad bool %d", x)
+		}
+		v.SetBool(x == 1)
+		return nil
+	},
+}
+
+var intCodec = codec{
+	wt: wireVarint,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		return binary.AppendUvarint(b, zigzag(v.Int())), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		x, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		n := unzigzag(x)
+		if v.OverflowInt(n) {
+			return d.fail("%d overflows %v", n, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	},
+}
+
+var uintCodec = codec{
+	wt: wireVarint,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		return binary.AppendUvarint(b, v.Uint()), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		x, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(x) {
+			return d.fail("%d overflows %v", x, v.Type())
+		}
+		v.SetUint(x)
+		return nil
+	},
+}
+
+// negativeZero reports whether v is -0, which IsZero counts as zero but
+// JSON writes as -0.
+func negativeZero(v reflect.Value) bool {
+	k := v.Kind()
+	return (k == reflect.Float64 || k == reflect.Float32) && math.Signbit(v.Float())
+}
+
+// JSON has no NaN or infinities, so neither does this encoding.
+func checkFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("%w: %v", ErrUnsupported, f)
+	}
+	return nil
+}
+
+var float64Codec = codec{
+	wt: wireFixed64,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		f := v.Float()
+		if err := checkFloat(f); err != nil {
+			return b, err
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		x, err := d.fixed64()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(math.Float64frombits(x))
+		return nil
+	},
+}
+
+var float32Codec = codec{
+	wt: wireFixed32,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		f := v.Float()
+		if err := checkFloat(f); err != nil {
+			return b, err
+		}
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		x, err := d.fixed32()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(math.Float32frombits(x)))
+		return nil
+	},
+}
+
+var stringCodec = codec{
+	wt: wireBytes,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		return append(b, v.String()...), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		v.SetString(string(d.buf))
+		d.advance(len(d.buf))
+		return nil
+	},
+}
+
+var bytesCodec = codec{
+	wt: wireBytes,
+	encode: func(b []byte, v reflect.Value) ([]byte, error) {
+		return append(b, v.Bytes()...), nil
+	},
+	decode: func(d *decoder, v reflect.Value) error {
+		v.SetBytes(append([]byte{}, d.buf...))
+		d.advance(len(d.buf))
+		return nil
+	},
+}
+
+// --- MARSHALERS ---
+
+type marshalFuncs struct {
+	marshal   func(v reflect.Value) ([]byte, error)
+	unmarshal func(v reflect.Value, data []byte) error
+}
+
+var binaryCodec = marshalFuncs{
+	marshal: func(v reflect.Value) ([]byte, error) {
+		return v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	},
+	unmarshal: func(v reflect.Value, data []byte) error {
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	},
+}
+
+var textCodec = marshalFuncs{
+	marshal: func(v reflect.Value) ([]byte, error) {
+		return v.Interface().(encoding.TextMarshaler).MarshalText()
+	},
+	unmarshal: func(v reflect.Value, data []byte) error {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(data)
+	},
+}
+
+func marshalerCodec(m marshalFuncs) codec {
+	return codec{
+		wt: wireBytes,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			data, err := m.marshal(v)
+			return append(b, data...), err
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			// Both interfaces promise to copy what they keep, so d.buf can
+			// be handed over as it is.
+			data, off := d.buf, d.off
+			d.advance(len(d.buf))
+			if err := m.unmarshal(v, data); err != nil {
+				return fmt.Errorf("wire: %v at byte %d: %w", v.Type(), off, err)
+			}
+			return nil
+		},
+	}
+}
+
+// --- CONTAINERS ---
+
+// checkElem rejects nil pointers in slices and maps: they have no place
+// to go, since an element cannot be left out the way a field can.
+func checkElem(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return fmt.Errorf("%w: nil %v element", ErrUnsupported, v.Type())
+	}
+	return nil
+}
+
+func sliceCodec(t reflect.Type, elem *codec) codec {
+	return codec{
+		wt: wireBytes,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			var err error
+			for i := range v.Len() {
+				e := v.Index(i)
+				if err = checkElem(e); err != nil {
+					return b, err
+				}
+				if b, err = elem.encodeElem(b, e); err != nil {
+					return b, err
+				}
+			}
+			return b, nil
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			v.Set(reflect.MakeSlice(t, 0, 0)) // empty, not nil, as in JSON
+			for n := 0; len(d.buf) > 0; n++ {
+				if n == v.Cap() {
+					grown := reflect.MakeSlice(t, n, 2*n+4)
+					reflect.Copy(grown, v)
+					v.Set(grown)
+				}
+				v.SetLen(n + 1)
+				if err := elem.decodeElem(d, v.Index(n)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func arrayCodec(t reflect.Type, elem *codec) codec {
+	return codec{
+		wt: wireBytes,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			var err error
+			for i := range v.Len() {
+				e := v.Index(i)
+				if err = checkElem(e); err != nil {
+					return b, err
+				}
+				if b, err = elem.encodeElem(b, e); err != nil {
+					return b, err
+				}
+			}
+			return b, nil
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			v.SetZero()
+			for i := 0; len(d.buf) > 0; i++ {
+				if i == t.Len() {
+					return d.fail("more than %d elements for %v", t.Len(), t)
+				}
+				if err := elem.decodeElem(d, v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// Map entries are written in Go's iteration order, which varies from run
+// to run; the decoded map is the same.
+func mapCodec(t reflect.Type, key, elem *codec) codec {
+	return codec{
+		wt: wireBytes,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			var err error
+			it := v.MapRange()
+			for it.Next() {
+				if err = checkElem(it.Value()); err != nil {
+					return b, err
+				}
+				if b, err = key.encodeElem(b, it.Key()); err != nil {
+					return b, err
+				}
+				if b, err = elem.encodeElem(b, it.Value()); err != nil {
+					return b, err
+				}
+			}
+			return b, nil
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(t))
+			}
+			k := reflect.New(t.Key()).Elem()
+			e := reflect.New(t.Elem()).Elem()
+			for len(d.buf) > 0 {
+				k.SetZero()
+				e.SetZero()
+				if err := key.decodeElem(d, k); err != nil {
+					return err
+				}
+				if len(d.buf) == 0 {
+					return d.fail("map key without a value")
+				}
+				if err := elem.decodeElem(d, e); err != nil {
+					return err
+				}
+				v.SetMapIndex(k, e)
+			}
+			return nil
+		},
+	}
+}
+
+func pointerCodec(t reflect.Type, elem *codec) codec {
+	return codec{
+		wt: elem.wt,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			return elem.encode(b, v.Elem())
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			return elem.decode(d, v.Elem())
+		},
+	}
+}
+
+// --- STRUCTS ---
+
+type field struct {
+	name  string
+	num   int
+	index int
+	key   []byte // the encoded field key
+	c     *codec
+}
+
+// maxField bounds field numbers, like Protocol Buffers does.
+const maxField = 1<<29 - 1
+
+func (b *builder) structCodec(t reflect.Type) (codec, error) {
+	var fields []*field
+	seen := make(map[int]string)
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("wire")
+		jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		switch {
+		case tag == "-":
+			continue
+		case !sf.IsExported() || jsonName == "-":
+			if tagged {
+				return codec{}, &TypeError{Type: t, Reason: fmt.Sprintf("field %s has a wire tag but is not in the JSON form", sf.Name)}
+			}
+			continue
+		case !tagged:
+			return codec{}, &TypeError{Type: t, Reason: fmt.Sprintf("field %s has no wire tag", sf.Name)}
+		}
+		num, err := strconv.Atoi(tag)
+		if err != nil || num < 1 || num > maxField {
+			return codec{}, &TypeError{Type: t, Reason: fmt.Sprintf("field %s: bad wire tag %q", sf.Name, tag)}
+		}
+		if other, ok := seen[num]; ok {
+			return codec{}, &TypeError{Type: t, Reason: fmt.Sprintf("fields %s and %s both have number %d", other, sf.Name, num)}
+		}
+		seen[num] = sf.Name
+		c, err := b.build(sf.Type)
+		if err != nil {
+			return codec{}, err
+		}
+		fields = append(fields, &field{
+			name:  sf.Name,
+			num:   num,
+			index: i,
+			key:   appendKey(nil, num, c.wt),
+			c:     c,
+		})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].num < fields[j].num })
+
+	// Small field numbers, the usual case, are looked up by index.
+	var byNum []*field
+	var byNumMap map[int]*field
+	if len(fields) > 0 && fields[len(fields)-1].num <= 1024 {
+		byNum = make([]*field, fields[len(fields)-1].num+1)
+		for _, f := range fields {
+			byNum[f.num] = f
+		}
+	} else {
+		byNumMap = make(map[int]*field, len(fields))
+		for _, f := range fields {
+			byNumMap[f.num] = f
+		}
+	}
+	lookup := func(num uint64) *field {
+		if byNumMap != nil {
+			return byNumMap[int(num)]
+		}
+		if num < uint64(len(byNum)) {
+			return byNum[num]
+		}
+		return nil
+	}
+
+	return codec{
+		wt: wireBytes,
+		encode: func(b []byte, v reflect.Value) ([]byte, error) {
+			var err error
+			for _, f := range fields {
+				fv := v.Field(f.index)
+				if fv.IsZero() && !negativeZero(fv) {
+					continue
+				}
+				b = append(b, f.key...)
+				if b, err = f.c.encodeElem(b, fv); err != nil {
+					return b, fmt.Errorf("%s.%s: %w", t.Name(), f.name, err)
+				}
+			}
+			return b, nil
+		},
+		decode: func(d *decoder, v reflect.Value) error {
+			for len(d.buf) > 0 {
+				key, err := d.uvarint()
+				if err != nil {
+					return err
+				}
+				f, wt := lookup(key>>3), wireType(key&7)
+				if f == nil {
+					if err := d.skip(wt); err != nil {
+						return err
+					}
+					continue
+				}
+				if wt != f.c.wt {
+					return d.fail("field %d of %v has wire type %d, want %d", f.num, t, wt, f.c.wt)
+				}
+				if err := f.c.decodeElem(d, v.Field(f.index)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,272 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type inner struct {
+	A string `json:"a" wire:"1"`
+	B []byte `json:"b" wire:"2"`
+}
+
+type everything struct {
+	Bool    bool              `json:"bool" wire:"1"`
+	Int     int               `json:"int" wire:"2"`
+	Int8    int8              `json:"int8" wire:"3"`
+	Uint16  uint16            `json:"uint16" wire:"4"`
+	F64     float64           `json:"f64" wire:"5"`
+	F32     float32           `json:"f32" wire:"6"`
+	Str     string            `json:"str" wire:"7"`
+	Inner   inner             `json:"inner" wire:"8"`
+	Ptr     *inner            `json:"ptr" wire:"9"`
+	IntPtr  *int              `json:"intPtr" wire:"10"`
+	Ints    []int             `json:"ints" wire:"11"`
+	Strs    []string          `json:"strs" wire:"12"`
+	Inners  []inner           `json:"inners" wire:"13"`
+	Map     map[string]int    `json:"map" wire:"14"`
+	Nested  map[int][]string  `json:"nested" wire:"15"`
+	Array   [3]int8           `json:"array" wire:"16"`
+	Time    time.Time         `json:"time" wire:"17"`
+	Ptrs    map[string]*inner `json:"ptrs" wire:"18"`
+	Far     string            `json:"far" wire:"5000"`
+	Secret  string            `json:"-"`
+	Skipped string            `json:"skipped" wire:"-"`
+	private int
+}
+
+func TestRoundTrip(t *testing.T) {
+	zero := 0
+	in := everything{
+		Bool: true, Int: -300, Int8: -128, Uint16: 65535,
+		F64: math.Copysign(0, -1), F32: 1.5,
+		Str:    strings.Repeat("long string ", 30), // a length of two bytes
+		Inner:  inner{A: "x", B: []byte{0, 1, 2}},
+		Ptr:    &inner{},
+		IntPtr: &zero,
+		Ints:   []int{},
+		Strs:   []string{"", "a"},
+		Inners: []inner{{A: "1"}, {}},
+		Map:    map[string]int{"a": 1, "": 0},
+		Nested: map[int][]string{-1: {"x"}, 2: nil},
+		Array:  [3]int8{1, 0, -1},
+		Time:   time.Date(2024, 2, 29, 12, 0, 0, 5, time.FixedZone("", -3*3600)),
+		Ptrs:   map[string]*inner{"p": {A: "q"}},
+		Far:    "far",
+		Secret: "not encoded",
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out everything
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Secret = ""
+	in.Nested[2] = []string{} // nil inside a container comes back empty
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", out, in)
+	}
+	if !math.Signbit(out.F64) {
+		t.Error("-0 lost its sign")
+	}
+
+	// The JSON forms agree, which is what compatibility with JSON means.
+	jin, _ := json.Marshal(in)
+	jout, _ := json.Marshal(out)
+	if !bytes.Equal(jin, jout) {
+		t.Errorf("JSON forms differ:\n%s\n%s", jin, jout)
+	}
+}
+
+func TestZeroValuesAreOmitted(t *testing.T) {
+	data, err := Marshal(everything{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("zero struct encodes to %x", data)
+	}
+	// nil and empty slices differ in JSON, so they differ here.
+	data, _ = Marshal(everything{Ints: []int{}})
+	if want := []byte{11<<3 | 2, 0}; !bytes.Equal(data, want) {
+		t.Errorf("empty slice: got %x; want %x", data, want)
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	for _, v := range []any{
+		-5,
+		"text",
+		[]inner{{A: "a"}, {A: "b", B: []byte("c")}},
+		map[string]bool{"yes": true},
+		&inner{A: "p"},
+	} {
+		data, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		out := reflect.New(reflect.TypeOf(v))
+		if err := Unmarshal(data, out.Interface()); err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		if !reflect.DeepEqual(out.Elem().Interface(), v) {
+			t.Errorf("got %v; want %v", out.Elem().Interface(), v)
+		}
+	}
+}
+
+// Version 1 and 2 of a record: v2 dropped Nick and added Tags and Score.
+type recordV1 struct {
+	ID   int    `json:"id" wire:"1"`
+	Name string `json:"name" wire:"2"`
+	Nick string `json:"nick" wire:"3"`
+}
+
+type recordV2 struct {
+	ID    int      `json:"id" wire:"1"`
+	Name  string   `json:"name" wire:"2"`
+	Tags  []string `json:"tags" wire:"4"`
+	Score float64  `json:"score" wire:"5"`
+	Sub   *inner   `json:"sub" wire:"6"`
+}
+
+func TestCompatibility(t *testing.T) {
+	old, _ := Marshal(recordV1{ID: 1, Name: "Ana", Nick: "A"})
+	var r2 recordV2
+	if err := Unmarshal(old, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if r2.ID != 1 || r2.Name != "Ana" || r2.Tags != nil {
+		t.Errorf("new reader, old data: %+v", r2)
+	}
+
+	fresh, _ := Marshal(recordV2{ID: 2, Name: "Bo", Tags: []string{"x"}, Score: 1.5, Sub: &inner{A: "s"}})
+	var r1 recordV1
+	if err := Unmarshal(fresh, &r1); err != nil {
+		t.Fatal(err)
+	}
+	if r1 != (recordV1{ID: 2, Name: "Bo"}) {
+		t.Errorf("old reader, new data: %+v", r1)
+	}
+
+	// Fields not in the data are left alone, as json.Unmarshal does.
+	r1 = recordV1{Nick: "kept"}
+	Unmarshal(fresh, &r1)
+	if r1.Nick != "kept" {
+		t.Errorf("Nick = %q; want it untouched", r1.Nick)
+	}
+}
+
+type node struct {
+	Value int   `json:"value" wire:"1"`
+	Next  *node `json:"next" wire:"2"`
+}
+
+func TestRecursiveType(t *testing.T) {
+	list := &node{1, &node{2, &node{3, nil}}}
+	data, err := Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out node
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&out, list) {
+		t.Errorf("got %+v", out)
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	for _, v := range []any{
+		struct {
+			A int `json:"a"`
+		}{},
+		struct {
+			A int `wire:"1"`
+			B int `wire:"1"`
+		}{},
+		struct {
+			A int `wire:"0"`
+		}{},
+		struct {
+			A int `json:"-" wire:"1"`
+		}{},
+		struct {
+			A any `wire:"1"`
+		}{},
+		struct {
+			C chan int `wire:"1"`
+		}{},
+	} {
+		var typeErr *TypeError
+		if _, err := Marshal(v); !errors.As(err, &typeErr) {
+			t.Errorf("%T: err = %v; want a TypeError", v, err)
+		}
+	}
+}
+
+func TestUnsupportedValues(t *testing.T) {
+	for _, v := range []any{
+		everything{F64: math.NaN()},
+		everything{F32: float32(math.Inf(1))},
+		everything{Ptrs: map[string]*inner{"nil": nil}},
+		[]*inner{nil},
+		(*inner)(nil),
+	} {
+		if _, err := Marshal(v); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%#v: err = %v; want ErrUnsupported", v, err)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	good, _ := Marshal(everything{Str: "hello", Inner: inner{A: "x"}, Int8: 5})
+	for i := range good {
+		var out everything
+		// Every proper prefix is cut off in the middle of something.
+		if err := Unmarshal(good[:i], &out); i > 0 && err == nil && !prefixIsWhole(good, i) {
+			t.Errorf("prefix of %d bytes decoded without an error", i)
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"bad varint", []byte{0x80}},
+		{"length past end", []byte{7<<3 | 2, 10, 'a'}},
+		{"wrong wire type", []byte{7<<3 | 0, 1}},
+		{"overflow", []byte{3 << 3, 0x80, 0x02}}, // int8 256
+		{"bad bool", []byte{1 << 3, 2}},
+		{"unknown wire type", []byte{15<<3 | 3}},
+		{"array too long", []byte{16<<3 | 2, 4, 1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		var out everything
+		if err := Unmarshal(tt.data, &out); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: err = %v; want ErrMalformed", tt.name, err)
+		}
+	}
+	var n int
+	if err := Unmarshal([]byte{2, 0}, &n); !errors.Is(err, ErrMalformed) {
+		t.Errorf("trailing data: err = %v", err)
+	}
+}
+
+// prefixIsWhole reports whether good[:i] ends between two fields.
+func prefixIsWhole(good []byte, i int) bool {
+	d := decoder{buf: good}
+	for len(d.buf) > 0 && d.off < i {
+		key, _ := d.uvarint()
+		d.skip(wireType(key & 7))
+	}
+	return d.off == i
+}